@@ -4,19 +4,27 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/marcodenic/agentry/internal/audit"
 	"github.com/marcodenic/agentry/internal/config"
 	"github.com/marcodenic/agentry/internal/core"
 	"github.com/marcodenic/agentry/internal/cost"
 	"github.com/marcodenic/agentry/internal/debug"
+	"github.com/marcodenic/agentry/internal/httpx"
 	"github.com/marcodenic/agentry/internal/memory"
+	"github.com/marcodenic/agentry/internal/memstore"
 	"github.com/marcodenic/agentry/internal/model"
+	"github.com/marcodenic/agentry/internal/policy"
+	"github.com/marcodenic/agentry/internal/sandbox"
 	"github.com/marcodenic/agentry/internal/team"
 	"github.com/marcodenic/agentry/internal/tool"
 	"github.com/marcodenic/agentry/internal/trace"
@@ -61,13 +69,72 @@ func loadPrimaryAgentRole() (*team.RoleConfig, error) {
 	return nil, fmt.Errorf("agent_0.yaml not found in any search path")
 }
 
+var (
+	auditOnce   sync.Once
+	auditWriter *audit.Log
+)
+
+// auditLog returns the log named by AGENTRY_AUDIT_LOG, opened once so every
+// registry wrapped in this process appends to the same rotating file. It is
+// nil when auditing is off or the file cannot be opened.
+func auditLog() *audit.Log {
+	auditOnce.Do(func() {
+		if path := os.Getenv("AGENTRY_AUDIT_LOG"); path != "" {
+			if lw, err := audit.Open(path, 1<<20); err == nil {
+				auditWriter = lw
+			}
+		}
+	})
+	return auditWriter
+}
+
+// closeTools stops everything tools started in the background: processes,
+// shell sessions, MCP servers and language servers. Commands run it before
+// they return, including on errors and signals.
+func closeTools() {
+	tool.CloseMCPServers()
+	tool.CloseShellSessions()
+	tool.StopProcesses()
+	tool.CloseLanguageServers()
+}
+
 // buildAgent constructs an Agent from configuration.
 func buildAgent(cfg *config.File) (*core.Agent, error) {
-	tool.SetPermissions(cfg.Permissions.Tools)
-	// Sandboxing completely removed
+	// Refuse to run on a shared store that cannot be opened, such as a bolt
+	// file locked by another process, instead of keeping state apart from it.
+	if err := memstore.Init(); err != nil {
+		return nil, err
+	}
+	sb, err := sandbox.FromConfig(cfg.Sandbox, "")
+	if err != nil {
+		return nil, err
+	}
+	netCfg, err := httpx.FromConfig(cfg.Network)
+	if err != nil {
+		return nil, err
+	}
+	hc, err := httpx.New(netCfg)
+	if err != nil {
+		return nil, err
+	}
+	httpx.SetDefault(hc)
 	reg := tool.Registry{}
 	for _, m := range cfg.Tools {
-		tl, err := tool.FromManifest(m)
+		// OpenAPI manifests register one tool per operation.
+		if m.OpenAPI != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			ops, err := tool.FromOpenAPI(ctx, m, cfg.Credentials)
+			cancel()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			for name, tl := range ops {
+				reg[name] = tl
+			}
+			continue
+		}
+		tl, err := tool.FromManifestWithCredentials(m, cfg.Credentials)
 		if err != nil {
 			if errors.Is(err, tool.ErrUnknownBuiltin) {
 				debug.Printf("skipping builtin %s: not available", m.Name)
@@ -78,13 +145,30 @@ func buildAgent(cfg *config.File) (*core.Agent, error) {
 		reg[m.Name] = tl
 	}
 
-	// Agent delegation tool is registered by team.RegisterAgentTool at runtime.
-	var logWriter *audit.Log
-	if path := os.Getenv("AGENTRY_AUDIT_LOG"); path != "" {
-		if lw, err := audit.Open(path, 1<<20); err == nil {
-			logWriter = lw
-			reg = tool.WrapWithAudit(reg, lw)
+	// Tools discovered from MCP servers are namespaced as <server>__<tool>.
+	if len(cfg.MCPServers) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := tool.RegisterMCPServers(ctx, reg, cfg.MCPServers); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 		}
+		cancel()
+	}
+
+	// Agent delegation tool is registered by team.RegisterAgentTool at runtime.
+	logWriter := auditLog()
+	engine, err := policy.New(cfg.Permissions, "")
+	if err != nil {
+		return nil, err
+	}
+	// Policy decisions go to the audit log too; avoid a typed nil writer.
+	var decisions io.Writer
+	if logWriter != nil {
+		decisions = logWriter
+	}
+	perms := tool.NewPermissions(cfg.Permissions.Tools, engine, decisions).Sandboxed(sb)
+	reg = perms.Apply(reg)
+	if logWriter != nil {
+		reg = tool.WrapWithAudit(reg, logWriter)
 	}
 
 	// Use the first configured model, or mock if none configured
@@ -137,17 +221,28 @@ func buildAgent(cfg *config.File) (*core.Agent, error) {
 		debug.Printf("Using mock model")
 	}
 
+	// Every backend is paired with a BM25 keyword index so exact identifiers
+	// are still found when embeddings miss them. For persistent backends the
+	// indexed text is kept in the shared store so the index outlives the
+	// process.
 	var vec memory.VectorStore
 	switch cfg.Vector.Type {
 	case "qdrant":
-		vec = memory.NewQdrant(cfg.Vector.URL, cfg.Vector.Collection)
+		ns := "keyword-index:qdrant:" + cfg.Vector.URL + "/" + cfg.Vector.Collection
+		if vec, err = memory.NewStoredHybrid(memory.NewQdrant(cfg.Vector.URL, cfg.Vector.Collection), memstore.Get(), ns); err != nil {
+			return nil, fmt.Errorf("keyword index: %w", err)
+		}
 	case "faiss":
-		vec = memory.NewFaiss(cfg.Vector.URL)
+		ns := "keyword-index:faiss:" + cfg.Vector.URL
+		if vec, err = memory.NewStoredHybrid(memory.NewFaiss(cfg.Vector.URL), memstore.Get(), ns); err != nil {
+			return nil, fmt.Errorf("keyword index: %w", err)
+		}
 	default:
-		vec = memory.NewInMemoryVector()
+		vec = memory.NewDefaultVector()
 	}
 
 	ag := core.New(client, modelName, reg, memory.NewInMemory(), vec, nil)
+	ag.Permissions = perms
 
 	// Configure error handling for resilience
 	ag.ErrorHandling.TreatErrorsAsResults = true
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/marcodenic/agentry/internal/overlay"
+	"github.com/marcodenic/agentry/internal/patch"
+)
+
+const applyUsage = `Usage: agentry apply [--check] [--fuzz N] [--ignore-whitespace] FILE|-
+
+Applies a patch written by a --dry-run session (or any unified diff) to the
+working tree, all files or none. With --check the patch is only tested;
+nothing is written.`
+
+// runApplyCmd implements `agentry apply`.
+func runApplyCmd(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, applyUsage) }
+	check := fs.Bool("check", false, "test whether the patch applies without writing")
+	opts := patch.DefaultOptions()
+	fs.IntVar(&opts.Fuzz, "fuzz", opts.Fuzz, "context lines that may be ignored at each end of a hunk")
+	fs.BoolVar(&opts.IgnoreWhitespace, "ignore-whitespace", false, "match lines ignoring whitespace differences")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var data []byte
+	var err error
+	if name := fs.Arg(0); name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		applyFail(err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		fmt.Println("apply: patch is empty; nothing to do")
+		return
+	}
+
+	fsys := patch.OS()
+	if *check {
+		fsys = overlay.New()
+	}
+	res, err := patch.ApplyWith(string(data), fsys, opts)
+	for _, c := range res.Files {
+		fmt.Printf("%s +%d -%d\n", c.Path, c.Additions, c.Deletions)
+		for _, n := range c.Notes {
+			fmt.Printf("  %s\n", n)
+		}
+	}
+	if err != nil {
+		applyFail(err)
+	}
+	if *check {
+		fmt.Printf("patch applies cleanly (%d files)\n", len(res.Files))
+	}
+}
+
+func applyFail(err error) {
+	fmt.Fprintf(os.Stderr, "apply: %v\n", err)
+	os.Exit(1)
+}
+
+// emitDryRun reports the changes a dry run proposed: the diff goes to path,
+// or to stdout when path is empty.
+func emitDryRun(o *overlay.FS, path string) {
+	if o == nil {
+		return
+	}
+	diff, err := o.Diff(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dry run: failed to render diff: %v\n", err)
+		return
+	}
+	if diff == "" {
+		fmt.Fprintln(os.Stderr, "📝 Dry run: no file changes proposed")
+		return
+	}
+	if path == "" {
+		fmt.Print(diff)
+		fmt.Fprintln(os.Stderr, "📝 Dry run: nothing was written; save the diff above and run `agentry apply FILE` to apply it")
+		return
+	}
+	if err := os.WriteFile(path, []byte(diff), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "dry run: failed to write %s: %v\n", path, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "📝 Dry run: proposed changes written to %s; apply with `agentry apply %s`\n", path, path)
+}
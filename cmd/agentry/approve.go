@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/marcodenic/agentry/internal/config"
+	"github.com/marcodenic/agentry/internal/policy"
+)
+
+// stdinIsTerminal reports whether someone can answer approval prompts.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// warnAskDenies says on stderr that calls the permissions policy marks
+// "ask" will be refused, since no one can answer them where agentry runs.
+func warnAskDenies(p config.Permissions, where string) {
+	asks := strings.EqualFold(strings.TrimSpace(p.Default), string(policy.Ask))
+	for _, r := range p.Rules {
+		asks = asks || strings.EqualFold(strings.TrimSpace(r.Action), string(policy.Ask))
+	}
+	if asks {
+		fmt.Fprintf(os.Stderr, "Warning: %s has no one to approve calls, so permission rules that ask will deny them\n", where)
+	}
+}
+
+// terminalApprover asks on out and reads y/N from in before a call the
+// policy marks "ask" runs. Prompts are serialized since agents run in
+// parallel.
+func terminalApprover(in io.Reader, out io.Writer) policy.Approver {
+	var mu sync.Mutex
+	r := bufio.NewReader(in)
+	return func(ctx context.Context, c policy.Call, d policy.Decision) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		args, _ := json.Marshal(c.Args)
+		summary := string(args)
+		if len(summary) > 300 {
+			summary = summary[:300] + "..."
+		}
+		fmt.Fprintf(out, "\n⚠️  Policy %s asks before running %s %s", d.Rule, c.Tool, summary)
+		if d.Reason != "" {
+			fmt.Fprintf(out, "\n   %s", d.Reason)
+		}
+		fmt.Fprint(out, "\n   Allow? [y/N] ")
+		line, err := r.ReadString('\n')
+		if err != nil && line == "" {
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true
+		}
+		return false
+	}
+}
@@ -29,6 +29,8 @@ type commonOpts struct {
 	denyTools      string
 	disableContext bool
 	auditLog       string
+	dryRun         bool
+	patchOut       string
 
 	// New flags (prefer flags over env vars)
 	maxIter     int // 0 = unlimited
@@ -42,7 +44,7 @@ func parseCommon(name string, args []string) (*commonOpts, []string) {
 	fs.StringVar(&opts.theme, "theme", "", "theme name override")
 	fs.StringVar(&opts.keybindsPath, "keybinds", "", "path to keybinds json")
 	fs.StringVar(&opts.credsPath, "creds", "", "path to credentials json")
-	fs.StringVar(&opts.mcpFlag, "mcp", "", "comma-separated MCP servers")
+	fs.StringVar(&opts.mcpFlag, "mcp", "", "comma-separated MCP servers (NAME=URL or NAME=COMMAND)")
 	fs.StringVar(&opts.saveID, "save-id", "", "save conversation state to this ID")
 	fs.StringVar(&opts.resumeID, "resume-id", "", "load conversation state from this ID")
 	fs.StringVar(&opts.ckptID, "checkpoint-id", "", "checkpoint session id")
@@ -53,6 +55,8 @@ func parseCommon(name string, args []string) (*commonOpts, []string) {
 	fs.StringVar(&opts.denyTools, "deny-tools", "", "comma-separated list of tools to exclude")
 	fs.BoolVar(&opts.disableContext, "disable-context", false, "disable context pipeline")
 	fs.StringVar(&opts.auditLog, "audit-log", "", "path to audit log file")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "keep file changes in memory and emit them as a patch")
+	fs.StringVar(&opts.patchOut, "patch-out", "", "write the dry-run patch to this file (default: stdout)")
 	// Debug/diagnostic flags
 	fs.IntVar(&opts.maxIter, "max_iter", 0, "limit agent iterations (0=unlimited)")
 	fs.IntVar(&opts.maxIter, "max-iter", 0, "limit agent iterations (0=unlimited)")
@@ -176,6 +180,14 @@ func applyOverrides(cfg *config.File, o *commonOpts) {
 		os.Setenv("AGENTRY_AUDIT_LOG", o.auditLog)
 	}
 
+	// Shared store backend is read by memstore.Init on first use
+	if cfg.Store != "" {
+		os.Setenv("AGENTRY_STORE", cfg.Store)
+	}
+	if cfg.StorePath != "" {
+		os.Setenv("AGENTRY_STORE_PATH", cfg.StorePath)
+	}
+
 	if o.theme != "" {
 		if cfg.Themes == nil {
 			cfg.Themes = map[string]string{}
@@ -200,9 +212,18 @@ func applyOverrides(cfg *config.File, o *commonOpts) {
 		if cfg.MCPServers == nil {
 			cfg.MCPServers = map[string]string{}
 		}
+		// Entries are NAME=URL|COMMAND; unnamed entries become srv1, srv2, ...
 		parts := strings.Split(o.mcpFlag, ",")
 		for i, p := range parts {
-			cfg.MCPServers[fmt.Sprintf("srv%d", i+1)] = strings.TrimSpace(p)
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			name, spec, ok := strings.Cut(p, "=")
+			if !ok || strings.ContainsAny(name, " /:") {
+				name, spec = fmt.Sprintf("srv%d", i+1), p
+			}
+			cfg.MCPServers[name] = strings.TrimSpace(spec)
 		}
 	}
 
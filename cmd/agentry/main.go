@@ -8,9 +8,11 @@ import (
 	agentry "github.com/marcodenic/agentry/internal"
 	"github.com/marcodenic/agentry/internal/cost"
 	"github.com/marcodenic/agentry/internal/env"
+	"github.com/marcodenic/agentry/internal/sandbox"
 )
 
 func main() {
+	sandbox.Init()
 	env.Load()
 
 	// If no arguments, start TUI (default)
@@ -43,9 +45,9 @@ func main() {
 	var command string
 	var commandArgs []string
 
-	// Recognized commands: tui, refresh-models, version. Deprecated aliases: chat/ask/prompt → direct prompt.
+	// Recognized commands: tui, refresh-models, store, mcp, undo, apply, version. Deprecated aliases: chat/ask/prompt → direct prompt.
 	switch remainingArgs[0] {
-	case "tui", "refresh-models", "version":
+	case "tui", "refresh-models", "store", "mcp", "undo", "apply", "version":
 		command = remainingArgs[0]
 		commandArgs = remainingArgs[1:]
 	case "chat", "ask", "prompt":
@@ -64,6 +66,14 @@ func main() {
 		runTui(commandArgs)
 	case "refresh-models":
 		runRefreshModelsCmd(commandArgs)
+	case "store":
+		runStoreCmd(commandArgs, opts)
+	case "mcp":
+		runMCPCmd(commandArgs, opts)
+	case "undo":
+		runUndoCmd(commandArgs)
+	case "apply":
+		runApplyCmd(commandArgs)
 	case "version":
 		fmt.Printf("agentry %s\n", agentry.Version)
 	case "prompt-direct":
@@ -85,6 +95,12 @@ USAGE:
 COMMANDS:
     (no command)         Start TUI interface (default)
   refresh-models       Update model pricing data
+  store ls|get|set|rm  Inspect and edit the shared store (todos, coordination, state)
+  store export|import  Back up or restore namespaces as JSONL
+  store migrate        Copy the shared store between backends (--from file --to bolt)
+  mcp serve            Serve tools and team roles over MCP (stdio, or --http ADDR)
+  undo                 Undo agent file changes (--last N, --agent NAME, --session ID, --list)
+  apply FILE|-         Apply a dry-run patch to the working tree (--check to test only)
   help                 Show this help message
   
   Direct prompt execution:
@@ -99,7 +115,7 @@ FLAGS:
   --http_timeout SEC     HTTP timeout in seconds (default 300)
   --keybinds PATH        Path to custom keybindings JSON file
   --creds PATH           Path to credentials JSON file
-  --mcp SERVERS          Comma-separated MCP server list
+  --mcp SERVERS          Comma-separated MCP servers (NAME=URL or NAME=COMMAND)
   --save-id ID           Save conversation state to this ID
   --resume-id ID         Load conversation state from this ID  
   --checkpoint-id ID     Checkpoint session ID
@@ -109,6 +125,8 @@ FLAGS:
   --deny-tools TOOLS     Remove specific tools from available set (comma-separated)
   --disable-context      Disable context pipeline
   --audit-log PATH       Path to audit log file
+  --dry-run              Keep file changes in memory and emit them as a patch
+  --patch-out PATH       Write the dry-run patch to PATH (default: stdout)
 
 EXAMPLES:
   agentry                                  # Start TUI (default)
@@ -117,6 +135,13 @@ EXAMPLES:
   agentry --debug analyze code             # Debug mode with direct prompt
   agentry --resume-id my-session           # Resume TUI session
   agentry refresh-models                   # Update model data
+  agentry store ls --backend file         # List shared store namespaces
+  agentry store export todo:project:1a2b > todos.jsonl  # Back up a namespace
+  agentry store migrate --from file --to bolt  # Move shared store to bbolt
+  agentry mcp serve --http localhost:8765     # Expose tools and agents to MCP clients
+  agentry undo --agent coder               # Revert everything the coder wrote this session
+  agentry --dry-run --patch-out fix.patch fix the flaky test  # Propose changes only
+  agentry apply fix.patch                  # Apply a reviewed dry-run patch
   
   Tool filtering examples:
   agentry --allow-tools echo,ping "test"           # Only echo and ping tools
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	agentry "github.com/marcodenic/agentry/internal"
+	"github.com/marcodenic/agentry/internal/config"
+	"github.com/marcodenic/agentry/internal/mcp"
+	"github.com/marcodenic/agentry/internal/team"
+	"github.com/marcodenic/agentry/internal/tool"
+	"github.com/marcodenic/agentry/internal/trace"
+)
+
+const mcpUsage = `Usage: agentry mcp serve [--http ADDR] [--token TOKEN] [--no-roles]
+
+Serves the configured tools and the team's roles to MCP clients, over
+stdin/stdout by default or streamable HTTP at ADDR (e.g. localhost:8765).
+An ADDR without a host listens on loopback only. Listening on any other
+address requires a bearer token (--token or AGENTRY_MCP_TOKEN); requests
+from browser pages on non-local origins are always refused.
+
+Each role is exposed as agent_<role> {input}; calls delegate through the
+team and stream the agent's steps as progress notifications. Tool
+permissions and the audit log (--audit-log) apply to every call; calls a
+rule would ask about are denied, since no one can answer.`
+
+// runMCPCmd dispatches `agentry mcp <subcommand>`.
+func runMCPCmd(args []string, opts *commonOpts) {
+	if len(args) == 0 {
+		fmt.Println(mcpUsage)
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "serve":
+		if err := runMCPServe(args[1:], opts); err != nil {
+			fmt.Fprintf(os.Stderr, "mcp serve: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("Error: Unknown mcp command '%s'\n", args[0])
+		fmt.Println(mcpUsage)
+		os.Exit(1)
+	}
+}
+
+// runMCPServe serves until stdin closes or a signal arrives. It returns
+// instead of exiting so the tools it started are always cleaned up.
+func runMCPServe(args []string, opts *commonOpts) error {
+	fs := flag.NewFlagSet("mcp serve", flag.ExitOnError)
+	addr := fs.String("http", "", "serve streamable HTTP on this address instead of stdio")
+	token := fs.String("token", os.Getenv("AGENTRY_MCP_TOKEN"), "bearer token HTTP clients must send")
+	noRoles := fs.Bool("no-roles", false, "do not expose team roles as agent_<role> tools")
+	_ = fs.Parse(args)
+
+	listen := *addr
+	if listen != "" {
+		var err error
+		if listen, err = mcpListenAddr(listen, *token); err != nil {
+			return err
+		}
+	}
+
+	// On stdio, stdout carries the protocol; everything else that would be
+	// printed there (progress, warnings) goes to stderr instead.
+	protocolOut := os.Stdout
+	if *addr == "" {
+		os.Stdout = os.Stderr
+	}
+
+	cfg, err := config.Load(opts.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	applyOverrides(cfg, opts)
+	defer closeTools()
+	ag, err := buildAgent(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to build agent: %w", err)
+	}
+
+	configDir := ""
+	if opts.configPath != "" {
+		configDir = filepath.Dir(opts.configPath)
+	}
+	tm, err := team.NewTeamWithRoles(ag, 0, "", cfg.Include, configDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to create team context: %v\n", err)
+	} else {
+		tm.RegisterAgentTool(ag.Tools)
+		// Delegation tools are added after buildAgent wrapped the registry.
+		if lw := auditLog(); lw != nil {
+			for name, t := range tool.WrapWithAudit(tool.Registry{
+				"agent":           ag.Tools["agent"],
+				"parallel_agents": ag.Tools["parallel_agents"],
+			}, lw) {
+				ag.Tools[name] = t
+			}
+		}
+	}
+
+	// MCP has no way to ask the client's user, so "ask" denies.
+	warnAskDenies(cfg.Permissions, "mcp serve")
+	srv := newMCPServer(ag.Tools, tm, !*noRoles)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *addr == "" {
+		if err := srv.ServeStdio(ctx, os.Stdin, protocolOut); err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
+	}
+	srv.Token = *token
+	hs := &http.Server{Addr: listen, Handler: srv}
+	go func() {
+		<-ctx.Done()
+		_ = hs.Close()
+	}()
+	fmt.Fprintf(os.Stderr, "Serving %d MCP tools on http://%s\n", len(srv.Tools()), listen)
+	if err := hs.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// mcpListenAddr binds an address without a host to loopback, and refuses
+// any other non-loopback address unless a token is set: the server runs
+// shell and file tools for whoever can reach it.
+func mcpListenAddr(addr, token string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid --http address %q: %w", addr, err)
+	}
+	if host == "" {
+		return net.JoinHostPort("127.0.0.1", port), nil
+	}
+	if !mcp.LoopbackHost(host) && token == "" {
+		return "", fmt.Errorf("listening on %s exposes every tool to the network; set --token or AGENTRY_MCP_TOKEN", addr)
+	}
+	return addr, nil
+}
+
+// newMCPServer exposes every tool in reg except the generic agent tool,
+// plus (when roles is set and tm is non-nil) one agent_<role> tool per team
+// role. Calls go through the registry's tools, so permission checks and
+// audit wrappers apply, and are tagged with the "mcp" audit source.
+func newMCPServer(reg tool.Registry, tm *team.Team, roles bool) *mcp.Server {
+	srv := mcp.NewServer("agentry", agentry.Version)
+	srv.Instructions = "Agentry tools and agents. agent_<role> tools delegate a task to a specialised agent and return its answer."
+
+	names := make([]string, 0, len(reg))
+	for name := range reg {
+		if name != "agent" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := reg[name]
+		srv.AddTool(mcp.Tool{Name: name, Description: t.Description(), InputSchema: t.JSONSchema()},
+			mcpToolHandler(t, tm, nil))
+	}
+
+	delegate, ok := reg["agent"]
+	if !roles || tm == nil || !ok {
+		return srv
+	}
+	roleNames := tm.AvailableRoleNames()
+	sort.Strings(roleNames)
+	for _, role := range roleNames {
+		if role == "agent_0" {
+			continue
+		}
+		desc := "Delegate a task to the " + role + " agent and return its answer"
+		if r := tm.GetRole(role); r != nil && len(r.Capabilities) > 0 {
+			desc += " (capabilities: " + strings.Join(r.Capabilities, ", ") + ")"
+		}
+		schema := map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"input": map[string]any{"type": "string", "description": "Task for the " + role + " agent"},
+			},
+			"required": []string{"input"},
+		}
+		fixed := map[string]any{"agent": role}
+		srv.AddTool(mcp.Tool{Name: "agent_" + role, Description: desc, InputSchema: schema},
+			mcpToolHandler(delegate, tm, fixed))
+	}
+	return srv
+}
+
+// mcpToolHandler runs t for an MCP call. fixed arguments override the
+// caller's. Agents started by the call report their steps as progress.
+func mcpToolHandler(t tool.Tool, tm *team.Team, fixed map[string]any) mcp.ToolHandler {
+	return func(ctx context.Context, args map[string]any, progress func(string)) (*mcp.CallToolResult, error) {
+		for k, v := range fixed {
+			args[k] = v
+		}
+		ctx = tool.WithAuditSource(ctx, "mcp")
+		if tm != nil {
+			ctx = team.WithContext(ctx, tm)
+		}
+		ctx = trace.WithWriter(ctx, progressTracer{team: tm, progress: progress})
+		res, err := tool.Run(ctx, t, args)
+		if err != nil {
+			return nil, err
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: res.Text}}, StructuredContent: res.Data}, nil
+	}
+}
+
+// progressTracer turns the trace events of delegated agents into MCP
+// progress messages.
+type progressTracer struct {
+	team     *team.Team
+	progress func(string)
+}
+
+func (p progressTracer) Write(_ context.Context, e trace.Event) {
+	var what string
+	switch e.Type {
+	case trace.EventModelStart:
+		what = "thinking"
+	case trace.EventToolStart:
+		m, _ := e.Data.(map[string]any)
+		what = fmt.Sprintf("running %v", m["name"])
+	case trace.EventFinal:
+		what = "done"
+	default:
+		return
+	}
+	p.progress(p.agentName(e.AgentID) + ": " + what)
+}
+
+func (p progressTracer) agentName(id string) string {
+	if p.team != nil {
+		for _, a := range p.team.ListAgents() {
+			if a.Agent != nil && a.Agent.ID.String() == id {
+				return a.Name
+			}
+		}
+	}
+	return "agent"
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
 
 	"github.com/marcodenic/agentry/internal/config"
 	"github.com/marcodenic/agentry/internal/debug"
+	"github.com/marcodenic/agentry/internal/overlay"
+	"github.com/marcodenic/agentry/internal/policy"
 	"github.com/marcodenic/agentry/internal/team"
 	"github.com/marcodenic/agentry/internal/trace"
 )
@@ -27,18 +31,30 @@ func runPrompt(prompt string, args []string) {
 }
 
 func runPromptWithOpts(prompt string, opts *commonOpts) {
+	if err := promptWithOpts(prompt, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ ERR: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// promptWithOpts runs prompt with Agent 0. It returns instead of exiting so
+// the tools it started are always cleaned up, on SIGINT and SIGTERM too.
+func promptWithOpts(prompt string, opts *commonOpts) error {
 	cfg, err := config.Load(opts.configPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to load config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to load config: %w", err)
 	}
 	applyOverrides(cfg, opts)
+	defer closeTools()
 	ag, err := buildAgent(cfg)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to build agent: %w", err)
 	}
 	// Apply iteration cap from flags (0 = unlimited)
 	ag.MaxIter = opts.maxIter
+	if opts.dryRun {
+		ag.Overlay = overlay.New()
+	}
 
 	// Debug: tool count before/after role configuration
 	debug.Printf("Before agent_0 config: agent has %d tools", len(ag.Tools))
@@ -59,6 +75,9 @@ func runPromptWithOpts(prompt string, opts *commonOpts) {
 		agent0RolePath := "templates/roles/agent_0.yaml"
 		if role, err := team.LoadRoleFromFile(agent0RolePath); err == nil {
 			ag.Prompt = role.Prompt
+			if role.DryRun && ag.Overlay == nil {
+				ag.Overlay = overlay.New()
+			}
 			debug.Printf("Agent 0 loaded role configuration from %s (prompt length: %d chars)", agent0RolePath, len(role.Prompt))
 		} else {
 			debug.Printf("Failed to load Agent 0 role from %s: %v", agent0RolePath, err)
@@ -101,11 +120,19 @@ func runPromptWithOpts(prompt string, opts *commonOpts) {
 	ag.Tracer = col
 
 	// Create context with team for coordination tools
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	if teamCtx != nil {
 		ctx = team.WithContext(ctx, teamCtx)
 		debug.Printf("Team context attached to execution context")
 	}
+	// Calls the permissions policy marks "ask" are confirmed on the terminal,
+	// and denied when stdin is not one.
+	if stdinIsTerminal() {
+		ctx = policy.WithApprover(ctx, terminalApprover(os.Stdin, os.Stderr))
+	} else {
+		warnAskDenies(cfg.Permissions, "a prompt run without a terminal on stdin")
+	}
 	debug.Printf("Running Agent 0 with prompt length=%d", len(prompt))
 
 	// Show actual useful information about what's happening
@@ -122,10 +149,19 @@ func runPromptWithOpts(prompt string, opts *commonOpts) {
 		fmt.Fprintf(os.Stderr, "� Available agents for delegation: %v\n", availableAgents)
 	}
 
+	// A dry run's proposed changes are reported even when the run fails.
+	dryRun := func() {
+		o := ag.Overlay
+		if teamCtx != nil {
+			o = teamCtx.Overlay()
+		}
+		emitDryRun(o, opts.patchOut)
+	}
+
 	out, err := ag.Run(ctx, prompt)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "❌ ERR: %v\n", err)
-		os.Exit(1)
+		dryRun()
+		return err
 	}
 
 	sum := trace.Analyze(prompt, col.Events())
@@ -135,6 +171,7 @@ func runPromptWithOpts(prompt string, opts *commonOpts) {
 
 	// Print the model output to stdout
 	fmt.Println(out)
+	dryRun()
 
 	// Print usage summary to stderr (always show, not just in debug)
 	if sum.TotalTokens > 0 {
@@ -144,4 +181,5 @@ func runPromptWithOpts(prompt string, opts *commonOpts) {
 	if opts.saveID != "" {
 		_ = ag.SaveState(context.Background(), opts.saveID)
 	}
+	return nil
 }
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/config"
+	"github.com/marcodenic/agentry/internal/memstore"
+)
+
+const storeUsage = `Usage: agentry store <command> [flags] [args]
+
+Commands:
+  ls [NS]                   List namespaces, or the keys in NS with sizes and TTLs
+  get NS KEY                Print a value (JSON is pretty-printed unless --raw)
+  set NS KEY [VALUE|-]      Store a value; "-" or no VALUE reads stdin (--ttl 1h)
+  rm NS [KEY...]            Delete keys, or every key matching --prefix
+  export [NS...]            Write namespaces as JSONL (default: all) to stdout or -o FILE
+  import [FILE]             Read JSONL from FILE or stdin (--ns overrides the namespace)
+  migrate                   Copy the store between backends (--from file --to bolt)
+
+Every command except migrate accepts --backend (memory|file|bolt) and --path;
+they default to the store configured in .agentry.yaml or AGENTRY_STORE.`
+
+// runStoreCmd dispatches `agentry store <subcommand>`.
+func runStoreCmd(args []string, opts *commonOpts) {
+	if len(args) == 0 {
+		fmt.Println(storeUsage)
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "ls":
+		runStoreLs(args[1:], opts)
+	case "get":
+		runStoreGet(args[1:], opts)
+	case "set":
+		runStoreSet(args[1:], opts)
+	case "rm":
+		runStoreRm(args[1:], opts)
+	case "export":
+		runStoreExport(args[1:], opts)
+	case "import":
+		runStoreImport(args[1:], opts)
+	case "migrate":
+		runStoreMigrate(args[1:])
+	default:
+		fmt.Printf("Error: Unknown store command '%s'\n", args[0])
+		fmt.Println(storeUsage)
+		os.Exit(1)
+	}
+}
+
+// storeFlags registers the backend selection flags shared by the store
+// subcommands and returns a function that opens the chosen store.
+func storeFlags(fs *flag.FlagSet, opts *commonOpts) func() memstore.SharedStore {
+	backend := fs.String("backend", "", "store backend (memory|file|bolt; default from config)")
+	path := fs.String("path", "", "store path (default: backend default location)")
+	return func() memstore.SharedStore {
+		b, p := *backend, *path
+		if b == "" || p == "" {
+			var cfgStore, cfgPath string
+			if opts != nil && opts.configPath != "" {
+				if cfg, err := config.Load(opts.configPath); err == nil {
+					cfgStore, cfgPath = cfg.Store, cfg.StorePath
+				}
+			}
+			if cfgStore == "" {
+				cfgStore = os.Getenv("AGENTRY_STORE")
+			}
+			if cfgPath == "" {
+				cfgPath = os.Getenv("AGENTRY_STORE_PATH")
+			}
+			if b == "" {
+				b = cfgStore
+			}
+			if p == "" && b == cfgStore {
+				p = cfgPath
+			}
+		}
+		if b == "" || b == memstore.BackendMemory {
+			fmt.Fprintln(os.Stderr, "note: the memory backend only lives inside one agentry process; use --backend file or bolt to inspect persisted data")
+		}
+		s, err := memstore.Open(b, p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "store: %v\n", err)
+			os.Exit(1)
+		}
+		return s
+	}
+}
+
+func storeFail(cmd string, err error) {
+	fmt.Fprintf(os.Stderr, "store %s: %v\n", cmd, err)
+	os.Exit(1)
+}
+
+func runStoreLs(args []string, opts *commonOpts) {
+	fs := flag.NewFlagSet("store ls", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "only list keys starting with this prefix")
+	open := storeFlags(fs, opts)
+	_ = fs.Parse(args)
+	s := open()
+	defer s.Close()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+	if fs.NArg() == 0 {
+		nss, err := s.Namespaces()
+		if err != nil {
+			storeFail("ls", err)
+		}
+		fmt.Fprintln(tw, "NAMESPACE\tKEYS\tSIZE")
+		for _, ns := range nss {
+			entries, err := s.Scan(ns, *prefix)
+			if err != nil {
+				storeFail("ls", err)
+			}
+			size := 0
+			for _, e := range entries {
+				size += len(e.Value)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", ns, len(entries), formatBytes(size))
+		}
+		return
+	}
+	now := time.Now()
+	fmt.Fprintln(tw, "KEY\tSIZE\tTTL")
+	for _, ns := range fs.Args() {
+		entries, err := s.Scan(ns, *prefix)
+		if err != nil {
+			storeFail("ls", err)
+		}
+		for _, e := range entries {
+			key := e.Key
+			if fs.NArg() > 1 {
+				key = ns + "/" + key
+			}
+			ttl := "-"
+			if !e.ExpiresAt.IsZero() {
+				ttl = e.ExpiresAt.Sub(now).Round(time.Second).String()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", key, formatBytes(len(e.Value)), ttl)
+		}
+	}
+}
+
+func runStoreGet(args []string, opts *commonOpts) {
+	fs := flag.NewFlagSet("store get", flag.ExitOnError)
+	raw := fs.Bool("raw", false, "print the stored bytes without JSON formatting")
+	open := storeFlags(fs, opts)
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		storeFail("get", fmt.Errorf("usage: agentry store get NS KEY"))
+	}
+	s := open()
+	defer s.Close()
+
+	val, ok, err := s.Get(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		storeFail("get", err)
+	}
+	if !ok {
+		storeFail("get", fmt.Errorf("%s/%s not found", fs.Arg(0), fs.Arg(1)))
+	}
+	if !*raw {
+		var buf bytes.Buffer
+		if json.Indent(&buf, val, "", "  ") == nil {
+			val = buf.Bytes()
+		}
+	}
+	os.Stdout.Write(val)
+	if len(val) > 0 && val[len(val)-1] != '\n' {
+		fmt.Println()
+	}
+}
+
+func runStoreSet(args []string, opts *commonOpts) {
+	fs := flag.NewFlagSet("store set", flag.ExitOnError)
+	ttl := fs.Duration("ttl", 0, "expire the key after this long (0 = never)")
+	asJSON := fs.Bool("json", false, "reject values that are not valid JSON")
+	open := storeFlags(fs, opts)
+	_ = fs.Parse(args)
+	if fs.NArg() < 2 || fs.NArg() > 3 {
+		storeFail("set", fmt.Errorf("usage: agentry store set [--ttl D] NS KEY [VALUE|-]"))
+	}
+	var val []byte
+	if fs.NArg() == 3 && fs.Arg(2) != "-" {
+		val = []byte(fs.Arg(2))
+	} else {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			storeFail("set", err)
+		}
+		val = bytes.TrimRight(b, "\n")
+	}
+	if *asJSON && !json.Valid(val) {
+		storeFail("set", fmt.Errorf("value is not valid JSON"))
+	}
+	s := open()
+	defer s.Close()
+	if err := s.Set(fs.Arg(0), fs.Arg(1), val, *ttl); err != nil {
+		storeFail("set", err)
+	}
+}
+
+func runStoreRm(args []string, opts *commonOpts) {
+	fs := flag.NewFlagSet("store rm", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "delete every key starting with this prefix")
+	all := fs.Bool("all", false, "delete every key in the namespace")
+	open := storeFlags(fs, opts)
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 || (fs.NArg() == 1 && *prefix == "" && !*all) {
+		storeFail("rm", fmt.Errorf("usage: agentry store rm NS KEY... | rm --prefix P NS | rm --all NS"))
+	}
+	s := open()
+	defer s.Close()
+
+	ns := fs.Arg(0)
+	keys := fs.Args()[1:]
+	if *prefix != "" || *all {
+		entries, err := s.Scan(ns, *prefix)
+		if err != nil {
+			storeFail("rm", err)
+		}
+		for _, e := range entries {
+			keys = append(keys, e.Key)
+		}
+	}
+	ops := make([]memstore.Op, 0, len(keys))
+	for _, k := range keys {
+		ops = append(ops, memstore.Op{NS: ns, Key: k, Delete: true})
+	}
+	if err := s.Batch(ops); err != nil {
+		storeFail("rm", err)
+	}
+	fmt.Fprintf(os.Stderr, "Deleted %d key(s) from %s\n", len(ops), ns)
+}
+
+func runStoreExport(args []string, opts *commonOpts) {
+	fs := flag.NewFlagSet("store export", flag.ExitOnError)
+	out := fs.String("o", "", "write to this file instead of stdout")
+	open := storeFlags(fs, opts)
+	_ = fs.Parse(args)
+	s := open()
+	defer s.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			storeFail("export", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	n, err := memstore.Export(s, bw, fs.Args()...)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		storeFail("export", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d entries\n", n)
+}
+
+func runStoreImport(args []string, opts *commonOpts) {
+	fs := flag.NewFlagSet("store import", flag.ExitOnError)
+	ns := fs.String("ns", "", "import every record into this namespace")
+	open := storeFlags(fs, opts)
+	_ = fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if fs.NArg() > 0 && fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			storeFail("import", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	s := open()
+	defer s.Close()
+	n, err := memstore.Import(s, r, *ns)
+	if err != nil {
+		storeFail("import", fmt.Errorf("%w (imported %d entries before failing)", err, n))
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d entries\n", n)
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
+
+func runStoreMigrate(args []string) {
+	fs := flag.NewFlagSet("store migrate", flag.ExitOnError)
+	from := fs.String("from", "", "source backend (file|bolt)")
+	to := fs.String("to", "", "destination backend (file|bolt)")
+	fromPath := fs.String("from-path", "", "source path (default: backend default location)")
+	toPath := fs.String("to-path", "", "destination path (default: backend default location)")
+	_ = fs.Parse(args)
+
+	if *from == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "store migrate: --from and --to are required")
+		os.Exit(1)
+	}
+	if *from == memstore.BackendMemory || *to == memstore.BackendMemory {
+		fmt.Fprintln(os.Stderr, "store migrate: the memory backend does not outlive the process; use file or bolt")
+		os.Exit(1)
+	}
+	if *fromPath == "" {
+		*fromPath = memstore.DefaultPath(*from)
+	}
+	if *toPath == "" {
+		*toPath = memstore.DefaultPath(*to)
+	}
+	if *from == *to && *fromPath == *toPath {
+		fmt.Fprintln(os.Stderr, "store migrate: source and destination are the same")
+		os.Exit(1)
+	}
+
+	src, err := memstore.Open(*from, *fromPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "store migrate: %v\n", err)
+		os.Exit(1)
+	}
+	defer src.Close()
+	dst, err := memstore.Open(*to, *toPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "store migrate: %v\n", err)
+		os.Exit(1)
+	}
+	defer dst.Close()
+
+	n, err := memstore.Migrate(src, dst)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "store migrate: %v (copied %d entries before failing)\n", err, n)
+		os.Exit(1)
+	}
+	fmt.Printf("Migrated %d entries from %s (%s) to %s (%s)\n", n, *from, *fromPath, *to, *toPath)
+	if *to == memstore.BackendBolt {
+		fmt.Println("Set AGENTRY_STORE=bolt or `store: bolt` in .agentry.yaml to use it.")
+	}
+}
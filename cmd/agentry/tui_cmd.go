@@ -14,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/marcodenic/agentry/internal/config"
 	"github.com/marcodenic/agentry/internal/debug"
+	"github.com/marcodenic/agentry/internal/overlay"
 	"github.com/marcodenic/agentry/internal/tui"
 )
 
@@ -43,6 +44,15 @@ func loadEnvFile(path string) error {
 }
 
 func runTui(args []string) {
+	if err := tuiWithArgs(args); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ ERR: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// tuiWithArgs runs the TUI. It returns instead of exiting so the tools it
+// started are always cleaned up, on SIGINT and SIGTERM too.
+func tuiWithArgs(args []string) error {
 	// Load .env.local file if it exists
 	if _, err := os.Stat(".env.local"); err == nil {
 		if err := loadEnvFile(".env.local"); err != nil {
@@ -59,16 +69,19 @@ func runTui(args []string) {
 	opts, _ := parseCommon("tui", args)
 	cfg, err := config.Load(opts.configPath)
 	if err != nil {
-		fmt.Printf("failed to load config: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to load config: %w", err)
 	}
 	applyOverrides(cfg, opts)
+	defer closeTools()
 	ag, err := buildAgent(cfg)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("failed to build agent: %w", err)
 	}
 	// Apply iteration cap from flags (0 = unlimited)
 	ag.MaxIter = opts.maxIter
+	if opts.dryRun {
+		ag.Overlay = overlay.New()
+	}
 
 	// No iteration cap
 	if opts.ckptID != "" {
@@ -86,23 +99,24 @@ func runTui(args []string) {
 	}
 	model := tui.NewWithConfig(ag, cfg.Include, configDir)
 
-	// Set up signal handling for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigCh
-		cancel() // Cancel context to signal shutdown
-	}()
+	// A signal ends the program through its context; the session is still
+	// saved and the tools cleaned up below.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithContext(ctx))
-	if _, err := p.Run(); err != nil {
-		panic(err)
+	final, err := p.Run()
+	if err != nil && ctx.Err() == nil {
+		return err
 	}
 
-	cancel() // Ensure cleanup even if program exits normally
 	if opts.saveID != "" {
 		_ = ag.SaveState(context.Background(), opts.saveID)
 	}
+	o := ag.Overlay
+	if m, ok := final.(tui.Model); ok && m.Overlay() != nil {
+		o = m.Overlay()
+	}
+	emitDryRun(o, opts.patchOut)
+	return nil
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/marcodenic/agentry/internal/journal"
+)
+
+const undoUsage = `Usage: agentry undo [--last N | --agent NAME | --call ID] [--session ID] [--force] [--list | --sessions]
+
+Restores files written by agent file tools to their state before the
+selected changes. Without a selector the most recent change is undone; the
+session defaults to the most recent one.`
+
+// runUndoCmd implements `agentry undo`.
+func runUndoCmd(args []string) {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, undoUsage) }
+	last := fs.Int("last", 0, "undo the last N changes (tool calls)")
+	agent := fs.String("agent", "", "undo the changes of this agent (name or ID prefix)")
+	call := fs.String("call", "", "undo the writes of one tool call")
+	session := fs.String("session", "", "journal session (default: most recent)")
+	force := fs.Bool("force", false, "restore even over later changes to the same files")
+	list := fs.Bool("list", false, "list the session's changes instead of undoing")
+	sessions := fs.Bool("sessions", false, "list journal sessions")
+	_ = fs.Parse(args)
+
+	dir := journal.Dir()
+	if *sessions {
+		ss, err := journal.Sessions(dir)
+		if err != nil {
+			undoFail(err)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SESSION\tLAST WRITE")
+		for _, s := range ss {
+			fmt.Fprintf(tw, "%s\t%s\n", s.ID, s.Modified.Format("2006-01-02 15:04:05"))
+		}
+		tw.Flush()
+		return
+	}
+
+	id := *session
+	if id == "" {
+		ss, err := journal.Sessions(dir)
+		if err != nil {
+			undoFail(err)
+		}
+		if len(ss) == 0 {
+			undoFail(fmt.Errorf("no journal sessions in %s", dir))
+		}
+		id = ss[0].ID
+	}
+	j, err := journal.Open(dir, id)
+	if err != nil {
+		undoFail(err)
+	}
+
+	if *list {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "SEQ\tTIME\tAGENT\tTOOL\tCALL\tPATH\n")
+		for _, e := range j.Entries() {
+			path := e.Path
+			if !e.Existed {
+				path += " (created)"
+			}
+			if e.Undone {
+				path += " [undone]"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", e.Seq, e.Time.Format("15:04:05"), e.AgentLabel(), e.Tool, e.CallID, path)
+		}
+		tw.Flush()
+		return
+	}
+
+	undone, err := j.Undo(journal.Selector{Last: *last, Agent: *agent, CallID: *call}, *force)
+	for _, e := range undone {
+		fmt.Printf("undid #%d %s (%s by %s)\n", e.Seq, e.Path, e.Tool, e.AgentLabel())
+	}
+	if err != nil {
+		var ce *journal.ConflictError
+		if errors.As(err, &ce) {
+			fmt.Fprintln(os.Stderr, "undo: files changed since the selected changes; nothing restored (use --force to undo anyway):")
+			for _, c := range ce.Conflicts {
+				fmt.Fprintf(os.Stderr, "  %s: %s\n", c.Path, c.Reason)
+			}
+			os.Exit(1)
+		}
+		undoFail(err)
+	}
+}
+
+func undoFail(err error) {
+	fmt.Fprintf(os.Stderr, "undo: %v\n", err)
+	os.Exit(1)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 
 	"gopkg.in/yaml.v3"
 )
@@ -20,6 +21,46 @@ type ToolManifest struct {
 	MemLimit    string          `yaml:"mem_limit,omitempty"`
 	Engine      string          `yaml:"engine,omitempty"`
 	Permissions ToolPermissions `yaml:"permissions,omitempty"`
+
+	// Args declares the parameters of command and http tools: name -> JSON
+	// Schema fragment (with optional required/default) or a bare type name.
+	// Argv runs a command without a shell; {{name}} in an element is
+	// replaced by that argument.
+	Argv []string `yaml:"argv,omitempty"`
+	// HTTP tool request shape; {{name}} is expanded in the URL, header and
+	// query values.
+	Method  string            `yaml:"method,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Query   map[string]string `yaml:"query,omitempty"`
+	// Extract selects part of a JSON response, e.g. data.items[0].name.
+	Extract string `yaml:"extract,omitempty"`
+	// Timeout bounds one execution of a command or http tool (default 60s).
+	Timeout string `yaml:"timeout,omitempty"`
+
+	// OpenAPI is the path or URL of an OpenAPI 3 spec; one tool is
+	// registered per operation, optionally filtered by Tags or Operations.
+	OpenAPI    string   `yaml:"openapi,omitempty"`
+	Tags       []string `yaml:"tags,omitempty"`
+	Operations []string `yaml:"operations,omitempty"`
+	// Server overrides the spec's first server: a base URL, an index into
+	// the spec's servers, or a word from a server's description.
+	Server string `yaml:"server,omitempty"`
+	// Auth names the credentials entry used to authenticate requests
+	// (default: the tool name).
+	Auth string `yaml:"auth,omitempty"`
+	// MaxOutput caps the response bytes returned to the model.
+	MaxOutput int `yaml:"max_output,omitempty"`
+
+	// Execution policy, enforced by the agent loop for every tool type;
+	// timeout and max_output above also apply to builtins. Truncate keeps
+	// the head (default), tail or middle of long output. Retries re-run a
+	// call that timed out or hit a network error, after Backoff (default
+	// 1s, doubling); only set them for idempotent tools. Concurrency caps
+	// simultaneous calls across agents.
+	Truncate    string `yaml:"truncate,omitempty"`
+	Retries     int    `yaml:"retries,omitempty"`
+	Backoff     string `yaml:"backoff,omitempty"`
+	Concurrency int    `yaml:"concurrency,omitempty"`
 }
 
 type ToolPermissions struct {
@@ -44,7 +85,8 @@ type File struct {
 	Tools       []ToolManifest               `yaml:"tools"`
 	Include     []string                     `yaml:"include"` // Add include support for role files
 	Memory      string                       `yaml:"memory"`
-	Store       string                       `yaml:"store"`
+	Store       string                       `yaml:"store"`      // shared store backend: memory, file or bolt
+	StorePath   string                       `yaml:"store_path"` // directory (file) or database path (bolt)
 	Vector      VectorManifest               `yaml:"vector_store"`
 	Theme       string                       `yaml:"theme"`
 	Themes      map[string]string            `yaml:"themes"`
@@ -54,16 +96,78 @@ type File struct {
 	Collector   string                       `yaml:"collector"`
 	Port        string                       `yaml:"port"`
 	Sandbox     Sandbox                      `yaml:"sandbox"`
+	Network     Network                      `yaml:"network"`
 	Permissions Permissions                  `yaml:"permissions"`
 	Budget      Budget                       `yaml:"budget"`
 }
 
+// Sandbox configures how shell and command tools run (see package sandbox).
 type Sandbox struct {
-	Engine string `yaml:"engine"`
+	Engine    string   `yaml:"engine"` // disabled (default), process or namespace
+	Timeout   string   `yaml:"timeout"`
+	CPULimit  string   `yaml:"cpu_limit"`
+	MemLimit  string   `yaml:"mem_limit"`
+	FileLimit string   `yaml:"file_limit"`
+	ProcLimit int      `yaml:"proc_limit"`
+	Net       string   `yaml:"net"` // host (default) or none
+	Env       []string `yaml:"env"` // extra variables passed through
+	Workdir   string   `yaml:"workdir"`
+	// OnUnavailable is warn (default) or refuse when the requested
+	// isolation cannot be provided.
+	OnUnavailable string `yaml:"on_unavailable"`
+}
+
+// Network configures the HTTP client shared by the network tools (see
+// package httpx).
+type Network struct {
+	// AllowDomains, when set, limits requests to these hosts; a leading
+	// "*." also matches subdomains. DenyDomains always wins.
+	AllowDomains []string `yaml:"allow_domains"`
+	DenyDomains  []string `yaml:"deny_domains"`
+	// AllowPrivate permits loopback, private and link-local addresses,
+	// which are refused by default.
+	AllowPrivate bool         `yaml:"allow_private"`
+	Proxy        string       `yaml:"proxy"` // default: HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	MaxRedirects int          `yaml:"max_redirects"`
+	MaxBody      string       `yaml:"max_body"` // response size cap, e.g. 10M
+	Timeout      string       `yaml:"timeout"`
+	Cache        NetworkCache `yaml:"cache"`
+}
+
+// NetworkCache configures the on-disk response cache.
+type NetworkCache struct {
+	// Mode is off (default), on, record or offline.
+	Mode string `yaml:"mode"`
+	Dir  string `yaml:"dir"`
+	TTL  string `yaml:"ttl"`
 }
 
 type Permissions struct {
 	Tools []string `yaml:"tools"`
+	// Default is the action for calls no rule matches: allow (default),
+	// deny or ask.
+	Default string       `yaml:"default"`
+	Rules   []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule matches a tool call by tool name and arguments. Every criterion
+// that is set must match; the first matching rule decides (see package
+// policy).
+type PolicyRule struct {
+	Name  string   `yaml:"name"`
+	Tools []string `yaml:"tools"` // tool name globs; empty matches every tool
+	// Paths are globs for path arguments: relative to the workspace unless
+	// they start with /, ** spans directories and a pattern without / is
+	// matched against the base name.
+	Paths []string `yaml:"paths"`
+	// OutsideWorkspace matches calls with a path outside the workspace.
+	OutsideWorkspace bool     `yaml:"outside_workspace"`
+	Commands         []string `yaml:"commands"`      // prefixes of each command in a shell line
+	CommandRegex     []string `yaml:"command_regex"` // regexes over the whole command
+	URLs             []string `yaml:"urls"`          // URL globs (* matches anything)
+	Domains          []string `yaml:"domains"`       // hosts; *.example.com matches subdomains
+	Action           string   `yaml:"action"`        // allow, deny or ask
+	Reason           string   `yaml:"reason"`
 }
 
 type Budget struct {
@@ -93,6 +197,9 @@ func merge(dst *File, src File) {
 	if src.Store != "" {
 		dst.Store = src.Store
 	}
+	if src.StorePath != "" {
+		dst.StorePath = src.StorePath
+	}
 	if src.Vector.Type != "" {
 		dst.Vector = src.Vector
 	}
@@ -129,10 +236,13 @@ func merge(dst *File, src File) {
 	if src.Port != "" {
 		dst.Port = src.Port
 	}
-	if src.Sandbox.Engine != "" {
+	if !reflect.DeepEqual(src.Sandbox, Sandbox{}) {
 		dst.Sandbox = src.Sandbox
 	}
-	if len(src.Permissions.Tools) > 0 {
+	if !reflect.DeepEqual(src.Network, Network{}) {
+		dst.Network = src.Network
+	}
+	if !reflect.DeepEqual(src.Permissions, Permissions{}) {
 		dst.Permissions = src.Permissions
 	}
 	if src.Budget.Tokens > 0 || src.Budget.Dollars > 0 {
@@ -190,6 +300,12 @@ func Load(path string) (*File, error) {
 	if v := os.Getenv("AGENTRY_PORT"); v != "" {
 		out.Port = v
 	}
+	if v := os.Getenv("AGENTRY_STORE"); v != "" {
+		out.Store = v
+	}
+	if v := os.Getenv("AGENTRY_STORE_PATH"); v != "" {
+		out.StorePath = v
+	}
 
 	return &out, nil
 }
@@ -10,6 +10,24 @@ var TeamContextKey = struct{ key string }{"agentry.team"}
 // when running within a Team. Team sets this value before invoking the agent.
 var AgentNameContextKey = struct{ key string }{"agentry.agent-name"}
 
+// AgentIDContextKey provides the ID of the agent executing a tool call. The
+// agent loop sets it before invoking each tool.
+var AgentIDContextKey = struct{ key string }{"agentry.agent-id"}
+
+// ToolCallContextKey provides the ToolCall being executed. The agent loop
+// sets it alongside AgentIDContextKey.
+var ToolCallContextKey = struct{ key string }{"agentry.tool-call"}
+
+// ToolCall identifies one tool invocation requested by the model.
+type ToolCall struct {
+	ID   string
+	Name string
+}
+
+// TaskContextKey provides the input the current agent run was started with,
+// so tools can tailor their results to the task at hand.
+var TaskContextKey = struct{ key string }{"agentry.task"}
+
 // TeamService defines the contract for team coordination services.
 // This interface breaks import cycles between tool and team packages.
 type TeamService interface {
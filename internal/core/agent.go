@@ -12,11 +12,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/marcodenic/agentry/internal/contracts"
 	"github.com/marcodenic/agentry/internal/cost"
 	"github.com/marcodenic/agentry/internal/debug"
 	"github.com/marcodenic/agentry/internal/env"
 	"github.com/marcodenic/agentry/internal/memory"
 	"github.com/marcodenic/agentry/internal/model"
+	"github.com/marcodenic/agentry/internal/overlay"
 	promptpkg "github.com/marcodenic/agentry/internal/prompt"
 	"github.com/marcodenic/agentry/internal/tokens"
 	"github.com/marcodenic/agentry/internal/tool"
@@ -43,6 +45,12 @@ type Agent struct {
 	JSONValidator *JSONValidator
 	// Role for display
 	Role string
+	// Permissions are the tool permissions the Tools registry was built
+	// with; agents spawned from this one inherit and may only narrow them.
+	Permissions *tool.Permissions
+	// Overlay, when set, makes this a dry run: file tools write to it
+	// instead of the disk.
+	Overlay *overlay.FS
 
 	// cached tool names to reduce repeated map iteration/log noise
 	cachedToolNames []string
@@ -351,6 +359,7 @@ func (a *Agent) Run(ctx context.Context, input string) (string, error) {
 	if resetter, ok := a.Client.(interface{ ResetConversation() }); ok {
 		resetter.ResetConversation()
 	}
+	ctx = context.WithValue(ctx, contracts.TaskContextKey, input)
 
 	a.Trace(ctx, trace.EventModelStart, a.ModelName)
 
@@ -754,7 +763,11 @@ func (a *Agent) executeToolCalls(ctx context.Context, calls []model.ToolCall, st
 		} else {
 			debug.Printf("Agent '%s' executing tool '%s'", a.ID, tc.Name)
 		}
-		a.Trace(ctx, trace.EventToolStart, map[string]any{"name": tc.Name, "args": args})
+		startData := map[string]any{"name": tc.Name, "args": args}
+		if pol := tool.PolicyOf(t); !pol.IsZero() {
+			startData["policy"] = pol.Summary()
+		}
+		a.Trace(ctx, trace.EventToolStart, startData)
 
 		// Show tool execution to user (not just debug mode)
 		if os.Getenv("AGENTRY_TUI_MODE") != "1" {
@@ -767,10 +780,25 @@ func (a *Agent) executeToolCalls(ctx context.Context, calls []model.ToolCall, st
 			}
 		}
 
-		r, err := t.Execute(ctx, args)
+		toolCtx := context.WithValue(ctx, contracts.AgentIDContextKey, a.ID.String())
+		toolCtx = context.WithValue(toolCtx, contracts.ToolCallContextKey, contracts.ToolCall{ID: tc.ID, Name: tc.Name})
+		if a.Overlay != nil {
+			toolCtx = overlay.WithContext(toolCtx, a.Overlay)
+		}
+		toolCtx = trace.WithEmitter(toolCtx, func(typ trace.EventType, data any) { a.Trace(ctx, typ, data) })
+		res, ex, err := tool.RunWithPolicy(toolCtx, t, args, func(rt tool.Retry) {
+			a.Trace(ctx, trace.EventToolRetry, map[string]any{
+				"name": tc.Name, "attempt": rt.Attempt, "error": rt.Err.Error(),
+				"error_class": rt.Class, "delay_ms": rt.Delay.Milliseconds(),
+			})
+		})
+		r := res.Text
 		debug.Printf("Agent '%s' tool '%s' execute completed, err=%v, result_length=%d", a.ID, tc.Name, err, len(r))
 		if err != nil {
 			debug.Printf("Agent '%s' tool '%s' failed: %v", a.ID, tc.Name, err)
+			endData := map[string]any{"name": tc.Name, "error": err.Error(), "error_class": res.ErrorClass}
+			addExecution(endData, ex)
+			a.Trace(ctx, trace.EventToolEnd, endData)
 
 			// Show tool failure to user
 			if os.Getenv("AGENTRY_TUI_MODE") != "1" {
@@ -810,7 +838,9 @@ func (a *Agent) executeToolCalls(ctx context.Context, calls []model.ToolCall, st
 			fmt.Fprintf(os.Stderr, "✅ %s: %s completed\n", a.ID.String()[:8], tc.Name)
 		}
 
-		a.Trace(ctx, trace.EventToolEnd, map[string]any{"name": tc.Name, "result": r})
+		endData := toolEndData(tc.Name, res)
+		addExecution(endData, ex)
+		a.Trace(ctx, trace.EventToolEnd, endData)
 		step.ToolResults[tc.ID] = r
 		debug.Printf("Agent '%s' adding tool result to messages, role=tool, callID=%s", a.ID, tc.ID)
 
@@ -840,6 +870,43 @@ func (a *Agent) executeToolCalls(ctx context.Context, calls []model.ToolCall, st
 	return msgs, hadErrors, nil
 }
 
+// toolEndData is the tool_end event payload: the text result plus the
+// structured parts of a tool.Result, so trace consumers can render them
+// without parsing the text.
+func toolEndData(name string, res tool.Result) map[string]any {
+	data := map[string]any{"name": name, "result": res.Text}
+	if res.Data != nil {
+		data["data"] = res.Data
+	}
+	if res.MIME != "" {
+		data["mime"] = res.MIME
+	}
+	if len(res.Attachments) > 0 {
+		data["attachments"] = res.Attachments
+	}
+	if res.ErrorClass != "" {
+		data["error_class"] = res.ErrorClass
+	}
+	return data
+}
+
+// addExecution reports in a tool_end payload where the tool's execution
+// policy changed the call: retries, time queued, a timeout or truncation.
+func addExecution(data map[string]any, ex tool.Execution) {
+	if ex.Attempts > 1 {
+		data["attempts"] = ex.Attempts
+	}
+	if ex.Queued >= time.Millisecond {
+		data["queued_ms"] = ex.Queued.Milliseconds()
+	}
+	if ex.TimedOut {
+		data["timed_out"] = true
+	}
+	if ex.Truncated > 0 {
+		data["truncated_bytes"] = ex.Truncated
+	}
+}
+
 // getToolArgSummary returns a brief summary of key tool arguments for user-friendly logging
 func getToolArgSummary(toolName string, args map[string]any) string {
 	switch toolName {
@@ -875,7 +942,7 @@ func getToolArgSummary(toolName string, args map[string]any) string {
 		if path, ok := args["path"].(string); ok && path != "." {
 			return fmt.Sprintf("'%s'", path)
 		}
-	case "sh", "bash":
+	case "sh", "bash", "shell_session":
 		if cmd, ok := args["command"].(string); ok {
 			// Truncate very long commands
 			if len(cmd) > 50 {
@@ -894,6 +961,21 @@ func getToolArgSummary(toolName string, args map[string]any) string {
 			}
 			return fmt.Sprintf("-> %s", agent)
 		}
+	case "git_commit":
+		if msg, ok := args["message"].(string); ok {
+			msg, _, _ = strings.Cut(msg, "\n")
+			if len(msg) > 50 {
+				return fmt.Sprintf("'%s...'", msg[:47])
+			}
+			return fmt.Sprintf("'%s'", msg)
+		}
+	case "git_branch", "git_stash":
+		if action, ok := args["action"].(string); ok {
+			if name, nameOk := args["name"].(string); nameOk {
+				return fmt.Sprintf("%s '%s'", action, name)
+			}
+			return action
+		}
 	}
 	return ""
 }
@@ -65,12 +65,20 @@ func (a *Agent) Resume(ctx context.Context) error {
 	return a.LoadState(ctx, "")
 }
 func (a *Agent) Trace(ctx context.Context, typ trace.EventType, data any) {
+	extra := trace.WriterFrom(ctx)
+	if a.Tracer == nil && extra == nil {
+		return
+	}
+	ev := trace.Event{
+		Type:      typ,
+		AgentID:   a.ID.String(),
+		Data:      data,
+		Timestamp: trace.Now(),
+	}
 	if a.Tracer != nil {
-		a.Tracer.Write(ctx, trace.Event{
-			Type:      typ,
-			AgentID:   a.ID.String(),
-			Data:      data,
-			Timestamp: trace.Now(),
-		})
+		a.Tracer.Write(ctx, ev)
+	}
+	if extra != nil {
+		extra.Write(ctx, ev)
 	}
 }
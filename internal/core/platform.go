@@ -112,25 +112,47 @@ func getOtherBuiltinTools(allowedBuiltins []string) []string {
 // getBuiltinDescription returns a description for builtin tools
 func getBuiltinDescription(tool string) string {
 	descriptions := map[string]string{
-		"read_lines":     "Read specific lines with line-precise access",
-		"edit_range":     "Replace line ranges atomically",
-		"insert_at":      "Insert lines at specific positions",
-		"search_replace": "Advanced search/replace with regex",
-		"fileinfo":       "Comprehensive file analysis",
-		"view":           "Enhanced file viewing with line numbers",
-		"create":         "Create files with overwrite protection",
-		"web_search":     "Search the web for information",
-		"read_webpage":   "Extract content from web pages",
-		"api":            "Make HTTP/REST API calls",
-		"download":       "Download files from URLs",
-		"fetch":          "Download content from URLs",
-		"agent":          "Delegate tasks to specialized agents",
-		"patch":          "Apply unified diff patches",
-		"echo":           "Repeat/output text",
-		"ping":           "Test network connectivity",
-		"branch-tidy":    "Clean up Git branches",
-		"mcp":            "Connect to MCP servers",
-		"sysinfo":        "Get system information and hardware specs",
+		"read_lines":       "Read specific lines with line-precise access",
+		"edit_range":       "Replace line ranges atomically",
+		"insert_at":        "Insert lines at specific positions",
+		"search_replace":   "Advanced search/replace with regex",
+		"fileinfo":         "Comprehensive file analysis",
+		"view":             "Enhanced file viewing with line numbers",
+		"create":           "Create files with overwrite protection",
+		"web_search":       "Search the web for information",
+		"read_webpage":     "Extract content from web pages",
+		"api":              "Make HTTP/REST API calls",
+		"download":         "Download files from URLs",
+		"fetch":            "Download content from URLs",
+		"agent":            "Delegate tasks to specialized agents",
+		"patch":            "Apply unified diff patches",
+		"echo":             "Repeat/output text",
+		"ping":             "Test network connectivity",
+		"run_tests":        "Run the test suite with structured failures",
+		"lsp_definition":   "Go to a symbol's definition",
+		"lsp_references":   "Find every reference to a symbol",
+		"lsp_hover":        "Type and documentation of a symbol",
+		"lsp_symbols":      "Outline a file or search workspace symbols",
+		"lsp_rename":       "Rename a symbol across the workspace",
+		"repo_map":         "Ranked map of files and symbols for the task",
+		"undo":             "Undo file changes made this session",
+		"lease":            "Assign file ownership to an agent",
+		"go_outline":       "Outline a Go file's declarations",
+		"go_replace_func":  "Replace a Go function or method by name",
+		"go_add_method":    "Add a method to a Go type",
+		"go_ensure_import": "Add a Go import if missing",
+		"go_remove_import": "Remove an unused Go import",
+		"go_rename_local":  "Rename a local inside a Go function",
+		"git_status":       "Working tree status as JSON",
+		"git_diff":         "Staged, unstaged or ref diffs with per-file stats",
+		"git_log":          "Commit history as JSON",
+		"git_show":         "Show a commit or a file at a commit",
+		"git_branch":       "List, create, switch or delete branches",
+		"git_commit":       "Stage and commit changes",
+		"git_stash":        "Manage stashes",
+		"git_blame":        "Line-by-line authorship",
+		"mcp":              "Connect to MCP servers",
+		"sysinfo":          "Get system information and hardware specs",
 	}
 	if desc, exists := descriptions[tool]; exists {
 		return desc
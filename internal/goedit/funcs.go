@@ -0,0 +1,102 @@
+package goedit
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// ReplaceFunc replaces the function or method called name. code is either
+// a whole declaration starting with "func" (its doc comment, if any,
+// replaces the old one) or a new body, with or without braces.
+func ReplaceFunc(src []byte, name, code string) ([]byte, error) {
+	const op = "replace function"
+	fset, f, err := parse(op, src)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := findFunc(op, f, name)
+	if err != nil {
+		return nil, err
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, &Error{Op: op, Msg: "code is empty"}
+	}
+	var e splice
+	if strings.HasPrefix(code, "func") || strings.HasPrefix(code, "//") || strings.HasPrefix(code, "/*") {
+		nd, err := parseFunc(op, code)
+		if err != nil {
+			return nil, err
+		}
+		if nd.Name.Name != fd.Name.Name || recvType(nd) != recvType(fd) {
+			return nil, &Error{Op: op, Msg: fmt.Sprintf("code declares %s, not %s; use an add tool to add a new function", funcName(nd), funcName(fd))}
+		}
+		start := fset.Position(fd.Pos()).Offset
+		if nd.Doc != nil && fd.Doc != nil {
+			start = fset.Position(fd.Doc.Pos()).Offset
+		}
+		e = splice{start, fset.Position(fd.End()).Offset, code}
+	} else {
+		if fd.Body == nil {
+			return nil, errorAt(op, src, fset.Position(fd.Pos()).Line, "%s has no body to replace", funcName(fd))
+		}
+		if !strings.HasPrefix(code, "{") {
+			code = "{\n" + code + "\n}"
+		}
+		e = splice{fset.Position(fd.Body.Lbrace).Offset, fset.Position(fd.Body.End()).Offset, code}
+	}
+	return finish(op, applySplices(src, []splice{e}))
+}
+
+// AddMethod adds a method declaration (code, including its receiver and
+// any doc comment) after the last method of its receiver type, or after
+// the type declaration, or at the end of the file when the type is
+// declared elsewhere in the package. typeName, if set, must match the
+// receiver.
+func AddMethod(src []byte, typeName, code string) ([]byte, error) {
+	const op = "add method"
+	fset, f, err := parse(op, src)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := parseFunc(op, code)
+	if err != nil {
+		return nil, err
+	}
+	recv := recvType(nd)
+	if recv == "" {
+		return nil, &Error{Op: op, Msg: "code has no receiver; it must be a method such as func (t *T) Name()"}
+	}
+	if typeName = strings.TrimLeft(typeName, "*"); typeName != "" && typeName != recv {
+		return nil, &Error{Op: op, Msg: fmt.Sprintf("method receiver is %s, not %s", recv, typeName)}
+	}
+
+	insert := len(src)
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if recvType(d) != recv {
+				continue
+			}
+			if d.Name.Name == nd.Name.Name {
+				return nil, errorAt(op, src, fset.Position(d.Pos()).Line, "%s.%s already exists", recv, nd.Name.Name)
+			}
+			insert = fset.Position(d.End()).Offset
+		case *ast.GenDecl:
+			for _, s := range d.Specs {
+				if ts, ok := s.(*ast.TypeSpec); ok && ts.Name.Name == recv && insert == len(src) {
+					insert = fset.Position(d.End()).Offset
+				}
+			}
+		}
+	}
+	text := "\n\n" + strings.TrimSpace(code) + "\n"
+	if insert == len(src) {
+		text = strings.TrimPrefix(text, "\n")
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			text = "\n" + text
+		}
+	}
+	return finish(op, applySplices(src, []splice{{insert, insert, text}}))
+}
@@ -0,0 +1,224 @@
+// Package goedit makes structural edits to Go source files: replacing
+// functions, adding methods, managing imports and renaming locals. Edits
+// are spliced into the original text so comments and layout elsewhere are
+// kept, and every result is gofmt'ed and must parse before it is returned.
+package goedit
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Error is an edit that was refused. Context shows the numbered source
+// lines around Line.
+type Error struct {
+	Op      string
+	Msg     string
+	Line    int
+	Context string
+}
+
+func (e *Error) Error() string {
+	msg := e.Op + ": " + e.Msg
+	if e.Context != "" {
+		msg += "\n" + e.Context
+	}
+	return msg
+}
+
+func errorAt(op string, src []byte, line int, format string, args ...any) *Error {
+	return &Error{Op: op, Msg: fmt.Sprintf(format, args...), Line: line, Context: sourceContext(src, line, 3)}
+}
+
+// sourceContext returns the lines within radius of line, numbered, with the
+// line itself marked.
+func sourceContext(src []byte, line, radius int) string {
+	if line < 1 {
+		return ""
+	}
+	lines := strings.Split(string(src), "\n")
+	from, to := max(line-radius, 1), min(line+radius, len(lines))
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		mark := "  "
+		if i == line {
+			mark = "> "
+		}
+		fmt.Fprintf(&b, "%s%4d | %s\n", mark, i, lines[i-1])
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// errLine returns the line of the first error in a parser error list.
+func errLine(err error) (int, string) {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return list[0].Pos.Line, list[0].Msg
+	}
+	return 0, err.Error()
+}
+
+// parse parses src, refusing files that do not parse to begin with.
+func parse(op string, src []byte) (*token.FileSet, *ast.File, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		line, msg := errLine(err)
+		return nil, nil, errorAt(op, src, line, "file does not parse (%s); fix it before a structural edit", msg)
+	}
+	return fset, f, nil
+}
+
+// parseFunc parses code as a single function declaration.
+func parseFunc(op, code string) (*ast.FuncDecl, error) {
+	const prefix = "package p\n\n"
+	src := prefix + strings.TrimSpace(code) + "\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		line, msg := errLine(err)
+		line -= strings.Count(prefix, "\n")
+		return nil, &Error{Op: op, Msg: "code does not parse: " + msg, Line: line, Context: sourceContext([]byte(strings.TrimSpace(code)), line, 2)}
+	}
+	if len(f.Decls) != 1 {
+		return nil, &Error{Op: op, Msg: fmt.Sprintf("code must be exactly one function declaration, found %d declarations", len(f.Decls))}
+	}
+	fd, ok := f.Decls[0].(*ast.FuncDecl)
+	if !ok {
+		return nil, &Error{Op: op, Msg: "code must be a function declaration"}
+	}
+	return fd, nil
+}
+
+// finish gofmts the edited source. An edit that no longer parses is
+// refused with the offending lines.
+func finish(op string, src []byte) ([]byte, error) {
+	out, err := format.Source(src)
+	if err != nil {
+		line, msg := errLine(err)
+		return nil, errorAt(op, src, line, "result does not parse (%s); the file was not changed", msg)
+	}
+	return out, nil
+}
+
+// splice is a replacement of src[start:end].
+type splice struct {
+	start, end int
+	text       string
+}
+
+func applySplices(src []byte, edits []splice) []byte {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var b strings.Builder
+	prev := 0
+	for _, e := range edits {
+		b.Write(src[prev:e.start])
+		b.WriteString(e.text)
+		prev = e.end
+	}
+	b.Write(src[prev:])
+	return []byte(b.String())
+}
+
+// recvType returns the receiver's base type name, without pointer or type
+// parameters, or "" for a function.
+func recvType(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return ""
+	}
+	t := fd.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.ParenExpr:
+			t = x.X
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.Ident:
+			return x.Name
+		default:
+			return ""
+		}
+	}
+}
+
+func funcName(fd *ast.FuncDecl) string {
+	if r := recvType(fd); r != "" {
+		return r + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
+
+// findFunc finds a function by name, or a method by "Type.Method" (also
+// accepted as "(*Type).Method"). A bare name matches a method when there is
+// no function and exactly one method of that name.
+func findFunc(op string, f *ast.File, name string) (*ast.FuncDecl, error) {
+	recv, fn := "", name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		recv = strings.NewReplacer("(", "", ")", "", "*", "").Replace(name[:i])
+		fn = name[i+1:]
+	}
+	var methods []*ast.FuncDecl
+	var names []string
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		names = append(names, funcName(fd))
+		if fd.Name.Name != fn {
+			continue
+		}
+		r := recvType(fd)
+		switch {
+		case r == recv:
+			return fd, nil
+		case recv == "" && r != "":
+			methods = append(methods, fd)
+		}
+	}
+	if len(methods) == 1 {
+		return methods[0], nil
+	}
+	if len(methods) > 1 {
+		var ms []string
+		for _, m := range methods {
+			ms = append(ms, funcName(m))
+		}
+		return nil, &Error{Op: op, Msg: fmt.Sprintf("%q is ambiguous; use one of %s", name, strings.Join(ms, ", "))}
+	}
+	if len(names) > 30 {
+		names = append(names[:30], "...")
+	}
+	return nil, &Error{Op: op, Msg: fmt.Sprintf("no function %q in file; functions: %s", name, strings.Join(names, ", "))}
+}
+
+// lineStart returns the offset of the start of the line containing off.
+func lineStart(src []byte, off int) int {
+	for off > 0 && src[off-1] != '\n' {
+		off--
+	}
+	return off
+}
+
+// lineEnd returns the offset just past the newline ending the line
+// containing off.
+func lineEnd(src []byte, off int) int {
+	for off < len(src) && src[off] != '\n' {
+		off++
+	}
+	if off < len(src) {
+		off++
+	}
+	return off
+}
@@ -0,0 +1,150 @@
+package goedit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const sample = `package shop
+
+import "fmt"
+
+// Cart holds items.
+type Cart struct {
+	Items []string
+}
+
+// Add appends an item.
+func (c *Cart) Add(item string) {
+	c.Items = append(c.Items, item)
+}
+
+func Total(prices []int) int {
+	sum := 0
+	for _, p := range prices {
+		sum += p
+	}
+	if sum > 100 {
+		sum := sum - 10
+		fmt.Println(sum)
+	}
+	return sum
+}
+`
+
+func TestReplaceFunc(t *testing.T) {
+	out, err := ReplaceFunc([]byte(sample), "Total", "return len(prices)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "func Total(prices []int) int {\n\treturn len(prices)\n}") {
+		t.Fatalf("body not replaced:\n%s", out)
+	}
+
+	out, err = ReplaceFunc([]byte(sample), "(*Cart).Add", "// Add appends item once.\nfunc (c *Cart) Add(item string) { c.Items = append(c.Items, item) }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "// Add appends item once.\nfunc (c *Cart) Add") || strings.Contains(string(out), "Add appends an item") {
+		t.Fatalf("declaration not replaced:\n%s", out)
+	}
+
+	_, err = ReplaceFunc([]byte(sample), "Total", "return (")
+	var ge *Error
+	if !errors.As(err, &ge) || !strings.Contains(ge.Msg, "does not parse") || !strings.Contains(ge.Context, "> ") {
+		t.Fatalf("expected a parse error with context, got %v", err)
+	}
+	if _, err := ReplaceFunc([]byte(sample), "Missing", "return 0"); err == nil || !strings.Contains(err.Error(), "Cart.Add, Total") {
+		t.Fatalf("missing function error should list functions: %v", err)
+	}
+}
+
+func TestAddMethod(t *testing.T) {
+	out, err := AddMethod([]byte(sample), "Cart", "// Len counts items.\nfunc (c *Cart) Len() int { return len(c.Items) }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(out)
+	if add, ln := strings.Index(s, "func (c *Cart) Add"), strings.Index(s, "func (c *Cart) Len"); ln < add || ln > strings.Index(s, "func Total") {
+		t.Fatalf("method not placed after Add:\n%s", s)
+	}
+	if _, err := AddMethod(out, "Cart", "func (c Cart) Len() int { return 0 }"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("duplicate method: %v", err)
+	}
+	if _, err := AddMethod([]byte(sample), "Cart", "func Len() int { return 0 }"); err == nil {
+		t.Fatal("function without receiver accepted")
+	}
+}
+
+func TestImports(t *testing.T) {
+	out, changed, err := EnsureImport([]byte(sample), "strings", "")
+	if err != nil || !changed {
+		t.Fatalf("ensure: %v %v", changed, err)
+	}
+	if !strings.Contains(string(out), "import (\n\t\"fmt\"\n\t\"strings\"\n)") {
+		t.Fatalf("import block:\n%s", out)
+	}
+	if _, changed, _ := EnsureImport(out, "fmt", ""); changed {
+		t.Fatal("existing import added again")
+	}
+	out, changed, err = EnsureImport(out, "github.com/x/y/v2", "yy")
+	if err != nil || !changed || !strings.Contains(string(out), "yy \"github.com/x/y/v2\"") {
+		t.Fatalf("named import: %v\n%s", err, out)
+	}
+
+	out, changed, err = RemoveImport(out, "strings")
+	if err != nil || !changed || strings.Contains(string(out), `"strings"`) {
+		t.Fatalf("remove: %v\n%s", err, out)
+	}
+	if _, _, err := RemoveImport(out, "fmt"); err == nil || !strings.Contains(err.Error(), "still used") {
+		t.Fatalf("removing a used import: %v", err)
+	}
+
+	bare := "package p\n\nimport \"os\"\n\nvar _ = 1\n"
+	out, _, err = RemoveImport([]byte(bare), "os")
+	if err != nil || string(out) != "package p\n\nvar _ = 1\n" {
+		t.Fatalf("remove only import: %v\n%q", err, out)
+	}
+	out, _, err = EnsureImport(out, "os", "")
+	if err != nil || string(out) != bare {
+		t.Fatalf("add first import: %v\n%q", err, out)
+	}
+}
+
+func TestRenameLocal(t *testing.T) {
+	if _, _, err := RenameLocal([]byte(sample), "Total", "sum", "total", 0); err == nil || !strings.Contains(err.Error(), "pass line") {
+		t.Fatalf("ambiguous local: %v", err)
+	}
+	out, n, err := RenameLocal([]byte(sample), "Total", "sum", "total", 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The declaration, three uses and the initialiser of the inner sum.
+	if n != 5 || !strings.Contains(string(out), "sum := total - 10\n\t\tfmt.Println(sum)") || !strings.Contains(string(out), "return total") {
+		t.Fatalf("renamed %d:\n%s", n, out)
+	}
+	if _, _, err := RenameLocal([]byte(sample), "Total", "sum", "p", 16); err == nil || !strings.Contains(err.Error(), "shadowed") {
+		t.Fatalf("shadowing rename: %v", err)
+	}
+	if _, _, err := RenameLocal([]byte(sample), "Total", "sum", "fmt", 16); err == nil || !strings.Contains(err.Error(), "capture") {
+		t.Fatalf("capturing rename: %v", err)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	o, err := ParseOutline([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Package != "shop" || len(o.Imports) != 1 || len(o.Decls) != 3 {
+		t.Fatalf("outline: %+v", o)
+	}
+	add, ok := o.Find("Cart.Add")
+	if !ok || add.Kind != "method" || add.Signature != "func (c *Cart) Add(item string)" || add.Doc != "Add appends an item." || add.Line != 11 {
+		t.Fatalf("Add: %+v", add)
+	}
+	if cart, _ := o.Find("Cart"); len(cart.Members) != 1 || cart.Members[0] != "Items []string" {
+		t.Fatalf("Cart: %+v", cart)
+	}
+}
@@ -0,0 +1,152 @@
+package goedit
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// importName guesses the package name of an import path: its last element,
+// without a major version suffix or a go- prefix.
+func importName(path string) string {
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = parts[len(parts)-2]
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.ReplaceAll(name, "-", "")
+}
+
+func importPath(s *ast.ImportSpec) string {
+	p, _ := strconv.Unquote(s.Path.Value)
+	return p
+}
+
+// EnsureImport adds an import of path, under name if name is set. It
+// reports changed false when the file already has the import.
+func EnsureImport(src []byte, path, name string) (out []byte, changed bool, err error) {
+	const op = "ensure import"
+	if path == "" || strings.ContainsAny(path, "\"`\n ") {
+		return nil, false, &Error{Op: op, Msg: fmt.Sprintf("invalid import path %q", path)}
+	}
+	if name != "" && name != "_" && name != "." && !token.IsIdentifier(name) {
+		return nil, false, &Error{Op: op, Msg: fmt.Sprintf("invalid import name %q", name)}
+	}
+	fset, f, err := parse(op, src)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, s := range f.Imports {
+		if importPath(s) != path {
+			continue
+		}
+		have := ""
+		if s.Name != nil {
+			have = s.Name.Name
+		}
+		if have == name || name == "" {
+			return src, false, nil
+		}
+		if have != "_" {
+			return nil, false, errorAt(op, src, fset.Position(s.Pos()).Line, "%s is already imported as %q", path, have)
+		}
+	}
+
+	spec := strconv.Quote(path)
+	if name != "" {
+		spec = name + " " + spec
+	}
+	var e splice
+	var decl *ast.GenDecl
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			decl = gd
+			break
+		}
+	}
+	switch {
+	case decl == nil:
+		end := fset.Position(f.Name.End()).Offset
+		e = splice{end, end, "\n\nimport " + spec}
+	case decl.Lparen.IsValid():
+		rp := fset.Position(decl.Rparen).Offset
+		e = splice{rp, rp, "\t" + spec + "\n"}
+	default:
+		start, end := fset.Position(decl.Pos()).Offset, fset.Position(decl.End()).Offset
+		old := strings.TrimSpace(strings.TrimPrefix(string(src[start:end]), "import"))
+		e = splice{start, end, "import (\n\t" + old + "\n\t" + spec + "\n)"}
+	}
+	out, err = finish(op, applySplices(src, []splice{e}))
+	return out, err == nil, err
+}
+
+// RemoveImport removes the import of path. It refuses while the package is
+// still referred to in the file, and reports changed false when there is
+// no such import.
+func RemoveImport(src []byte, path string) (out []byte, changed bool, err error) {
+	const op = "remove import"
+	fset, f, err := parse(op, src)
+	if err != nil {
+		return nil, false, err
+	}
+	var decl *ast.GenDecl
+	var spec *ast.ImportSpec
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, s := range gd.Specs {
+			if is := s.(*ast.ImportSpec); importPath(is) == path {
+				decl, spec = gd, is
+			}
+		}
+	}
+	if spec == nil {
+		return src, false, nil
+	}
+
+	name := importName(path)
+	if spec.Name != nil {
+		name = spec.Name.Name
+	}
+	if name != "_" && name != "." {
+		var use ast.Node
+		ast.Inspect(f, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok && use == nil {
+				if id, ok := sel.X.(*ast.Ident); ok && id.Name == name {
+					use = sel
+				}
+			}
+			return use == nil
+		})
+		if use != nil {
+			return nil, false, errorAt(op, src, fset.Position(use.Pos()).Line, "%s is still used as %s", path, name)
+		}
+	}
+
+	// Remove the spec's lines, or the whole declaration when it is the
+	// only import in it.
+	var node ast.Node = spec
+	var doc *ast.CommentGroup = spec.Doc
+	if len(decl.Specs) == 1 {
+		node, doc = decl, decl.Doc
+	}
+	start := fset.Position(node.Pos()).Offset
+	if doc != nil {
+		start = fset.Position(doc.Pos()).Offset
+	}
+	end := fset.Position(node.End()).Offset
+	if spec.Comment != nil && node == ast.Node(spec) {
+		end = fset.Position(spec.Comment.End()).Offset
+	}
+	start, end = lineStart(src, start), lineEnd(src, end)
+	out, err = finish(op, applySplices(src, []splice{{start, end, ""}}))
+	return out, err == nil, err
+}
@@ -0,0 +1,169 @@
+package goedit
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+// Decl is one top-level declaration in an outline.
+type Decl struct {
+	Kind      string   `json:"kind"` // func, method, type, var or const
+	Name      string   `json:"name"`
+	Recv      string   `json:"recv,omitempty"`
+	Signature string   `json:"signature"`
+	Members   []string `json:"members,omitempty"` // struct fields or interface methods
+	Doc       string   `json:"doc,omitempty"`     // first line of the doc comment
+	Line      int      `json:"line"`
+	EndLine   int      `json:"end_line"`
+}
+
+// Outline is the shape of a Go file.
+type Outline struct {
+	Package string   `json:"package"`
+	Imports []string `json:"imports"`
+	Decls   []Decl   `json:"decls"`
+}
+
+// QualifiedName returns the name used to select the declaration in edits:
+// the function or type name, or "Type.Method".
+func (d Decl) QualifiedName() string {
+	if d.Recv != "" {
+		recv, _, _ := strings.Cut(strings.TrimLeft(d.Recv, "*"), "[")
+		return recv + "." + d.Name
+	}
+	return d.Name
+}
+
+func docLine(groups ...*ast.CommentGroup) string {
+	for _, g := range groups {
+		if g == nil {
+			continue
+		}
+		text := strings.TrimSpace(g.Text())
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[:i]
+		}
+		return text
+	}
+	return ""
+}
+
+func nodeString(fset *token.FileSet, n any) string {
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, fset, n); err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+// ParseOutline lists the package, imports and top-level declarations of src.
+func ParseOutline(src []byte) (*Outline, error) {
+	fset, f, err := parse("outline", src)
+	if err != nil {
+		return nil, err
+	}
+	o := &Outline{Package: f.Name.Name, Imports: []string{}, Decls: []Decl{}}
+	for _, s := range f.Imports {
+		imp := s.Path.Value
+		if s.Name != nil {
+			imp = s.Name.Name + " " + imp
+		}
+		o.Imports = append(o.Imports, imp)
+	}
+	lines := func(n ast.Node) (int, int) {
+		return fset.Position(n.Pos()).Line, fset.Position(n.End()).Line
+	}
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			decl := Decl{Kind: "func", Name: d.Name.Name, Doc: docLine(d.Doc)}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				decl.Kind = "method"
+				decl.Recv = nodeString(fset, d.Recv.List[0].Type)
+			}
+			sig := *d
+			sig.Doc, sig.Body = nil, nil
+			decl.Signature = nodeString(fset, &sig)
+			decl.Line, decl.EndLine = lines(d)
+			o.Decls = append(o.Decls, decl)
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, s := range d.Specs {
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					decl := Decl{Kind: "type", Name: s.Name.Name, Doc: docLine(s.Doc, d.Doc)}
+					decl.Line, decl.EndLine = lines(s)
+					switch t := s.Type.(type) {
+					case *ast.StructType:
+						decl.Signature = "type " + s.Name.Name + " struct"
+						decl.Members = fieldNames(fset, t.Fields)
+					case *ast.InterfaceType:
+						decl.Signature = "type " + s.Name.Name + " interface"
+						decl.Members = fieldNames(fset, t.Methods)
+					default:
+						spec := *s
+						spec.Doc, spec.Comment = nil, nil
+						decl.Signature = "type " + nodeString(fset, &spec)
+					}
+					o.Decls = append(o.Decls, decl)
+				case *ast.ValueSpec:
+					typ := ""
+					if s.Type != nil {
+						typ = " " + nodeString(fset, s.Type)
+					}
+					for _, n := range s.Names {
+						decl := Decl{Kind: d.Tok.String(), Name: n.Name, Signature: d.Tok.String() + " " + n.Name + typ, Doc: docLine(s.Doc, d.Doc)}
+						decl.Line, decl.EndLine = lines(s)
+						o.Decls = append(o.Decls, decl)
+					}
+				}
+			}
+		}
+	}
+	return o, nil
+}
+
+// fieldNames lists struct fields or interface methods as "Name Type".
+func fieldNames(fset *token.FileSet, fields *ast.FieldList) []string {
+	if fields == nil {
+		return nil
+	}
+	var out []string
+	for _, fl := range fields.List {
+		typ := nodeString(fset, fl.Type)
+		if len(fl.Names) == 0 {
+			out = append(out, typ)
+			continue
+		}
+		for _, n := range fl.Names {
+			if _, ok := fl.Type.(*ast.FuncType); ok {
+				out = append(out, n.Name+strings.TrimPrefix(typ, "func"))
+			} else {
+				out = append(out, n.Name+" "+typ)
+			}
+		}
+	}
+	return out
+}
+
+// Find returns the declaration selected by name, as accepted by the edit
+// functions.
+func (o *Outline) Find(name string) (Decl, bool) {
+	name = strings.NewReplacer("(", "", ")", "", "*", "").Replace(name)
+	for _, d := range o.Decls {
+		if d.QualifiedName() == name || (d.Kind == "func" && d.Name == name) {
+			return d, true
+		}
+	}
+	for _, d := range o.Decls {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return Decl{}, false
+}
@@ -0,0 +1,137 @@
+package goedit
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// stubImporter satisfies imports with empty packages so a single file can
+// be type-checked for its local scopes without loading dependencies.
+type stubImporter struct{}
+
+func (stubImporter) Import(path string) (*types.Package, error) {
+	pkg := types.NewPackage(path, importName(path))
+	pkg.MarkComplete()
+	return pkg, nil
+}
+
+// RenameLocal renames a parameter, result, variable, constant, type or
+// label declared inside the function fn. When more than one local has the
+// old name, line picks the one declared on that line. It returns the new
+// source and the number of identifiers changed.
+func RenameLocal(src []byte, fn, oldName, newName string, line int) ([]byte, int, error) {
+	const op = "rename local"
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return nil, 0, &Error{Op: op, Msg: fmt.Sprintf("%q is not a valid identifier", newName)}
+	}
+	if oldName == newName {
+		return nil, 0, &Error{Op: op, Msg: "old and new names are the same"}
+	}
+	fset, f, err := parse(op, src)
+	if err != nil {
+		return nil, 0, err
+	}
+	fd, err := findFunc(op, f, fn)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Errors from unresolved imports and other packages' identifiers are
+	// expected; scopes inside the function are still complete.
+	info := &types.Info{Defs: map[*ast.Ident]types.Object{}, Uses: map[*ast.Ident]types.Object{}, Scopes: map[ast.Node]*types.Scope{}}
+	conf := types.Config{Importer: stubImporter{}, Error: func(error) {}}
+	pkg, _ := conf.Check(f.Name.Name, fset, []*ast.File{f}, info)
+
+	inFunc := func(p token.Pos) bool { return p >= fd.Pos() && p < fd.End() }
+	var candidates []types.Object
+	for id, obj := range info.Defs {
+		if obj == nil || id.Name != oldName || !inFunc(id.Pos()) || obj.Parent() == nil || obj.Parent() == pkg.Scope() {
+			continue
+		}
+		if v, ok := obj.(*types.Var); ok && v.IsField() {
+			continue
+		}
+		candidates = append(candidates, obj)
+	}
+	for id, obj := range info.Defs {
+		// Labels have no scope parent but are local to the function.
+		if l, ok := obj.(*types.Label); ok && id.Name == oldName && inFunc(id.Pos()) {
+			candidates = append(candidates, l)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Pos() < candidates[j].Pos() })
+	if line > 0 {
+		var onLine []types.Object
+		for _, c := range candidates {
+			if fset.Position(c.Pos()).Line == line {
+				onLine = append(onLine, c)
+			}
+		}
+		candidates = onLine
+	}
+	switch len(candidates) {
+	case 0:
+		where := ""
+		if line > 0 {
+			where = fmt.Sprintf(" on line %d", line)
+		}
+		return nil, 0, errorAt(op, src, fset.Position(fd.Pos()).Line, "no local %q declared in %s%s", oldName, funcName(fd), where)
+	case 1:
+	default:
+		var lines []string
+		for _, c := range candidates {
+			lines = append(lines, fmt.Sprint(fset.Position(c.Pos()).Line))
+		}
+		return nil, 0, &Error{Op: op, Msg: fmt.Sprintf("%d locals named %q in %s (declared on lines %s); pass line to choose one", len(candidates), oldName, funcName(fd), strings.Join(lines, ", "))}
+	}
+	obj := candidates[0]
+
+	var idents []*ast.Ident
+	for id, o := range info.Defs {
+		if o == obj {
+			idents = append(idents, id)
+		}
+	}
+	for id, o := range info.Uses {
+		if o == obj {
+			idents = append(idents, id)
+		}
+	}
+
+	// The new name must not collide with a name in the same scope, be
+	// shadowed at a use, or capture a use of an outer newName.
+	if scope := obj.Parent(); scope != nil {
+		if other := scope.Lookup(newName); other != nil {
+			return nil, 0, errorAt(op, src, fset.Position(other.Pos()).Line, "%q is already declared in the same scope", newName)
+		}
+		for _, id := range idents {
+			inner := scope.Innermost(id.Pos())
+			if inner == nil {
+				continue
+			}
+			if _, other := inner.LookupParent(newName, id.Pos()); other != nil && other.Parent() != types.Universe && scope.Contains(other.Pos()) {
+				return nil, 0, errorAt(op, src, fset.Position(id.Pos()).Line, "%q would be shadowed here by the declaration on line %d", newName, fset.Position(other.Pos()).Line)
+			}
+		}
+		for id, o := range info.Uses {
+			if id.Name == newName && id.Pos() > obj.Pos() && scope.Contains(id.Pos()) && o != nil && !scope.Contains(o.Pos()) {
+				return nil, 0, errorAt(op, src, fset.Position(id.Pos()).Line, "renaming would capture this use of %q", newName)
+			}
+		}
+	}
+
+	edits := make([]splice, 0, len(idents))
+	for _, id := range idents {
+		off := fset.Position(id.Pos()).Offset
+		edits = append(edits, splice{off, off + len(oldName), newName})
+	}
+	out, err := finish(op, applySplices(src, edits))
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, len(idents), nil
+}
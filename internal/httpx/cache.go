@@ -0,0 +1,111 @@
+package httpx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// entry is a cached response as stored on disk, one JSON file per request.
+type entry struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	FinalURL    string      `json:"final_url"`
+	StatusCode  int         `json:"status_code"`
+	Status      string      `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+	ContentType string      `json:"content_type"`
+	Stored      time.Time   `json:"stored"`
+}
+
+// cacheKey identifies a request by method, URL and headers. Only GET and
+// HEAD requests without a body are cached.
+func cacheKey(req *http.Request) (string, bool) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead && req.Method != "" {
+		return "", false
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		return "", false
+	}
+	h := sha256.New()
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	h.Write([]byte(method + " " + req.URL.String() + "\n"))
+	names := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		h.Write([]byte(k + ": " + strings.Join(req.Header[k], ", ") + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func (c *Client) cachePath(key string) string {
+	return filepath.Join(c.cfg.CacheDir, key+".json")
+}
+
+// load returns the entry for key if it exists and, with a non-zero ttl, is
+// younger than ttl.
+func (c *Client) load(key string, ttl time.Duration) (*Response, bool) {
+	b, err := os.ReadFile(c.cachePath(key))
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if json.Unmarshal(b, &e) != nil {
+		return nil, false
+	}
+	if ttl > 0 && time.Since(e.Stored) > ttl {
+		return nil, false
+	}
+	return &Response{
+		URL:         e.FinalURL,
+		StatusCode:  e.StatusCode,
+		Status:      e.Status,
+		Header:      e.Header,
+		Body:        e.Body,
+		ContentType: e.ContentType,
+		Cached:      true,
+	}, true
+}
+
+// store writes r under key. Failures only cost a later cache miss.
+func (c *Client) store(key string, req *http.Request, r *Response) {
+	b, err := json.Marshal(entry{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		FinalURL:    r.URL,
+		StatusCode:  r.StatusCode,
+		Status:      r.Status,
+		Header:      r.Header,
+		Body:        r.Body,
+		ContentType: r.ContentType,
+		Stored:      time.Now(),
+	})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.cfg.CacheDir, 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.cfg.CacheDir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(b)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), c.cachePath(key)) != nil {
+		os.Remove(tmp.Name())
+	}
+}
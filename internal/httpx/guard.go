@@ -0,0 +1,102 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// BlockedError reports a request refused by the domain lists or the
+// private address check.
+type BlockedError struct {
+	Target string // the URL or, at dial time, the resolved address
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("request to %s blocked: %s", e.Target, e.Reason)
+}
+
+// check vets a URL before it is requested or redirected to.
+func (c *Client) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &BlockedError{Target: u.String(), Reason: "only http and https URLs are supported"}
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return &BlockedError{Target: u.String(), Reason: "no host"}
+	}
+	for _, d := range c.cfg.DenyDomains {
+		if matchDomain(host, d) {
+			return &BlockedError{Target: u.String(), Reason: "domain " + host + " is denied by network.deny_domains"}
+		}
+	}
+	if len(c.cfg.AllowDomains) > 0 {
+		allowed := false
+		for _, d := range c.cfg.AllowDomains {
+			if matchDomain(host, d) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &BlockedError{Target: u.String(), Reason: "domain " + host + " is not in network.allow_domains"}
+		}
+	}
+	if c.cfg.AllowPrivate {
+		return nil
+	}
+	// Names are checked again once resolved; catching the obvious ones here
+	// also covers requests sent through a proxy, which does the resolving.
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &BlockedError{Target: u.String(), Reason: privateReason}
+	}
+	if ip := net.ParseIP(host); ip != nil && privateIP(ip) {
+		return &BlockedError{Target: u.String(), Reason: privateReason}
+	}
+	return nil
+}
+
+const privateReason = "loopback, private and link-local addresses are not allowed (set network.allow_private to permit them)"
+
+// matchDomain reports whether host is pattern or, for "*.example.com", is
+// example.com or one of its subdomains.
+func matchDomain(host, pattern string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(pattern), "."))
+	if rest, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == rest || strings.HasSuffix(host, "."+rest)
+	}
+	return host == pattern
+}
+
+// cgnat is the shared address space of RFC 6598, internal to carriers.
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsUnspecified() || cgnat.Contains(ip)
+}
+
+// dial connects to addr, refusing private addresses after resolution
+// unless addr is a proxy or they are allowed.
+func (c *Client) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if _, proxy := c.proxies.Load(addr); !proxy && !c.cfg.AllowPrivate {
+		d.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip != nil && privateIP(ip) {
+				return &BlockedError{Target: addr + " (" + host + ")", Reason: privateReason}
+			}
+			return nil
+		}
+	}
+	return d.DialContext(ctx, network, addr)
+}
@@ -0,0 +1,343 @@
+// Package httpx is the HTTP client shared by the network tools.
+//
+// Every request and every redirect it follows is checked against a domain
+// allow/deny list, and connections to loopback, private and link-local
+// addresses are refused unless allowed. The address check runs on the
+// resolved IP at dial time, so a public name that resolves to an internal
+// address is refused too. Redirects and response bodies are capped, missing
+// content types are sniffed, proxies come from the config or the
+// environment, and GET responses can be cached on disk or replayed offline.
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/config"
+	"github.com/marcodenic/agentry/internal/sandbox"
+)
+
+// CacheMode selects how the response cache is used.
+type CacheMode string
+
+const (
+	// CacheOff never reads or writes the cache.
+	CacheOff CacheMode = "off"
+	// CacheOn serves fresh entries and stores new responses.
+	CacheOn CacheMode = "on"
+	// CacheRecord always goes to the network and stores the responses, to
+	// build a replay set for CacheOffline.
+	CacheRecord CacheMode = "record"
+	// CacheOffline serves only from the cache, whatever the entries' age,
+	// and fails requests it has no entry for.
+	CacheOffline CacheMode = "offline"
+)
+
+const (
+	defaultMaxRedirects = 5
+	defaultMaxBody      = 10 << 20
+	defaultTimeout      = 60 * time.Second
+	defaultTTL          = time.Hour
+)
+
+// Config describes a Client. Zero fields take the defaults: 5 redirects,
+// 10 MiB bodies, a 60 second timeout, the cache off and, when it is on, a
+// one hour TTL under the user cache directory.
+type Config struct {
+	AllowDomains []string
+	DenyDomains  []string
+	AllowPrivate bool
+	Proxy        string
+	MaxRedirects int
+	MaxBody      int64
+	Timeout      time.Duration
+	CacheMode    CacheMode
+	CacheDir     string
+	CacheTTL     time.Duration
+}
+
+// FromConfig converts the network section of the config file, then applies
+// the environment overrides: AGENTRY_HTTP_CACHE (a cache mode),
+// AGENTRY_HTTP_CACHE_DIR and AGENTRY_HTTP_ALLOW_PRIVATE.
+func FromConfig(n config.Network) (Config, error) {
+	c := Config{
+		AllowDomains: n.AllowDomains,
+		DenyDomains:  n.DenyDomains,
+		AllowPrivate: n.AllowPrivate,
+		Proxy:        n.Proxy,
+		MaxRedirects: n.MaxRedirects,
+		CacheMode:    CacheMode(strings.ToLower(n.Cache.Mode)),
+		CacheDir:     n.Cache.Dir,
+	}
+	var err error
+	if c.MaxBody, err = sandbox.ParseSize(n.MaxBody); err != nil {
+		return Config{}, fmt.Errorf("network.max_body: %w", err)
+	}
+	if c.Timeout, err = parseDuration(n.Timeout); err != nil {
+		return Config{}, fmt.Errorf("network.timeout: %w", err)
+	}
+	if c.CacheTTL, err = parseDuration(n.Cache.TTL); err != nil {
+		return Config{}, fmt.Errorf("network.cache.ttl: %w", err)
+	}
+	applyEnv(&c)
+	return c, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func applyEnv(c *Config) {
+	if v := os.Getenv("AGENTRY_HTTP_CACHE"); v != "" {
+		c.CacheMode = CacheMode(strings.ToLower(v))
+	}
+	if v := os.Getenv("AGENTRY_HTTP_CACHE_DIR"); v != "" {
+		c.CacheDir = v
+	}
+	switch strings.ToLower(os.Getenv("AGENTRY_HTTP_ALLOW_PRIVATE")) {
+	case "1", "true", "yes":
+		c.AllowPrivate = true
+	}
+}
+
+// Client performs guarded requests. It is safe for concurrent use.
+type Client struct {
+	cfg      Config
+	proxyURL *url.URL
+	hc       *http.Client
+	proxies  sync.Map // "host:port" of proxies in use, exempt from the address check
+}
+
+// New returns a Client for cfg.
+func New(cfg Config) (*Client, error) {
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = defaultMaxRedirects
+	}
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = defaultMaxBody
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = defaultTTL
+	}
+	switch cfg.CacheMode {
+	case "":
+		cfg.CacheMode = CacheOff
+	case CacheOff, CacheOn, CacheRecord, CacheOffline:
+	default:
+		return nil, fmt.Errorf("network cache mode: want off, on, record or offline, got %q", cfg.CacheMode)
+	}
+	if cfg.CacheDir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			dir = os.TempDir()
+		}
+		cfg.CacheDir = filepath.Join(dir, "agentry", "http")
+	}
+
+	c := &Client{cfg: cfg}
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("network proxy: invalid URL %q", cfg.Proxy)
+		}
+		c.proxyURL = u
+	}
+	c.hc = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 c.proxy,
+			DialContext:           c.dial,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			return c.check(req.URL)
+		},
+	}
+	return c, nil
+}
+
+// Config returns the client's configuration with defaults filled in.
+func (c *Client) Config() Config { return c.cfg }
+
+var (
+	stdMu sync.Mutex
+	std   *Client
+)
+
+// Default returns the client the network tools use. Until SetDefault is
+// called it is built from the defaults and the environment overrides.
+func Default() *Client {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	if std == nil {
+		var cfg Config
+		applyEnv(&cfg)
+		c, err := New(cfg)
+		if err != nil {
+			c, _ = New(Config{AllowPrivate: cfg.AllowPrivate})
+		}
+		std = c
+	}
+	return std
+}
+
+// SetDefault replaces the client the network tools use.
+func SetDefault(c *Client) {
+	stdMu.Lock()
+	std = c
+	stdMu.Unlock()
+}
+
+// Response is a response read in full, up to the body cap.
+type Response struct {
+	URL         string // after redirects
+	StatusCode  int
+	Status      string
+	Header      http.Header
+	Body        []byte
+	ContentType string // the Content-Type header, or sniffed from Body
+	Truncated   bool   // Body was cut at the size cap
+	Cached      bool   // served from the response cache
+}
+
+// OK reports a 2xx status.
+func (r *Response) OK() bool { return r.StatusCode >= 200 && r.StatusCode < 300 }
+
+// Get fetches rawURL with the given request headers.
+func (c *Client) Get(ctx context.Context, rawURL string, header map[string]string) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return c.Do(req)
+}
+
+// Do sends req and reads the response body up to the cap. GET and HEAD
+// requests without a body go through the cache according to its mode.
+func (c *Client) Do(req *http.Request) (*Response, error) {
+	if err := c.check(req.URL); err != nil {
+		return nil, err
+	}
+	key, cacheable := cacheKey(req)
+	switch c.cfg.CacheMode {
+	case CacheOn:
+		if cacheable {
+			if r, ok := c.load(key, c.cfg.CacheTTL); ok {
+				return r, nil
+			}
+		}
+	case CacheOffline:
+		if cacheable {
+			if r, ok := c.load(key, 0); ok {
+				return r, nil
+			}
+		}
+		return nil, fmt.Errorf("offline: no recorded response for %s %s", req.Method, req.URL)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), c.cfg.Timeout)
+	defer cancel()
+	resp, err := c.hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.cfg.MaxBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	r := &Response{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	}
+	if int64(len(body)) > c.cfg.MaxBody {
+		r.Body, r.Truncated = body[:c.cfg.MaxBody], true
+	}
+	r.ContentType = sniff(resp.Header.Get("Content-Type"), r.Body)
+	if cacheable && !r.Truncated && r.StatusCode < 400 && (c.cfg.CacheMode == CacheOn || c.cfg.CacheMode == CacheRecord) {
+		c.store(key, req, r)
+	}
+	return r, nil
+}
+
+// Stream sends req and returns the response with its body unread, for
+// downloads too large to buffer. Neither the body cap nor the cache apply;
+// the caller closes the body.
+func (c *Client) Stream(req *http.Request) (*http.Response, error) {
+	if err := c.check(req.URL); err != nil {
+		return nil, err
+	}
+	if c.cfg.CacheMode == CacheOffline {
+		return nil, fmt.Errorf("offline: %s %s cannot be streamed from the cache", req.Method, req.URL)
+	}
+	return c.hc.Do(req)
+}
+
+// sniff returns the declared content type, or one detected from the body
+// when none, or only the generic binary type, was declared.
+func sniff(declared string, body []byte) string {
+	if declared != "" && !strings.HasPrefix(declared, "application/octet-stream") {
+		return declared
+	}
+	if len(body) == 0 {
+		return declared
+	}
+	return http.DetectContentType(body)
+}
+
+// proxy picks the proxy for req and remembers its address so dial lets the
+// connection through even when the proxy runs on this machine.
+func (c *Client) proxy(req *http.Request) (*url.URL, error) {
+	u := c.proxyURL
+	if u == nil {
+		var err error
+		if u, err = http.ProxyFromEnvironment(req); err != nil {
+			return nil, err
+		}
+	}
+	if u != nil {
+		c.proxies.Store(hostPort(u), true)
+	}
+	return u, nil
+}
+
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
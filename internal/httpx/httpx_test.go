@@ -0,0 +1,93 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func get(t *testing.T, c *Client, url string) (*Response, error) {
+	t.Helper()
+	return c.Get(context.Background(), url, nil)
+}
+
+func TestGuards(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, "http://denied.example/", http.StatusFound)
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("<html><body>" + strings.Repeat("x", 100) + "</body></html>"))
+		}
+	}))
+	defer srv.Close()
+
+	strict, _ := New(Config{})
+	var blocked *BlockedError
+	for _, u := range []string{srv.URL, "http://localhost/", "http://[::1]/", "http://169.254.169.254/latest/meta-data"} {
+		if _, err := get(t, strict, u); !errors.As(err, &blocked) {
+			t.Fatalf("%s: expected a blocked request, got %v", u, err)
+		}
+	}
+
+	c, _ := New(Config{AllowPrivate: true, MaxRedirects: 2, MaxBody: 64, DenyDomains: []string{"*.example"}})
+	r, err := get(t, c, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Truncated || len(r.Body) != 64 || !strings.HasPrefix(r.ContentType, "text/html") {
+		t.Fatalf("truncated=%v len=%d type=%q", r.Truncated, len(r.Body), r.ContentType)
+	}
+	if _, err := get(t, c, srv.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "stopped after 2 redirects") {
+		t.Fatalf("redirect loop: %v", err)
+	}
+	if _, err := get(t, c, srv.URL+"/away"); !errors.As(err, &blocked) || !strings.Contains(err.Error(), "deny_domains") {
+		t.Fatalf("redirect to a denied domain: %v", err)
+	}
+
+	only, _ := New(Config{AllowPrivate: true, AllowDomains: []string{"*.example.com"}})
+	if _, err := get(t, only, srv.URL); !errors.As(err, &blocked) {
+		t.Fatalf("host outside allow_domains: %v", err)
+	}
+	if !matchDomain("api.example.com", "*.example.com") || !matchDomain("example.com", "*.example.com") || matchDomain("badexample.com", "*.example.com") {
+		t.Fatal("wildcard domain matching")
+	}
+}
+
+func TestCacheAndOfflineReplay(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"n":1}`))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	rec, _ := New(Config{AllowPrivate: true, CacheMode: CacheRecord, CacheDir: dir})
+	if _, err := get(t, rec, srv.URL+"/a"); err != nil {
+		t.Fatal(err)
+	}
+	on, _ := New(Config{AllowPrivate: true, CacheMode: CacheOn, CacheDir: dir})
+	r, err := get(t, on, srv.URL+"/a")
+	if err != nil || !r.Cached || hits.Load() != 1 {
+		t.Fatalf("cache hit: %v cached=%v hits=%d", err, r != nil && r.Cached, hits.Load())
+	}
+
+	srv.Close()
+	off, _ := New(Config{AllowPrivate: true, CacheMode: CacheOffline, CacheDir: dir})
+	r, err = get(t, off, srv.URL+"/a")
+	if err != nil || string(r.Body) != `{"n":1}` || r.ContentType != "application/json" || r.StatusCode != 200 {
+		t.Fatalf("replay: %v %+v", err, r)
+	}
+	if _, err := get(t, off, srv.URL+"/b"); err == nil || !strings.Contains(err.Error(), "offline") {
+		t.Fatalf("offline miss: %v", err)
+	}
+}
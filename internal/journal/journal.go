@@ -0,0 +1,508 @@
+// Package journal records the file writes made by agent tools so they can
+// be undone precisely. Each entry keeps the file's pre-image, the agent
+// that made the change and the tool call it came from. A journal holds one
+// session: a JSONL file of entries next to a content-addressed store of
+// pre-images.
+package journal
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one journal record: a write, or an undo of earlier writes.
+type Entry struct {
+	Seq     int         `json:"seq"`
+	Time    time.Time   `json:"time"`
+	Op      string      `json:"op"` // "write" or "undo"
+	Path    string      `json:"path,omitempty"`
+	Tool    string      `json:"tool,omitempty"`
+	AgentID string      `json:"agent_id,omitempty"`
+	Agent   string      `json:"agent,omitempty"`
+	CallID  string      `json:"call_id,omitempty"`
+	Existed bool        `json:"existed,omitempty"`
+	Mode    fs.FileMode `json:"mode,omitempty"`
+	Before  string      `json:"before,omitempty"` // blob holding the pre-image
+	After   string      `json:"after,omitempty"`  // hash of what was written; empty if the file was removed
+	Undoes  []int       `json:"undoes,omitempty"` // seqs restored by an undo
+	Undone  bool        `json:"undone,omitempty"` // set on load for writes an undo restored
+}
+
+// ChangeID groups the writes of one tool call; writes made outside a tool
+// call are changes of their own.
+func (e Entry) ChangeID() string {
+	if e.CallID != "" {
+		return e.CallID
+	}
+	return fmt.Sprintf("#%d", e.Seq)
+}
+
+// AgentLabel names the agent for display.
+func (e Entry) AgentLabel() string {
+	switch {
+	case e.Agent != "":
+		return e.Agent
+	case len(e.AgentID) > 8:
+		return e.AgentID[:8]
+	case e.AgentID != "":
+		return e.AgentID
+	}
+	return "user"
+}
+
+// Change is a file's state captured before a write, plus who is writing.
+type Change struct {
+	Path    string
+	Tool    string
+	AgentID string
+	Agent   string
+	CallID  string
+	Existed bool
+	Before  []byte
+	Mode    fs.FileMode
+}
+
+// Snapshot captures the current content of path before it is written.
+func Snapshot(path string) Change {
+	c := Change{Path: path}
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		if b, err := os.ReadFile(path); err == nil {
+			c.Existed, c.Before, c.Mode = true, b, info.Mode().Perm()
+		}
+	}
+	return c
+}
+
+// Journal is the record of one session.
+type Journal struct {
+	mu      sync.Mutex
+	dir     string
+	session string
+	entries []Entry
+}
+
+// Open loads session from dir, or starts it if it has no entries yet.
+func Open(dir, session string) (*Journal, error) {
+	if session == "" || session != filepath.Base(session) || strings.HasPrefix(session, ".") {
+		return nil, fmt.Errorf("invalid journal session %q", session)
+	}
+	j := &Journal{dir: dir, session: session}
+	f, err := os.Open(j.file())
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		j.entries = append(j.entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	j.markUndone()
+	return j, nil
+}
+
+// Session returns the session ID.
+func (j *Journal) Session() string { return j.session }
+
+func (j *Journal) file() string { return filepath.Join(j.dir, j.session+".jsonl") }
+
+func (j *Journal) markUndone() {
+	undone := map[int]bool{}
+	for _, e := range j.entries {
+		for _, s := range e.Undoes {
+			undone[s] = true
+		}
+	}
+	for i := range j.entries {
+		j.entries[i].Undone = undone[j.entries[i].Seq]
+	}
+}
+
+func (j *Journal) nextSeq() int {
+	if n := len(j.entries); n > 0 {
+		return j.entries[n-1].Seq + 1
+	}
+	return 1
+}
+
+func (j *Journal) append(e Entry) error {
+	if err := os.MkdirAll(j.dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.file(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	j.entries = append(j.entries, e)
+	return nil
+}
+
+func hash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (j *Journal) blobPath(h string) string {
+	return filepath.Join(j.dir, "blobs", h[:2], h)
+}
+
+func (j *Journal) putBlob(b []byte) (string, error) {
+	h := hash(b)
+	p := j.blobPath(h)
+	if _, err := os.Stat(p); err == nil {
+		return h, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	if err := writeAtomic(p, b, 0o644); err != nil {
+		return "", err
+	}
+	return h, nil
+}
+
+func (j *Journal) blob(h string) ([]byte, error) {
+	b, err := os.ReadFile(j.blobPath(h))
+	if err != nil {
+		return nil, fmt.Errorf("pre-image %s: %w", h[:12], err)
+	}
+	if hash(b) != h {
+		return nil, fmt.Errorf("pre-image %s is corrupt", h[:12])
+	}
+	return b, nil
+}
+
+// currentHash is the hash of path's content, or "" if it does not exist.
+func currentHash(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return hash(b)
+}
+
+func writeAtomic(path string, b []byte, mode fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Record stores c's pre-image and appends an entry for the write, taking
+// the new content from disk.
+func (j *Journal) Record(c Change) (Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	e := Entry{
+		Seq: j.nextSeq(), Time: time.Now(), Op: "write", Path: c.Path, Tool: c.Tool,
+		AgentID: c.AgentID, Agent: c.Agent, CallID: c.CallID, Existed: c.Existed, Mode: c.Mode,
+		After: currentHash(c.Path),
+	}
+	if c.Existed {
+		h, err := j.putBlob(c.Before)
+		if err != nil {
+			return Entry{}, fmt.Errorf("journal: %w", err)
+		}
+		e.Before = h
+	}
+	if err := j.append(e); err != nil {
+		return Entry{}, fmt.Errorf("journal: %w", err)
+	}
+	return e, nil
+}
+
+// Entries returns the session's writes in order, undone ones included.
+func (j *Journal) Entries() []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]Entry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if e.Op == "write" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Selector picks the changes to undo. With no field set it selects the
+// most recent change.
+type Selector struct {
+	Last   int    // the last N changes (tool calls)
+	Agent  string // only changes by this agent name or ID prefix
+	CallID string // only this tool call's writes
+}
+
+func (s Selector) matches(e Entry) bool {
+	if s.Agent != "" && e.Agent != s.Agent && !(e.AgentID != "" && strings.HasPrefix(e.AgentID, s.Agent)) {
+		return false
+	}
+	return s.CallID == "" || e.CallID == s.CallID
+}
+
+// Select returns the pending writes sel picks, newest first.
+func (j *Journal) Select(sel Selector) []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.selectLocked(sel)
+}
+
+func (j *Journal) selectLocked(sel Selector) []Entry {
+	last := sel.Last
+	if last <= 0 && sel.Agent == "" && sel.CallID == "" {
+		last = 1
+	}
+	var out []Entry
+	changes := map[string]bool{}
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		e := j.entries[i]
+		if e.Op != "write" || e.Undone || !sel.matches(e) {
+			continue
+		}
+		id := e.ChangeID()
+		if !changes[id] {
+			if last > 0 && len(changes) == last {
+				continue
+			}
+			changes[id] = true
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Conflict is a file an undo would not restore cleanly.
+type Conflict struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ConflictError reports files changed since the writes being undone.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	parts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		parts[i] = c.Path + ": " + c.Reason
+	}
+	return "undo would discard later changes (use force to undo anyway): " + strings.Join(parts, "; ")
+}
+
+// Undo restores the files written by the changes sel picks to their state
+// before the earliest of those writes, and records the undo. A file that
+// was written again by another change, or edited outside the journal,
+// since then is a conflict; unless force is set nothing is restored when
+// there are conflicts. It returns the writes undone, newest first.
+func (j *Journal) Undo(sel Selector, force bool) ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	selected := j.selectLocked(sel)
+	if len(selected) == 0 {
+		return nil, errors.New("nothing to undo")
+	}
+	chosen := map[int]bool{}
+	byPath := map[string][]Entry{}
+	var paths []string
+	for _, e := range selected {
+		chosen[e.Seq] = true
+		if _, ok := byPath[e.Path]; !ok {
+			paths = append(paths, e.Path)
+		}
+		byPath[e.Path] = append(byPath[e.Path], e)
+	}
+	sort.Strings(paths)
+
+	var conflicts []Conflict
+	for _, p := range paths {
+		es := byPath[p]
+		oldest, newest := es[len(es)-1], es[0]
+		later := false
+		for _, e := range j.entries {
+			if e.Op == "write" && !e.Undone && e.Path == p && e.Seq > oldest.Seq && !chosen[e.Seq] {
+				conflicts = append(conflicts, Conflict{p, fmt.Sprintf("changed again by %s (%s) at %s", e.AgentLabel(), e.Tool, e.Time.Format("15:04:05"))})
+				later = true
+				break
+			}
+		}
+		if !later && currentHash(p) != newest.After {
+			conflicts = append(conflicts, Conflict{p, "modified outside the journal since it was written"})
+		}
+	}
+	if len(conflicts) > 0 && !force {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+
+	var undone []Entry
+	var restoreErr error
+	for _, p := range paths {
+		es := byPath[p]
+		if err := j.restore(es[len(es)-1]); err != nil {
+			restoreErr = fmt.Errorf("restore %s: %w", p, err)
+			break
+		}
+		undone = append(undone, es...)
+	}
+	if len(undone) > 0 {
+		sort.Slice(undone, func(a, b int) bool { return undone[a].Seq > undone[b].Seq })
+		u := Entry{Seq: j.nextSeq(), Time: time.Now(), Op: "undo"}
+		for _, e := range undone {
+			u.Undoes = append(u.Undoes, e.Seq)
+		}
+		if err := j.append(u); err != nil && restoreErr == nil {
+			restoreErr = fmt.Errorf("journal: %w", err)
+		}
+		j.markUndone()
+		for i := range undone {
+			undone[i].Undone = true
+		}
+	}
+	return undone, restoreErr
+}
+
+// restore puts e's pre-image back, or removes the file e created.
+func (j *Journal) restore(e Entry) error {
+	if !e.Existed {
+		if err := os.Remove(e.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	b, err := j.blob(e.Before)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(e.Path), 0o755); err != nil {
+		return err
+	}
+	mode := e.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	return writeAtomic(e.Path, b, mode)
+}
+
+// Session describes a journal on disk.
+type Session struct {
+	ID       string    `json:"id"`
+	Modified time.Time `json:"modified"`
+}
+
+// Sessions lists the journals in dir, most recently written first.
+func Sessions(dir string) ([]Session, error) {
+	des, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Session
+	for _, de := range des {
+		id, ok := strings.CutSuffix(de.Name(), ".jsonl")
+		if !ok || de.IsDir() {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, Session{ID: id, Modified: info.ModTime()})
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Modified.After(out[b].Modified) })
+	return out, nil
+}
+
+// Dir is where journals are kept: AGENTRY_JOURNAL_DIR, or agentry/journal
+// under the user cache directory.
+func Dir() string {
+	if dir := os.Getenv("AGENTRY_JOURNAL_DIR"); dir != "" {
+		return dir
+	}
+	if cache, err := os.UserCacheDir(); err == nil && cache != "" {
+		return filepath.Join(cache, "agentry", "journal")
+	}
+	return filepath.Join(os.TempDir(), "agentry-journal")
+}
+
+// NewSessionID returns a sortable, unique session ID.
+func NewSessionID() string {
+	var b [3]byte
+	_, _ = rand.Read(b[:])
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(b[:])
+}
+
+var (
+	defaultMu      sync.Mutex
+	defaultJournal *Journal
+)
+
+// Default returns the journal for this process, starting a new session in
+// Dir on first use.
+func Default() *Journal {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultJournal == nil {
+		defaultJournal = &Journal{dir: Dir(), session: NewSessionID()}
+	}
+	return defaultJournal
+}
+
+// SetDefault replaces the process journal, for example to continue a
+// session.
+func SetDefault(j *Journal) {
+	defaultMu.Lock()
+	defaultJournal = j
+	defaultMu.Unlock()
+}
@@ -0,0 +1,137 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// write changes path to content and journals it as agent's call.
+func write(t *testing.T, j *Journal, path, content, agent, call string) {
+	t.Helper()
+	c := Snapshot(path)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c.Tool, c.AgentID, c.Agent, c.CallID = "write", agent+"-id", agent, call
+	if _, err := j.Record(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "<missing>"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestUndoRestoresPreImages(t *testing.T) {
+	dir, work := t.TempDir(), t.TempDir()
+	j, err := Open(dir, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := filepath.Join(work, "a.txt"), filepath.Join(work, "b.txt")
+	if err := os.WriteFile(a, []byte("original"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	write(t, j, a, "coder 1", "coder", "c1")
+	write(t, j, b, "new file", "coder", "c1")
+	write(t, j, a, "coder 2", "coder", "c2")
+
+	// The last change is c2 alone.
+	undone, err := j.Undo(Selector{}, false)
+	if err != nil || len(undone) != 1 || read(t, a) != "coder 1" {
+		t.Fatalf("undo last: %v %+v %q", err, undone, read(t, a))
+	}
+	// c1 wrote both files; a goes back to its original content and mode,
+	// and b, which c1 created, is removed.
+	if undone, err = j.Undo(Selector{Last: 1}, false); err != nil || len(undone) != 2 {
+		t.Fatalf("undo c1: %v %+v", err, undone)
+	}
+	if read(t, a) != "original" || read(t, b) != "<missing>" {
+		t.Fatalf("after undo: a=%q b=%q", read(t, a), read(t, b))
+	}
+	if info, _ := os.Stat(a); info.Mode().Perm() != 0o600 {
+		t.Fatalf("mode not restored: %v", info.Mode())
+	}
+	if _, err := j.Undo(Selector{}, false); err == nil {
+		t.Fatal("undo with nothing left succeeded")
+	}
+
+	// Reopening the session keeps the undone marks.
+	j2, err := Open(dir, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	es := j2.Entries()
+	if len(es) != 3 || !es[0].Undone || !es[2].Undone {
+		t.Fatalf("reloaded entries: %+v", es)
+	}
+}
+
+func TestUndoByAgentAndConflicts(t *testing.T) {
+	work := t.TempDir()
+	j, err := Open(t.TempDir(), "s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := filepath.Join(work, "a.go"), filepath.Join(work, "b.go")
+	write(t, j, a, "coder a", "coder", "c1")
+	write(t, j, b, "coder b", "coder", "c2")
+	write(t, j, a, "tester a", "tester", "t1")
+
+	// Undoing the coder would discard the tester's later write to a.
+	_, err = j.Undo(Selector{Agent: "coder"}, false)
+	var ce *ConflictError
+	if !errors.As(err, &ce) || len(ce.Conflicts) != 1 || ce.Conflicts[0].Path != a {
+		t.Fatalf("expected a conflict on a: %v", err)
+	}
+	if read(t, b) != "coder b" {
+		t.Fatal("conflicting undo restored files")
+	}
+
+	// Edits outside the journal are conflicts too.
+	if err := os.WriteFile(b, []byte("by hand"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Undo(Selector{Agent: "coder", CallID: "c2"}, false); !errors.As(err, &ce) {
+		t.Fatalf("expected a conflict on b: %v", err)
+	}
+
+	undone, err := j.Undo(Selector{Agent: "tester"}, false)
+	if err != nil || len(undone) != 1 || read(t, a) != "coder a" {
+		t.Fatalf("undo tester: %v %q", err, read(t, a))
+	}
+	if _, err := j.Undo(Selector{Agent: "coder"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if read(t, a) != "<missing>" || read(t, b) != "<missing>" {
+		t.Fatalf("forced undo: a=%q b=%q", read(t, a), read(t, b))
+	}
+}
+
+func TestSessions(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"one", "two"} {
+		j, err := Open(dir, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(t, j, filepath.Join(t.TempDir(), "f"), id, "a", "")
+	}
+	ss, err := Sessions(dir)
+	if err != nil || len(ss) != 2 {
+		t.Fatalf("sessions: %v %+v", err, ss)
+	}
+	if _, err := Open(dir, "../escape"); err == nil {
+		t.Fatal("session path escaping dir accepted")
+	}
+}
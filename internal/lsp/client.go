@@ -0,0 +1,724 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server describes a language server and the files it handles.
+type Server struct {
+	Language   string
+	Command    []string
+	Extensions []string
+}
+
+// Servers are the language servers agentry can start, in order of
+// preference for each file extension.
+var Servers = []Server{
+	{Language: "go", Command: []string{"gopls"}, Extensions: []string{".go"}},
+	{Language: "typescript", Command: []string{"typescript-language-server", "--stdio"}, Extensions: []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"}},
+	{Language: "python", Command: []string{"pyright-langserver", "--stdio"}, Extensions: []string{".py"}},
+	{Language: "python", Command: []string{"pylsp"}, Extensions: []string{".py"}},
+	{Language: "rust", Command: []string{"rust-analyzer"}, Extensions: []string{".rs"}},
+}
+
+var languageIDs = map[string]string{
+	".go": "go", ".ts": "typescript", ".tsx": "typescriptreact", ".js": "javascript",
+	".jsx": "javascriptreact", ".mjs": "javascript", ".cjs": "javascript", ".py": "python", ".rs": "rust",
+}
+
+// Installed reports whether the server's executable is on PATH.
+func (s Server) Installed() bool {
+	_, err := exec.LookPath(s.Command[0])
+	return err == nil
+}
+
+// ServerFor returns the first installed server that handles path.
+func ServerFor(path string) (Server, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	var names []string
+	for _, s := range Servers {
+		for _, e := range s.Extensions {
+			if e != ext {
+				continue
+			}
+			if s.Installed() {
+				return s, nil
+			}
+			names = append(names, s.Command[0])
+		}
+	}
+	if len(names) == 0 {
+		return Server{}, fmt.Errorf("no language server handles %q files", ext)
+	}
+	return Server{}, fmt.Errorf("no language server for %q files is installed (looked for %s)", ext, strings.Join(names, ", "))
+}
+
+const (
+	shutdownTimeout = 2 * time.Second
+	// diagnosticsSettle is how long the server must stay quiet after the
+	// last expected publishDiagnostics, since some servers publish a fast
+	// syntactic pass before the full one.
+	diagnosticsSettle = 300 * time.Millisecond
+)
+
+// Client is a connection to one language server for one workspace root.
+// Documents are synced on demand: before each request, every file the
+// client has opened is compared with its contents on disk and the server
+// is sent the new text if it changed, so edits made by other tools are
+// seen without explicit notifications.
+type Client struct {
+	Server Server
+	Root   string
+
+	conn *Conn
+	stop func() // ends the server process, if there is one
+
+	syncMu sync.Mutex // serialises document sync
+	mu     sync.Mutex
+	docs   map[string]*document
+	diags  map[string][]protoDiagnostic
+	seq    int
+	synced map[string]int // path -> seq of last didOpen/didChange
+	pub    map[string]int // path -> seq of last publishDiagnostics
+	notify chan struct{}  // closed and replaced on each publish
+}
+
+type document struct {
+	version int
+	text    string
+}
+
+// Start launches cmd as the language server for root and initializes it.
+// cmd must not have been started and must not have Stdin or Stdout set.
+func Start(ctx context.Context, srv Server, root string, cmd *exec.Cmd) (*Client, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &cappedBuffer{max: 2 << 10}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", srv.Command[0], err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	stop := func() {
+		select {
+		case <-exited:
+		case <-time.After(shutdownTimeout):
+			_ = cmd.Process.Kill()
+			<-exited
+		}
+	}
+	c, err := NewClient(ctx, srv, root, pipe{stdout, stdin}, stop)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		<-exited
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w\n%s", err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", srv.Command[0], err)
+	}
+	return c, nil
+}
+
+// NewClient runs the initialize handshake over rwc. stop, if not nil, is
+// called by Close after the stream is closed.
+func NewClient(ctx context.Context, srv Server, root string, rwc io.ReadWriteCloser, stop func()) (*Client, error) {
+	c := &Client{
+		Server: srv,
+		Root:   root,
+		stop:   stop,
+		docs:   map[string]*document{},
+		diags:  map[string][]protoDiagnostic{},
+		synced: map[string]int{},
+		pub:    map[string]int{},
+		notify: make(chan struct{}),
+	}
+	c.conn = NewConn(rwc, c.handle)
+	params := map[string]any{
+		"processId":        os.Getpid(),
+		"clientInfo":       map[string]any{"name": "agentry"},
+		"rootUri":          PathToURI(root),
+		"rootPath":         root,
+		"workspaceFolders": c.folders(),
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": false},
+				"definition":         map[string]any{"linkSupport": true},
+				"references":         map[string]any{},
+				"hover":              map[string]any{"contentFormat": []string{"markdown", "plaintext"}},
+				"documentSymbol":     map[string]any{"hierarchicalDocumentSymbolSupport": true},
+				"rename":             map[string]any{"prepareSupport": false},
+				"publishDiagnostics": map[string]any{"versionSupport": true},
+			},
+			"workspace": map[string]any{
+				"workspaceEdit":         map[string]any{"documentChanges": true},
+				"symbol":                map[string]any{},
+				"configuration":         true,
+				"workspaceFolders":      true,
+				"didChangeWatchedFiles": map[string]any{"dynamicRegistration": false},
+			},
+		},
+	}
+	if err := c.conn.Call(ctx, "initialize", params, nil); err != nil {
+		c.conn.Close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	if err := c.conn.Notify("initialized", map[string]any{}); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Alive reports whether the server connection is still open.
+func (c *Client) Alive() bool {
+	select {
+	case <-c.conn.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// Close asks the server to shut down and stops it.
+func (c *Client) Close() error {
+	if c.Alive() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if c.conn.Call(ctx, "shutdown", nil, nil) == nil {
+			_ = c.conn.Notify("exit", nil)
+		}
+		cancel()
+	}
+	err := c.conn.Close()
+	if c.stop != nil {
+		c.stop()
+	}
+	return err
+}
+
+func (c *Client) folders() []map[string]any {
+	return []map[string]any{{"uri": PathToURI(c.Root), "name": filepath.Base(c.Root)}}
+}
+
+// handle answers the server's requests and records its diagnostics.
+func (c *Client) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var p struct {
+			URI         string            `json:"uri"`
+			Diagnostics []protoDiagnostic `json:"diagnostics"`
+		}
+		if json.Unmarshal(params, &p) != nil {
+			return nil, nil
+		}
+		path, err := URIToPath(p.URI)
+		if err != nil {
+			return nil, nil
+		}
+		c.mu.Lock()
+		c.diags[path] = p.Diagnostics
+		c.seq++
+		c.pub[path] = c.seq
+		close(c.notify)
+		c.notify = make(chan struct{})
+		c.mu.Unlock()
+		return nil, nil
+	case "workspace/configuration":
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(params, &p)
+		return make([]any, len(p.Items)), nil
+	case "workspace/workspaceFolders":
+		return c.folders(), nil
+	case "workspace/applyEdit":
+		return map[string]any{"applied": false, "failureReason": "edits are applied by the client's tools"}, nil
+	case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability", "window/showMessageRequest":
+		return nil, nil
+	}
+	return nil, &RPCError{Code: codeMethodNotFound, Message: "method not supported: " + method}
+}
+
+// Files supplies contents that differ from the disk, such as a dry run's
+// proposed changes.
+type Files interface {
+	ReadFile(path string) ([]byte, error)
+	// Paths lists the files whose contents differ.
+	Paths() []string
+}
+
+type filesKey struct{}
+
+// WithFiles makes requests made with ctx show the server the contents in
+// files instead of the disk. Every file of the server's language in files is
+// opened first, so edits and locations in files the request did not name
+// match those contents too.
+func WithFiles(ctx context.Context, files Files) context.Context {
+	return context.WithValue(ctx, filesKey{}, files)
+}
+
+// Sync opens path on the server, or sends its new contents if it changed
+// since it was last sent, and refreshes every other open document.
+func (c *Client) Sync(ctx context.Context, path string) error {
+	read := os.ReadFile
+	files, _ := ctx.Value(filesKey{}).(Files)
+	if files != nil {
+		read = files.ReadFile
+	}
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	if err := c.syncLocked(path, read); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	var open []string
+	for p := range c.docs {
+		if p != path {
+			open = append(open, p)
+		}
+	}
+	c.mu.Unlock()
+	if files != nil {
+		for _, p := range files.Paths() {
+			if p != path && slices.Contains(c.Server.Extensions, strings.ToLower(filepath.Ext(p))) && !slices.Contains(open, p) {
+				open = append(open, p)
+			}
+		}
+	}
+	for _, p := range open {
+		if err := c.syncLocked(p, read); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) syncLocked(path string, read func(string) ([]byte, error)) error {
+	b, err := read(path)
+	c.mu.Lock()
+	doc := c.docs[path]
+	if err != nil {
+		if doc != nil && errors.Is(err, os.ErrNotExist) {
+			delete(c.docs, path)
+			c.mu.Unlock()
+			_ = c.conn.Notify("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": PathToURI(path)}})
+			return err
+		}
+		c.mu.Unlock()
+		return err
+	}
+	text := string(b)
+	if doc != nil && doc.text == text {
+		c.mu.Unlock()
+		return nil
+	}
+	method := "textDocument/didChange"
+	var params map[string]any
+	if doc == nil {
+		doc = &document{}
+		c.docs[path] = doc
+		method = "textDocument/didOpen"
+	}
+	doc.version++
+	doc.text = text
+	c.seq++
+	c.synced[path] = c.seq
+	if method == "textDocument/didOpen" {
+		params = map[string]any{"textDocument": map[string]any{
+			"uri": PathToURI(path), "languageId": languageIDs[strings.ToLower(filepath.Ext(path))], "version": doc.version, "text": text,
+		}}
+	} else {
+		params = map[string]any{
+			"textDocument":   map[string]any{"uri": PathToURI(path), "version": doc.version},
+			"contentChanges": []map[string]any{{"text": text}},
+		}
+	}
+	c.mu.Unlock()
+	return c.conn.Notify(method, params)
+}
+
+// Changed tells the server that files were written. Open documents are
+// re-sent; for the rest the server is told to re-read them from disk.
+func (c *Client) Changed(paths []string) error {
+	var closed []map[string]any
+	for _, p := range paths {
+		if !slices.Contains(c.Server.Extensions, strings.ToLower(filepath.Ext(p))) {
+			continue
+		}
+		c.mu.Lock()
+		_, open := c.docs[p]
+		c.mu.Unlock()
+		if open {
+			c.syncMu.Lock()
+			err := c.syncLocked(p, os.ReadFile)
+			c.syncMu.Unlock()
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		closed = append(closed, map[string]any{"uri": PathToURI(p), "type": 2})
+	}
+	if len(closed) == 0 {
+		return nil
+	}
+	return c.conn.Notify("workspace/didChangeWatchedFiles", map[string]any{"changes": closed})
+}
+
+// text returns the contents of path as last sent to the server, or as on
+// disk if it is not open.
+func (c *Client) text(path string) string {
+	c.mu.Lock()
+	doc := c.docs[path]
+	c.mu.Unlock()
+	if doc != nil {
+		return doc.text
+	}
+	b, _ := os.ReadFile(path)
+	return string(b)
+}
+
+// position syncs path and converts a 1-based line and character column.
+func (c *Client) position(ctx context.Context, path string, line, col int) (map[string]any, error) {
+	if err := c.Sync(ctx, path); err != nil {
+		return nil, err
+	}
+	pos, err := PositionAt(c.text(path), line, col)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"textDocument": map[string]any{"uri": PathToURI(path)}, "position": pos}, nil
+}
+
+// locations converts protocol locations, reading each file once.
+func (c *Client) locations(locs []protoLocation) []Location {
+	texts := map[string]string{}
+	var out []Location
+	for _, l := range locs {
+		uri, r := l.URI, l.Range
+		if l.TargetURI != "" {
+			uri = l.TargetURI
+			if l.TargetSelectionRange != nil {
+				r = *l.TargetSelectionRange
+			}
+		}
+		path, err := URIToPath(uri)
+		if err != nil {
+			continue
+		}
+		text, ok := texts[path]
+		if !ok {
+			text = c.text(path)
+			texts[path] = text
+		}
+		out = append(out, toLocation(path, text, r))
+	}
+	return out
+}
+
+func decodeLocations(raw json.RawMessage) []protoLocation {
+	var many []protoLocation
+	if json.Unmarshal(raw, &many) == nil {
+		return many
+	}
+	var one protoLocation
+	if json.Unmarshal(raw, &one) == nil && (one.URI != "" || one.TargetURI != "") {
+		return []protoLocation{one}
+	}
+	return nil
+}
+
+// Definition returns where the symbol at line:col of path is defined.
+func (c *Client) Definition(ctx context.Context, path string, line, col int) ([]Location, error) {
+	params, err := c.position(ctx, path, line, col)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/definition", params, &raw); err != nil {
+		return nil, err
+	}
+	return c.locations(decodeLocations(raw)), nil
+}
+
+// References returns the uses of the symbol at line:col of path.
+func (c *Client) References(ctx context.Context, path string, line, col int, includeDeclaration bool) ([]Location, error) {
+	params, err := c.position(ctx, path, line, col)
+	if err != nil {
+		return nil, err
+	}
+	params["context"] = map[string]any{"includeDeclaration": includeDeclaration}
+	var locs []protoLocation
+	if err := c.conn.Call(ctx, "textDocument/references", params, &locs); err != nil {
+		return nil, err
+	}
+	return c.locations(locs), nil
+}
+
+// Hover returns the documentation and type information for line:col of
+// path, and the range it applies to if the server gave one.
+func (c *Client) Hover(ctx context.Context, path string, line, col int) (string, *Location, error) {
+	params, err := c.position(ctx, path, line, col)
+	if err != nil {
+		return "", nil, err
+	}
+	var res *struct {
+		Contents json.RawMessage `json:"contents"`
+		Range    *Range          `json:"range"`
+	}
+	if err := c.conn.Call(ctx, "textDocument/hover", params, &res); err != nil {
+		return "", nil, err
+	}
+	if res == nil {
+		return "", nil, nil
+	}
+	var loc *Location
+	if res.Range != nil {
+		l := toLocation(path, c.text(path), *res.Range)
+		loc = &l
+	}
+	return strings.TrimSpace(hoverText(res.Contents)), loc, nil
+}
+
+// DocumentSymbols returns the declarations in path, flattened, with each
+// nested symbol's parent as its container.
+func (c *Client) DocumentSymbols(ctx context.Context, path string) ([]Symbol, error) {
+	if err := c.Sync(ctx, path); err != nil {
+		return nil, err
+	}
+	var syms []protoSymbol
+	if err := c.conn.Call(ctx, "textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": PathToURI(path)}}, &syms); err != nil {
+		return nil, err
+	}
+	text := c.text(path)
+	var out []Symbol
+	var walk func([]protoSymbol, string)
+	walk = func(list []protoSymbol, container string) {
+		for _, s := range list {
+			sym := Symbol{Name: s.Name, Kind: symbolKind(s.Kind), Detail: s.Detail, Container: container}
+			switch {
+			case s.SelectionRange != nil:
+				sym.Location = toLocation(path, text, *s.SelectionRange)
+			case s.Range != nil:
+				sym.Location = toLocation(path, text, *s.Range)
+			case s.Location != nil:
+				sym.Location = toLocation(path, text, s.Location.Range)
+				sym.Container = s.ContainerName
+			}
+			out = append(out, sym)
+			walk(s.Children, s.Name)
+		}
+	}
+	walk(syms, "")
+	return out, nil
+}
+
+// WorkspaceSymbols searches the workspace for symbols matching query.
+func (c *Client) WorkspaceSymbols(ctx context.Context, query string) ([]Symbol, error) {
+	var syms []protoSymbol
+	if err := c.conn.Call(ctx, "workspace/symbol", map[string]any{"query": query}, &syms); err != nil {
+		return nil, err
+	}
+	texts := map[string]string{}
+	var out []Symbol
+	for _, s := range syms {
+		if s.Location == nil {
+			continue
+		}
+		path, err := URIToPath(s.Location.URI)
+		if err != nil {
+			continue
+		}
+		text, ok := texts[path]
+		if !ok {
+			text = c.text(path)
+			texts[path] = text
+		}
+		out = append(out, Symbol{
+			Name: s.Name, Kind: symbolKind(s.Kind), Container: s.ContainerName,
+			Location: toLocation(path, text, s.Location.Range),
+		})
+	}
+	return out, nil
+}
+
+// Rename asks the server for the edits that rename the symbol at line:col
+// of path to newName. The edits are returned, not applied. Renames that
+// would create, move or delete files are rejected.
+func (c *Client) Rename(ctx context.Context, path string, line, col int, newName string) ([]FileEdit, error) {
+	params, err := c.position(ctx, path, line, col)
+	if err != nil {
+		return nil, err
+	}
+	params["newName"] = newName
+	var we *workspaceEdit
+	if err := c.conn.Call(ctx, "textDocument/rename", params, &we); err != nil {
+		return nil, err
+	}
+	if we == nil {
+		return nil, errors.New("the server returned no edits for this position")
+	}
+	byPath := map[string][]TextEdit{}
+	add := func(uri string, edits []TextEdit) error {
+		p, err := URIToPath(uri)
+		if err != nil {
+			return err
+		}
+		byPath[p] = append(byPath[p], edits...)
+		return nil
+	}
+	for _, dc := range we.DocumentChanges {
+		if dc.Kind != "" {
+			return nil, fmt.Errorf("rename needs a %s file operation, which is not supported", dc.Kind)
+		}
+		if err := add(dc.TextDocument.URI, dc.Edits); err != nil {
+			return nil, err
+		}
+	}
+	if len(we.DocumentChanges) == 0 {
+		for uri, edits := range we.Changes {
+			if err := add(uri, edits); err != nil {
+				return nil, err
+			}
+		}
+	}
+	out := make([]FileEdit, 0, len(byPath))
+	for p, edits := range byPath {
+		out = append(out, FileEdit{Path: p, Edits: edits})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// Diagnostics syncs paths and waits for the server to publish diagnostics
+// for each of them, or for ctx to end. complete is false when ctx ended
+// before every file had been reported.
+func (c *Client) Diagnostics(ctx context.Context, paths []string) (diags []Diagnostic, complete bool, err error) {
+	for _, p := range paths {
+		if err := c.Sync(ctx, p); err != nil {
+			return nil, false, err
+		}
+	}
+	for {
+		c.mu.Lock()
+		fresh := true
+		for _, p := range paths {
+			if c.pub[p] < c.synced[p] || c.pub[p] == 0 {
+				fresh = false
+				break
+			}
+		}
+		notify := c.notify
+		c.mu.Unlock()
+
+		var settle <-chan time.Time
+		if fresh {
+			settle = time.After(diagnosticsSettle)
+		}
+		select {
+		case <-notify:
+			continue
+		case <-settle:
+			complete = true
+		case <-ctx.Done():
+		case <-c.conn.Done():
+		}
+		break
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range paths {
+		text := ""
+		if doc := c.docs[p]; doc != nil {
+			text = doc.text
+		}
+		for _, d := range c.diags[p] {
+			tool := d.Source
+			if tool == "" {
+				tool = c.Server.Command[0]
+			}
+			var code any
+			_ = json.Unmarshal(d.Code, &code)
+			codeStr := ""
+			if code != nil {
+				codeStr = fmt.Sprint(code)
+			}
+			diags = append(diags, Diagnostic{
+				File:     p,
+				Line:     d.Range.Start.Line + 1,
+				Col:      column(text, d.Range.Start),
+				Code:     codeStr,
+				Severity: severityName(d.Severity),
+				Message:  d.Message,
+				Tool:     tool,
+				Language: c.Server.Language,
+			})
+		}
+	}
+	return diags, complete, nil
+}
+
+// pipe joins a server's stdout and stdin into one stream.
+type pipe struct {
+	io.ReadCloser
+	w io.WriteCloser
+}
+
+func (p pipe) Write(b []byte) (int, error) { return p.w.Write(b) }
+
+func (p pipe) Close() error {
+	err := p.w.Close()
+	if rerr := p.ReadCloser.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// cappedBuffer keeps the first max bytes written to it.
+type cappedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,252 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers the handful of requests the client makes. Foo is
+// declared at 4:6 and used after an emoji on line 6, whose UTF-16 column
+// differs from its character column.
+type fakeServer struct {
+	conn *Conn
+	uri  string
+
+	mu      sync.Mutex
+	changes []int             // versions received through didChange
+	texts   map[string]string // latest text by URI
+}
+
+const fakeSource = "package main\n\n// héllo\nfunc Foo() {}\n\nfunc main() { _ = \"😀\"; Foo() }\n"
+
+func rng(l1, c1, l2, c2 int) Range {
+	return Range{Start: Position{l1, c1}, End: Position{l2, c2}}
+}
+
+func (s *fakeServer) handle(method string, params json.RawMessage) (any, error) {
+	var p struct {
+		TextDocument struct {
+			URI     string `json:"uri"`
+			Version int    `json:"version"`
+			Text    string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Position Position `json:"position"`
+		NewName  string   `json:"newName"`
+	}
+	_ = json.Unmarshal(params, &p)
+	use := Position{5, 24}
+	switch method {
+	case "initialize":
+		return map[string]any{"capabilities": map[string]any{}}, nil
+	case "textDocument/didOpen", "textDocument/didChange":
+		s.mu.Lock()
+		text := p.TextDocument.Text
+		if method == "textDocument/didChange" {
+			s.changes = append(s.changes, p.TextDocument.Version)
+			text = p.ContentChanges[0].Text
+		}
+		if s.texts == nil {
+			s.texts = map[string]string{}
+		}
+		s.texts[p.TextDocument.URI] = text
+		s.mu.Unlock()
+		go s.conn.Notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         p.TextDocument.URI,
+			"diagnostics": []any{map[string]any{"range": rng(5, 24, 5, 27), "severity": 2, "code": 1001, "message": "result of Foo is unused"}},
+		})
+		return nil, nil
+	case "textDocument/definition":
+		if p.Position != use {
+			return []any{}, nil
+		}
+		return []any{map[string]any{"targetUri": s.uri, "targetRange": rng(3, 0, 3, 13), "targetSelectionRange": rng(3, 5, 3, 8)}}, nil
+	case "textDocument/references":
+		return []any{
+			map[string]any{"uri": s.uri, "range": rng(3, 5, 3, 8)},
+			map[string]any{"uri": s.uri, "range": rng(5, 24, 5, 27)},
+		}, nil
+	case "textDocument/hover":
+		return map[string]any{"contents": map[string]any{"kind": "markdown", "value": "func Foo()"}, "range": rng(5, 24, 5, 27)}, nil
+	case "textDocument/documentSymbol":
+		return []any{
+			map[string]any{"name": "Foo", "kind": 12, "range": rng(3, 0, 3, 13), "selectionRange": rng(3, 5, 3, 8)},
+			map[string]any{"name": "main", "kind": 12, "range": rng(5, 0, 5, 35), "selectionRange": rng(5, 5, 5, 9),
+				"children": []any{map[string]any{"name": "_", "kind": 13, "range": rng(5, 14, 5, 15), "selectionRange": rng(5, 14, 5, 15)}}},
+		}, nil
+	case "textDocument/rename":
+		return map[string]any{"documentChanges": []any{map[string]any{
+			"textDocument": map[string]any{"uri": s.uri, "version": 1},
+			"edits": []any{
+				map[string]any{"range": rng(5, 24, 5, 27), "newText": p.NewName},
+				map[string]any{"range": rng(3, 5, 3, 8), "newText": p.NewName},
+			},
+		}}}, nil
+	case "shutdown":
+		return nil, nil
+	}
+	return nil, nil
+}
+
+func TestClient(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte(fakeSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	clientEnd, serverEnd := net.Pipe()
+	srv := &fakeServer{uri: PathToURI(path)}
+	srv.conn = NewConn(serverEnd, srv.handle)
+	defer srv.conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := NewClient(ctx, Server{Language: "go", Command: []string{"fake"}, Extensions: []string{".go"}}, dir, clientEnd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	defs, err := c.Definition(ctx, path, 6, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 1 || defs[0].Line != 4 || defs[0].Col != 6 || defs[0].EndCol != 9 || defs[0].Text != "func Foo() {}" {
+		t.Fatalf("definition: %+v", defs)
+	}
+
+	refs, err := c.References(ctx, path, 4, 6, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[1].Line != 6 || refs[1].Col != 24 || refs[1].EndCol != 27 {
+		t.Fatalf("references: %+v", refs)
+	}
+
+	hover, loc, err := c.Hover(ctx, path, 6, 24)
+	if err != nil || hover != "func Foo()" || loc == nil || loc.Col != 24 {
+		t.Fatalf("hover: %q %+v %v", hover, loc, err)
+	}
+
+	syms, err := c.DocumentSymbols(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(syms) != 3 || syms[0].Kind != "function" || syms[2].Container != "main" || syms[2].Kind != "variable" {
+		t.Fatalf("symbols: %+v", syms)
+	}
+
+	diags, complete, err := c.Diagnostics(ctx, []string{path})
+	if err != nil || !complete {
+		t.Fatalf("diagnostics: complete=%v err=%v", complete, err)
+	}
+	if len(diags) != 1 || diags[0].Line != 6 || diags[0].Col != 24 || diags[0].Severity != "warning" || diags[0].Code != "1001" || diags[0].Tool != "fake" {
+		t.Fatalf("diagnostics: %+v", diags)
+	}
+
+	edits, err := c.Rename(ctx, path, 4, 6, "Bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 1 || edits[0].Path != path {
+		t.Fatalf("rename: %+v", edits)
+	}
+	renamed, err := ApplyEdits(fakeSource, edits[0].Edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\n// héllo\nfunc Bar() {}\n\nfunc main() { _ = \"😀\"; Bar() }\n"
+	if renamed != want {
+		t.Fatalf("renamed source:\n%s", renamed)
+	}
+
+	// An edit made on disk is sent before the next request.
+	if err := os.WriteFile(path, []byte(renamed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DocumentSymbols(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	changes := srv.changes
+	srv.mu.Unlock()
+	if len(changes) != 1 || changes[0] != 2 {
+		t.Fatalf("didChange versions: %v", changes)
+	}
+}
+
+// memFiles holds proposed contents for some files, like a dry run overlay.
+type memFiles map[string]string
+
+func (m memFiles) ReadFile(path string) ([]byte, error) {
+	if s, ok := m[path]; ok {
+		return []byte(s), nil
+	}
+	return os.ReadFile(path)
+}
+
+func (m memFiles) Paths() []string {
+	var paths []string
+	for p := range m {
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func TestClientWithFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte(fakeSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other.go")
+	clientEnd, serverEnd := net.Pipe()
+	srv := &fakeServer{uri: PathToURI(path)}
+	srv.conn = NewConn(serverEnd, srv.handle)
+	defer srv.conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := NewClient(ctx, Server{Language: "go", Command: []string{"fake"}, Extensions: []string{".go"}}, dir, clientEnd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Proposed contents reach the server, for files the request did not
+	// name too, and the disk is sent again once they are no longer in play.
+	proposed := "package main\n\nfunc Baz() {}\n"
+	files := memFiles{path: proposed, other: "package main\n", filepath.Join(dir, "notes.txt"): "x"}
+	if _, err := c.DocumentSymbols(WithFiles(ctx, files), path); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	got, gotOther, n := srv.texts[PathToURI(path)], srv.texts[PathToURI(other)], len(srv.texts)
+	srv.mu.Unlock()
+	if got != proposed || gotOther != "package main\n" || n != 2 {
+		t.Fatalf("server saw %q and %q (%d files)", got, gotOther, n)
+	}
+	if _, err := c.DocumentSymbols(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	got = srv.texts[PathToURI(path)]
+	srv.mu.Unlock()
+	if got != fakeSource {
+		t.Fatalf("disk content not restored: %q", got)
+	}
+}
+
+func TestApplyEditsOverlap(t *testing.T) {
+	_, err := ApplyEdits("abcdef", []TextEdit{{Range: rng(0, 0, 0, 3), NewText: "x"}, {Range: rng(0, 2, 0, 4), NewText: "y"}})
+	if err == nil {
+		t.Fatal("overlapping edits accepted")
+	}
+}
@@ -0,0 +1,247 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// ErrClosed is returned by calls on a connection whose peer has gone away.
+var ErrClosed = errors.New("lsp: connection closed")
+
+// RPCError is a JSON-RPC error response.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string { return fmt.Sprintf("lsp: %s (code %d)", e.Message, e.Code) }
+
+const codeMethodNotFound = -32601
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *RPCError        `json:"error,omitempty"`
+}
+
+// Handler answers requests and receives notifications from the server. For
+// notifications the result is ignored. Handlers run on the read loop and
+// must not call back into the connection synchronously.
+type Handler func(method string, params json.RawMessage) (any, error)
+
+// Conn is a JSON-RPC 2.0 connection using the LSP base protocol
+// (Content-Length framed messages).
+type Conn struct {
+	rwc     io.ReadWriteCloser
+	handler Handler
+
+	wmu sync.Mutex // serialises writes
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	err     error
+
+	done chan struct{}
+}
+
+// NewConn starts reading messages from rwc. The connection ends when rwc
+// returns an error or Close is called.
+func NewConn(rwc io.ReadWriteCloser, handler Handler) *Conn {
+	c := &Conn{rwc: rwc, handler: handler, pending: map[int64]chan *message{}, done: make(chan struct{})}
+	go c.readLoop()
+	return c
+}
+
+// Done is closed once the connection has ended.
+func (c *Conn) Done() <-chan struct{} { return c.done }
+
+// Close closes the underlying stream, which ends the read loop.
+func (c *Conn) Close() error { return c.rwc.Close() }
+
+// Call sends a request and decodes its result into result, which may be nil.
+// When ctx ends first the request is cancelled with $/cancelRequest.
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	raw := json.RawMessage(strconv.FormatInt(id, 10))
+	if err := c.send(&message{ID: &raw, Method: method}, params); err != nil {
+		c.forget(id)
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp == nil {
+			return c.closedErr()
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("lsp: decode %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.forget(id)
+		_ = c.Notify("$/cancelRequest", map[string]any{"id": id})
+		return ctx.Err()
+	case <-c.done:
+		return c.closedErr()
+	}
+}
+
+// Notify sends a notification.
+func (c *Conn) Notify(method string, params any) error {
+	return c.send(&message{Method: method}, params)
+}
+
+func (c *Conn) forget(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Conn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return ErrClosed
+}
+
+func (c *Conn) send(m *message, params any) error {
+	m.JSONRPC = "2.0"
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("lsp: encode %s: %w", m.Method, err)
+		}
+		m.Params = b
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("lsp: encode %s: %w", m.Method, err)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := fmt.Fprintf(c.rwc, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return fmt.Errorf("lsp: write: %w", err)
+	}
+	if _, err := c.rwc.Write(body); err != nil {
+		return fmt.Errorf("lsp: write: %w", err)
+	}
+	return nil
+}
+
+func (c *Conn) readLoop() {
+	r := textproto.NewReader(bufio.NewReader(c.rwc))
+	var err error
+	for {
+		var m *message
+		if m, err = readMessage(r); err != nil {
+			break
+		}
+		c.dispatch(m)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, fs.ErrClosed) {
+		err = ErrClosed
+	}
+	c.mu.Lock()
+	c.err = err
+	pending := c.pending
+	c.pending = map[int64]chan *message{}
+	c.mu.Unlock()
+	for _, ch := range pending {
+		close(ch)
+	}
+	close(c.done)
+}
+
+func readMessage(r *textproto.Reader) (*message, error) {
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("lsp: bad Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r.R, body); err != nil {
+		return nil, err
+	}
+	var m message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("lsp: decode message: %w", err)
+	}
+	return &m, nil
+}
+
+func (c *Conn) dispatch(m *message) {
+	switch {
+	case m.Method == "" && m.ID != nil:
+		// A response to one of our calls.
+		id, err := strconv.ParseInt(string(*m.ID), 10, 64)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		ch := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- m
+		}
+	case m.ID != nil:
+		// A request from the server.
+		var result any
+		var rpcErr *RPCError
+		if c.handler == nil {
+			rpcErr = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + m.Method}
+		} else if res, err := c.handler(m.Method, m.Params); err != nil {
+			if !errors.As(err, &rpcErr) {
+				rpcErr = &RPCError{Code: -32603, Message: err.Error()}
+			}
+		} else {
+			result = res
+		}
+		reply := &message{ID: m.ID, Error: rpcErr}
+		if rpcErr == nil {
+			b, err := json.Marshal(result)
+			if err != nil {
+				b = []byte("null")
+			}
+			reply.Result = b
+		}
+		_ = c.send(reply, nil)
+	default:
+		if c.handler != nil {
+			_, _ = c.handler(m.Method, m.Params)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -69,6 +70,11 @@ type Diagnostic struct {
 
 // Check runs language server diagnostics on the provided files.
 func Check(files []string) (string, error) {
+	return CheckContext(context.Background(), files)
+}
+
+// CheckContext is Check with checkers that are killed when ctx ends.
+func CheckContext(ctx context.Context, files []string) (string, error) {
 	var out bytes.Buffer
 	var goFiles, tsFiles, pyFiles, rsFiles, jsFiles []string
 	for _, f := range files {
@@ -88,7 +94,7 @@ func Check(files []string) (string, error) {
 	if len(goFiles) > 0 && contains("go", languages) {
 		if _, err := exec.LookPath("gopls"); err == nil {
 			args := append([]string{"check"}, goFiles...)
-			cmd := exec.Command("gopls", args...)
+			cmd := exec.CommandContext(ctx, "gopls", args...)
 			b, err := cmd.CombinedOutput()
 			out.Write(b)
 			if err != nil {
@@ -99,7 +105,7 @@ func Check(files []string) (string, error) {
 	if len(tsFiles) > 0 && contains("typescript", languages) {
 		if _, err := exec.LookPath("tsc"); err == nil {
 			args := append([]string{"--noEmit"}, tsFiles...)
-			cmd := exec.Command("tsc", args...)
+			cmd := exec.CommandContext(ctx, "tsc", args...)
 			b, err := cmd.CombinedOutput()
 			out.Write(b)
 			if err != nil {
@@ -110,7 +116,7 @@ func Check(files []string) (string, error) {
 	if len(pyFiles) > 0 && contains("python", languages) {
 		if _, err := exec.LookPath("pyright"); err == nil {
 			args := append([]string{}, pyFiles...)
-			cmd := exec.Command("pyright", args...)
+			cmd := exec.CommandContext(ctx, "pyright", args...)
 			b, err := cmd.CombinedOutput()
 			out.Write(b)
 			if err != nil {
@@ -121,7 +127,7 @@ func Check(files []string) (string, error) {
 	if len(rsFiles) > 0 && contains("rust", languages) {
 		if _, err := exec.LookPath("cargo"); err == nil {
 			// cargo check will analyze the entire crate; ignore file list
-			cmd := exec.Command("cargo", "check")
+			cmd := exec.CommandContext(ctx, "cargo", "check")
 			b, err := cmd.CombinedOutput()
 			out.Write(b)
 			if err != nil {
@@ -132,7 +138,7 @@ func Check(files []string) (string, error) {
 	if len(jsFiles) > 0 && contains("javascript", languages) {
 		if _, err := exec.LookPath("eslint"); err == nil {
 			args := append([]string{"--no-error-on-unmatched-pattern"}, jsFiles...)
-			cmd := exec.Command("eslint", args...)
+			cmd := exec.CommandContext(ctx, "eslint", args...)
 			b, err := cmd.CombinedOutput()
 			out.Write(b)
 			if err != nil {
@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+)
+
+// Launcher prepares the command for a language server run in root.
+type Launcher func(root string, argv []string) (*exec.Cmd, error)
+
+// Manager starts language servers on demand and keeps one running per
+// workspace root and language. A server that has exited is restarted on
+// next use.
+type Manager struct {
+	launch Launcher
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewManager returns a Manager that starts servers with launch.
+func NewManager(launch Launcher) *Manager {
+	return &Manager{launch: launch, clients: map[string]*Client{}}
+}
+
+// Client returns the client for the language of path in root, starting
+// its server if needed.
+func (m *Manager) Client(ctx context.Context, root, path string) (*Client, error) {
+	srv, err := ServerFor(path)
+	if err != nil {
+		return nil, err
+	}
+	key := root + "\x00" + srv.Language
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c := m.clients[key]; c != nil {
+		if c.Alive() {
+			return c, nil
+		}
+		_ = c.Close()
+		delete(m.clients, key)
+	}
+	cmd, err := m.launch(root, srv.Command)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Start(ctx, srv, root, cmd)
+	if err != nil {
+		return nil, err
+	}
+	m.clients[key] = c
+	return c, nil
+}
+
+// Changed tells every running server that paths were written.
+func (m *Manager) Changed(paths []string) {
+	m.mu.Lock()
+	clients := make([]*Client, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	m.mu.Unlock()
+	for _, c := range clients {
+		if c.Alive() {
+			_ = c.Changed(paths)
+		}
+	}
+}
+
+// Close shuts down every server.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = map[string]*Client{}
+	m.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = c.Close()
+		}()
+	}
+	wg.Wait()
+}
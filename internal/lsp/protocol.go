@@ -0,0 +1,319 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Position is a protocol position: a zero-based line and an offset in UTF-16
+// code units within that line.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a half-open protocol range.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// TextEdit replaces Range with NewText.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// FileEdit is the part of a rename that touches one file.
+type FileEdit struct {
+	Path  string     `json:"path"`
+	Edits []TextEdit `json:"edits"`
+}
+
+// Location is a source range as reported to tools: 1-based lines and
+// columns counted in characters, plus the text of the first line.
+type Location struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	EndLine int    `json:"end_line"`
+	EndCol  int    `json:"end_col"`
+	Text    string `json:"text,omitempty"`
+}
+
+// Symbol is a named declaration from a document or workspace symbol query.
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Detail    string `json:"detail,omitempty"`
+	Container string `json:"container,omitempty"`
+	Location
+}
+
+type protoLocation struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+
+	// LocationLink fields, sent instead when the client supports links.
+	TargetURI            string `json:"targetUri"`
+	TargetSelectionRange *Range `json:"targetSelectionRange"`
+}
+
+type protoDiagnostic struct {
+	Range    Range           `json:"range"`
+	Severity int             `json:"severity"`
+	Code     json.RawMessage `json:"code"`
+	Source   string          `json:"source"`
+	Message  string          `json:"message"`
+}
+
+type protoSymbol struct {
+	Name   string `json:"name"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail"`
+
+	// DocumentSymbol
+	SelectionRange *Range        `json:"selectionRange"`
+	Range          *Range        `json:"range"`
+	Children       []protoSymbol `json:"children"`
+
+	// SymbolInformation and WorkspaceSymbol
+	Location      *protoLocation `json:"location"`
+	ContainerName string         `json:"containerName"`
+}
+
+type workspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes"`
+	DocumentChanges []struct {
+		Kind         string `json:"kind"`
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Edits []TextEdit `json:"edits"`
+	} `json:"documentChanges"`
+}
+
+var symbolKinds = []string{"", "file", "module", "namespace", "package", "class", "method", "property",
+	"field", "constructor", "enum", "interface", "function", "variable", "constant", "string", "number",
+	"boolean", "array", "object", "key", "null", "enum_member", "struct", "event", "operator", "type_parameter"}
+
+func symbolKind(k int) string {
+	if k > 0 && k < len(symbolKinds) {
+		return symbolKinds[k]
+	}
+	return "unknown"
+}
+
+func severityName(s int) string {
+	switch s {
+	case 2:
+		return "warning"
+	case 3:
+		return "info"
+	case 4:
+		return "hint"
+	default:
+		return "error"
+	}
+}
+
+// PathToURI returns the file URI for an absolute path.
+func PathToURI(path string) string {
+	p := filepath.ToSlash(path)
+	if runtime.GOOS == "windows" {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// URIToPath returns the path named by a file URI.
+func URIToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI %q", uri)
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.FromSlash(p), nil
+}
+
+// lineBounds returns the byte offsets of the start and end (excluding the
+// newline) of the zero-based line, and false if the text is shorter.
+func lineBounds(text string, line int) (int, int, bool) {
+	start := 0
+	for i := 0; i < line; i++ {
+		nl := strings.IndexByte(text[start:], '\n')
+		if nl < 0 {
+			return 0, 0, false
+		}
+		start += nl + 1
+	}
+	end := len(text)
+	if nl := strings.IndexByte(text[start:], '\n'); nl >= 0 {
+		end = start + nl
+	}
+	return start, end, true
+}
+
+// utf16Units returns the length of s in UTF-16 code units.
+func utf16Units(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// byteOffset converts a protocol position to a byte offset in text. A
+// character past the end of its line means the end of the line.
+func byteOffset(text string, p Position) (int, error) {
+	start, end, ok := lineBounds(text, p.Line)
+	if !ok {
+		return 0, fmt.Errorf("line %d is past the end of the file", p.Line+1)
+	}
+	units := 0
+	for i, r := range text[start:end] {
+		if units >= p.Character {
+			return start + i, nil
+		}
+		if r >= 0x10000 {
+			units += 2
+		} else {
+			units++
+		}
+	}
+	return end, nil
+}
+
+// PositionAt converts a 1-based line and character column into a protocol
+// position. A column past the end of the line means the end of the line.
+func PositionAt(text string, line, col int) (Position, error) {
+	if line < 1 {
+		return Position{}, errors.New("line must be >= 1")
+	}
+	start, end, ok := lineBounds(text, line-1)
+	if !ok {
+		return Position{}, fmt.Errorf("line %d is past the end of the file", line)
+	}
+	s := text[start:end]
+	if col < 1 {
+		col = 1
+	}
+	i, n := 0, 0
+	for n < col-1 && i < len(s) {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return Position{Line: line - 1, Character: utf16Units(s[:i])}, nil
+}
+
+// column converts a protocol position to a 1-based character column.
+func column(text string, p Position) int {
+	start, _, ok := lineBounds(text, p.Line)
+	if !ok {
+		return p.Character + 1
+	}
+	off, err := byteOffset(text, p)
+	if err != nil {
+		return p.Character + 1
+	}
+	return utf8.RuneCountInString(text[start:off]) + 1
+}
+
+// toLocation converts r in the file at path with contents text.
+func toLocation(path, text string, r Range) Location {
+	loc := Location{
+		File:    path,
+		Line:    r.Start.Line + 1,
+		Col:     column(text, r.Start),
+		EndLine: r.End.Line + 1,
+		EndCol:  column(text, r.End),
+	}
+	if start, end, ok := lineBounds(text, r.Start.Line); ok {
+		loc.Text = strings.TrimSpace(text[start:end])
+	}
+	return loc
+}
+
+// ApplyEdits applies non-overlapping edits to text.
+func ApplyEdits(text string, edits []TextEdit) (string, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		start, err := byteOffset(text, e.Range.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := byteOffset(text, e.Range.End)
+		if err != nil {
+			return "", err
+		}
+		if end < start {
+			return "", fmt.Errorf("edit at line %d has its end before its start", e.Range.Start.Line+1)
+		}
+		spans = append(spans, span{start, end, e.NewText})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var b strings.Builder
+	prev := 0
+	for _, s := range spans {
+		if s.start < prev {
+			return "", errors.New("overlapping edits")
+		}
+		b.WriteString(text[prev:s.start])
+		b.WriteString(s.text)
+		prev = s.end
+	}
+	b.WriteString(text[prev:])
+	return b.String(), nil
+}
+
+// hoverText flattens the contents of a hover result, which may be
+// MarkupContent, a MarkedString or an array of MarkedStrings.
+func hoverText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var marked struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if json.Unmarshal(raw, &marked) == nil && marked.Value != "" {
+		if marked.Language != "" {
+			return "```" + marked.Language + "\n" + marked.Value + "\n```"
+		}
+		return marked.Value
+	}
+	var parts []json.RawMessage
+	if json.Unmarshal(raw, &parts) == nil {
+		var out []string
+		for _, p := range parts {
+			if t := hoverText(p); t != "" {
+				out = append(out, t)
+			}
+		}
+		return strings.Join(out, "\n\n")
+	}
+	return ""
+}
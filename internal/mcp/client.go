@@ -0,0 +1,381 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	agentry "github.com/marcodenic/agentry/internal"
+	"github.com/marcodenic/agentry/internal/debug"
+)
+
+// DefaultTimeout bounds a request whose context has no deadline.
+const DefaultTimeout = 60 * time.Second
+
+// supportedVersions are the protocol revisions the client accepts from a
+// server; the wire format of the methods used here is unchanged between them.
+var supportedVersions = map[string]bool{
+	"2024-11-05": true,
+	"2025-03-26": true,
+	"2025-06-18": true,
+}
+
+// errDisconnected reports that the connection dropped while a request was
+// in flight, so the server may or may not have acted on it.
+var errDisconnected = errors.New("mcp server disconnected")
+
+// Client is a session with one MCP server. It connects lazily, and if the
+// server goes away it reconnects and re-initializes on the next request.
+type Client struct {
+	Name string
+	Spec string
+
+	// Dial opens a transport to the server; it defaults to Dial(ctx, Spec).
+	Dial func(ctx context.Context) (Transport, error)
+	// Timeout bounds requests whose context has no deadline.
+	Timeout time.Duration
+	// OnNotification receives server notifications such as
+	// notifications/progress or notifications/tools/list_changed. When it is
+	// set, tool calls request progress notifications.
+	OnNotification func(method string, params json.RawMessage)
+
+	mu       sync.Mutex
+	sess     *session
+	progress int64
+}
+
+// NewClient returns a client for the server described by spec (an http(s)
+// URL or a command line). No connection is made until the first request.
+func NewClient(name, spec string) *Client {
+	return &Client{Name: name, Spec: spec, Timeout: DefaultTimeout}
+}
+
+// Connect opens the session now instead of on first use.
+func (c *Client) Connect(ctx context.Context) error {
+	_, err := c.session(ctx)
+	return err
+}
+
+// Info returns the server's handshake result from the current session.
+func (c *Client) Info() (InitializeResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sess == nil || c.sess.closed() {
+		return InitializeResult{}, false
+	}
+	return c.sess.info, true
+}
+
+// Close ends the current session, if any.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	s := c.sess
+	c.sess = nil
+	c.mu.Unlock()
+	if s != nil {
+		return s.t.Close()
+	}
+	return nil
+}
+
+func (c *Client) session(ctx context.Context) (*session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sess != nil && !c.sess.closed() {
+		return c.sess, nil
+	}
+	if c.sess != nil {
+		debug.Printf("mcp %s: reconnecting after %v", c.Name, c.sess.t.Err())
+		c.sess = nil
+	}
+	dial := c.Dial
+	if dial == nil {
+		dial = func(ctx context.Context) (Transport, error) { return Dial(ctx, c.Spec) }
+	}
+	t, err := dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("mcp %s: %w", c.Name, err)
+	}
+	s := newSession(t, c)
+	if err := s.initialize(ctx, c.timeout()); err != nil {
+		_ = t.Close()
+		return nil, fmt.Errorf("mcp %s: initialize: %w", c.Name, err)
+	}
+	c.sess = s
+	return s, nil
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultTimeout
+}
+
+// call issues a request, reconnecting once if the session has dropped.
+// A request that reached the server is only retried when it is safe to
+// repeat, so a tool is never run twice because of a reconnect.
+func (c *Client) call(ctx context.Context, method string, params, out any) error {
+	for attempt := 0; ; attempt++ {
+		s, err := c.session(ctx)
+		if err != nil {
+			return err
+		}
+		err = s.call(ctx, method, params, out, c.timeout())
+		if attempt == 0 && ctx.Err() == nil &&
+			(errors.Is(err, ErrClosed) || (errors.Is(err, errDisconnected) && method != "tools/call")) {
+			c.drop(s)
+			continue
+		}
+		if err != nil && !errors.As(err, new(*RPCError)) {
+			return fmt.Errorf("mcp %s: %s: %w", c.Name, method, err)
+		}
+		return err
+	}
+}
+
+func (c *Client) drop(s *session) {
+	c.mu.Lock()
+	if c.sess == s {
+		c.sess = nil
+	}
+	c.mu.Unlock()
+	_ = s.t.Close()
+}
+
+// Ping checks that the server is responsive.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, "ping", struct{}{}, nil)
+}
+
+// ListTools returns every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var all []Tool
+	cursor := ""
+	for {
+		var res ListToolsResult
+		if err := c.call(ctx, "tools/list", cursorParams(cursor), &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Tools...)
+		if cursor = res.NextCursor; cursor == "" {
+			return all, nil
+		}
+	}
+}
+
+// CallTool invokes a tool. A result with IsError set is returned without an
+// error; protocol failures are returned as errors.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	params := CallToolParams{Name: name, Arguments: args}
+	if c.OnNotification != nil {
+		c.mu.Lock()
+		c.progress++
+		params.Meta = &RequestMeta{ProgressToken: fmt.Sprintf("%s-%d", c.Name, c.progress)}
+		c.mu.Unlock()
+	}
+	var res CallToolResult
+	if err := c.call(ctx, "tools/call", params, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListResources returns every resource the server offers.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var all []Resource
+	cursor := ""
+	for {
+		var res ListResourcesResult
+		if err := c.call(ctx, "resources/list", cursorParams(cursor), &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Resources...)
+		if cursor = res.NextCursor; cursor == "" {
+			return all, nil
+		}
+	}
+}
+
+// ReadResource fetches the contents of a resource.
+func (c *Client) ReadResource(ctx context.Context, uri string) (*ReadResourceResult, error) {
+	var res ReadResourceResult
+	if err := c.call(ctx, "resources/read", map[string]string{"uri": uri}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListPrompts returns every prompt template the server offers.
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var all []Prompt
+	cursor := ""
+	for {
+		var res ListPromptsResult
+		if err := c.call(ctx, "prompts/list", cursorParams(cursor), &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Prompts...)
+		if cursor = res.NextCursor; cursor == "" {
+			return all, nil
+		}
+	}
+}
+
+// GetPrompt renders a prompt template with the given arguments.
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var res GetPromptResult
+	params := map[string]any{"name": name}
+	if len(args) > 0 {
+		params["arguments"] = args
+	}
+	if err := c.call(ctx, "prompts/get", params, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func cursorParams(cursor string) any {
+	if cursor == "" {
+		return struct{}{}
+	}
+	return map[string]string{"cursor": cursor}
+}
+
+// session is one initialized connection.
+type session struct {
+	t      Transport
+	client *Client
+	info   InitializeResult
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan *Message
+	done    chan struct{}
+}
+
+func newSession(t Transport, c *Client) *session {
+	s := &session{t: t, client: c, pending: map[string]chan *Message{}, done: make(chan struct{})}
+	go s.readLoop()
+	return s
+}
+
+func (s *session) closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *session) initialize(ctx context.Context, timeout time.Duration) error {
+	params := InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		ClientInfo:      Implementation{Name: "agentry", Version: agentry.Version},
+	}
+	if err := s.call(ctx, "initialize", params, &s.info, timeout); err != nil {
+		return err
+	}
+	if !supportedVersions[s.info.ProtocolVersion] {
+		return fmt.Errorf("unsupported protocol version %q", s.info.ProtocolVersion)
+	}
+	msg, _ := NewNotification("notifications/initialized", nil)
+	return s.t.Send(ctx, msg)
+}
+
+func (s *session) readLoop() {
+	for msg := range s.t.Recv() {
+		switch {
+		case msg.IsResponse():
+			s.mu.Lock()
+			ch, ok := s.pending[string(msg.ID)]
+			delete(s.pending, string(msg.ID))
+			s.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		case msg.IsRequest():
+			go s.answer(msg)
+		case msg.IsNotification():
+			if fn := s.client.OnNotification; fn != nil {
+				fn(msg.Method, msg.Params)
+			}
+		}
+	}
+	s.mu.Lock()
+	close(s.done)
+	for id, ch := range s.pending {
+		close(ch)
+		delete(s.pending, id)
+	}
+	s.mu.Unlock()
+}
+
+// answer replies to server-initiated requests. Only ping is supported since
+// the client advertises no optional capabilities.
+func (s *session) answer(req *Message) {
+	var resp *Message
+	if req.Method == "ping" {
+		resp, _ = NewResult(req.ID, struct{}{})
+	} else {
+		resp = NewError(req.ID, CodeMethodNotFound, "method not supported by client: "+req.Method)
+	}
+	_ = s.t.Send(context.Background(), resp)
+}
+
+func (s *session) call(ctx context.Context, method string, params, out any, timeout time.Duration) error {
+	if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	s.mu.Lock()
+	if s.closed() {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	s.nextID++
+	id := s.nextID
+	req, err := NewRequest(id, method, params)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	ch := make(chan *Message, 1)
+	s.pending[string(req.ID)] = ch
+	s.mu.Unlock()
+
+	if err := s.t.Send(ctx, req); err != nil {
+		s.mu.Lock()
+		delete(s.pending, string(req.ID))
+		s.mu.Unlock()
+		return err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return errDisconnected
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if out != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, out)
+		}
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.pending, string(req.ID))
+		s.mu.Unlock()
+		if n, err := NewNotification("notifications/cancelled", map[string]any{"requestId": id, "reason": ctx.Err().Error()}); err == nil {
+			_ = s.t.Send(context.Background(), n)
+		}
+		return ctx.Err()
+	}
+}
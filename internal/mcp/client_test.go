@@ -0,0 +1,169 @@
+package mcp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/mcp"
+	"github.com/marcodenic/agentry/internal/mcp/mcptest"
+)
+
+// TestMain lets the test binary double as a stdio MCP server: the mcptest
+// fake, or an mcp.Server built by newTestServer. HTTP tests talk to
+// loopback servers, which the shared client refuses by default.
+func TestMain(m *testing.M) {
+	os.Setenv("AGENTRY_HTTP_ALLOW_PRIVATE", "1")
+	switch os.Getenv("MCPTEST_STDIO") {
+	case "1":
+		_ = mcptest.New().ServeStdio(os.Stdin, os.Stdout)
+		os.Exit(0)
+	case "server":
+		_ = newTestServer().ServeStdio(context.Background(), os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func stdioClient(t *testing.T) *mcp.Client {
+	t.Helper()
+	t.Setenv("MCPTEST_STDIO", "1")
+	c := mcp.NewClient("fake", os.Args[0])
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func exercise(t *testing.T, c *mcp.Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 4 || tools[0].Name != "echo" || tools[3].Name != "crash" {
+		t.Fatalf("expected 4 paginated tools, got %+v", tools)
+	}
+	if tools[0].InputSchema["required"] == nil {
+		t.Fatalf("input schema not preserved: %v", tools[0].InputSchema)
+	}
+	info, ok := c.Info()
+	if !ok || info.ServerInfo.Name != "mcptest" || info.Capabilities.Resources == nil {
+		t.Fatalf("unexpected server info: %+v", info)
+	}
+
+	res, err := c.CallTool(ctx, "add", map[string]any{"a": 2, "b": 3.5})
+	if err != nil || res.IsError || res.Text() != "5.5" {
+		t.Fatalf("add: %+v %v", res, err)
+	}
+	res, err = c.CallTool(ctx, "fail", nil)
+	if err != nil || !res.IsError {
+		t.Fatalf("fail should be a tool error: %+v %v", res, err)
+	}
+	var rpcErr *mcp.RPCError
+	if _, err := c.CallTool(ctx, "nope", nil); !errors.As(err, &rpcErr) || rpcErr.Code != mcp.CodeInvalidParams {
+		t.Fatalf("expected invalid params error, got %v", err)
+	}
+
+	resources, err := c.ListResources(ctx)
+	if err != nil || len(resources) != 1 {
+		t.Fatalf("resources: %v %v", resources, err)
+	}
+	contents, err := c.ReadResource(ctx, resources[0].URI)
+	if err != nil || contents.Contents[0].Text != "hello from mcptest" {
+		t.Fatalf("read resource: %+v %v", contents, err)
+	}
+
+	prompts, err := c.ListPrompts(ctx)
+	if err != nil || len(prompts) != 1 || !prompts[0].Arguments[0].Required {
+		t.Fatalf("prompts: %+v %v", prompts, err)
+	}
+	p, err := c.GetPrompt(ctx, "greet", map[string]string{"name": "Ada"})
+	if err != nil || p.Messages[0].Content.Text != "Hello, Ada!" {
+		t.Fatalf("get prompt: %+v %v", p, err)
+	}
+}
+
+func TestClientStdio(t *testing.T) {
+	exercise(t, stdioClient(t))
+}
+
+func TestClientHTTP(t *testing.T) {
+	srv := httptest.NewServer(mcptest.New())
+	defer srv.Close()
+	c := mcp.NewClient("fake", srv.URL)
+	defer c.Close()
+	exercise(t, c)
+}
+
+func TestClientHTTPEventStream(t *testing.T) {
+	fake := mcptest.New()
+	fake.SSE = true
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	var progress atomic.Int32
+	c := mcp.NewClient("fake", srv.URL)
+	c.OnNotification = func(method string, _ json.RawMessage) {
+		if method == "notifications/progress" {
+			progress.Add(1)
+		}
+	}
+	defer c.Close()
+	exercise(t, c)
+	if progress.Load() == 0 {
+		t.Fatal("expected progress notifications from the event stream")
+	}
+}
+
+func TestClientReconnectsHTTP(t *testing.T) {
+	fake := mcptest.New()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	c := mcp.NewClient("fake", srv.URL)
+	defer c.Close()
+	ctx := context.Background()
+
+	if _, err := c.ListTools(ctx); err != nil {
+		t.Fatal(err)
+	}
+	fake.DropSessions() // server restart: the old session ID is now unknown
+	res, err := c.CallTool(ctx, "echo", map[string]any{"text": "again"})
+	if err != nil || res.Text() != "again" {
+		t.Fatalf("call after session loss: %+v %v", res, err)
+	}
+	if n := fake.Initializations(); n != 2 {
+		t.Fatalf("expected 2 initializations, got %d", n)
+	}
+}
+
+func TestClientReconnectsStdio(t *testing.T) {
+	c := stdioClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A tool call that kills the server is reported, not silently retried.
+	if _, err := c.CallTool(ctx, "crash", nil); err == nil || !strings.Contains(err.Error(), "disconnected") {
+		t.Fatalf("expected disconnect error, got %v", err)
+	}
+	res, err := c.CallTool(ctx, "echo", map[string]any{"text": "back"})
+	if err != nil || res.Text() != "back" {
+		t.Fatalf("call after restart: %+v %v", res, err)
+	}
+}
+
+func TestDialRejectsEmptyCommand(t *testing.T) {
+	if _, err := mcp.Dial(context.Background(), "  "); err == nil {
+		t.Fatal("expected error for empty command")
+	}
+	if _, err := mcp.Dial(context.Background(), `server "unterminated`); err == nil {
+		t.Fatal("expected error for unterminated quote")
+	}
+}
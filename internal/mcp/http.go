@@ -0,0 +1,228 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/marcodenic/agentry/internal/httpx"
+)
+
+// SessionHeader carries the session ID assigned by a streamable HTTP server.
+const SessionHeader = "Mcp-Session-Id"
+
+// httpTransport implements the streamable HTTP transport: every client
+// message is POSTed to one endpoint and the server answers with either a
+// JSON body or an SSE stream of messages.
+type httpTransport struct {
+	url     string
+	headers http.Header
+
+	mu      sync.RWMutex
+	session string
+	closed  bool
+	recv    chan *Message
+	done    chan struct{}
+	err     error
+	once    sync.Once
+}
+
+// NewHTTPTransport returns a transport for the MCP endpoint at url. headers
+// (e.g. Authorization) are added to every request.
+func NewHTTPTransport(url string, headers http.Header) Transport {
+	return &httpTransport{
+		url:     url,
+		headers: headers,
+		recv:    make(chan *Message, 16),
+		done:    make(chan struct{}),
+	}
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range t.headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	t.mu.RLock()
+	if t.session != "" {
+		req.Header.Set(SessionHeader, t.session)
+	}
+	t.mu.RUnlock()
+	return req, nil
+}
+
+func (t *httpTransport) Send(ctx context.Context, msg *Message) error {
+	select {
+	case <-t.done:
+		return fmt.Errorf("%w: %v", ErrClosed, t.Err())
+	default:
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := httpx.Default().Stream(req)
+	if err != nil {
+		// The request may or may not have reached the server. End the
+		// transport so the next request reconnects, but let the caller
+		// decide whether repeating this one is safe.
+		if ctx.Err() == nil {
+			t.fail(fmt.Errorf("%w: %v", ErrClosed, err))
+		}
+		return fmt.Errorf("%w: %v", errDisconnected, err)
+	}
+	if sid := resp.Header.Get(SessionHeader); sid != "" {
+		t.mu.Lock()
+		t.session = sid
+		t.mu.Unlock()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusNoContent:
+		resp.Body.Close()
+		return nil
+	case resp.StatusCode == http.StatusNotFound && req.Header.Get(SessionHeader) != "":
+		// The server forgot our session; the client must initialize again.
+		resp.Body.Close()
+		t.fail(fmt.Errorf("%w: session expired", ErrClosed))
+		return t.Err() // never processed, so safe to retry
+	case resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return fmt.Errorf("mcp http %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if ct == "text/event-stream" {
+		// Responses and progress notifications arrive on the stream, which
+		// may outlive this call.
+		go func() {
+			defer resp.Body.Close()
+			t.readSSE(resp.Body)
+		}()
+		return nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return t.deliverJSON(body)
+}
+
+// deliverJSON accepts a single message or a batch array.
+func (t *httpTransport) deliverJSON(body []byte) error {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	if body[0] == '[' {
+		var batch []*Message
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return err
+		}
+		for _, m := range batch {
+			t.deliver(m)
+		}
+		return nil
+	}
+	var m Message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return err
+	}
+	t.deliver(&m)
+	return nil
+}
+
+// readSSE parses a text/event-stream body, delivering each event's data as a
+// JSON-RPC message.
+func (t *httpTransport) readSSE(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var data []string
+	flush := func() {
+		if len(data) > 0 {
+			_ = t.deliverJSON([]byte(strings.Join(data, "\n")))
+			data = data[:0]
+		}
+	}
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// event:, id: and retry: fields and comments are not needed here.
+	}
+	flush()
+}
+
+func (t *httpTransport) deliver(m *Message) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.recv <- m:
+	case <-t.done:
+	}
+}
+
+func (t *httpTransport) fail(err error) {
+	t.once.Do(func() {
+		t.err = err
+		close(t.done)
+		t.mu.Lock()
+		t.closed = true
+		close(t.recv)
+		t.mu.Unlock()
+	})
+}
+
+func (t *httpTransport) Recv() <-chan *Message { return t.recv }
+
+func (t *httpTransport) Err() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// Close ends the session on the server (best-effort) and the transport.
+func (t *httpTransport) Close() error {
+	t.mu.RLock()
+	session := t.session
+	t.mu.RUnlock()
+	if session != "" {
+		if req, err := t.newRequest(context.Background(), http.MethodDelete, nil); err == nil {
+			if resp, err := httpx.Default().Stream(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	t.fail(ErrClosed)
+	return nil
+}
@@ -0,0 +1,248 @@
+// Package mcptest provides a small in-process MCP server for tests. It
+// speaks both stdio and streamable HTTP and offers a fixed set of tools,
+// resources and prompts.
+package mcptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/marcodenic/agentry/internal/mcp"
+)
+
+// pageSize makes tools/list paginate so clients exercise cursors.
+const pageSize = 2
+
+// Server is a fake MCP server. Its tools are:
+//
+//	echo  {text}  returns text
+//	add   {a,b}   returns a+b
+//	fail  {}      returns an isError result
+//	crash {}      exits the process (stdio only) without answering
+//
+// It exposes the resource mem://greeting and the prompt "greet" {name}.
+type Server struct {
+	// SSE makes the HTTP handler answer requests with an event stream that
+	// carries a progress notification before the response.
+	SSE bool
+
+	initCount atomic.Int64
+	mu        sync.Mutex
+	sessions  map[string]bool
+	nextSess  int
+}
+
+// New returns a fake server.
+func New() *Server {
+	return &Server{sessions: map[string]bool{}}
+}
+
+// Initializations reports how many initialize requests the server has seen.
+func (s *Server) Initializations() int { return int(s.initCount.Load()) }
+
+// DropSessions forgets every HTTP session, as a restarted server would.
+func (s *Server) DropSessions() {
+	s.mu.Lock()
+	s.sessions = map[string]bool{}
+	s.mu.Unlock()
+}
+
+var tools = []mcp.Tool{
+	{Name: "echo", Description: "Echo text back", InputSchema: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"text": map[string]any{"type": "string"}},
+		"required":   []any{"text"},
+	}},
+	{Name: "add", Description: "Add two numbers", InputSchema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"a": map[string]any{"type": "number"},
+			"b": map[string]any{"type": "number"},
+		},
+		"required": []any{"a", "b"},
+	}},
+	{Name: "fail", Description: "Always fails", InputSchema: map[string]any{"type": "object"}},
+	{Name: "crash", Description: "Exit without answering", InputSchema: map[string]any{"type": "object"}},
+}
+
+// Handle answers one message; it returns nil for notifications.
+func (s *Server) Handle(msg *mcp.Message) *mcp.Message {
+	if !msg.IsRequest() {
+		return nil
+	}
+	result, rpcErr := s.dispatch(msg)
+	if rpcErr != nil {
+		return &mcp.Message{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr}
+	}
+	resp, err := mcp.NewResult(msg.ID, result)
+	if err != nil {
+		return mcp.NewError(msg.ID, mcp.CodeInternalError, err.Error())
+	}
+	return resp
+}
+
+func (s *Server) dispatch(msg *mcp.Message) (any, *mcp.RPCError) {
+	var params map[string]any
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &mcp.RPCError{Code: mcp.CodeInvalidParams, Message: err.Error()}
+		}
+	}
+	switch msg.Method {
+	case "initialize":
+		s.initCount.Add(1)
+		return mcp.InitializeResult{
+			ProtocolVersion: mcp.ProtocolVersion,
+			Capabilities: mcp.ServerCapabilities{
+				Tools:     &mcp.ListChanged{},
+				Resources: &mcp.ResourcesCapability{},
+				Prompts:   &mcp.ListChanged{},
+			},
+			ServerInfo: mcp.Implementation{Name: "mcptest", Version: "1.0"},
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		start, _ := strconv.Atoi(str(params, "cursor"))
+		end := min(start+pageSize, len(tools))
+		res := mcp.ListToolsResult{Tools: tools[start:end]}
+		if end < len(tools) {
+			res.NextCursor = strconv.Itoa(end)
+		}
+		return res, nil
+	case "tools/call":
+		args, _ := params["arguments"].(map[string]any)
+		return s.call(str(params, "name"), args)
+	case "resources/list":
+		return mcp.ListResourcesResult{Resources: []mcp.Resource{
+			{URI: "mem://greeting", Name: "greeting", MimeType: "text/plain"},
+		}}, nil
+	case "resources/read":
+		if uri := str(params, "uri"); uri != "mem://greeting" {
+			return nil, &mcp.RPCError{Code: mcp.CodeInvalidParams, Message: "unknown resource " + uri}
+		}
+		return mcp.ReadResourceResult{Contents: []mcp.ResourceContents{
+			{URI: "mem://greeting", MimeType: "text/plain", Text: "hello from mcptest"},
+		}}, nil
+	case "prompts/list":
+		return mcp.ListPromptsResult{Prompts: []mcp.Prompt{{
+			Name: "greet", Description: "Greet someone",
+			Arguments: []mcp.PromptArgument{{Name: "name", Required: true}},
+		}}}, nil
+	case "prompts/get":
+		if str(params, "name") != "greet" {
+			return nil, &mcp.RPCError{Code: mcp.CodeInvalidParams, Message: "unknown prompt"}
+		}
+		args, _ := params["arguments"].(map[string]any)
+		return mcp.GetPromptResult{Messages: []mcp.PromptMessage{{
+			Role:    "user",
+			Content: mcp.Content{Type: "text", Text: fmt.Sprintf("Hello, %v!", args["name"])},
+		}}}, nil
+	default:
+		return nil, &mcp.RPCError{Code: mcp.CodeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+}
+
+func (s *Server) call(name string, args map[string]any) (any, *mcp.RPCError) {
+	text := func(t string) mcp.CallToolResult {
+		return mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: t}}}
+	}
+	switch name {
+	case "echo":
+		return text(str(args, "text")), nil
+	case "add":
+		a, _ := args["a"].(float64)
+		b, _ := args["b"].(float64)
+		return text(strconv.FormatFloat(a+b, 'f', -1, 64)), nil
+	case "fail":
+		r := text("tool failed on purpose")
+		r.IsError = true
+		return r, nil
+	case "crash":
+		os.Exit(3)
+	}
+	return nil, &mcp.RPCError{Code: mcp.CodeInvalidParams, Message: "unknown tool " + name}
+}
+
+func str(m map[string]any, k string) string {
+	v, _ := m[k].(string)
+	return v
+}
+
+// ServeStdio reads newline-delimited messages from r and writes responses
+// to w until r is exhausted.
+func (s *Server) ServeStdio(r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(w)
+	for sc.Scan() {
+		var msg mcp.Message
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			continue
+		}
+		if resp := s.Handle(&msg); resp != nil {
+			if err := enc.Encode(resp); err != nil {
+				return err
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// ServeHTTP implements the streamable HTTP transport.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		s.mu.Lock()
+		delete(s.sessions, r.Header.Get(mcp.SessionHeader))
+		s.mu.Unlock()
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var msg mcp.Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if msg.Method == "initialize" {
+		s.mu.Lock()
+		s.nextSess++
+		id := fmt.Sprintf("sess-%d", s.nextSess)
+		s.sessions[id] = true
+		s.mu.Unlock()
+		w.Header().Set(mcp.SessionHeader, id)
+	} else {
+		s.mu.Lock()
+		ok := s.sessions[r.Header.Get(mcp.SessionHeader)]
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+	}
+	resp := s.Handle(&msg)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if !s.SSE {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	progress, _ := mcp.NewNotification("notifications/progress", mcp.ProgressParams{ProgressToken: "t", Progress: 1, Total: 1})
+	for _, m := range []*mcp.Message{progress, resp} {
+		b, _ := json.Marshal(m)
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", b)
+	}
+}
@@ -0,0 +1,278 @@
+// Package mcp implements the Model Context Protocol: JSON-RPC 2.0 messages,
+// the stdio and streamable HTTP transports, and a client that discovers and
+// calls a server's tools, resources and prompts.
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ProtocolVersion is the MCP revision this package speaks.
+const ProtocolVersion = "2025-03-26"
+
+// Standard JSON-RPC error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Message is a JSON-RPC 2.0 request, notification or response. Requests
+// carry an ID and a Method, notifications only a Method, and responses an ID
+// with either Result or Error.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// IsRequest reports whether m expects a response.
+func (m *Message) IsRequest() bool { return m.Method != "" && m.ID != nil }
+
+// IsNotification reports whether m is a one-way notification.
+func (m *Message) IsNotification() bool { return m.Method != "" && m.ID == nil }
+
+// IsResponse reports whether m answers an earlier request.
+func (m *Message) IsResponse() bool { return m.Method == "" && m.ID != nil }
+
+// NewRequest builds a request with a numeric ID.
+func NewRequest(id int64, method string, params any) (*Message, error) {
+	m, err := NewNotification(method, params)
+	if err != nil {
+		return nil, err
+	}
+	m.ID = json.RawMessage(strconv.FormatInt(id, 10))
+	return m, nil
+}
+
+// NewNotification builds a notification.
+func NewNotification(method string, params any) (*Message, error) {
+	m := &Message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		m.Params = b
+	}
+	return m, nil
+}
+
+// NewResult builds a successful response to the request with the given ID.
+func NewResult(id json.RawMessage, result any) (*Message, error) {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{JSONRPC: "2.0", ID: id, Result: b}, nil
+}
+
+// NewError builds an error response to the request with the given ID.
+func NewError(id json.RawMessage, code int, msg string) *Message {
+	return &Message{JSONRPC: "2.0", ID: id, Error: &RPCError{Code: code, Message: msg}}
+}
+
+// RPCError is a JSON-RPC error object returned by the peer.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// Implementation names a client or server.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// ListChanged advertises support for list_changed notifications.
+type ListChanged struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ResourcesCapability advertises resource support.
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ServerCapabilities lists the optional features a server supports.
+type ServerCapabilities struct {
+	Tools     *ListChanged         `json:"tools,omitempty"`
+	Resources *ResourcesCapability `json:"resources,omitempty"`
+	Prompts   *ListChanged         `json:"prompts,omitempty"`
+	Logging   *struct{}            `json:"logging,omitempty"`
+}
+
+// ClientCapabilities lists the optional features a client supports.
+type ClientCapabilities struct {
+	Roots    *ListChanged `json:"roots,omitempty"`
+	Sampling *struct{}    `json:"sampling,omitempty"`
+}
+
+// InitializeParams is sent by the client to open a session.
+type InitializeParams struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+	ClientInfo      Implementation     `json:"clientInfo"`
+}
+
+// InitializeResult is the server's half of the handshake.
+type InitializeResult struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
+	ServerInfo      Implementation     `json:"serverInfo"`
+	Instructions    string             `json:"instructions,omitempty"`
+}
+
+// Tool describes a callable tool and the JSON Schema of its arguments.
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// ListToolsResult is one page of tools/list.
+type ListToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// CallToolParams invokes a tool.
+type CallToolParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Meta      *RequestMeta   `json:"_meta,omitempty"`
+}
+
+// RequestMeta carries request metadata; a ProgressToken asks the server to
+// send notifications/progress for the request.
+type RequestMeta struct {
+	ProgressToken any `json:"progressToken,omitempty"`
+}
+
+// Content is one block of a tool result or prompt message.
+type Content struct {
+	Type     string            `json:"type"` // text, image, audio, resource
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"` // base64 for image and audio
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// CallToolResult is the outcome of tools/call. IsError marks failures the
+// tool itself reported, as opposed to protocol errors.
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	// StructuredContent is the result as a JSON object, alongside the
+	// text in Content.
+	StructuredContent any  `json:"structuredContent,omitempty"`
+	IsError           bool `json:"isError,omitempty"`
+}
+
+// Text flattens the result into a string suitable for a model. Binary
+// content is summarised rather than inlined.
+func (r *CallToolResult) Text() string {
+	return contentText(r.Content)
+}
+
+func contentText(blocks []Content) string {
+	var out []byte
+	for i, c := range blocks {
+		if i > 0 {
+			out = append(out, '\n')
+		}
+		switch c.Type {
+		case "text":
+			out = append(out, c.Text...)
+		case "resource":
+			if c.Resource != nil && c.Resource.Text != "" {
+				out = append(out, c.Resource.Text...)
+			} else if c.Resource != nil {
+				out = fmt.Appendf(out, "[resource %s]", c.Resource.URI)
+			}
+		default:
+			out = fmt.Appendf(out, "[%s %s, %d bytes base64]", c.Type, c.MimeType, len(c.Data))
+		}
+	}
+	return string(out)
+}
+
+// Resource is an entry from resources/list.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ListResourcesResult is one page of resources/list.
+type ListResourcesResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// ResourceContents is the body of a resource; exactly one of Text or Blob
+// (base64) is set.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// ReadResourceResult is returned by resources/read.
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// PromptArgument describes one template parameter of a prompt.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Prompt is an entry from prompts/list.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// ListPromptsResult is one page of prompts/list.
+type ListPromptsResult struct {
+	Prompts    []Prompt `json:"prompts"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// PromptMessage is one message of a rendered prompt.
+type PromptMessage struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// GetPromptResult is returned by prompts/get.
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// ProgressParams is the payload of notifications/progress.
+type ProgressParams struct {
+	ProgressToken any     `json:"progressToken"`
+	Progress      float64 `json:"progress"`
+	Total         float64 `json:"total,omitempty"`
+	Message       string  `json:"message,omitempty"`
+}
@@ -0,0 +1,456 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolHandler runs one tool call. progress sends a notifications/progress
+// message to the caller if it asked for progress and is a no-op otherwise.
+// A returned error is reported to the caller as an isError result.
+type ToolHandler func(ctx context.Context, args map[string]any, progress func(message string)) (*CallToolResult, error)
+
+// Server exposes tools to MCP clients over stdio (ServeStdio) or streamable
+// HTTP (ServeHTTP). Only the tools capability is offered.
+type Server struct {
+	Info         Implementation
+	Instructions string
+	// Token, if set, must be sent by HTTP clients as a bearer token.
+	Token string
+	// SessionIdle closes HTTP sessions unused for this long and MaxSessions
+	// caps how many are open, closing the least recently used to make room.
+	// Zero selects DefaultSessionIdle and DefaultMaxSessions.
+	SessionIdle time.Duration
+	MaxSessions int
+
+	mu       sync.RWMutex
+	tools    []Tool
+	handlers map[string]ToolHandler
+
+	sessMu   sync.Mutex
+	sessions map[string]time.Time // session ID -> last request
+}
+
+// Defaults for Server.SessionIdle and Server.MaxSessions.
+const (
+	DefaultSessionIdle = 30 * time.Minute
+	DefaultMaxSessions = 64
+)
+
+// NewServer returns a server that identifies itself as name/version.
+func NewServer(name, version string) *Server {
+	return &Server{
+		Info:     Implementation{Name: name, Version: version},
+		handlers: map[string]ToolHandler{},
+		sessions: map[string]time.Time{},
+	}
+}
+
+// AddTool registers t, replacing any tool with the same name.
+func (s *Server) AddTool(t Tool, h ToolHandler) {
+	if t.InputSchema == nil {
+		t.InputSchema = map[string]any{"type": "object"}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.handlers[t.Name]; ok {
+		for i := range s.tools {
+			if s.tools[i].Name == t.Name {
+				s.tools[i] = t
+			}
+		}
+	} else {
+		s.tools = append(s.tools, t)
+	}
+	s.handlers[t.Name] = h
+}
+
+// Tools returns the registered tools in registration order.
+func (s *Server) Tools() []Tool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Tool(nil), s.tools...)
+}
+
+// Handle answers one message and returns nil for anything that is not a
+// request. notify, if non-nil, receives the notifications produced while the
+// request runs (progress).
+func (s *Server) Handle(ctx context.Context, msg *Message, notify func(*Message)) *Message {
+	if !msg.IsRequest() {
+		return nil
+	}
+	result, rpcErr := s.dispatch(ctx, msg, notify)
+	if rpcErr != nil {
+		return &Message{JSONRPC: "2.0", ID: msg.ID, Error: rpcErr}
+	}
+	resp, err := NewResult(msg.ID, result)
+	if err != nil {
+		return NewError(msg.ID, CodeInternalError, err.Error())
+	}
+	return resp
+}
+
+func (s *Server) dispatch(ctx context.Context, msg *Message, notify func(*Message)) (any, *RPCError) {
+	switch msg.Method {
+	case "initialize":
+		var p InitializeParams
+		if len(msg.Params) > 0 {
+			if err := json.Unmarshal(msg.Params, &p); err != nil {
+				return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+			}
+		}
+		version := ProtocolVersion
+		if supportedVersions[p.ProtocolVersion] {
+			version = p.ProtocolVersion
+		}
+		return InitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    ServerCapabilities{Tools: &ListChanged{}},
+			ServerInfo:      s.Info,
+			Instructions:    s.Instructions,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return ListToolsResult{Tools: s.Tools()}, nil
+	case "tools/call":
+		var p CallToolParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		s.mu.RLock()
+		h, ok := s.handlers[p.Name]
+		s.mu.RUnlock()
+		if !ok {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown tool " + p.Name}
+		}
+		if p.Arguments == nil {
+			p.Arguments = map[string]any{}
+		}
+		res, err := h(ctx, p.Arguments, progressFunc(p.Meta, notify))
+		if err != nil {
+			return CallToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+		}
+		if res == nil {
+			res = &CallToolResult{Content: []Content{}}
+		}
+		return res, nil
+	default:
+		return nil, &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+}
+
+// progressFunc returns the progress callback for a request: it counts up
+// from 1 and is silent unless the caller supplied a progress token.
+func progressFunc(meta *RequestMeta, notify func(*Message)) func(string) {
+	if meta == nil || meta.ProgressToken == nil || notify == nil {
+		return func(string) {}
+	}
+	var mu sync.Mutex
+	n := 0
+	return func(message string) {
+		mu.Lock()
+		n++
+		p := ProgressParams{ProgressToken: meta.ProgressToken, Progress: float64(n), Message: message}
+		mu.Unlock()
+		if m, err := NewNotification("notifications/progress", p); err == nil {
+			notify(m)
+		}
+	}
+}
+
+// ServeStdio reads newline-delimited messages from r and writes responses
+// and notifications to w until r is exhausted or ctx ends. Tool calls run
+// concurrently and are cancelled by notifications/cancelled.
+func (s *Server) ServeStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wmu sync.Mutex
+	enc := json.NewEncoder(w)
+	write := func(m *Message) {
+		wmu.Lock()
+		defer wmu.Unlock()
+		_ = enc.Encode(m)
+	}
+
+	var (
+		mu       sync.Mutex
+		inflight = map[string]context.CancelFunc{}
+		wg       sync.WaitGroup
+	)
+	defer wg.Wait()
+
+	lines := make(chan []byte)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for sc.Scan() {
+			select {
+			case lines <- append([]byte(nil), sc.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- sc.Err()
+	}()
+
+	for {
+		var line []byte
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case l, ok := <-lines:
+			if !ok {
+				select {
+				case err := <-scanErr:
+					return err
+				default:
+					return nil
+				}
+			}
+			line = bytes.TrimSpace(l)
+		}
+		if len(line) == 0 {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			write(NewError(json.RawMessage("null"), CodeParseError, err.Error()))
+			continue
+		}
+		if msg.Method == "notifications/cancelled" {
+			var p struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			if json.Unmarshal(msg.Params, &p) == nil {
+				mu.Lock()
+				if c := inflight[string(p.RequestID)]; c != nil {
+					c()
+				}
+				mu.Unlock()
+			}
+			continue
+		}
+		if !msg.IsRequest() {
+			continue
+		}
+		if msg.Method != "tools/call" {
+			write(s.Handle(ctx, &msg, nil))
+			continue
+		}
+		id := string(msg.ID)
+		callCtx, callCancel := context.WithCancel(ctx)
+		mu.Lock()
+		inflight[id] = callCancel
+		mu.Unlock()
+		wg.Add(1)
+		go func(msg Message) {
+			defer wg.Done()
+			defer func() {
+				mu.Lock()
+				delete(inflight, id)
+				mu.Unlock()
+				callCancel()
+			}()
+			write(s.Handle(callCtx, &msg, write))
+		}(msg)
+	}
+}
+
+// ServeHTTP implements the streamable HTTP transport. initialize opens a
+// session identified by SessionHeader, which lasts until the client sends
+// DELETE, it goes idle or it is displaced at the cap. Tool calls from
+// clients that accept an event stream get their progress notifications on
+// it before the result.
+//
+// Requests whose Origin is not a loopback host are refused, so a web page
+// cannot drive the server through the user's browser (DNS rebinding).
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" && !LocalOrigin(origin) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if s.Token != "" {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodDelete:
+		s.sessMu.Lock()
+		delete(s.sessions, r.Header.Get(SessionHeader))
+		s.sessMu.Unlock()
+		return
+	case http.MethodPost:
+	default:
+		// No server-initiated stream is offered.
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg Message
+	if err := json.NewDecoder(io.LimitReader(r.Body, 16<<20)).Decode(&msg); err != nil {
+		writeJSON(w, http.StatusBadRequest, NewError(json.RawMessage("null"), CodeParseError, err.Error()))
+		return
+	}
+	if msg.Method == "initialize" {
+		id, err := newSessionID()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.openSession(id)
+		w.Header().Set(SessionHeader, id)
+	} else {
+		sid := r.Header.Get(SessionHeader)
+		if sid == "" {
+			http.Error(w, "missing "+SessionHeader+" header", http.StatusBadRequest)
+			return
+		}
+		if !s.touchSession(sid) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+	}
+
+	if !msg.IsRequest() {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	fl, canFlush := w.(http.Flusher)
+	if msg.Method != "tools/call" || !canFlush || !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		writeJSON(w, http.StatusOK, s.Handle(r.Context(), &msg, nil))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fl.Flush()
+	var (
+		wmu  sync.Mutex
+		done bool
+	)
+	send := func(m *Message) {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return
+		}
+		wmu.Lock()
+		defer wmu.Unlock()
+		if done { // a handler reporting progress after it returned
+			return
+		}
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", b)
+		fl.Flush()
+	}
+	resp := s.Handle(r.Context(), &msg, send)
+	send(resp)
+	wmu.Lock()
+	done = true
+	wmu.Unlock()
+}
+
+// openSession records a new session, first dropping idle ones and, at the
+// cap, the least recently used.
+func (s *Server) openSession(id string) {
+	idle, max := s.SessionIdle, s.MaxSessions
+	if idle <= 0 {
+		idle = DefaultSessionIdle
+	}
+	if max <= 0 {
+		max = DefaultMaxSessions
+	}
+	now := time.Now()
+	s.sessMu.Lock()
+	defer s.sessMu.Unlock()
+	for sid, last := range s.sessions {
+		if now.Sub(last) > idle {
+			delete(s.sessions, sid)
+		}
+	}
+	for len(s.sessions) >= max {
+		var oldest string
+		for sid, last := range s.sessions {
+			if oldest == "" || last.Before(s.sessions[oldest]) {
+				oldest = sid
+			}
+		}
+		delete(s.sessions, oldest)
+	}
+	s.sessions[id] = now
+}
+
+// touchSession reports whether id is an open session that has not been idle
+// too long, and marks it used.
+func (s *Server) touchSession(id string) bool {
+	idle := s.SessionIdle
+	if idle <= 0 {
+		idle = DefaultSessionIdle
+	}
+	now := time.Now()
+	s.sessMu.Lock()
+	defer s.sessMu.Unlock()
+	last, ok := s.sessions[id]
+	if !ok {
+		return false
+	}
+	if now.Sub(last) > idle {
+		delete(s.sessions, id)
+		return false
+	}
+	s.sessions[id] = now
+	return true
+}
+
+// LocalOrigin reports whether an Origin header names a loopback host.
+func LocalOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return LoopbackHost(u.Hostname())
+}
+
+// LoopbackHost reports whether host is localhost or a loopback IP.
+func LoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, m *Message) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(m)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
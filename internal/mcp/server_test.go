@@ -0,0 +1,198 @@
+package mcp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/mcp"
+)
+
+// newTestServer offers "steps" {n}, which reports n progress messages, and
+// "wait", which blocks until its call is cancelled.
+func newTestServer() *mcp.Server {
+	s := mcp.NewServer("agentry-test", "1.0")
+	s.AddTool(mcp.Tool{Name: "steps", Description: "Report progress", InputSchema: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"n": map[string]any{"type": "integer"}},
+	}}, func(ctx context.Context, args map[string]any, progress func(string)) (*mcp.CallToolResult, error) {
+		n, _ := args["n"].(float64)
+		if n < 0 {
+			return nil, errors.New("n must not be negative")
+		}
+		for i := 1; i <= int(n); i++ {
+			progress(fmt.Sprintf("step %d", i))
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: fmt.Sprintf("did %d steps", int(n))}}}, nil
+	})
+	s.AddTool(mcp.Tool{Name: "wait"}, func(ctx context.Context, _ map[string]any, _ func(string)) (*mcp.CallToolResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	return s
+}
+
+func exerciseServer(t *testing.T, c *mcp.Client) {
+	t.Helper()
+	var mu sync.Mutex
+	var messages []string
+	c.OnNotification = func(method string, params json.RawMessage) {
+		var p mcp.ProgressParams
+		if method == "notifications/progress" && json.Unmarshal(params, &p) == nil {
+			mu.Lock()
+			messages = append(messages, p.Message)
+			mu.Unlock()
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 2 || tools[1].InputSchema["type"] != "object" {
+		t.Fatalf("list tools: %+v %v", tools, err)
+	}
+	if info, _ := c.Info(); info.ServerInfo.Name != "agentry-test" || info.Capabilities.Tools == nil {
+		t.Fatalf("unexpected server info: %+v", info)
+	}
+
+	res, err := c.CallTool(ctx, "steps", map[string]any{"n": 3})
+	if err != nil || res.IsError || res.Text() != "did 3 steps" {
+		t.Fatalf("steps: %+v %v", res, err)
+	}
+	// Progress arrives before the response on both transports.
+	mu.Lock()
+	got := fmt.Sprint(messages)
+	mu.Unlock()
+	if got != "[step 1 step 2 step 3]" {
+		t.Fatalf("progress messages = %s", got)
+	}
+
+	res, err = c.CallTool(ctx, "steps", map[string]any{"n": -1})
+	if err != nil || !res.IsError || res.Text() != "n must not be negative" {
+		t.Fatalf("handler errors should be tool errors: %+v %v", res, err)
+	}
+	var rpcErr *mcp.RPCError
+	if _, err := c.CallTool(ctx, "nope", nil); !errors.As(err, &rpcErr) || rpcErr.Code != mcp.CodeInvalidParams {
+		t.Fatalf("expected invalid params error, got %v", err)
+	}
+
+	// A cancelled call must not wedge the session.
+	short, stop := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer stop()
+	if _, err := c.CallTool(short, "wait", nil); err == nil {
+		t.Fatal("expected wait to time out")
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("ping after cancel: %v", err)
+	}
+}
+
+func TestServerStdio(t *testing.T) {
+	t.Setenv("MCPTEST_STDIO", "server")
+	c := mcp.NewClient("agentry", os.Args[0])
+	defer c.Close()
+	exerciseServer(t, c)
+}
+
+func TestServerHTTP(t *testing.T) {
+	srv := httptest.NewServer(newTestServer())
+	defer srv.Close()
+	c := mcp.NewClient("agentry", srv.URL)
+	defer c.Close()
+	exerciseServer(t, c)
+}
+
+func TestServerHTTPOriginAndToken(t *testing.T) {
+	s := newTestServer()
+	s.Token = "secret"
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	post := func(origin, auth string) int {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for _, c := range []struct {
+		origin, auth string
+		want         int
+	}{
+		{"https://evil.example", "Bearer secret", http.StatusForbidden},
+		{"null", "Bearer secret", http.StatusForbidden},
+		{"http://localhost:3000", "", http.StatusUnauthorized},
+		{"", "Bearer wrong", http.StatusUnauthorized},
+		{"http://127.0.0.1:3000", "Bearer secret", http.StatusOK},
+		{"", "Bearer secret", http.StatusOK},
+	} {
+		if got := post(c.origin, c.auth); got != c.want {
+			t.Errorf("origin %q auth %q: status %d, want %d", c.origin, c.auth, got, c.want)
+		}
+	}
+}
+
+func TestServerHTTPSessions(t *testing.T) {
+	s := newTestServer()
+	s.MaxSessions = 2
+	s.SessionIdle = 200 * time.Millisecond
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	do := func(method, sid, body string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL, strings.NewReader(body))
+		if sid != "" {
+			req.Header.Set(mcp.SessionHeader, sid)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	open := func() string {
+		return do(http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`).Header.Get(mcp.SessionHeader)
+	}
+	ping := func(sid string) int {
+		return do(http.MethodPost, sid, `{"jsonrpc":"2.0","id":2,"method":"ping"}`).StatusCode
+	}
+
+	a, b := open(), open()
+	if ping(a) != http.StatusOK || ping(b) != http.StatusOK {
+		t.Fatal("new sessions should be usable")
+	}
+	do(http.MethodDelete, a, "")
+	if got := ping(a); got != http.StatusNotFound {
+		t.Fatalf("deleted session: status %d", got)
+	}
+
+	// At the cap the least recently used session makes room.
+	c := open()
+	ping(b)
+	d := open()
+	if ping(c) != http.StatusNotFound || ping(b) != http.StatusOK || ping(d) != http.StatusOK {
+		t.Fatal("expected the least recently used session to be closed")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if got := ping(b); got != http.StatusNotFound {
+		t.Fatalf("idle session: status %d", got)
+	}
+}
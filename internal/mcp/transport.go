@@ -0,0 +1,240 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/debug"
+)
+
+// ErrClosed is returned when sending on a transport that has shut down.
+var ErrClosed = errors.New("mcp transport closed")
+
+// Transport moves JSON-RPC messages between a client and one server.
+type Transport interface {
+	// Send delivers one message to the server.
+	Send(ctx context.Context, msg *Message) error
+	// Recv yields messages from the server. It is closed when the
+	// connection ends, after which Err reports why.
+	Recv() <-chan *Message
+	Err() error
+	Close() error
+}
+
+// Dial opens a transport for spec: http:// and https:// URLs use streamable
+// HTTP, anything else is run as a stdio server command line.
+func Dial(ctx context.Context, spec string) (Transport, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		return NewHTTPTransport(spec, nil), nil
+	}
+	argv, err := splitCommand(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("empty MCP server command")
+	}
+	return StartStdio(argv[0], argv[1:]...)
+}
+
+// splitCommand splits a command line on whitespace, honouring single and
+// double quotes and backslash escapes. No shell is involved.
+func splitCommand(s string) ([]string, error) {
+	var (
+		args  []string
+		cur   strings.Builder
+		quote rune
+		inArg bool
+		esc   bool
+	)
+	for _, r := range s {
+		switch {
+		case esc:
+			cur.WriteRune(r)
+			esc = false
+		case r == '\\' && quote != '\'':
+			esc, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || esc {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// stdioTransport speaks newline-delimited JSON-RPC with a subprocess.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	recv   chan *Message
+	stderr *tailBuffer
+
+	writeMu sync.Mutex
+	once    sync.Once
+	done    chan struct{}
+	err     error
+}
+
+// StartStdio launches name with args and talks MCP over its stdin/stdout.
+// The server's stderr is kept for error reports.
+func StartStdio(name string, args ...string) (Transport, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Env = os.Environ()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	t := &stdioTransport{
+		cmd:    cmd,
+		stdin:  stdin,
+		recv:   make(chan *Message, 16),
+		stderr: &tailBuffer{max: 4096},
+		done:   make(chan struct{}),
+	}
+	cmd.Stderr = t.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start MCP server %s: %w", name, err)
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) readLoop(r io.Reader) {
+	defer close(t.recv)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var msg Message
+			if jerr := json.Unmarshal(line, &msg); jerr != nil {
+				debug.Printf("mcp: ignoring malformed line from server: %v", jerr)
+			} else {
+				t.recv <- &msg
+			}
+		}
+		if err != nil {
+			werr := t.cmd.Wait()
+			t.fail(t.exitError(werr))
+			return
+		}
+	}
+}
+
+func (t *stdioTransport) exitError(werr error) error {
+	msg := "MCP server exited"
+	if werr != nil {
+		msg += ": " + werr.Error()
+	}
+	if tail := strings.TrimSpace(t.stderr.String()); tail != "" {
+		msg += " (stderr: " + tail + ")"
+	}
+	return errors.New(msg)
+}
+
+func (t *stdioTransport) fail(err error) {
+	t.once.Do(func() {
+		t.err = err
+		close(t.done)
+	})
+}
+
+func (t *stdioTransport) Send(ctx context.Context, msg *Message) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-t.done:
+		return fmt.Errorf("%w: %v", ErrClosed, t.Err())
+	default:
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+func (t *stdioTransport) Recv() <-chan *Message { return t.recv }
+
+func (t *stdioTransport) Err() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// Close asks the server to exit by closing stdin, then kills it if it has
+// not gone within a grace period.
+func (t *stdioTransport) Close() error {
+	_ = t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		_ = t.cmd.Process.Kill()
+		<-t.done
+	}
+	t.fail(ErrClosed)
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
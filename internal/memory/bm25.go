@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// Default BM25 tuning parameters.
+const (
+	DefaultBM25K1 = 1.2
+	DefaultBM25B  = 0.75
+)
+
+// Result is a scored retrieval hit.
+type Result struct {
+	ID    string
+	Score float64
+	// Terms lists the query terms found in the document (keyword matches only).
+	Terms []string
+	// KeywordRank and VectorRank are 1-based positions in the individual
+	// rankings that were fused; zero means the document was absent.
+	KeywordRank int
+	VectorRank  int
+}
+
+// BM25 is an in-memory keyword index using Okapi BM25 scoring over
+// Tokenize terms. It is safe for concurrent use.
+type BM25 struct {
+	K1 float64
+	B  float64
+
+	mu       sync.RWMutex
+	docs     map[string]map[string]int // id -> term -> frequency
+	lens     map[string]int
+	df       map[string]int
+	totalLen int
+}
+
+// NewBM25 returns an empty index with default parameters.
+func NewBM25() *BM25 {
+	return &BM25{
+		K1:   DefaultBM25K1,
+		B:    DefaultBM25B,
+		docs: make(map[string]map[string]int),
+		lens: make(map[string]int),
+		df:   make(map[string]int),
+	}
+}
+
+// Add indexes text under id, replacing any previous document with that id.
+func (b *BM25) Add(id, text string) {
+	terms := Tokenize(text)
+	tf := make(map[string]int, len(terms))
+	for _, t := range terms {
+		tf[t]++
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(id)
+	b.docs[id] = tf
+	b.lens[id] = len(terms)
+	b.totalLen += len(terms)
+	for t := range tf {
+		b.df[t]++
+	}
+}
+
+// Remove drops id from the index.
+func (b *BM25) Remove(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(id)
+}
+
+func (b *BM25) removeLocked(id string) {
+	tf, ok := b.docs[id]
+	if !ok {
+		return
+	}
+	for t := range tf {
+		if b.df[t]--; b.df[t] <= 0 {
+			delete(b.df, t)
+		}
+	}
+	b.totalLen -= b.lens[id]
+	delete(b.docs, id)
+	delete(b.lens, id)
+}
+
+// Len returns the number of indexed documents.
+func (b *BM25) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.docs)
+}
+
+// Search returns up to k documents matching at least one query term,
+// ordered by descending BM25 score.
+func (b *BM25) Search(query string, k int) []Result {
+	qterms := uniqueTerms(Tokenize(query))
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	n := float64(len(b.docs))
+	if n == 0 || len(qterms) == 0 || k <= 0 {
+		return nil
+	}
+	avgLen := float64(b.totalLen) / n
+	if avgLen == 0 {
+		avgLen = 1
+	}
+	var res []Result
+	for id, tf := range b.docs {
+		var score float64
+		var matched []string
+		for _, t := range qterms {
+			f, ok := tf[t]
+			if !ok {
+				continue
+			}
+			df := float64(b.df[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			freq := float64(f)
+			norm := freq + b.K1*(1-b.B+b.B*float64(b.lens[id])/avgLen)
+			score += idf * freq * (b.K1 + 1) / norm
+			matched = append(matched, t)
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		res = append(res, Result{ID: id, Score: score, Terms: matched})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].ID < res[j].ID
+	})
+	if k < len(res) {
+		res = res[:k]
+	}
+	for i := range res {
+		res[i].KeywordRank = i + 1
+	}
+	return res
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]struct{}, len(terms))
+	out := terms[:0:0]
+	for _, t := range terms {
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/marcodenic/agentry/internal/memstore"
+)
+
+// DefaultRRFK is the rank constant used by reciprocal-rank fusion.
+const DefaultRRFK = 60
+
+// Searcher is implemented by stores that can return scored results
+// rather than bare IDs.
+type Searcher interface {
+	Search(ctx context.Context, text string, k int) ([]Result, error)
+}
+
+// Hybrid combines a BM25 keyword index with any VectorStore and merges the
+// two rankings using reciprocal-rank fusion. Exact identifiers are found by
+// the keyword side while the vector side contributes semantic matches.
+// Hybrid itself satisfies VectorStore, so it can be used wherever a plain
+// store is expected.
+type Hybrid struct {
+	Vector  VectorStore
+	Keyword *BM25
+	// RRFK dampens the influence of top ranks; zero means DefaultRRFK.
+	RRFK float64
+
+	// store and ns, when set, keep indexed text so the keyword index can be
+	// rebuilt by the next process using the same backend.
+	store memstore.SharedStore
+	ns    string
+}
+
+// NewHybrid wraps vs with a fresh keyword index. vs may be nil for
+// keyword-only retrieval.
+func NewHybrid(vs VectorStore) *Hybrid {
+	return &Hybrid{Vector: vs, Keyword: NewBM25(), RRFK: DefaultRRFK}
+}
+
+// NewStoredHybrid is NewHybrid for a persistent vector backend. The text of
+// each document is also saved in namespace ns of store, and the keyword
+// index starts with what earlier processes saved there, so keyword search
+// covers the same documents as the backend after a restart.
+func NewStoredHybrid(vs VectorStore, store memstore.SharedStore, ns string) (*Hybrid, error) {
+	h := NewHybrid(vs)
+	entries, err := store.Scan(ns, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		h.Keyword.Add(e.Key, string(e.Value))
+	}
+	h.store, h.ns = store, ns
+	return h, nil
+}
+
+// NewDefaultVector returns the store used when no backend is configured:
+// the in-memory vector store with a keyword index alongside.
+func NewDefaultVector() *Hybrid {
+	return NewHybrid(NewInMemoryVector())
+}
+
+func (h *Hybrid) Add(ctx context.Context, id, text string) error {
+	if h.store != nil {
+		if err := h.store.Set(h.ns, id, []byte(text), 0); err != nil {
+			return err
+		}
+	}
+	h.Keyword.Add(id, text)
+	if h.Vector == nil {
+		return nil
+	}
+	return h.Vector.Add(ctx, id, text)
+}
+
+func (h *Hybrid) Query(ctx context.Context, text string, k int) ([]string, error) {
+	res, err := h.Search(ctx, text, k)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(res))
+	for _, r := range res {
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
+
+// Search returns up to k fused results with their RRF score, the rank each
+// side assigned and the query terms that matched.
+func (h *Hybrid) Search(ctx context.Context, text string, k int) ([]Result, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	// Pull a deeper candidate list from each side so fusion can promote
+	// documents that rank moderately well in both.
+	depth := k * 4
+	if depth < 20 {
+		depth = 20
+	}
+	rrfK := h.RRFK
+	if rrfK <= 0 {
+		rrfK = DefaultRRFK
+	}
+
+	fused := map[string]*Result{}
+	get := func(id string) *Result {
+		r, ok := fused[id]
+		if !ok {
+			r = &Result{ID: id}
+			fused[id] = r
+		}
+		return r
+	}
+
+	for i, kr := range h.Keyword.Search(text, depth) {
+		r := get(kr.ID)
+		r.KeywordRank = i + 1
+		r.Terms = kr.Terms
+		r.Score += 1 / (rrfK + float64(i+1))
+	}
+
+	if h.Vector != nil {
+		ids, err := vectorRanking(ctx, h.Vector, text, depth)
+		if err != nil {
+			return nil, err
+		}
+		for i, id := range ids {
+			r := get(id)
+			r.VectorRank = i + 1
+			r.Score += 1 / (rrfK + float64(i+1))
+		}
+	}
+
+	out := make([]Result, 0, len(fused))
+	for _, r := range fused {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].ID < out[j].ID
+	})
+	if k < len(out) {
+		out = out[:k]
+	}
+	return out, nil
+}
+
+// vectorRanking prefers scored search so that zero-similarity documents
+// are not fused in as if they were matches.
+func vectorRanking(ctx context.Context, vs VectorStore, text string, k int) ([]string, error) {
+	if s, ok := vs.(Searcher); ok {
+		res, err := s.Search(ctx, text, k)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(res))
+		for _, r := range res {
+			ids = append(ids, r.ID)
+		}
+		return ids, nil
+	}
+	return vs.Query(ctx, text, k)
+}
@@ -0,0 +1,91 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/memstore"
+)
+
+func TestTokenizeSplitsIdentifiers(t *testing.T) {
+	got := Tokenize("parseHTTPRequest snake_case_name x")
+	want := []string{"parsehttprequest", "parse", "http", "request", "snakecasename", "snake", "case", "name"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tokenize = %v, want %v", got, want)
+	}
+}
+
+func TestBM25RanksExactIdentifier(t *testing.T) {
+	idx := NewBM25()
+	idx.Add("a", "func loadTodos reads the todo namespace")
+	idx.Add("b", "the board renders items in columns")
+	idx.Add("c", "todo items are rendered on the board")
+	res := idx.Search("LoadTodos", 2)
+	if len(res) == 0 || res[0].ID != "a" {
+		t.Fatalf("expected a first, got %#v", res)
+	}
+	if res[0].Score <= 0 || len(res[0].Terms) == 0 {
+		t.Fatalf("expected score and matched terms, got %#v", res[0])
+	}
+	idx.Remove("a")
+	if res := idx.Search("loadtodos", 1); len(res) != 0 {
+		t.Fatalf("expected no results after remove, got %#v", res)
+	}
+}
+
+func TestHybridFusesRankings(t *testing.T) {
+	ctx := context.Background()
+	h := NewDefaultVector()
+	_ = h.Add(ctx, "store", "SharedStore interface with Set Get Delete")
+	_ = h.Add(ctx, "board", "todo board shows shared items")
+	_ = h.Add(ctx, "other", "unrelated text about themes")
+
+	res, err := h.Search(ctx, "sharedStore delete", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) == 0 || res[0].ID != "store" {
+		t.Fatalf("expected store first, got %#v", res)
+	}
+	if res[0].KeywordRank != 1 || res[0].VectorRank == 0 {
+		t.Fatalf("expected both rankings to contribute, got %#v", res[0])
+	}
+	for _, r := range res {
+		if r.ID == "other" {
+			t.Fatalf("unmatched document should not be returned: %#v", res)
+		}
+	}
+
+	ids, err := h.Query(ctx, "sharedStore", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "store" {
+		t.Fatalf("unexpected ids: %#v", ids)
+	}
+}
+
+func TestStoredHybridRebuildsKeywordIndex(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.NewMemoryStore()
+	h, err := NewStoredHybrid(nil, store, "keyword-index:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = h.Add(ctx, "store", "SharedStore interface with Set Get Delete")
+	_ = h.Add(ctx, "board", "todo board shows shared items")
+
+	// A new process starts with the documents the last one indexed.
+	h, err = NewStoredHybrid(nil, store, "keyword-index:test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Keyword.Len() != 2 {
+		t.Fatalf("expected 2 documents, got %d", h.Keyword.Len())
+	}
+	ids, err := h.Query(ctx, "sharedStore", 1)
+	if err != nil || len(ids) != 1 || ids[0] != "store" {
+		t.Fatalf("unexpected ids: %v %v", ids, err)
+	}
+}
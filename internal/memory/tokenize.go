@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits text into lowercase search terms with code-aware handling.
+// Identifiers are kept whole and additionally split on snake_case and
+// camelCase boundaries, so "parseHTTPRequest" yields "parsehttprequest",
+// "parse", "http" and "request". Single-character terms are dropped.
+func Tokenize(text string) []string {
+	var out []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		word = strings.Trim(word, "_")
+		if word == "" {
+			continue
+		}
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			out = appendTerm(out, strings.ReplaceAll(word, "_", ""))
+		}
+		for _, p := range parts {
+			out = appendTerm(out, p)
+		}
+	}
+	return out
+}
+
+func appendTerm(out []string, term string) []string {
+	term = strings.ToLower(term)
+	if len([]rune(term)) < 2 {
+		return out
+	}
+	return append(out, term)
+}
+
+// splitIdentifier breaks an identifier on underscores and case changes.
+// Runs of capitals are treated as one acronym ("HTTPServer" → HTTP, Server).
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, seg := range strings.Split(word, "_") {
+		if seg == "" {
+			continue
+		}
+		rs := []rune(seg)
+		start := 0
+		for i := 1; i < len(rs); i++ {
+			prev, cur := rs[i-1], rs[i]
+			boundary := false
+			switch {
+			case unicode.IsLower(prev) && unicode.IsUpper(cur):
+				boundary = true
+			case unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(rs) && unicode.IsLower(rs[i+1]):
+				boundary = true
+			case unicode.IsLetter(prev) && unicode.IsDigit(cur), unicode.IsDigit(prev) && unicode.IsLetter(cur):
+				boundary = true
+			}
+			if boundary {
+				parts = append(parts, string(rs[start:i]))
+				start = i
+			}
+		}
+		parts = append(parts, string(rs[start:]))
+	}
+	return parts
+}
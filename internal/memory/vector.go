@@ -5,6 +5,7 @@ import (
 	"math"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // VectorStore defines minimal interface for vector retrieval.
@@ -17,6 +18,7 @@ type VectorStore interface {
 
 // InMemoryVector is a naive store keeping text docs.
 type InMemoryVector struct {
+	mu   sync.RWMutex
 	docs map[string]string
 	vecs map[string]map[string]float64
 }
@@ -26,32 +28,50 @@ func NewInMemoryVector() *InMemoryVector {
 }
 
 func (v *InMemoryVector) Add(_ context.Context, id, text string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	v.docs[id] = text
 	v.vecs[id] = embed(text)
 	return nil
 }
 
 func (v *InMemoryVector) Query(_ context.Context, text string, k int) ([]string, error) {
-	qv := embed(text)
-	type pair struct {
-		id  string
-		sim float64
-	}
-	list := make([]pair, 0, len(v.vecs))
-	for id, vec := range v.vecs {
-		list = append(list, pair{id: id, sim: cosine(vec, qv)})
-	}
-	sort.Slice(list, func(i, j int) bool { return list[i].sim > list[j].sim })
+	list := v.rank(text)
 	if k > len(list) {
 		k = len(list)
 	}
 	res := make([]string, 0, k)
 	for i := 0; i < k; i++ {
-		res = append(res, list[i].id)
+		res = append(res, list[i].ID)
 	}
 	return res, nil
 }
 
+// Search returns up to k documents with non-zero cosine similarity.
+func (v *InMemoryVector) Search(_ context.Context, text string, k int) ([]Result, error) {
+	var res []Result
+	for _, r := range v.rank(text) {
+		if len(res) >= k || r.Score <= 0 {
+			break
+		}
+		r.VectorRank = len(res) + 1
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (v *InMemoryVector) rank(text string) []Result {
+	qv := embed(text)
+	v.mu.RLock()
+	list := make([]Result, 0, len(v.vecs))
+	for id, vec := range v.vecs {
+		list = append(list, Result{ID: id, Score: cosine(vec, qv)})
+	}
+	v.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+	return list
+}
+
 func embed(text string) map[string]float64 {
 	vec := map[string]float64{}
 	for _, w := range strings.Fields(strings.ToLower(text)) {
@@ -0,0 +1,361 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ttlBucket indexes expiring keys as <expiry:8><ns>\x00<key> so cleanup is
+// a prefix walk rather than a full scan. The leading NUL keeps it from
+// colliding with namespace buckets.
+var ttlBucket = []byte("\x00ttl")
+
+// boltStore is a transactional SharedStore backed by a single bbolt file.
+// Each namespace is a top-level bucket and values are stored as an 8-byte
+// big-endian expiry (unix nanos, zero for none) followed by the raw bytes.
+type boltStore struct {
+	db  *bolt.DB
+	hub watchHub
+}
+
+// NewBoltStore opens (or creates) a bbolt database at path. If path is an
+// existing directory the database is created inside it as store.db.
+func NewBoltStore(path string) (SharedStore, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "store.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("open bolt store %s: in use by another process (one process at a time can open a bolt store; use the file backend to share it)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open bolt store %s: %w", path, err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+func encodeValue(val []byte, exp time.Time) []byte {
+	out := make([]byte, 8+len(val))
+	if !exp.IsZero() {
+		binary.BigEndian.PutUint64(out, uint64(exp.UnixNano()))
+	}
+	copy(out[8:], val)
+	return out
+}
+
+func decodeValue(raw []byte) ([]byte, time.Time) {
+	if len(raw) < 8 {
+		return nil, time.Time{}
+	}
+	var exp time.Time
+	if n := binary.BigEndian.Uint64(raw); n != 0 {
+		exp = time.Unix(0, int64(n))
+	}
+	return append([]byte(nil), raw[8:]...), exp
+}
+
+func ttlKey(exp time.Time, ns, key string) []byte {
+	out := make([]byte, 8, 8+len(ns)+1+len(key))
+	binary.BigEndian.PutUint64(out, uint64(exp.UnixNano()))
+	out = append(out, ns...)
+	out = append(out, 0)
+	return append(out, key...)
+}
+
+func expired(exp time.Time, now time.Time) bool {
+	return !exp.IsZero() && !now.Before(exp)
+}
+
+func setTx(tx *bolt.Tx, ns, key string, val []byte, ttl time.Duration) error {
+	if ns == "" || key == "" {
+		return errors.New("namespace and key required")
+	}
+	b, err := tx.CreateBucketIfNotExists([]byte(ns))
+	if err != nil {
+		return err
+	}
+	if err := dropTTL(tx, b, ns, key); err != nil {
+		return err
+	}
+	var exp time.Time
+	if ttl > 0 {
+		exp = time.Now().Add(ttl)
+		idx, err := tx.CreateBucketIfNotExists(ttlBucket)
+		if err != nil {
+			return err
+		}
+		if err := idx.Put(ttlKey(exp, ns, key), nil); err != nil {
+			return err
+		}
+	}
+	return b.Put([]byte(key), encodeValue(val, exp))
+}
+
+func deleteTx(tx *bolt.Tx, ns, key string) (bool, error) {
+	b := tx.Bucket([]byte(ns))
+	if b == nil || b.Get([]byte(key)) == nil {
+		return false, nil
+	}
+	if err := dropTTL(tx, b, ns, key); err != nil {
+		return false, err
+	}
+	if err := b.Delete([]byte(key)); err != nil {
+		return false, err
+	}
+	if k, _ := b.Cursor().First(); k == nil {
+		return true, tx.DeleteBucket([]byte(ns))
+	}
+	return true, nil
+}
+
+// update runs fn in a write transaction and publishes the events it
+// emitted once the transaction has committed.
+func (s *boltStore) update(fn func(tx *bolt.Tx, emit func(Event)) error) error {
+	var events []Event
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		events = events[:0]
+		return fn(tx, func(ev Event) { events = append(events, ev) })
+	})
+	if err != nil {
+		return err
+	}
+	for _, ev := range events {
+		s.hub.publish(ev)
+	}
+	return nil
+}
+
+// dropTTL removes the index entry for the key's current expiry, if any.
+func dropTTL(tx *bolt.Tx, b *bolt.Bucket, ns, key string) error {
+	raw := b.Get([]byte(key))
+	if raw == nil {
+		return nil
+	}
+	_, exp := decodeValue(raw)
+	if exp.IsZero() {
+		return nil
+	}
+	if idx := tx.Bucket(ttlBucket); idx != nil {
+		return idx.Delete(ttlKey(exp, ns, key))
+	}
+	return nil
+}
+
+func (s *boltStore) Set(ns, key string, val []byte, ttl time.Duration) error {
+	return s.update(func(tx *bolt.Tx, emit func(Event)) error {
+		if err := setTx(tx, ns, key, val, ttl); err != nil {
+			return err
+		}
+		emit(Event{Type: EventSet, NS: ns, Key: key, Value: val})
+		return nil
+	})
+}
+
+func (s *boltStore) Get(ns, key string) ([]byte, bool, error) {
+	var (
+		val []byte
+		exp time.Time
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ns))
+		if b == nil {
+			return nil
+		}
+		if raw := b.Get([]byte(key)); raw != nil {
+			val, exp = decodeValue(raw)
+		}
+		return nil
+	})
+	if err != nil || val == nil {
+		return nil, false, err
+	}
+	if expired(exp, time.Now()) {
+		// expired; cleanup lazily
+		_ = s.update(func(tx *bolt.Tx, emit func(Event)) error {
+			if ok, err := deleteTx(tx, ns, key); err != nil || !ok {
+				return err
+			}
+			emit(Event{Type: EventExpire, NS: ns, Key: key})
+			return nil
+		})
+		return nil, false, nil
+	}
+	return val, true, nil
+}
+
+func (s *boltStore) Delete(ns, key string) error {
+	return s.update(func(tx *bolt.Tx, emit func(Event)) error {
+		if ok, err := deleteTx(tx, ns, key); err != nil || !ok {
+			return err
+		}
+		emit(Event{Type: EventDelete, NS: ns, Key: key})
+		return nil
+	})
+}
+
+func (s *boltStore) Keys(ns string) ([]string, error) {
+	entries, err := s.Scan(ns, "")
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.Key)
+	}
+	return res, nil
+}
+
+func (s *boltStore) Scan(ns, prefix string) ([]Entry, error) {
+	res := make([]Entry, 0)
+	now := time.Now()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ns))
+		if b == nil {
+			return nil
+		}
+		p := []byte(prefix)
+		c := b.Cursor()
+		for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+			val, exp := decodeValue(v)
+			if expired(exp, now) {
+				continue
+			}
+			res = append(res, Entry{Key: string(k), Value: val, ExpiresAt: exp})
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (s *boltStore) Namespaces() ([]string, error) {
+	res := make([]string, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if bytes.Equal(name, ttlBucket) {
+				return nil
+			}
+			if k, _ := b.Cursor().First(); k != nil {
+				res = append(res, string(name))
+			}
+			return nil
+		})
+	})
+	sort.Strings(res)
+	return res, err
+}
+
+func (s *boltStore) Batch(ops []Op) error {
+	return s.update(func(tx *bolt.Tx, emit func(Event)) error {
+		for _, op := range ops {
+			if op.Delete {
+				if op.NS == "" || op.Key == "" {
+					return errors.New("namespace and key required")
+				}
+				ok, err := deleteTx(tx, op.NS, op.Key)
+				if err != nil {
+					return err
+				}
+				if ok {
+					emit(Event{Type: EventDelete, NS: op.NS, Key: op.Key})
+				}
+				continue
+			}
+			if err := setTx(tx, op.NS, op.Key, op.Value, op.TTL); err != nil {
+				return err
+			}
+			emit(Event{Type: EventSet, NS: op.NS, Key: op.Key, Value: op.Value})
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) CompareAndSwap(ns, key string, old, val []byte, ttl time.Duration) (bool, error) {
+	swapped := false
+	err := s.update(func(tx *bolt.Tx, emit func(Event)) error {
+		var (
+			cur   []byte
+			found bool
+		)
+		if b := tx.Bucket([]byte(ns)); b != nil {
+			if raw := b.Get([]byte(key)); raw != nil {
+				var exp time.Time
+				cur, exp = decodeValue(raw)
+				found = !expired(exp, time.Now())
+			}
+		}
+		if old == nil {
+			if found {
+				return nil
+			}
+		} else if !found || !bytes.Equal(cur, old) {
+			return nil
+		}
+		swapped = true
+		emit(Event{Type: EventSet, NS: ns, Key: key, Value: val})
+		return setTx(tx, ns, key, val, ttl)
+	})
+	return swapped, err
+}
+
+func (s *boltStore) CleanupExpired() error {
+	now := time.Now()
+	return s.update(func(tx *bolt.Tx, emit func(Event)) error {
+		idx := tx.Bucket(ttlBucket)
+		if idx == nil {
+			return nil
+		}
+		var done [][]byte
+		c := idx.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			if len(k) < 9 || int64(binary.BigEndian.Uint64(k)) > now.UnixNano() {
+				break
+			}
+			done = append(done, append([]byte(nil), k...))
+		}
+		for _, k := range done {
+			if err := idx.Delete(k); err != nil {
+				return err
+			}
+			ns, key, ok := bytes.Cut(k[8:], []byte{0})
+			if !ok {
+				continue
+			}
+			b := tx.Bucket(ns)
+			if b == nil {
+				continue
+			}
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+			emit(Event{Type: EventExpire, NS: string(ns), Key: string(key)})
+			if first, _ := b.Cursor().First(); first == nil {
+				if err := tx.DeleteBucket(ns); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Watch reports changes made through this process. bbolt holds an exclusive
+// file lock, so no other process can write to the same database concurrently.
+func (s *boltStore) Watch(ctx context.Context, ns, prefix string) (<-chan Event, error) {
+	return s.hub.subscribe(ctx, ns, prefix), nil
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
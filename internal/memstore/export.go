@@ -0,0 +1,142 @@
+package memstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Record is one line of a JSONL store export. Values that are compact JSON
+// are embedded as-is so exports stay readable, other UTF-8 goes in Text and
+// anything else is base64 in Raw. Exactly one of the three is set.
+type Record struct {
+	NS        string          `json:"ns"`
+	Key       string          `json:"key"`
+	JSON      json.RawMessage `json:"json,omitempty"`
+	Text      *string         `json:"text,omitempty"`
+	Raw       []byte          `json:"raw,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+}
+
+// importBatch bounds how many records Import writes per Batch.
+const importBatch = 256
+
+func newRecord(ns string, e Entry) Record {
+	r := Record{NS: ns, Key: e.Key}
+	var buf bytes.Buffer
+	if json.Compact(&buf, e.Value) == nil && bytes.Equal(buf.Bytes(), e.Value) {
+		r.JSON = json.RawMessage(e.Value)
+	} else if utf8.Valid(e.Value) {
+		text := string(e.Value)
+		r.Text = &text
+	} else {
+		r.Raw = e.Value
+	}
+	if !e.ExpiresAt.IsZero() {
+		exp := e.ExpiresAt
+		r.ExpiresAt = &exp
+	}
+	return r
+}
+
+// Value returns the stored bytes the record describes.
+func (r Record) Value() []byte {
+	if r.JSON != nil {
+		return r.JSON
+	}
+	if r.Text != nil {
+		return []byte(*r.Text)
+	}
+	return r.Raw
+}
+
+// Export writes every live entry of the given namespaces to w as JSONL, one
+// Record per line. No namespaces means all of them. It returns the number
+// of records written.
+func Export(s SharedStore, w io.Writer, namespaces ...string) (int, error) {
+	if len(namespaces) == 0 {
+		var err error
+		if namespaces, err = s.Namespaces(); err != nil {
+			return 0, fmt.Errorf("list namespaces: %w", err)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	n := 0
+	for _, ns := range namespaces {
+		entries, err := s.Scan(ns, "")
+		if err != nil {
+			return n, fmt.Errorf("scan %s: %w", ns, err)
+		}
+		for _, e := range entries {
+			if err := enc.Encode(newRecord(ns, e)); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Import reads JSONL records produced by Export and writes them to s,
+// preserving remaining TTLs and skipping records that have already expired.
+// A non-empty ns overrides the namespace of every record. It returns the
+// number of entries written.
+func Import(s SharedStore, r io.Reader, ns string) (int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	var (
+		ops  []Op
+		n    int
+		line int
+	)
+	flush := func() error {
+		if len(ops) == 0 {
+			return nil
+		}
+		if err := s.Batch(ops); err != nil {
+			return err
+		}
+		n += len(ops)
+		ops = ops[:0]
+		return nil
+	}
+	now := time.Now()
+	for sc.Scan() {
+		line++
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ns != "" {
+			rec.NS = ns
+		}
+		if rec.NS == "" || rec.Key == "" {
+			return n, fmt.Errorf("line %d: %w", line, errors.New("namespace and key required"))
+		}
+		var ttl time.Duration
+		if rec.ExpiresAt != nil {
+			if ttl = rec.ExpiresAt.Sub(now); ttl <= 0 {
+				continue
+			}
+		}
+		ops = append(ops, Op{NS: rec.NS, Key: rec.Key, Value: rec.Value(), TTL: ttl})
+		if len(ops) >= importBatch {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
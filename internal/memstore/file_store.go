@@ -1,11 +1,16 @@
 package memstore
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,6 +18,8 @@ import (
 type fileStore struct {
 	root string
 	mu   sync.RWMutex
+	hub  watchHub
+	poll filePoller
 }
 
 type fileRecord struct {
@@ -23,22 +30,91 @@ type fileRecord struct {
 // NewFileStore creates a directory-backed SharedStore.
 func NewFileStore(root string) SharedStore {
 	_ = os.MkdirAll(root, 0o755)
-	return &fileStore{root: root}
+	migrateNames(root)
+	f := &fileStore{root: root}
+	f.hub.onChange = f.watchersChanged
+	return f
 }
 
 func (f *fileStore) nsDir(ns string) string {
-	return filepath.Join(f.root, ns)
+	return filepath.Join(f.root, escapeName(ns))
 }
 
 func (f *fileStore) filePath(ns, key string) string {
-	// keep key as filename (safe subset expected). If contains path sep, replace.
-	sanitized := filepath.Base(key)
-	return filepath.Join(f.nsDir(ns), sanitized+".json")
+	return filepath.Join(f.nsDir(ns), escapeName(key)+".json")
+}
+
+// escapeName makes a namespace or key safe to use as a single path element.
+// Only separators, '%' and a leading '.' are escaped so plain names map to
+// themselves; migrateNames renames the few files earlier versions stored
+// under other names.
+func escapeName(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '/' || c == '\\' || c == '%' || (i == 0 && c == '.') {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func unescapeName(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// migrateNames renames namespace directories and record files written by
+// earlier versions, which stored names as-is, to their escaped form. A name
+// is left alone when it is already what escapeName produces or when the
+// escaped name is taken.
+func migrateNames(root string) {
+	rename := func(dir, name, ext string) string {
+		base := strings.TrimSuffix(name, ext)
+		if escapeName(unescapeName(base)) == base {
+			return name
+		}
+		to := escapeName(base) + ext
+		if _, err := os.Lstat(filepath.Join(dir, to)); err == nil {
+			return name
+		}
+		if os.Rename(filepath.Join(dir, name), filepath.Join(dir, to)) != nil {
+			return name
+		}
+		return to
+	}
+	nss, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, ns := range nss {
+		if !ns.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, rename(root, ns.Name(), ""))
+		files, _ := os.ReadDir(dir)
+		for _, file := range files {
+			if filepath.Ext(file.Name()) == ".json" {
+				rename(dir, file.Name(), ".json")
+			}
+		}
+	}
 }
 
 func (f *fileStore) Set(ns, key string, val []byte, ttl time.Duration) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	return f.setLocked(ns, key, val, ttl)
+}
+
+func (f *fileStore) setLocked(ns, key string, val []byte, ttl time.Duration) error {
 	if ns == "" || key == "" {
 		return errors.New("namespace and key required")
 	}
@@ -55,38 +131,83 @@ func (f *fileStore) Set(ns, key string, val []byte, ttl time.Duration) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(f.filePath(ns, key), b, 0o644)
+	// Write through a temp file so readers in other processes never see a
+	// partially written record. Its name is unique, since another process
+	// may be writing the same key, and is never taken for a record.
+	path := f.filePath(ns, key)
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	f.poll.record(ns, key, path)
+	f.hub.publish(Event{Type: EventSet, NS: ns, Key: key, Value: val})
+	return nil
 }
 
 func (f *fileStore) Get(ns, key string) ([]byte, bool, error) {
 	f.mu.RLock()
 	path := f.filePath(ns, key)
 	f.mu.RUnlock()
+	rec, ok, err := f.readRecord(ns, key, path)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return rec.Value, true, nil
+}
+
+// readRecord loads the record at path, removing it if it has expired.
+func (f *fileStore) readRecord(ns, key, path string) (fileRecord, bool, error) {
+	var rec fileRecord
 	b, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			return nil, false, nil
+			return rec, false, nil
 		}
-		return nil, false, err
+		return rec, false, err
 	}
-	var rec fileRecord
 	if err := json.Unmarshal(b, &rec); err != nil {
-		return nil, false, err
+		return rec, false, err
 	}
 	if !rec.ExpiresAt.IsZero() && time.Now().After(rec.ExpiresAt) {
-		_ = os.Remove(path)
-		return nil, false, nil
+		if os.Remove(path) == nil {
+			f.poll.forget(ns, key)
+			f.hub.publish(Event{Type: EventExpire, NS: ns, Key: key})
+		}
+		return rec, false, nil
 	}
-	return rec.Value, true, nil
+	return rec, true, nil
 }
 
 func (f *fileStore) Delete(ns, key string) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
+	return f.deleteLocked(ns, key)
+}
+
+func (f *fileStore) deleteLocked(ns, key string) error {
 	path := f.filePath(ns, key)
-	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
 		return err
 	}
+	f.poll.forget(ns, key)
+	f.hub.publish(Event{Type: EventDelete, NS: ns, Key: key})
 	return nil
 }
 
@@ -103,7 +224,7 @@ func (f *fileStore) Keys(ns string) ([]string, error) {
 	for _, e := range entries {
 		name := e.Name()
 		if filepath.Ext(name) == ".json" {
-			res = append(res, name[:len(name)-5])
+			res = append(res, unescapeName(name[:len(name)-5]))
 		}
 	}
 	return res, nil
@@ -140,9 +261,100 @@ func (f *fileStore) CleanupExpired() error {
 				continue
 			}
 			if !rec.ExpiresAt.IsZero() && now.After(rec.ExpiresAt) {
-				_ = os.Remove(path)
+				if os.Remove(path) == nil {
+					nsName := unescapeName(ns.Name())
+					key := unescapeName(strings.TrimSuffix(file.Name(), ".json"))
+					f.poll.forget(nsName, key)
+					f.hub.publish(Event{Type: EventExpire, NS: nsName, Key: key})
+				}
 			}
 		}
 	}
 	return nil
 }
+
+func (f *fileStore) Scan(ns, prefix string) ([]Entry, error) {
+	keys, err := f.Keys(ns)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	res := make([]Entry, 0, len(keys))
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		rec, ok, err := f.readRecord(ns, k, f.filePath(ns, k))
+		if err != nil || !ok {
+			continue
+		}
+		res = append(res, Entry{Key: k, Value: rec.Value, ExpiresAt: rec.ExpiresAt})
+	}
+	return res, nil
+}
+
+func (f *fileStore) Namespaces() ([]string, error) {
+	entries, err := os.ReadDir(f.root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		ns := unescapeName(e.Name())
+		if keys, err := f.Keys(ns); err == nil && len(keys) > 0 {
+			res = append(res, ns)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (f *fileStore) Batch(ops []Op) error {
+	for _, op := range ops {
+		if op.NS == "" || op.Key == "" {
+			return errors.New("namespace and key required")
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, op := range ops {
+		var err error
+		if op.Delete {
+			err = f.deleteLocked(op.NS, op.Key)
+		} else {
+			err = f.setLocked(op.NS, op.Key, op.Value, op.TTL)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fileStore) CompareAndSwap(ns, key string, old, val []byte, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rec, ok, err := f.readRecord(ns, key, f.filePath(ns, key))
+	if err != nil {
+		return false, err
+	}
+	if old == nil {
+		if ok {
+			return false, nil
+		}
+	} else if !ok || !bytes.Equal(rec.Value, old) {
+		return false, nil
+	}
+	return true, f.setLocked(ns, key, val, ttl)
+}
+
+func (f *fileStore) Close() error {
+	f.poll.stop()
+	return nil
+}
@@ -0,0 +1,168 @@
+package memstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPollInterval is how often the file store rescans watched
+// namespaces for writes made by other processes.
+const defaultPollInterval = 500 * time.Millisecond
+
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// filePoller tracks the last known state of watched namespace directories
+// so that changes written by other agentry processes can be turned into
+// events. Writes made through this process update the snapshot directly and
+// are therefore not reported twice.
+type filePoller struct {
+	mu     sync.Mutex
+	seen   map[string]map[string]fileStamp // ns -> key -> stamp; nil until baselined
+	cancel context.CancelFunc
+}
+
+func pollInterval() time.Duration {
+	if v := os.Getenv("AGENTRY_STORE_POLL_MS"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return defaultPollInterval
+}
+
+// watchersChanged starts the poller when the first watcher subscribes and
+// stops it when the last one leaves.
+func (f *fileStore) watchersChanged(n int) {
+	f.poll.mu.Lock()
+	defer f.poll.mu.Unlock()
+	switch {
+	case n > 0 && f.poll.cancel == nil:
+		ctx, cancel := context.WithCancel(context.Background())
+		f.poll.cancel = cancel
+		f.poll.seen = make(map[string]map[string]fileStamp)
+		go f.pollLoop(ctx)
+	case n == 0 && f.poll.cancel != nil:
+		f.poll.cancel()
+		f.poll.cancel = nil
+		f.poll.seen = nil
+	}
+}
+
+func (p *filePoller) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+		p.seen = nil
+	}
+}
+
+// record notes a write made by this process.
+func (p *filePoller) record(ns, key, path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.seen == nil || p.seen[ns] == nil {
+		return
+	}
+	if info, err := os.Stat(path); err == nil {
+		p.seen[ns][key] = fileStamp{mod: info.ModTime(), size: info.Size()}
+	}
+}
+
+// forget notes a removal made by this process.
+func (p *filePoller) forget(ns, key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.seen != nil && p.seen[ns] != nil {
+		delete(p.seen[ns], key)
+	}
+}
+
+func (f *fileStore) pollLoop(ctx context.Context) {
+	t := time.NewTicker(pollInterval())
+	defer t.Stop()
+	f.pollOnce()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			f.pollOnce()
+		}
+	}
+}
+
+func (f *fileStore) pollOnce() {
+	nss, all := f.hub.watchedNamespaces()
+	if all {
+		nss = nss[:0]
+		if entries, err := os.ReadDir(f.root); err == nil {
+			for _, e := range entries {
+				if e.IsDir() {
+					nss = append(nss, unescapeName(e.Name()))
+				}
+			}
+		}
+	}
+	for _, ns := range nss {
+		f.pollNamespace(ns)
+	}
+}
+
+func (f *fileStore) pollNamespace(ns string) {
+	current := map[string]fileStamp{}
+	if entries, err := os.ReadDir(f.nsDir(ns)); err == nil {
+		for _, e := range entries {
+			name := e.Name()
+			if filepath.Ext(name) != ".json" {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			current[unescapeName(strings.TrimSuffix(name, ".json"))] = fileStamp{mod: info.ModTime(), size: info.Size()}
+		}
+	}
+
+	f.poll.mu.Lock()
+	if f.poll.seen == nil {
+		f.poll.mu.Unlock()
+		return
+	}
+	prev, baselined := f.poll.seen[ns]
+	f.poll.seen[ns] = current
+	f.poll.mu.Unlock()
+	if !baselined {
+		return
+	}
+
+	for key, st := range current {
+		if old, ok := prev[key]; ok && old == st {
+			continue
+		}
+		rec, ok, err := f.readRecord(ns, key, f.filePath(ns, key))
+		if err != nil || !ok {
+			continue
+		}
+		f.hub.publish(Event{Type: EventSet, NS: ns, Key: key, Value: rec.Value})
+	}
+	for key := range prev {
+		if _, ok := current[key]; !ok {
+			f.hub.publish(Event{Type: EventDelete, NS: ns, Key: key})
+		}
+	}
+}
+
+func (f *fileStore) Watch(ctx context.Context, ns, prefix string) (<-chan Event, error) {
+	return f.hub.subscribe(ctx, ns, prefix), nil
+}
@@ -1,6 +1,11 @@
 package memstore
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,6 +19,7 @@ type memEntry struct {
 type memoryStore struct {
 	mu   sync.RWMutex
 	data map[string]map[string]memEntry // ns -> key -> entry
+	hub  watchHub
 }
 
 // NewMemoryStore creates an in-memory SharedStore.
@@ -23,7 +29,13 @@ func NewMemoryStore() SharedStore {
 
 func (m *memoryStore) Set(ns, key string, val []byte, ttl time.Duration) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.setLocked(ns, key, val, ttl)
+	m.mu.Unlock()
+	m.hub.publish(Event{Type: EventSet, NS: ns, Key: key, Value: val})
+	return nil
+}
+
+func (m *memoryStore) setLocked(ns, key string, val []byte, ttl time.Duration) {
 	if _, ok := m.data[ns]; !ok {
 		m.data[ns] = make(map[string]memEntry)
 	}
@@ -32,7 +44,6 @@ func (m *memoryStore) Set(ns, key string, val []byte, ttl time.Duration) error {
 		exp = time.Now().Add(ttl)
 	}
 	m.data[ns][key] = memEntry{val: append([]byte(nil), val...), exp: exp}
-	return nil
 }
 
 func (m *memoryStore) Get(ns, key string) ([]byte, bool, error) {
@@ -47,6 +58,7 @@ func (m *memoryStore) Get(ns, key string) ([]byte, bool, error) {
 		m.mu.Lock()
 		delete(m.data[ns], key)
 		m.mu.Unlock()
+		m.hub.publish(Event{Type: EventExpire, NS: ns, Key: key})
 		return nil, false, nil
 	}
 	return append([]byte(nil), entry.val...), true, nil
@@ -54,16 +66,27 @@ func (m *memoryStore) Get(ns, key string) ([]byte, bool, error) {
 
 func (m *memoryStore) Delete(ns, key string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-	if _, ok := m.data[ns]; ok {
-		delete(m.data[ns], key)
-		if len(m.data[ns]) == 0 {
-			delete(m.data, ns)
-		}
+	existed := m.deleteLocked(ns, key)
+	m.mu.Unlock()
+	if existed {
+		m.hub.publish(Event{Type: EventDelete, NS: ns, Key: key})
 	}
 	return nil
 }
 
+func (m *memoryStore) deleteLocked(ns, key string) bool {
+	mp, ok := m.data[ns]
+	if !ok {
+		return false
+	}
+	_, existed := mp[key]
+	delete(mp, key)
+	if len(mp) == 0 {
+		delete(m.data, ns)
+	}
+	return existed
+}
+
 func (m *memoryStore) Keys(ns string) ([]string, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -81,17 +104,107 @@ func (m *memoryStore) Keys(ns string) ([]string, error) {
 
 func (m *memoryStore) CleanupExpired() error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	now := time.Now()
+	var events []Event
 	for ns, mp := range m.data {
 		for k, e := range mp {
 			if !e.exp.IsZero() && now.After(e.exp) {
 				delete(mp, k)
+				events = append(events, Event{Type: EventExpire, NS: ns, Key: k})
 			}
 		}
 		if len(mp) == 0 {
 			delete(m.data, ns)
 		}
 	}
+	m.mu.Unlock()
+	for _, ev := range events {
+		m.hub.publish(ev)
+	}
 	return nil
 }
+
+func (m *memoryStore) Scan(ns, prefix string) ([]Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := time.Now()
+	res := make([]Entry, 0)
+	for k, e := range m.data[ns] {
+		if !strings.HasPrefix(k, prefix) || (!e.exp.IsZero() && !now.Before(e.exp)) {
+			continue
+		}
+		res = append(res, Entry{Key: k, Value: append([]byte(nil), e.val...), ExpiresAt: e.exp})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res, nil
+}
+
+func (m *memoryStore) Namespaces() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	res := make([]string, 0, len(m.data))
+	for ns, mp := range m.data {
+		if len(mp) > 0 {
+			res = append(res, ns)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (m *memoryStore) Batch(ops []Op) error {
+	for _, op := range ops {
+		if op.NS == "" || op.Key == "" {
+			return errors.New("namespace and key required")
+		}
+	}
+	m.mu.Lock()
+	events := make([]Event, 0, len(ops))
+	for _, op := range ops {
+		if op.Delete {
+			if m.deleteLocked(op.NS, op.Key) {
+				events = append(events, Event{Type: EventDelete, NS: op.NS, Key: op.Key})
+			}
+			continue
+		}
+		m.setLocked(op.NS, op.Key, op.Value, op.TTL)
+		events = append(events, Event{Type: EventSet, NS: op.NS, Key: op.Key, Value: op.Value})
+	}
+	m.mu.Unlock()
+	for _, ev := range events {
+		m.hub.publish(ev)
+	}
+	return nil
+}
+
+func (m *memoryStore) CompareAndSwap(ns, key string, old, val []byte, ttl time.Duration) (bool, error) {
+	swapped, err := m.compareAndSwap(ns, key, old, val, ttl)
+	if swapped {
+		m.hub.publish(Event{Type: EventSet, NS: ns, Key: key, Value: val})
+	}
+	return swapped, err
+}
+
+func (m *memoryStore) compareAndSwap(ns, key string, old, val []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.data[ns][key]
+	if ok && !cur.exp.IsZero() && !time.Now().Before(cur.exp) {
+		ok = false
+	}
+	if old == nil {
+		if ok {
+			return false, nil
+		}
+	} else if !ok || !bytes.Equal(cur.val, old) {
+		return false, nil
+	}
+	m.setLocked(ns, key, val, ttl)
+	return true, nil
+}
+
+func (m *memoryStore) Watch(ctx context.Context, ns, prefix string) (<-chan Event, error) {
+	return m.hub.subscribe(ctx, ns, prefix), nil
+}
+
+func (m *memoryStore) Close() error { return nil }
@@ -1,8 +1,14 @@
 package memstore
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -58,3 +64,294 @@ func TestFileStore_SetGetKeys(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestFileStore_KeysWithSeparatorsDoNotCollide(t *testing.T) {
+	fs := NewFileStore(t.TempDir())
+	if err := fs.Set("ns", "a/b", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Set("ns", "c/b", []byte("2"), 0); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := fs.Get("ns", "a/b")
+	if err != nil || !ok || string(v) != "1" {
+		t.Fatalf("get a/b failed: %v %v %s", err, ok, v)
+	}
+	entries, err := fs.Scan("ns", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Key != "a/b" || entries[1].Key != "c/b" {
+		t.Fatalf("unexpected entries: %#v", entries)
+	}
+}
+
+func TestFileStore_MigratesOldNames(t *testing.T) {
+	dir := t.TempDir()
+	// Earlier versions stored namespaces and keys as-is.
+	for name, val := range map[string]string{"50%done": "a", ".hidden": "b", "plain": "c"} {
+		if err := os.MkdirAll(filepath.Join(dir, "n%s"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		rec := fmt.Sprintf(`{"value":%q}`, base64.StdEncoding.EncodeToString([]byte(val)))
+		if err := os.WriteFile(filepath.Join(dir, "n%s", name+".json"), []byte(rec), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := NewFileStore(dir)
+	for key, want := range map[string]string{"50%done": "a", ".hidden": "b", "plain": "c"} {
+		v, ok, err := fs.Get("n%s", key)
+		if err != nil || !ok || string(v) != want {
+			t.Fatalf("get %s: %v %v %s", key, err, ok, v)
+		}
+	}
+	if keys, _ := fs.Keys("n%s"); len(keys) != 3 {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+}
+
+func TestFileStore_ConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := NewFileStore(dir) // one per process
+			for j := 0; j < 20; j++ {
+				if err := s.Set("ns", "k", []byte("v"), 0); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	entries, _ := os.ReadDir(filepath.Join(dir, "ns"))
+	if len(entries) != 1 || entries[0].Name() != "k.json" {
+		t.Fatalf("unexpected files: %v", entries)
+	}
+}
+
+func TestStores_ScanBatchCAS(t *testing.T) {
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+	stores := map[string]SharedStore{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(t.TempDir()),
+		"bolt":   bolt,
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			err := s.Batch([]Op{
+				{NS: "team", Key: "coord-1", Value: []byte("a")},
+				{NS: "team", Key: "coord-2", Value: []byte("b")},
+				{NS: "team", Key: "shared", Value: []byte("c")},
+				{NS: "todo", Key: "item:1", Value: []byte("d")},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := s.Scan("team", "coord-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 2 || entries[0].Key != "coord-1" || string(entries[1].Value) != "b" {
+				t.Fatalf("unexpected scan: %#v", entries)
+			}
+			nss, err := s.Namespaces()
+			if err != nil || len(nss) != 2 || nss[0] != "team" || nss[1] != "todo" {
+				t.Fatalf("unexpected namespaces: %v %v", nss, err)
+			}
+
+			if ok, err := s.CompareAndSwap("team", "shared", []byte("x"), []byte("y"), 0); err != nil || ok {
+				t.Fatalf("CAS with wrong old value should fail: %v %v", ok, err)
+			}
+			if ok, err := s.CompareAndSwap("team", "shared", []byte("c"), []byte("y"), 0); err != nil || !ok {
+				t.Fatalf("CAS should succeed: %v %v", ok, err)
+			}
+			if ok, _ := s.CompareAndSwap("team", "shared", nil, []byte("z"), 0); ok {
+				t.Fatal("CAS with nil old should fail when key exists")
+			}
+			if ok, _ := s.CompareAndSwap("team", "lock", nil, []byte("me"), 0); !ok {
+				t.Fatal("CAS with nil old should create missing key")
+			}
+
+			if err := s.Set("todo", "tmp", []byte("t"), 10*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if err := s.CleanupExpired(); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := s.Get("todo", "tmp"); ok {
+				t.Fatal("expected tmp expired")
+			}
+			if keys, _ := s.Keys("todo"); len(keys) != 1 {
+				t.Fatalf("expected 1 todo key after cleanup, got %v", keys)
+			}
+		})
+	}
+}
+
+func TestMigrateFileToBolt(t *testing.T) {
+	src := NewFileStore(t.TempDir())
+	_ = src.Set("agent-state", "sess", []byte(`{"prompt":"p"}`), 0)
+	_ = src.Set("team", "coord-1", []byte("e"), time.Hour)
+	path := filepath.Join(t.TempDir(), "store.db")
+	dst, err := Open(BackendBolt, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if _, err := Open(BackendBolt, path); err == nil || !strings.Contains(err.Error(), "another process") {
+		t.Fatalf("a second open must report the lock: %v", err)
+	}
+	n, err := Migrate(src, dst)
+	if err != nil || n != 2 {
+		t.Fatalf("migrate: n=%d err=%v", n, err)
+	}
+	v, ok, err := dst.Get("agent-state", "sess")
+	if err != nil || !ok || string(v) != `{"prompt":"p"}` {
+		t.Fatalf("unexpected value: %v %v %s", err, ok, v)
+	}
+	entries, _ := dst.Scan("team", "")
+	if len(entries) != 1 || entries[0].ExpiresAt.IsZero() {
+		t.Fatalf("expected TTL to be preserved: %#v", entries)
+	}
+}
+
+func nextEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for store event")
+		return Event{}
+	}
+}
+
+func TestStores_Watch(t *testing.T) {
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "store.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bolt.Close()
+	stores := map[string]SharedStore{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(t.TempDir()),
+		"bolt":   bolt,
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			ch, err := s.Watch(ctx, "todo", "item:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = s.Set("other", "item:1", []byte("ignored"), 0)
+			_ = s.Set("todo", "meta", []byte("ignored"), 0)
+			if err := s.Set("todo", "item:1", []byte("a"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if ev := nextEvent(t, ch); ev.Type != EventSet || ev.Key != "item:1" || string(ev.Value) != "a" {
+				t.Fatalf("unexpected event: %#v", ev)
+			}
+			if err := s.Delete("todo", "item:1"); err != nil {
+				t.Fatal(err)
+			}
+			if ev := nextEvent(t, ch); ev.Type != EventDelete || ev.Key != "item:1" {
+				t.Fatalf("unexpected event: %#v", ev)
+			}
+			_ = s.Set("todo", "item:2", []byte("b"), 10*time.Millisecond)
+			nextEvent(t, ch)
+			time.Sleep(20 * time.Millisecond)
+			_ = s.CleanupExpired()
+			if ev := nextEvent(t, ch); ev.Type != EventExpire || ev.Key != "item:2" {
+				t.Fatalf("unexpected event: %#v", ev)
+			}
+
+			cancel()
+			for range ch {
+			}
+		})
+	}
+}
+
+func TestFileStore_WatchSeesOtherProcess(t *testing.T) {
+	t.Setenv("AGENTRY_STORE_POLL_MS", "10")
+	dir := t.TempDir()
+	watcher := NewFileStore(dir)
+	writer := NewFileStore(dir) // stands in for another agentry process
+	_ = writer.Set("todo", "item:old", []byte("x"), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := watcher.Watch(ctx, "todo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond) // let the poller take its baseline
+
+	if err := writer.Set("todo", "item:new", []byte("y"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, ch); ev.Type != EventSet || ev.Key != "item:new" || string(ev.Value) != "y" {
+		t.Fatalf("unexpected event: %#v", ev)
+	}
+	if err := writer.Delete("todo", "item:old"); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, ch); ev.Type != EventDelete || ev.Key != "item:old" {
+		t.Fatalf("unexpected event: %#v", ev)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := NewFileStore(t.TempDir())
+	values := map[string][]byte{
+		"item:1": []byte(`{"title":"a <b>"}`),
+		"pretty": []byte("{\n  \"a\": 1\n}"),
+		"text":   []byte("plain text"),
+		"bin":    {0xff, 0x00, 0x01},
+	}
+	for k, v := range values {
+		if err := src.Set("todo", k, v, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = src.Set("todo", "ttl", []byte("soon"), time.Hour)
+	_ = src.Set("other", "k", []byte("skip"), 0)
+
+	var buf bytes.Buffer
+	n, err := Export(src, &buf, "todo")
+	if err != nil || n != 5 {
+		t.Fatalf("export: %d %v", n, err)
+	}
+	if !strings.Contains(buf.String(), `"json":{"title":"a <b>"}`) || !strings.Contains(buf.String(), `"text":"plain text"`) {
+		t.Fatalf("export not readable:\n%s", buf.String())
+	}
+
+	dst := NewMemoryStore()
+	if n, err := Import(dst, &buf, "copy"); err != nil || n != 5 {
+		t.Fatalf("import: %d %v", n, err)
+	}
+	for k, v := range values {
+		got, ok, _ := dst.Get("copy", k)
+		if !ok || !bytes.Equal(got, v) {
+			t.Fatalf("%s: got %q want %q", k, got, v)
+		}
+	}
+	entries, _ := dst.Scan("copy", "ttl")
+	if len(entries) != 1 || entries[0].ExpiresAt.IsZero() {
+		t.Fatalf("ttl not preserved: %#v", entries)
+	}
+
+	if _, err := Import(dst, strings.NewReader(`{"ns":"x"}`), ""); err == nil {
+		t.Fatal("expected error for record without key")
+	}
+}
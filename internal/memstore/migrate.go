@@ -0,0 +1,39 @@
+package memstore
+
+import (
+	"fmt"
+	"time"
+)
+
+// Migrate copies every live entry from src into dst, preserving remaining
+// TTLs. Each namespace is written as one Batch. It returns the number of
+// entries copied.
+func Migrate(src, dst SharedStore) (int, error) {
+	namespaces, err := src.Namespaces()
+	if err != nil {
+		return 0, fmt.Errorf("list namespaces: %w", err)
+	}
+	total := 0
+	now := time.Now()
+	for _, ns := range namespaces {
+		entries, err := src.Scan(ns, "")
+		if err != nil {
+			return total, fmt.Errorf("scan %s: %w", ns, err)
+		}
+		ops := make([]Op, 0, len(entries))
+		for _, e := range entries {
+			var ttl time.Duration
+			if !e.ExpiresAt.IsZero() {
+				if ttl = e.ExpiresAt.Sub(now); ttl <= 0 {
+					continue
+				}
+			}
+			ops = append(ops, Op{NS: ns, Key: e.Key, Value: e.Value, TTL: ttl})
+		}
+		if err := dst.Batch(ops); err != nil {
+			return total, fmt.Errorf("write %s: %w", ns, err)
+		}
+		total += len(ops)
+	}
+	return total, nil
+}
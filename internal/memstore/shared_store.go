@@ -1,6 +1,8 @@
 package memstore
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -14,38 +16,128 @@ type SharedStore interface {
 	Delete(ns, key string) error
 	Keys(ns string) ([]string, error)
 	CleanupExpired() error
+
+	// Scan returns live entries in ns whose key starts with prefix, sorted by key.
+	Scan(ns, prefix string) ([]Entry, error)
+	// Namespaces lists namespaces that currently hold at least one key.
+	Namespaces() ([]string, error)
+	// Batch applies all ops together. Only the bolt backend guarantees
+	// atomicity across processes; the others serialise within the process.
+	Batch(ops []Op) error
+	// CompareAndSwap stores val only if the current value equals old.
+	// A nil old means the key must not exist. It reports whether the swap happened.
+	CompareAndSwap(ns, key string, old, val []byte, ttl time.Duration) (bool, error)
+	// Watch delivers set, delete and expire events for keys in ns starting
+	// with prefix until ctx is cancelled, then closes the channel. An empty
+	// ns watches every namespace.
+	Watch(ctx context.Context, ns, prefix string) (<-chan Event, error)
+	Close() error
+}
+
+// Entry is a stored value along with its metadata.
+type Entry struct {
+	Key       string
+	Value     []byte
+	ExpiresAt time.Time // zero means no expiry
+}
+
+// Op is one write within a Batch. Delete removes the key and ignores Value.
+type Op struct {
+	NS     string
+	Key    string
+	Value  []byte
+	TTL    time.Duration
+	Delete bool
 }
 
+// Backend names accepted by Open and AGENTRY_STORE.
+const (
+	BackendMemory = "memory"
+	BackendFile   = "file"
+	BackendBolt   = "bolt"
+)
+
 var (
 	once         sync.Once
 	defaultStore SharedStore
+	initErr      error
 )
 
-// Init initializes the default store once.
-func Init() {
+// DefaultPath returns the on-disk location used by a backend when no path is
+// configured via AGENTRY_STORE_PATH.
+func DefaultPath(backend string) string {
+	if p := os.Getenv("AGENTRY_STORE_PATH"); p != "" {
+		return p
+	}
+	base := ".agentry_store"
+	if home, err := os.UserHomeDir(); err == nil {
+		base = filepath.Join(home, ".local", "share", "agentry", "store")
+	}
+	if backend == BackendBolt {
+		return filepath.Join(base, "store.db")
+	}
+	return base
+}
+
+// Open creates a SharedStore for the named backend. An empty path selects
+// DefaultPath; it is ignored by the memory backend.
+func Open(backend, path string) (SharedStore, error) {
+	if path == "" && backend != BackendMemory && backend != "" {
+		path = DefaultPath(backend)
+	}
+	switch backend {
+	case "", BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendFile:
+		return NewFileStore(path), nil
+	case BackendBolt:
+		return NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store backend %q (want memory, file or bolt)", backend)
+	}
+}
+
+// Init initializes the default store once. If the configured backend cannot
+// be opened it returns the error on every call, and the default store fails
+// every operation with it rather than quietly keeping state apart from other
+// processes.
+func Init() error {
 	once.Do(func() {
 		// Determine backing store from env
-		switch os.Getenv("AGENTRY_STORE") {
-		case "file":
-			root := os.Getenv("AGENTRY_STORE_PATH")
-			if root == "" {
-				if home, err := os.UserHomeDir(); err == nil {
-					root = filepath.Join(home, ".local", "share", "agentry", "store")
-				} else {
-					root = ".agentry_store"
-				}
-			}
-			defaultStore = NewFileStore(root)
-		default:
-			defaultStore = NewMemoryStore()
+		backend := os.Getenv("AGENTRY_STORE")
+		s, err := Open(backend, "")
+		if err != nil {
+			initErr = fmt.Errorf("shared store: %w", err)
+			s = unavailableStore{initErr}
 		}
+		defaultStore = s
 	})
+	return initErr
 }
 
 // Get returns the initialized default SharedStore.
 func Get() SharedStore {
 	if defaultStore == nil {
-		Init()
+		_ = Init() // a failure is reported by every operation
 	}
 	return defaultStore
 }
+
+// unavailableStore stands in for a backend that failed to open.
+type unavailableStore struct{ err error }
+
+func (u unavailableStore) Set(string, string, []byte, time.Duration) error { return u.err }
+func (u unavailableStore) Get(string, string) ([]byte, bool, error)        { return nil, false, u.err }
+func (u unavailableStore) Delete(string, string) error                     { return u.err }
+func (u unavailableStore) Keys(string) ([]string, error)                   { return nil, u.err }
+func (u unavailableStore) CleanupExpired() error                           { return u.err }
+func (u unavailableStore) Scan(string, string) ([]Entry, error)            { return nil, u.err }
+func (u unavailableStore) Namespaces() ([]string, error)                   { return nil, u.err }
+func (u unavailableStore) Batch([]Op) error                                { return u.err }
+func (u unavailableStore) CompareAndSwap(string, string, []byte, []byte, time.Duration) (bool, error) {
+	return false, u.err
+}
+func (u unavailableStore) Watch(context.Context, string, string) (<-chan Event, error) {
+	return nil, u.err
+}
+func (u unavailableStore) Close() error { return nil }
@@ -0,0 +1,117 @@
+package memstore
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType describes what happened to a key.
+type EventType string
+
+const (
+	EventSet    EventType = "set"
+	EventDelete EventType = "delete"
+	EventExpire EventType = "expire"
+)
+
+// Event is a change notification delivered to watchers. Value is only set
+// for EventSet.
+type Event struct {
+	Type      EventType
+	NS        string
+	Key       string
+	Value     []byte
+	Timestamp time.Time
+}
+
+// watchBuffer is the per-subscriber channel capacity. Events are dropped
+// rather than blocking writers when a subscriber falls behind, so consumers
+// should treat an event as a hint to reload rather than a complete log.
+const watchBuffer = 64
+
+type subscriber struct {
+	ns     string
+	prefix string
+	ch     chan Event
+}
+
+func (s *subscriber) matches(ev Event) bool {
+	return (s.ns == "" || s.ns == ev.NS) && strings.HasPrefix(ev.Key, s.prefix)
+}
+
+// watchHub fans store events out to subscribers.
+type watchHub struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+	// onChange is called with the number of subscribers after each change.
+	onChange func(n int)
+}
+
+// subscribe registers a watcher until ctx is done. An empty ns matches all
+// namespaces.
+func (h *watchHub) subscribe(ctx context.Context, ns, prefix string) <-chan Event {
+	sub := &subscriber{ns: ns, prefix: prefix, ch: make(chan Event, watchBuffer)}
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[*subscriber]struct{})
+	}
+	h.subs[sub] = struct{}{}
+	n := len(h.subs)
+	h.mu.Unlock()
+	if h.onChange != nil {
+		h.onChange(n)
+	}
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs, sub)
+		close(sub.ch)
+		n := len(h.subs)
+		h.mu.Unlock()
+		if h.onChange != nil {
+			h.onChange(n)
+		}
+	}()
+	return sub.ch
+}
+
+func (h *watchHub) publish(ev Event) {
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if !sub.matches(ev) {
+			continue
+		}
+		e := ev
+		if ev.Value != nil {
+			e.Value = append([]byte(nil), ev.Value...)
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// watchedNamespaces returns the namespaces subscribers care about; a nil
+// result with all=true means at least one subscriber watches everything.
+func (h *watchHub) watchedNamespaces() (nss []string, all bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	seen := map[string]struct{}{}
+	for sub := range h.subs {
+		if sub.ns == "" {
+			return nil, true
+		}
+		if _, ok := seen[sub.ns]; !ok {
+			seen[sub.ns] = struct{}{}
+			nss = append(nss, sub.ns)
+		}
+	}
+	return nss, false
+}
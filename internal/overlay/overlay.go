@@ -0,0 +1,192 @@
+// Package overlay is an in-memory layer over the filesystem for dry runs.
+// Writes and removals are kept in memory, reads see them, and the pending
+// changes can be rendered as a unified diff against what is on disk.
+package overlay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+type file struct {
+	data    []byte
+	mode    fs.FileMode
+	mod     time.Time
+	deleted bool
+}
+
+// FS holds the files changed in a dry run, keyed by absolute path.
+type FS struct {
+	mu    sync.RWMutex
+	files map[string]*file
+}
+
+// New returns an empty overlay.
+func New() *FS {
+	return &FS{files: map[string]*file{}}
+}
+
+func abs(name string) string {
+	if p, err := filepath.Abs(name); err == nil {
+		return p
+	}
+	return filepath.Clean(name)
+}
+
+// ReadFile returns the overlay's content for name, or the file on disk.
+func (o *FS) ReadFile(name string) ([]byte, error) {
+	o.mu.RLock()
+	f, ok := o.files[abs(name)]
+	o.mu.RUnlock()
+	if !ok {
+		return os.ReadFile(name)
+	}
+	if f.deleted {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return bytes.Clone(f.data), nil
+}
+
+// Stat describes name as the overlay sees it.
+func (o *FS) Stat(name string) (fs.FileInfo, error) {
+	o.mu.RLock()
+	f, ok := o.files[abs(name)]
+	o.mu.RUnlock()
+	if !ok {
+		return os.Stat(name)
+	}
+	if f.deleted {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fileInfo{name: filepath.Base(name), size: int64(len(f.data)), mode: f.mode, mod: f.mod}, nil
+}
+
+// WriteFile records data as name's content. The disk is not touched.
+func (o *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p := abs(name)
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if f, ok := o.files[p]; ok && !f.deleted {
+		perm = f.mode
+	} else if info, err := os.Stat(p); err == nil {
+		perm = info.Mode().Perm()
+	}
+	o.files[p] = &file{data: bytes.Clone(data), mode: perm, mod: time.Now()}
+	return nil
+}
+
+// Remove records name as deleted.
+func (o *FS) Remove(name string) error {
+	if _, err := o.Stat(name); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	o.files[abs(name)] = &file{deleted: true, mod: time.Now()}
+	o.mu.Unlock()
+	return nil
+}
+
+// Paths lists the files the overlay has changed, sorted.
+func (o *FS) Paths() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	paths := make([]string, 0, len(o.files))
+	for p := range o.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Diff renders the overlay as a unified diff against the disk, with paths
+// relative to root where they are inside it. Files whose overlay content
+// matches the disk are left out.
+func (o *FS) Diff(root string) (string, error) {
+	root = abs(root)
+	var b strings.Builder
+	for _, p := range o.Paths() {
+		o.mu.RLock()
+		f := o.files[p]
+		o.mu.RUnlock()
+		old, err := os.ReadFile(p)
+		existed := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if (!existed && f.deleted) || (existed && !f.deleted && bytes.Equal(old, f.data)) {
+			continue
+		}
+		name := filepath.ToSlash(p)
+		if rel, err := filepath.Rel(root, p); err == nil && !strings.HasPrefix(rel, "..") {
+			name = filepath.ToSlash(rel)
+		}
+		ud := difflib.UnifiedDiff{FromFile: "a/" + name, ToFile: "b/" + name, Context: 3}
+		if existed {
+			ud.A = splitLines(old)
+		} else {
+			ud.FromFile = "/dev/null"
+		}
+		if f.deleted {
+			ud.ToFile = "/dev/null"
+		} else {
+			ud.B = splitLines(f.data)
+		}
+		if err := difflib.WriteUnifiedDiff(&b, ud); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// splitLines keeps each line's newline. A last line without one carries
+// the "\ No newline at end of file" marker, so it differs from the same
+// line with a newline and the diff stays exact.
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n\\ No newline at end of file\n"
+	return lines
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+	mod  time.Time
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return fi.mod }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() any           { return nil }
+
+type contextKey struct{}
+
+// WithContext returns ctx carrying o; file tools called with it write to
+// the overlay instead of the disk.
+func WithContext(ctx context.Context, o *FS) context.Context {
+	return context.WithValue(ctx, contextKey{}, o)
+}
+
+// FromContext returns the overlay carried by ctx, or nil.
+func FromContext(ctx context.Context) *FS {
+	o, _ := ctx.Value(contextKey{}).(*FS)
+	return o
+}
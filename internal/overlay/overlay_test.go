@@ -0,0 +1,80 @@
+package overlay
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/patch"
+)
+
+func TestOverlayDiffAppliesToDisk(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	files := map[string]string{"edit.txt": "one\ntwo\nthree\n", "gone.txt": "bye\n", "tail.txt": "no newline"}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	o := New()
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(o.WriteFile("edit.txt", []byte("one\n2\nthree\n"), 0o644))
+	must(o.WriteFile(filepath.Join("sub", "new.txt"), []byte("fresh\n"), 0o644))
+	must(o.WriteFile("tail.txt", []byte("no newline\n"), 0o644))
+	must(o.Remove("gone.txt"))
+
+	// Reads see the overlay; the disk is untouched.
+	if b, _ := o.ReadFile("edit.txt"); string(b) != "one\n2\nthree\n" {
+		t.Fatalf("overlay read: %q", b)
+	}
+	if _, err := o.Stat("gone.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("removed file still visible: %v", err)
+	}
+	if b, _ := os.ReadFile("edit.txt"); string(b) != files["edit.txt"] {
+		t.Fatalf("disk changed: %q", b)
+	}
+
+	diff, err := o.Diff(dir)
+	must(err)
+	for _, want := range []string{"--- a/edit.txt", "+++ b/sub/new.txt", "+++ /dev/null", `\ No newline at end of file`} {
+		if !strings.Contains(diff, want) {
+			t.Fatalf("diff lacks %q:\n%s", want, diff)
+		}
+	}
+
+	// The diff checks cleanly against a fresh overlay, then applies.
+	if _, err := patch.ApplyFS(diff, New()); err != nil {
+		t.Fatalf("check: %v\n%s", err, diff)
+	}
+	res, err := patch.Apply(diff)
+	must(err)
+	if len(res.Files) != 4 {
+		t.Fatalf("applied files: %+v", res.Files)
+	}
+	for name, want := range map[string]string{"edit.txt": "one\n2\nthree\n", "sub/new.txt": "fresh\n", "tail.txt": "no newline\n"} {
+		if b, _ := os.ReadFile(name); string(b) != want {
+			t.Fatalf("%s after apply: %q", name, b)
+		}
+	}
+	if _, err := os.Stat("gone.txt"); !os.IsNotExist(err) {
+		t.Fatalf("gone.txt not removed: %v", err)
+	}
+	if diff, _ := o.Diff(dir); diff != "" {
+		t.Fatalf("overlay still differs from disk:\n%s", diff)
+	}
+}
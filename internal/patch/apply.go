@@ -1,7 +1,6 @@
 package patch
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,6 +16,8 @@ type Change struct {
 	Path      string `json:"path"`
 	Additions int    `json:"additions"`
 	Deletions int    `json:"deletions"`
+	// Notes describe hunks placed away from their stated line or with fuzz.
+	Notes []string `json:"notes,omitempty"`
 }
 
 // Result contains metadata about an applied patch.
@@ -24,50 +25,203 @@ type Result struct {
 	Files []Change `json:"files"`
 }
 
-// Apply parses a unified diff and applies it to the local filesystem.
-// It returns metadata about the applied changes.
+// FS is the file access Apply needs.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Remove(name string) error
+}
+
+// osFS is the local filesystem.
+type osFS struct{}
+
+// OS returns the local filesystem.
+func OS() FS { return osFS{} }
+
+func (osFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (osFS) Remove(name string) error             { return os.Remove(name) }
+
+func (osFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, perm)
+}
+
+// Apply parses a unified diff and applies it to the local filesystem with
+// DefaultOptions. It returns metadata about the applied changes.
 func Apply(patchStr string) (Result, error) {
+	return ApplyFS(patchStr, osFS{})
+}
+
+// ApplyFS is Apply against fsys.
+func ApplyFS(patchStr string, fsys FS) (Result, error) {
+	return ApplyWith(patchStr, fsys, DefaultOptions())
+}
+
+// pending is a file's state while a patch is being applied.
+type pending struct {
+	path    string
+	orig    []byte // content before the patch
+	existed bool
+	lines   []string
+	content []byte // what is written, from lines
+	remove  bool
+}
+
+// ApplyWith applies a unified diff to fsys. The patch applies as a whole
+// or not at all: every hunk of every file is placed before anything is
+// written, a *RejectError lists the hunks that could not be, and a failed
+// write rolls back the files already written.
+func ApplyWith(patchStr string, fsys FS, opts Options) (Result, error) {
 	fds, err := diff.ParseMultiFileDiff([]byte(patchStr))
 	if err != nil {
 		return Result{}, err
 	}
-	var res Result
+	m := matcher{opts: opts}
+	var (
+		res     Result
+		files   []*pending
+		byPath  = map[string]*pending{}
+		rejects = &RejectError{}
+	)
 	for _, fd := range fds {
 		path := choosePath(fd)
 		st := fd.Stat()
-		change := Change{Path: path, Additions: int(st.Added), Deletions: int(st.Deleted)}
+		// go-diff counts a removed line followed by an added one as changed.
+		change := Change{Path: path, Additions: int(st.Added + st.Changed), Deletions: int(st.Deleted + st.Changed)}
+		rejects.Hunks += len(fd.Hunks)
+
+		f := byPath[path]
+		if f == nil {
+			f = &pending{path: path}
+			b, err := fsys.ReadFile(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return Result{}, err
+			}
+			f.orig, f.existed = b, err == nil
+			if f.existed {
+				f.lines = splitLines(string(b))
+			}
+			byPath[path] = f
+			files = append(files, f)
+		}
 		if fd.NewName == "/dev/null" {
-			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
-				return res, err
+			// Deleting a file that is already gone is not an error.
+			if f.existed && !f.remove {
+				_, _, rej := m.applyHunks(f.lines, fd.Hunks)
+				rejects.add(path, rej)
 			}
+			f.remove, f.lines = true, nil
 			res.Files = append(res.Files, change)
 			continue
 		}
 		var lines []string
-		if fd.OrigName != "/dev/null" {
-			b, err := os.ReadFile(path)
-			if err != nil && !errors.Is(err, os.ErrNotExist) {
-				return res, err
+		if fd.OrigName != "/dev/null" && !f.remove {
+			lines = f.lines
+		}
+		patched, notes, rej := m.applyHunks(lines, fd.Hunks)
+		rejects.add(path, rej)
+		f.lines, f.remove = patched, false
+		change.Notes = notes
+		res.Files = append(res.Files, change)
+	}
+	if len(rejects.Rejects) > 0 {
+		return Result{}, rejects
+	}
+	for _, f := range files {
+		content := strings.Join(f.lines, "\n")
+		if len(f.lines) > 0 {
+			content += "\n"
+		}
+		f.content = []byte(content)
+	}
+	if err := commit(fsys, files); err != nil {
+		return Result{}, err
+	}
+	return res, nil
+}
+
+// File is a file's new content for WriteFiles.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// WriteFiles writes every file to fsys or, like a patch, none of them: a
+// failed write rolls back the files already written.
+func WriteFiles(fsys FS, files []File) error {
+	ps := make([]*pending, len(files))
+	for i, f := range files {
+		b, err := fsys.ReadFile(f.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		ps[i] = &pending{path: f.Path, orig: b, existed: err == nil, content: f.Content}
+	}
+	return commit(fsys, ps)
+}
+
+// commit writes or removes each file, rolling back on the first failure.
+func commit(fsys FS, files []*pending) error {
+	var written []*pending
+	for _, f := range files {
+		var err error
+		if f.remove {
+			if f.existed {
+				err = fsys.Remove(f.path)
 			}
-			lines = splitLines(string(b))
+		} else {
+			err = fsys.WriteFile(f.path, f.content, 0644)
 		}
-		patched, err := applyHunks(lines, fd.Hunks)
 		if err != nil {
-			return res, fmt.Errorf("%s: %w", path, err)
-		}
-		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-			return res, err
+			return rollback(fsys, written, fmt.Errorf("%s: %w", f.path, err))
 		}
-		content := strings.Join(patched, "\n")
-		if len(patched) > 0 {
-			content += "\n"
+		written = append(written, f)
+	}
+	return nil
+}
+
+func (e *RejectError) add(path string, rejects []Reject) {
+	for _, r := range rejects {
+		r.Path = path
+		e.Rejects = append(e.Rejects, r)
+	}
+}
+
+// rollback restores the files written before err and returns err,
+// annotated with any file that could not be restored.
+func rollback(fsys FS, written []*pending, err error) error {
+	var failed []string
+	for i := len(written) - 1; i >= 0; i-- {
+		f := written[i]
+		var rerr error
+		if f.existed {
+			rerr = fsys.WriteFile(f.path, f.orig, 0644)
+		} else {
+			rerr = fsys.Remove(f.path)
 		}
-		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-			return res, err
+		if rerr != nil {
+			failed = append(failed, f.path)
 		}
-		res.Files = append(res.Files, change)
 	}
-	return res, nil
+	if len(failed) > 0 {
+		return fmt.Errorf("%w; rollback failed for %s", err, strings.Join(failed, ", "))
+	}
+	return fmt.Errorf("%w; changes rolled back", err)
+}
+
+// Files lists the paths a unified diff touches, in order.
+func Files(patchStr string) ([]string, error) {
+	fds, err := diff.ParseMultiFileDiff([]byte(patchStr))
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(fds))
+	for _, fd := range fds {
+		paths = append(paths, choosePath(fd))
+	}
+	return paths, nil
 }
 
 func choosePath(fd *diff.FileDiff) string {
@@ -85,55 +239,6 @@ func splitLines(s string) []string {
 	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
 }
 
-func applyHunks(orig []string, hunks []*diff.Hunk) ([]string, error) {
-	out := make([]string, 0, len(orig))
-	idx := 0
-	for _, h := range hunks {
-		start := int(h.OrigStartLine) - 1
-		for idx < start && idx < len(orig) {
-			out = append(out, orig[idx])
-			idx++
-		}
-		bodyLines := bytes.Split(bytes.TrimSuffix(h.Body, []byte{'\n'}), []byte{'\n'})
-		for _, b := range bodyLines {
-			if len(b) == 0 {
-				continue
-			}
-			prefix := b[0]
-			line := string(b[1:])
-			switch prefix {
-			case ' ':
-				if idx >= len(orig) || orig[idx] != line {
-					return nil, fmt.Errorf("context mismatch: expected %q, got %q", origLine(orig, idx), line)
-				}
-				out = append(out, line)
-				idx++
-			case '-':
-				if idx >= len(orig) || orig[idx] != line {
-					return nil, fmt.Errorf("delete mismatch: expected %q, got %q", origLine(orig, idx), line)
-				}
-				idx++
-			case '+':
-				out = append(out, line)
-			case '\\':
-				// ignore no newline marker
-			}
-		}
-	}
-	for idx < len(orig) {
-		out = append(out, orig[idx])
-		idx++
-	}
-	return out, nil
-}
-
-func origLine(orig []string, idx int) string {
-	if idx >= 0 && idx < len(orig) {
-		return orig[idx]
-	}
-	return ""
-}
-
 // MarshalResult marshals a Result to JSON.
 func MarshalResult(r Result) (string, error) {
 	b, err := json.Marshal(r)
@@ -0,0 +1,139 @@
+package patch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+// memFS is an in-memory FS whose writes to failPath fail.
+type memFS struct {
+	files    map[string]string
+	failPath string
+}
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	s, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return []byte(s), nil
+}
+
+func (m *memFS) WriteFile(name string, data []byte, _ os.FileMode) error {
+	if name == m.failPath {
+		return errors.New("disk full")
+	}
+	m.files[name] = string(data)
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	if _, ok := m.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+const source = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n\nfunc helper() int {\n\treturn 1\n}\n"
+
+func TestApplyOffsetAndFuzz(t *testing.T) {
+	// The hunk claims line 2 but the function is at line 9, and its last
+	// context line is wrong.
+	p := "--- a/main.go\n+++ b/main.go\n@@ -2,4 +2,4 @@\n func helper() int {\n-\treturn 1\n+\treturn 2\n }\n // trailing comment\n"
+	m := &memFS{files: map[string]string{"main.go": source}}
+	if _, err := ApplyWith(p, m, Options{}); err == nil {
+		t.Fatal("exact application accepted wrong context")
+	}
+	res, err := ApplyWith(p, m, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(m.files["main.go"], "\treturn 2\n}\n") || strings.Contains(m.files["main.go"], "return 1") {
+		t.Fatalf("patched:\n%s", m.files["main.go"])
+	}
+	if len(res.Files) != 1 || res.Files[0].Additions != 1 || len(res.Files[0].Notes) != 1 || !strings.Contains(res.Files[0].Notes[0], "line 9 (offset +7, fuzz 1)") {
+		t.Fatalf("notes: %+v", res.Files)
+	}
+}
+
+func TestApplyIgnoreWhitespace(t *testing.T) {
+	// The model re-indented the context with spaces.
+	p := "--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() {\n-    fmt.Println(\"hello\")\n+\tfmt.Println(\"bye\")\n }\n"
+	m := &memFS{files: map[string]string{"main.go": source}}
+	if _, err := ApplyWith(p, m, DefaultOptions()); err == nil {
+		t.Fatal("whitespace difference accepted without IgnoreWhitespace")
+	}
+	if _, err := ApplyWith(p, m, Options{IgnoreWhitespace: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(m.files["main.go"], "\tfmt.Println(\"bye\")") {
+		t.Fatalf("patched:\n%s", m.files["main.go"])
+	}
+}
+
+func TestApplyIsAtomic(t *testing.T) {
+	good := "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n-one\n+uno\n two\n"
+	bad := "--- a/b.txt\n+++ b/b.txt\n@@ -1,3 +1,3 @@\n alpha\n-betta\n+beta\n gamma\n"
+	created := "--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+new\n"
+	orig := map[string]string{"a.txt": "one\ntwo\n", "b.txt": "alpha\nbeta\ngamma\ndelta\n"}
+	clone := func() map[string]string {
+		c := map[string]string{}
+		for k, v := range orig {
+			c[k] = v
+		}
+		return c
+	}
+
+	// A rejected hunk in the second file leaves the first untouched and
+	// points at the region it most resembles.
+	m := &memFS{files: clone()}
+	_, err := ApplyWith(good+bad+created, m, DefaultOptions())
+	var rej *RejectError
+	if !errors.As(err, &rej) || len(rej.Rejects) != 1 || rej.Hunks != 3 {
+		t.Fatalf("expected one reject: %v", err)
+	}
+	r := rej.Rejects[0]
+	if r.Path != "b.txt" || r.Hunk != 1 || r.NearestLine != 1 || r.Similarity < 0.6 || r.Nearest[1] != "beta" {
+		t.Fatalf("reject: %+v", r)
+	}
+	if !strings.Contains(err.Error(), "nearest match at line 1") {
+		t.Fatalf("message: %v", err)
+	}
+	if m.files["a.txt"] != orig["a.txt"] || len(m.files) != 2 {
+		t.Fatalf("files changed: %v", m.files)
+	}
+
+	// A failed write rolls back the files already written, including
+	// created ones.
+	m = &memFS{files: clone(), failPath: "b.txt"}
+	fix := strings.Replace(bad, "-betta", "-beta", 1)
+	if _, err := ApplyWith(good+created+fix, m, DefaultOptions()); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected rollback: %v", err)
+	}
+	if m.files["a.txt"] != orig["a.txt"] || len(m.files) != 2 {
+		t.Fatalf("not rolled back: %v", m.files)
+	}
+
+	m = &memFS{files: clone()}
+	if _, err := ApplyWith(good+created+fix, m, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if m.files["a.txt"] != "uno\ntwo\n" || m.files["b.txt"] != "alpha\nbeta\ngamma\ndelta\n" || m.files["c.txt"] != "new\n" {
+		t.Fatalf("applied: %v", m.files)
+	}
+}
+
+func TestWriteFilesRollsBack(t *testing.T) {
+	m := &memFS{files: map[string]string{"a.txt": "one\n", "b.txt": "two\n"}, failPath: "b.txt"}
+	err := WriteFiles(m, []File{{Path: "a.txt", Content: []byte("ONE\n")}, {Path: "new.txt", Content: []byte("new\n")}, {Path: "b.txt", Content: []byte("TWO\n")}})
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected rollback: %v", err)
+	}
+	if m.files["a.txt"] != "one\n" || len(m.files) != 2 {
+		t.Fatalf("not rolled back: %v", m.files)
+	}
+}
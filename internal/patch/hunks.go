@@ -0,0 +1,290 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	diff "github.com/sourcegraph/go-diff/diff"
+)
+
+// Options control how hunks are located in the files they patch.
+type Options struct {
+	// Fuzz is how many context lines may be ignored at each end of a hunk
+	// that does not match with its full context, as with patch -F.
+	Fuzz int
+	// IgnoreWhitespace compares lines with runs of whitespace collapsed and
+	// leading and trailing whitespace dropped. Context lines keep the file's
+	// own whitespace.
+	IgnoreWhitespace bool
+}
+
+// DefaultOptions are the options Apply and ApplyFS use: hunks are searched
+// for anywhere in the file, nearest their stated line first, with a fuzz
+// of 2.
+func DefaultOptions() Options {
+	return Options{Fuzz: 2}
+}
+
+// op is one line of a hunk body.
+type op struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// hunk is a parsed diff hunk.
+type hunk struct {
+	header string
+	start  int // 0-based line the hunk claims to start at
+	ops    []op
+}
+
+func parseHunk(h *diff.Hunk) hunk {
+	ph := hunk{
+		header: fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OrigStartLine, h.OrigLines, h.NewStartLine, h.NewLines),
+		start:  int(h.OrigStartLine) - 1,
+	}
+	if h.OrigLines == 0 {
+		// "-N,0" names the line the additions go after.
+		ph.start = int(h.OrigStartLine)
+	}
+	body := bytes.Split(bytes.TrimRight(h.Body, "\n"), []byte{'\n'})
+	for _, b := range body {
+		line := strings.TrimSuffix(string(b), "\r")
+		if line == "" {
+			// Blank context lines often lose their leading space.
+			ph.ops = append(ph.ops, op{kind: ' '})
+			continue
+		}
+		switch line[0] {
+		case ' ', '-', '+':
+			ph.ops = append(ph.ops, op{kind: line[0], text: line[1:]})
+		}
+		// "\ No newline at end of file" and anything else is ignored.
+	}
+	return ph
+}
+
+// trim drops up to fuzz context lines from each end of the hunk and
+// returns the remaining ops with the number dropped from the front.
+func (h hunk) trim(fuzz int) ([]op, int) {
+	ops := h.ops
+	front := 0
+	for front < fuzz && front < len(ops) && ops[front].kind == ' ' {
+		front++
+	}
+	ops = ops[front:]
+	back := 0
+	for back < fuzz && back < len(ops) && ops[len(ops)-1-back].kind == ' ' {
+		back++
+	}
+	return ops[:len(ops)-back], front
+}
+
+func oldSide(ops []op) []string {
+	var old []string
+	for _, o := range ops {
+		if o.kind != '+' {
+			old = append(old, o.text)
+		}
+	}
+	return old
+}
+
+// Reject is a hunk that could not be placed.
+type Reject struct {
+	Path   string `json:"path"`
+	Hunk   int    `json:"hunk"` // 1-based, within the file
+	Header string `json:"header"`
+	// Nearest is the region of the file most like the hunk's old side,
+	// starting at NearestLine (1-based), and Similarity the fraction of its
+	// lines that match. It is empty when nothing in the file resembles the
+	// hunk.
+	NearestLine int      `json:"nearest_line,omitempty"`
+	Nearest     []string `json:"nearest,omitempty"`
+	Similarity  float64  `json:"similarity,omitempty"`
+}
+
+// RejectError reports the hunks that did not apply. When it is returned
+// nothing has been written.
+type RejectError struct {
+	Rejects []Reject
+	Hunks   int // total hunks in the patch
+}
+
+func (e *RejectError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d hunks rejected; nothing was written", len(e.Rejects), e.Hunks)
+	for _, r := range e.Rejects {
+		fmt.Fprintf(&b, "\n%s hunk %d (%s): context not found", r.Path, r.Hunk, r.Header)
+		if len(r.Nearest) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "; nearest match at line %d (%.0f%% of lines match):", r.NearestLine, r.Similarity*100)
+		for i, l := range r.Nearest {
+			fmt.Fprintf(&b, "\n  %4d: %s", r.NearestLine+i, l)
+		}
+	}
+	return b.String()
+}
+
+// matcher places hunks in one file.
+type matcher struct {
+	opts Options
+}
+
+func (m matcher) equal(a, b string) bool {
+	if a == b {
+		return true
+	}
+	return m.opts.IgnoreWhitespace && normalize(a) == normalize(b)
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// at reports whether old matches orig starting at pos.
+func (m matcher) at(orig, old []string, pos int) bool {
+	if pos < 0 || pos+len(old) > len(orig) {
+		return false
+	}
+	for i, l := range old {
+		if !m.equal(orig[pos+i], l) {
+			return false
+		}
+	}
+	return true
+}
+
+// find returns where old matches orig at or after from, preferring the
+// position nearest want.
+func (m matcher) find(orig, old []string, from, want int) (int, bool) {
+	last := len(orig) - len(old)
+	if last < from {
+		return 0, false
+	}
+	want = min(max(want, from), last)
+	for d := 0; want-d >= from || want+d <= last; d++ {
+		if want-d >= from && m.at(orig, old, want-d) {
+			return want - d, true
+		}
+		if d > 0 && m.at(orig, old, want+d) {
+			return want + d, true
+		}
+	}
+	return 0, false
+}
+
+// applyHunks patches orig. Hunks that cannot be placed are returned as
+// rejects (without Path set) and the rest still apply; notes describe
+// hunks placed away from their stated line or with fuzz.
+func (m matcher) applyHunks(orig []string, hunks []*diff.Hunk) (out []string, notes []string, rejects []Reject) {
+	out = make([]string, 0, len(orig))
+	idx, offset := 0, 0
+	for n, raw := range hunks {
+		h := parseHunk(raw)
+		placed, tried := false, -1
+		for fuzz := 0; fuzz <= m.opts.Fuzz && !placed; fuzz++ {
+			ops, front := h.trim(fuzz)
+			if len(ops) == tried {
+				break // no context left to trim
+			}
+			tried = len(ops)
+			old := oldSide(ops)
+			if len(old) == 0 && fuzz > 0 {
+				break // fuzz trimmed away all the context
+			}
+			want := h.start + front + offset
+			var pos int
+			if len(old) == 0 {
+				// Pure additions go where the hunk says, clamped to the file.
+				pos = min(max(want, idx), len(orig))
+			} else {
+				var ok bool
+				if pos, ok = m.find(orig, old, idx, want); !ok {
+					continue
+				}
+			}
+			out = append(out, orig[idx:pos]...)
+			j := pos
+			for _, o := range ops {
+				switch o.kind {
+				case ' ':
+					out = append(out, orig[j])
+					j++
+				case '-':
+					j++
+				case '+':
+					out = append(out, o.text)
+				}
+			}
+			offset = pos - (h.start + front)
+			if offset != 0 || fuzz > 0 {
+				var how []string
+				if offset != 0 {
+					how = append(how, fmt.Sprintf("offset %+d", offset))
+				}
+				if fuzz > 0 {
+					how = append(how, fmt.Sprintf("fuzz %d", fuzz))
+				}
+				notes = append(notes, fmt.Sprintf("hunk %d applied at line %d (%s)", n+1, pos-front+1, strings.Join(how, ", ")))
+			}
+			idx = j
+			placed = true
+		}
+		if !placed {
+			rejects = append(rejects, nearest(orig, h, n+1))
+		}
+	}
+	out = append(out, orig[idx:]...)
+	return out, notes, rejects
+}
+
+// nearest finds the region of orig that best resembles the hunk's old side
+// so the rejection can point at it.
+func nearest(orig []string, h hunk, n int) Reject {
+	r := Reject{Hunk: n, Header: h.header}
+	old := oldSide(h.ops)
+	if len(old) == 0 || len(orig) == 0 {
+		return r
+	}
+	lenient := matcher{opts: Options{IgnoreWhitespace: true}}
+	type cand struct{ pos, score int }
+	var cands []cand
+	for pos := 0; pos < len(orig); pos++ {
+		score := 0
+		for i, l := range old {
+			if pos+i < len(orig) && lenient.equal(orig[pos+i], l) {
+				score++
+			}
+		}
+		if score > 0 {
+			cands = append(cands, cand{pos, score})
+		}
+	}
+	if len(cands) == 0 {
+		return r
+	}
+	// Best score first; among equals, nearest the hunk's stated line.
+	sort.SliceStable(cands, func(i, j int) bool {
+		if cands[i].score != cands[j].score {
+			return cands[i].score > cands[j].score
+		}
+		return abs(cands[i].pos-h.start) < abs(cands[j].pos-h.start)
+	})
+	best := cands[0]
+	end := min(best.pos+len(old), len(orig))
+	r.NearestLine = best.pos + 1
+	r.Nearest = append([]string(nil), orig[best.pos:end]...)
+	r.Similarity = float64(best.score) / float64(len(old))
+	return r
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
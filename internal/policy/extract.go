@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"strings"
+)
+
+// inputs are the parts of a call's arguments that rules look at.
+type inputs struct {
+	paths    []string
+	commands []string
+	urls     []string
+}
+
+// Argument names treated as paths, commands and URLs across builtins,
+// manifests and MCP tools.
+var (
+	pathArgs    = []string{"path", "paths", "file", "file_path", "filename", "dir", "directory", "cwd", "output", "target", "source", "dest", "destination"}
+	commandArgs = []string{"command", "cmd", "script"}
+	urlArgs     = []string{"url", "uri", "endpoint"}
+)
+
+func extract(c Call) inputs {
+	var in inputs
+	for _, k := range pathArgs {
+		in.paths = appendStrings(in.paths, c.Args[k])
+	}
+	for _, k := range commandArgs {
+		in.commands = appendStrings(in.commands, c.Args[k])
+	}
+	in.commands = append(in.commands, c.Commands...)
+	for _, k := range urlArgs {
+		in.urls = appendStrings(in.urls, c.Args[k])
+	}
+	if c.Tool == "patch" {
+		if diff, ok := c.Args["patch"].(string); ok {
+			in.paths = append(in.paths, patchPaths(diff)...)
+		}
+	}
+	return in
+}
+
+func appendStrings(out []string, v any) []string {
+	switch v := v.(type) {
+	case string:
+		if v != "" {
+			out = append(out, v)
+		}
+	case []string:
+		for _, s := range v {
+			out = appendStrings(out, s)
+		}
+	case []any:
+		for _, s := range v {
+			out = appendStrings(out, s)
+		}
+	}
+	return out
+}
+
+// patchPaths returns the files a unified diff touches.
+func patchPaths(diff string) []string {
+	var out []string
+	for _, line := range strings.Split(diff, "\n") {
+		var name string
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			name = line[4:]
+		case strings.HasPrefix(line, "+++ "):
+			name = line[4:]
+		default:
+			continue
+		}
+		if i := strings.IndexByte(name, '\t'); i >= 0 {
+			name = name[:i]
+		}
+		name = strings.TrimSpace(name)
+		if name == "" || name == "/dev/null" {
+			continue
+		}
+		if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+			name = name[2:]
+		}
+		out = append(out, name)
+	}
+	return out
+}
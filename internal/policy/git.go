@@ -0,0 +1,150 @@
+package policy
+
+import (
+	"strings"
+)
+
+// gitValueOptions take the next word as their value, which may be free text
+// such as a commit message, a pattern or a path.
+var gitValueOptions = map[string]bool{
+	"-m": true, "--message": true, "-F": true, "--file": true,
+	"-C": true, "-c": true, "-t": true, "--template": true,
+	"--author": true, "--date": true, "--trailer": true,
+	"-e": true, "-S": true, "-G": true, "--grep": true,
+	"--git-dir": true, "--work-tree": true, "--namespace": true,
+}
+
+// gitForced reports whether a command line runs git with a forcing option:
+// --force (or --force-with-lease, ...), a short option cluster with f, or
+// -D. Option values and anything after -- are skipped, so a commit message
+// that mentions -f does not count.
+func gitForced(line string) bool {
+	for _, words := range commandWords(line) {
+		for i, w := range words {
+			if w == "git" || strings.HasSuffix(w, "/git") {
+				if gitArgsForce(words[i+1:]) {
+					return true
+				}
+				break
+			}
+		}
+	}
+	return false
+}
+
+func gitArgsForce(args []string) bool {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			return false
+		case gitValueOptions[a]:
+			i++
+		case strings.HasPrefix(a, "--"):
+			if strings.HasPrefix(a, "--force") {
+				return true
+			}
+		case a == "-D":
+			return true
+		case len(a) > 1 && a[0] == '-':
+			// A cluster of short options: one that takes a value ends it,
+			// with the rest of the word or the next word as the value.
+			for j, c := range a[1:] {
+				if c == 'f' {
+					return true
+				}
+				if gitValueOptions["-"+string(c)] {
+					if j == len(a)-2 {
+						i++
+					}
+					break
+				}
+			}
+		}
+	}
+	return false
+}
+
+// commandWords splits a shell line into commands, as splitCommands does, and
+// each command into words, honouring quotes and backslashes so a quoted
+// argument stays one word. Command substitutions, which the shell runs even
+// inside double quotes, are returned as commands of their own.
+func commandWords(line string) [][]string {
+	var (
+		cmds  [][]string
+		words []string
+		word  strings.Builder
+		inW   bool
+		quote byte
+	)
+	endWord := func() {
+		if inW {
+			words = append(words, word.String())
+			word.Reset()
+			inW = false
+		}
+	}
+	endCmd := func() {
+		endWord()
+		if len(words) > 0 {
+			cmds = append(cmds, words)
+			words = nil
+		}
+	}
+	// substitution returns the commands in the $(...) or `...` starting at
+	// i and the index of its last byte.
+	substitution := func(i int) ([][]string, int) {
+		open, end := 2, strings.IndexByte(line[i+2:], ')')
+		if line[i] == '`' {
+			open, end = 1, strings.IndexByte(line[i+1:], '`')
+		}
+		if end < 0 {
+			return commandWords(line[i+open:]), len(line) - 1
+		}
+		return commandWords(line[i+open : i+open+end]), i + open + end
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+		case quote == '"':
+			switch {
+			case c == '"':
+				quote = 0
+			case c == '\\' && i+1 < len(line) && strings.IndexByte("\"\\$`", line[i+1]) >= 0:
+				i++
+				word.WriteByte(line[i])
+			case c == '`' || c == '$' && strings.HasPrefix(line[i:], "$("):
+				sub, end := substitution(i)
+				cmds = append(cmds, sub...)
+				word.WriteString(line[i : end+1])
+				i = end
+			default:
+				word.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inW = c, true
+		case c == '\\' && i+1 < len(line):
+			i++
+			word.WriteByte(line[i])
+			inW = true
+		case c == ' ' || c == '\t':
+			endWord()
+		case c == '$' && strings.HasPrefix(line[i:], "$("):
+			endCmd()
+			i++
+		case strings.IndexByte(";&|\n`()", c) >= 0:
+			endCmd()
+		default:
+			word.WriteByte(c)
+			inW = true
+		}
+	}
+	endCmd()
+	return cmds
+}
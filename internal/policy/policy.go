@@ -0,0 +1,394 @@
+// Package policy decides whether a tool call may run based on the tool name
+// and its arguments. Rules come from the permissions section of
+// .agentry.yaml and are evaluated in order; the first rule whose criteria
+// all match decides the action, otherwise the default applies.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/marcodenic/agentry/internal/config"
+)
+
+// Action is the outcome of a rule.
+type Action string
+
+const (
+	Allow Action = "allow"
+	Deny  Action = "deny"
+	Ask   Action = "ask"
+)
+
+func parseAction(s string) (Action, error) {
+	switch a := Action(strings.ToLower(strings.TrimSpace(s))); a {
+	case Allow, Deny, Ask:
+		return a, nil
+	case "":
+		return Allow, nil
+	}
+	return "", fmt.Errorf("want allow, deny or ask, got %q", s)
+}
+
+// Decision is the result of evaluating a call.
+type Decision struct {
+	Action Action
+	// Rule names the matching rule ("rule 2" when it has no name), or is
+	// "default" when no rule matched.
+	Rule   string
+	Reason string
+}
+
+// Call is a tool invocation to evaluate.
+type Call struct {
+	Tool string
+	Args map[string]any
+	// Commands are command lines the call runs that its arguments do not
+	// spell out, such as the git invocation behind a git_* builtin. They
+	// are matched by command rules alongside the command arguments.
+	Commands []string
+}
+
+type rule struct {
+	name     string
+	tools    []string
+	paths    []pathPattern
+	outside  bool
+	prefixes []string
+	regexes  []*regexp.Regexp
+	urls     []*regexp.Regexp
+	domains  []string
+	check    func(cmd string) bool // built-in command matcher
+	action   Action
+	reason   string
+}
+
+// Engine evaluates calls against a rule list. A nil Engine allows
+// everything.
+type Engine struct {
+	root  string
+	def   Action
+	rules []rule
+}
+
+// builtinRules are checked after the configured rules, so a matching rule
+// in .agentry.yaml (for example an allow rule for "git push") overrides
+// them. check, when set, must also match one of the call's commands.
+var builtinRules = []struct {
+	config.PolicyRule
+	check func(cmd string) bool
+}{
+	{PolicyRule: config.PolicyRule{
+		Name:     "builtin: git push",
+		Commands: []string{"git push"},
+		Action:   "deny",
+		Reason:   "git push is disabled by default; add an allow rule to permit it",
+	}},
+	{PolicyRule: config.PolicyRule{
+		Name:   "builtin: git force",
+		Action: "deny",
+		Reason: "forced git operations are disabled by default; add an allow rule to permit them",
+	}, check: gitForced},
+}
+
+// New compiles the rules in c followed by the built-in rules. root is the
+// workspace used for relative paths and OutsideWorkspace; empty means the
+// current directory.
+func New(c config.Permissions, root string) (*Engine, error) {
+	def, err := parseAction(c.Default)
+	if err != nil {
+		return nil, fmt.Errorf("permissions.default: %w", err)
+	}
+	if root == "" {
+		if root, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return nil, err
+	}
+	e := &Engine{root: root, def: def}
+	for i, rc := range c.Rules {
+		r, err := compileRule(rc, i)
+		if err != nil {
+			return nil, err
+		}
+		e.rules = append(e.rules, r)
+	}
+	for i, br := range builtinRules {
+		r, err := compileRule(br.PolicyRule, i)
+		if err != nil {
+			panic(err)
+		}
+		r.check = br.check
+		e.rules = append(e.rules, r)
+	}
+	return e, nil
+}
+
+func compileRule(rc config.PolicyRule, i int) (rule, error) {
+	r := rule{name: rc.Name, tools: rc.Tools, outside: rc.OutsideWorkspace, prefixes: rc.Commands, domains: rc.Domains, reason: rc.Reason}
+	if r.name == "" {
+		r.name = fmt.Sprintf("rule %d", i+1)
+	}
+	fail := func(field string, err error) error {
+		return fmt.Errorf("permissions.rules[%d] (%s) %s: %w", i, r.name, field, err)
+	}
+	var err error
+	if r.action, err = parseAction(rc.Action); err != nil || rc.Action == "" {
+		if err == nil {
+			err = fmt.Errorf("missing action")
+		}
+		return r, fail("action", err)
+	}
+	for _, t := range rc.Tools {
+		if _, err := path.Match(t, ""); err != nil {
+			return r, fail("tools", err)
+		}
+	}
+	for _, p := range rc.Paths {
+		r.paths = append(r.paths, pathPattern{
+			re:   globRegexp(p, true),
+			abs:  strings.HasPrefix(p, "/"),
+			base: !strings.Contains(p, "/"),
+		})
+	}
+	for _, u := range rc.URLs {
+		r.urls = append(r.urls, globRegexp(u, false))
+	}
+	for _, expr := range rc.CommandRegex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return r, fail("command_regex", err)
+		}
+		r.regexes = append(r.regexes, re)
+	}
+	return r, nil
+}
+
+// Evaluate returns the decision for c.
+func (e *Engine) Evaluate(c Call) Decision {
+	if e == nil {
+		return Decision{Action: Allow}
+	}
+	in := extract(c)
+	for _, r := range e.rules {
+		if e.matches(r, c.Tool, in) {
+			return Decision{Action: r.action, Rule: r.name, Reason: r.reason}
+		}
+	}
+	return Decision{Action: e.def, Rule: "default"}
+}
+
+func (e *Engine) matches(r rule, tool string, in inputs) bool {
+	if len(r.tools) > 0 && !anyMatch(r.tools, func(p string) bool { ok, _ := path.Match(p, tool); return ok }) {
+		return false
+	}
+	if len(r.paths) > 0 && !anyPath(in.paths, func(p string) bool { return e.pathMatches(r.paths, p) }) {
+		return false
+	}
+	if r.outside && !anyPath(in.paths, e.outside) {
+		return false
+	}
+	if len(r.prefixes) > 0 && !anyPath(in.commands, func(cmd string) bool { return prefixMatch(r.prefixes, cmd) }) {
+		return false
+	}
+	if len(r.regexes) > 0 && !anyPath(in.commands, func(cmd string) bool {
+		for _, re := range r.regexes {
+			if re.MatchString(cmd) {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+	if r.check != nil && !anyPath(in.commands, r.check) {
+		return false
+	}
+	if len(r.urls) > 0 && !anyPath(in.urls, func(u string) bool {
+		for _, re := range r.urls {
+			if re.MatchString(u) {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+	if len(r.domains) > 0 && !anyPath(in.urls, func(u string) bool { return domainMatch(r.domains, u) }) {
+		return false
+	}
+	return true
+}
+
+func anyMatch(patterns []string, f func(string) bool) bool {
+	for _, p := range patterns {
+		if f(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyPath(values []string, f func(string) bool) bool {
+	for _, v := range values {
+		if f(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the absolute, cleaned form of p and its slash-separated
+// form relative to the workspace ("" when outside it).
+func (e *Engine) resolve(p string) (abs, rel string) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(e.root, p)
+	}
+	abs = filepath.Clean(p)
+	if r, err := filepath.Rel(e.root, abs); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		rel = filepath.ToSlash(r)
+	}
+	return abs, rel
+}
+
+func (e *Engine) outside(p string) bool {
+	_, rel := e.resolve(p)
+	return rel == ""
+}
+
+// pathPattern is a compiled path glob: abs patterns match the absolute
+// path, base patterns (no /) the base name, others the workspace-relative
+// path.
+type pathPattern struct {
+	re        *regexp.Regexp
+	abs, base bool
+}
+
+func (e *Engine) pathMatches(patterns []pathPattern, p string) bool {
+	abs, rel := e.resolve(p)
+	abs = filepath.ToSlash(abs)
+	for _, pp := range patterns {
+		switch {
+		case pp.abs:
+			if pp.re.MatchString(abs) {
+				return true
+			}
+		case pp.base:
+			if pp.re.MatchString(path.Base(abs)) {
+				return true
+			}
+		case rel != "" && pp.re.MatchString(rel):
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp compiles a glob. For paths, * and ? stop at / and ** spans
+// directories ("a/**" also matches a itself); otherwise * matches anything.
+func globRegexp(glob string, paths bool) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case paths && strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case paths && strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			sb.WriteString("(?:/.*)?")
+			i += 2
+		case paths && strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			if paths {
+				sb.WriteString("[^/]*")
+			} else {
+				sb.WriteString(".*")
+			}
+		case c == '?':
+			if paths {
+				sb.WriteString("[^/]")
+			} else {
+				sb.WriteString(".")
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// prefixMatch checks each command of a shell line (split on ;, &&, ||, |
+// and newlines) against the prefixes, ignoring repeated spaces.
+func prefixMatch(prefixes []string, line string) bool {
+	for _, cmd := range splitCommands(line) {
+		cmd = strings.Join(strings.Fields(cmd), " ")
+		for _, p := range prefixes {
+			p = strings.Join(strings.Fields(p), " ")
+			if cmd == p || strings.HasPrefix(cmd, p+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var commandSeparators = regexp.MustCompile(`&&|\|\||[;|\n&]|\$\(|` + "`")
+
+func splitCommands(line string) []string {
+	var out []string
+	for _, part := range commandSeparators.Split(line, -1) {
+		part = strings.TrimLeft(strings.TrimSpace(part), "({")
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func domainMatch(domains []string, raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if suffix, ok := strings.CutPrefix(d, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Approver is asked to confirm calls whose decision is Ask. It returns
+// whether the call may run.
+type Approver func(ctx context.Context, c Call, d Decision) bool
+
+type approverKey struct{}
+
+// WithApprover attaches an Approver to ctx. Without one, Ask denies.
+func WithApprover(ctx context.Context, a Approver) context.Context {
+	return context.WithValue(ctx, approverKey{}, a)
+}
+
+// ApproverFrom returns the Approver attached to ctx, or nil.
+func ApproverFrom(ctx context.Context) Approver {
+	a, _ := ctx.Value(approverKey{}).(Approver)
+	return a
+}
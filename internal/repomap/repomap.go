@@ -0,0 +1,392 @@
+// Package repomap builds a compact, ranked map of a repository: the source
+// files with their top-level symbols and signatures, ordered by how much
+// the rest of the code refers to them and by relevance to a query, and
+// trimmed to a token budget.
+package repomap
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/tokens"
+)
+
+const (
+	// DefaultMaxTokens is the budget used when Options.MaxTokens is unset.
+	DefaultMaxTokens = 1500
+	// MaxTokensLimit caps the budget a caller may ask for.
+	MaxTokensLimit = 20000
+
+	maxFileSize     = 512 << 10
+	maxFiles        = 10000
+	maxFileSymbols  = 30
+	maxDefiners     = 10
+	relevanceWeight = 10
+)
+
+var ignoreDirs = map[string]bool{
+	"node_modules": true, "dist": true, "build": true, "target": true, "vendor": true,
+	"__pycache__": true, "venv": true, "coverage": true, "tmp": true, "testdata": true,
+}
+
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true, "from": true,
+	"into": true, "add": true, "fix": true, "make": true, "use": true, "when": true, "should": true,
+	"can": true, "are": true, "not": true, "all": true, "how": true, "what": true, "where": true,
+	"file": true, "files": true, "code": true, "new": true, "our": true, "please": true,
+}
+
+// Options select and rank the files in a map.
+type Options struct {
+	// Query ranks files and symbols whose names match its words first.
+	Query string
+	// MaxTokens bounds the rendered map; zero means DefaultMaxTokens.
+	MaxTokens int
+	// Paths restricts the scan to these directories or files under root.
+	Paths []string
+	// IncludeTests also maps test files.
+	IncludeTests bool
+}
+
+// RankedFile is one file in a map.
+type RankedFile struct {
+	Path     string   `json:"path"`
+	Language string   `json:"language"`
+	Score    float64  `json:"score"`
+	Symbols  []Symbol `json:"symbols"`
+}
+
+// Map is a rendered repository map.
+type Map struct {
+	Files   []RankedFile `json:"files"`
+	Scanned int          `json:"scanned"`
+	Omitted int          `json:"omitted"`
+	Tokens  int          `json:"tokens"`
+	Text    string       `json:"text"`
+}
+
+type entry struct {
+	mod     time.Time
+	size    int64
+	lang    string
+	symbols []Symbol
+	idents  map[string]int
+	words   map[string]bool
+}
+
+// Cache keeps parsed files between builds and reparses a file only when its
+// modification time or size changes.
+type Cache struct {
+	mu     sync.Mutex
+	files  map[string]*entry
+	parses int
+}
+
+// NewCache returns an empty cache.
+func NewCache() *Cache {
+	return &Cache{files: map[string]*entry{}}
+}
+
+var defaultCache = NewCache()
+
+// Build maps root using the shared cache.
+func Build(root string, opts Options) (*Map, error) {
+	return defaultCache.Build(root, opts)
+}
+
+type scanned struct {
+	rel string
+	*entry
+}
+
+// Build scans root, ranks its files and renders them within the budget.
+func (c *Cache) Build(root string, opts Options) (*Map, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	dirs := []string{root}
+	if len(opts.Paths) > 0 {
+		dirs = dirs[:0]
+		for _, p := range opts.Paths {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(root, p)
+			}
+			p = filepath.Clean(p)
+			if rel, err := filepath.Rel(root, p); err != nil || strings.HasPrefix(rel, "..") {
+				return nil, fmt.Errorf("path %s is outside %s", p, root)
+			}
+			dirs = append(dirs, p)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var files []scanned
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			name := d.Name()
+			if d.IsDir() {
+				if path != dir && (ignoreDirs[name] || strings.HasPrefix(name, ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if len(files) >= maxFiles {
+				return filepath.SkipAll
+			}
+			lang := language(path)
+			if lang == "" || seen[path] || (!opts.IncludeTests && isTestFile(path)) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil || info.Size() > maxFileSize {
+				return nil
+			}
+			seen[path] = true
+			e := c.load(path, lang, info)
+			if e == nil {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			files = append(files, scanned{filepath.ToSlash(rel), e})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.prune(dirs, seen)
+
+	ranked := rank(files, queryTerms(opts.Query))
+	return render(ranked, len(files), opts), nil
+}
+
+// load returns the cached entry for path, reparsing it when it changed.
+func (c *Cache) load(path, lang string, info fs.FileInfo) *entry {
+	if e, ok := c.files[path]; ok && e.mod.Equal(info.ModTime()) && e.size == info.Size() {
+		return e
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		delete(c.files, path)
+		return nil
+	}
+	c.parses++
+	e := &entry{mod: info.ModTime(), size: info.Size(), lang: lang, idents: identifiers(src), words: map[string]bool{}}
+	if lang == "go" {
+		e.symbols = parseGo(src)
+	} else {
+		e.symbols = parseTags(lang, src)
+	}
+	for id := range e.idents {
+		for _, w := range words(id) {
+			e.words[w] = true
+		}
+	}
+	c.files[path] = e
+	return e
+}
+
+// prune drops cached files under dirs that were not seen by this scan.
+func (c *Cache) prune(dirs []string, seen map[string]bool) {
+	for path := range c.files {
+		if seen[path] {
+			continue
+		}
+		for _, dir := range dirs {
+			if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				delete(c.files, path)
+				break
+			}
+		}
+	}
+}
+
+func queryTerms(q string) []string {
+	var terms []string
+	dup := map[string]bool{}
+	for _, w := range words(q) {
+		if len(w) < 3 || stopwords[w] || dup[w] {
+			continue
+		}
+		dup[w] = true
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// matches reports whether a query term names word, allowing for plurals
+// and other suffixes on longer words.
+func matches(term, word string) bool {
+	if term == word {
+		return true
+	}
+	if len(term) >= 4 && strings.HasPrefix(word, term) {
+		return true
+	}
+	return len(word) >= 4 && strings.HasPrefix(term, word)
+}
+
+func matchesAny(term string, ws []string) bool {
+	for _, w := range ws {
+		if matches(term, w) {
+			return true
+		}
+	}
+	return false
+}
+
+type rankedSymbol struct {
+	Symbol
+	score float64
+}
+
+type rankedFile struct {
+	scanned
+	score  float64
+	ranked []rankedSymbol
+}
+
+// rank scores each file by references to its symbols from other files and
+// by the query terms its path, symbols and identifiers match.
+func rank(files []scanned, terms []string) []rankedFile {
+	definers := map[string]int{}
+	for _, f := range files {
+		names := map[string]bool{}
+		for _, s := range f.symbols {
+			names[s.baseName()] = true
+		}
+		for n := range names {
+			definers[n]++
+		}
+	}
+	// referrers counts the files mentioning each defined name, definers
+	// included.
+	referrers := map[string]int{}
+	for _, f := range files {
+		for id := range f.idents {
+			if definers[id] > 0 {
+				referrers[id]++
+			}
+		}
+	}
+
+	out := make([]rankedFile, 0, len(files))
+	for _, f := range files {
+		rf := rankedFile{scanned: f}
+		pathWords := words(f.rel)
+		symHits := map[string]bool{}
+		for _, s := range f.symbols {
+			rs := rankedSymbol{Symbol: s}
+			name := s.baseName()
+			if d := definers[name]; d <= maxDefiners && len(name) >= 3 {
+				refs := float64(referrers[name] - d)
+				rs.score = math.Log2(1 + refs/float64(d))
+				rf.score += rs.score
+			}
+			nameWords := words(s.Name)
+			for _, t := range terms {
+				if matchesAny(t, nameWords) {
+					symHits[t] = true
+					rs.score += relevanceWeight
+				}
+			}
+			rf.ranked = append(rf.ranked, rs)
+		}
+		relevance := 0
+		for _, t := range terms {
+			if matchesAny(t, pathWords) {
+				relevance += 4
+			}
+			if symHits[t] {
+				relevance += 3
+			}
+			if f.words[t] {
+				relevance++
+			} else if len(t) >= 4 {
+				for w := range f.words {
+					if matches(t, w) {
+						relevance++
+						break
+					}
+				}
+			}
+		}
+		rf.score += float64(relevance * relevanceWeight)
+		if len(rf.ranked) == 0 && relevance == 0 {
+			continue
+		}
+		out = append(out, rf)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].score != out[j].score {
+			return out[i].score > out[j].score
+		}
+		return out[i].rel < out[j].rel
+	})
+	return out
+}
+
+// render writes files in rank order until the budget is spent. Each file
+// lists its highest-scoring symbols in source order.
+func render(files []rankedFile, scanned int, opts Options) *Map {
+	budget := opts.MaxTokens
+	if budget <= 0 {
+		budget = DefaultMaxTokens
+	}
+	budget = min(budget, MaxTokensLimit)
+
+	m := &Map{Files: []RankedFile{}, Scanned: scanned}
+	var b strings.Builder
+	for i, f := range files {
+		syms := f.ranked
+		if len(syms) > maxFileSymbols {
+			syms = append([]rankedSymbol(nil), syms...)
+			sort.SliceStable(syms, func(i, j int) bool { return syms[i].score > syms[j].score })
+			syms = syms[:maxFileSymbols]
+			sort.Slice(syms, func(i, j int) bool { return syms[i].Line < syms[j].Line })
+		}
+		var chunk strings.Builder
+		chunk.WriteString(f.rel + ":\n")
+		rf := RankedFile{Path: f.rel, Language: f.lang, Score: math.Round(f.score*100) / 100, Symbols: make([]Symbol, 0, len(syms))}
+		for _, s := range syms {
+			fmt.Fprintf(&chunk, "  %d: %s\n", s.Line, s.Signature)
+			rf.Symbols = append(rf.Symbols, s.Symbol)
+		}
+		if more := len(f.ranked) - len(syms); more > 0 {
+			fmt.Fprintf(&chunk, "  ... %d more symbols\n", more)
+		}
+		n := tokens.CountWithFallback(chunk.String())
+		if m.Tokens+n > budget {
+			m.Omitted = len(files) - i
+			break
+		}
+		m.Tokens += n
+		b.WriteString(chunk.String())
+		m.Files = append(m.Files, rf)
+	}
+	if m.Omitted > 0 {
+		fmt.Fprintf(&b, "... %d more files (raise max_tokens or narrow paths to see them)\n", m.Omitted)
+	}
+	header := fmt.Sprintf("Repository map: %d of %d files, ranked by references", len(m.Files), scanned)
+	if terms := queryTerms(opts.Query); len(terms) > 0 {
+		header += " and relevance to: " + strings.Join(terms, " ")
+	}
+	m.Text = header + "\n" + b.String()
+	return m
+}
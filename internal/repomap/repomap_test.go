@@ -0,0 +1,140 @@
+package repomap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+var repo = map[string]string{
+	"store/store.go":          "package store\n\n// Store keeps records.\ntype Store struct{}\n\nfunc Open(path string) (*Store, error) { return &Store{}, nil }\n\nfunc (s *Store) Get(key string) string { return key }\n",
+	"api/handler.go":          "package api\n\nimport \"x/store\"\n\nfunc Serve(s *store.Store) { s.Get(\"a\") }\n",
+	"cli/main.go":             "package main\n\nimport \"x/store\"\n\nfunc main() { s, _ := store.Open(\"db\"); _ = s }\n",
+	"auth/login.py":           "class LoginManager:\n    def authenticate(self, user):\n        return True\n\ndef logout(user):\n    pass\n",
+	"web/session.ts":          "export class SessionStore {\n  refresh(token: string): void {\n  }\n}\nexport const createSession = (id: string) => new SessionStore();\n",
+	"store/store_test.go":     "package store\n\nfunc TestOpen() {}\n",
+	"node_modules/x/index.js": "function ignored() {}\n",
+}
+
+func TestBuildRanksByReferences(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, repo)
+	m, err := NewCache().Build(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Scanned != 5 {
+		t.Fatalf("scanned %d files, want 5 (tests and node_modules skipped)", m.Scanned)
+	}
+	if m.Files[0].Path != "store/store.go" {
+		t.Fatalf("most referenced file not first: %+v", m.Files)
+	}
+	for _, want := range []string{"func (s *Store) Get(key string) string", "class LoginManager", "LoginManager.authenticate", "export const createSession"} {
+		if !strings.Contains(m.Text, want) && !hasSymbol(m, want) {
+			t.Fatalf("map missing %q:\n%s", want, m.Text)
+		}
+	}
+
+	m, err = NewCache().Build(dir, Options{IncludeTests: true, Paths: []string{"store"}})
+	if err != nil || m.Scanned != 2 {
+		t.Fatalf("paths and include_tests: %v %+v", err, m)
+	}
+}
+
+func hasSymbol(m *Map, name string) bool {
+	for _, f := range m.Files {
+		for _, s := range f.Symbols {
+			if s.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestBuildRanksByQuery(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, repo)
+	m, err := NewCache().Build(dir, Options{Query: "fix the login authentication"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Files[0].Path != "auth/login.py" || !strings.Contains(m.Text, "relevance to: login authentication") {
+		t.Fatalf("query did not rank login first:\n%s", m.Text)
+	}
+	m, _ = NewCache().Build(dir, Options{Query: "session token refresh"})
+	if m.Files[0].Path != "web/session.ts" {
+		t.Fatalf("query did not rank session first:\n%s", m.Text)
+	}
+}
+
+func TestBuildTrimsToBudget(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, repo)
+	m, err := NewCache().Build(dir, Options{MaxTokens: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Tokens > 40 || m.Omitted == 0 || len(m.Files)+m.Omitted != 5 {
+		t.Fatalf("budget not applied: tokens=%d omitted=%d files=%d", m.Tokens, m.Omitted, len(m.Files))
+	}
+	if !strings.Contains(m.Text, "more files") {
+		t.Fatalf("omitted files not reported:\n%s", m.Text)
+	}
+}
+
+func TestCacheInvalidatesOnChange(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, repo)
+	c := NewCache()
+	if _, err := c.Build(dir, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Build(dir, Options{}); err != nil || c.parses != 5 {
+		t.Fatalf("unchanged files reparsed: %d parses", c.parses)
+	}
+
+	p := filepath.Join(dir, "cli", "main.go")
+	if err := os.WriteFile(p, []byte("package main\n\nfunc Run() {}\n\nfunc main() { Run() }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(p, later, later); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(dir, "auth", "login.py"))
+	m, err := c.Build(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.parses != 6 || !hasSymbol(m, "Run") || len(c.files) != 4 {
+		t.Fatalf("parses=%d cached=%d map:\n%s", c.parses, len(c.files), m.Text)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	src := "pub struct Point { x: i32 }\n\nimpl Point {\n    pub fn norm(&self) -> f64 {\n        0.0\n    }\n}\n\nfn main() {}\n"
+	syms := parseTags("rust", []byte(src))
+	var names []string
+	for _, s := range syms {
+		names = append(names, s.Kind+":"+s.Name)
+	}
+	if got := strings.Join(names, " "); got != "struct:Point impl:Point method:Point.norm func:main" {
+		t.Fatalf("rust tags: %s", got)
+	}
+}
@@ -0,0 +1,172 @@
+package repomap
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/marcodenic/agentry/internal/goedit"
+)
+
+// Symbol is a top-level declaration (or a method of one) in a file.
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Signature string `json:"signature"`
+	Line      int    `json:"line"`
+}
+
+// baseName is the part of the symbol other files refer to: the method
+// name of "Type.Method".
+func (s Symbol) baseName() string {
+	if i := strings.LastIndexByte(s.Name, '.'); i >= 0 {
+		return s.Name[i+1:]
+	}
+	return s.Name
+}
+
+var languages = map[string]string{
+	".go": "go", ".py": "python", ".rs": "rust", ".rb": "ruby",
+	".ts": "typescript", ".tsx": "typescript", ".js": "javascript", ".jsx": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+}
+
+func language(path string) string { return languages[strings.ToLower(filepath.Ext(path))] }
+
+func isTestFile(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	return strings.HasSuffix(base, "_test.go") || strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test.py") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") || strings.HasSuffix(base, "_spec.rb")
+}
+
+// tag is a line pattern for one kind of declaration. name is the index of
+// the submatch holding the declared name.
+type tag struct {
+	re   *regexp.Regexp
+	kind string
+	name int
+}
+
+var tagPatterns = map[string][]tag{
+	"python": {
+		{regexp.MustCompile(`^class\s+(\w+)`), "class", 1},
+		{regexp.MustCompile(`^(?:async\s+)?def\s+(\w+)`), "func", 1},
+		{regexp.MustCompile(`^\s+(?:async\s+)?def\s+(\w+)`), "method", 1},
+	},
+	"javascript": jsTags,
+	"typescript": jsTags,
+	"rust": {
+		{regexp.MustCompile(`^\s*(?:pub(?:\([\w:]+\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"\w+"\s+)?fn\s+(\w+)`), "func", 1},
+		{regexp.MustCompile(`^(?:pub(?:\([\w:]+\))?\s+)?(struct|enum|trait|union|type|mod)\s+(\w+)`), "", 2},
+		{regexp.MustCompile(`^impl(?:<[^>]*>)?\s+(?:[\w:<>, ]+\s+for\s+)?(\w+)`), "impl", 1},
+	},
+	"ruby": {
+		{regexp.MustCompile(`^\s*(class|module)\s+([\w:]+)`), "", 2},
+		{regexp.MustCompile(`^\s*def\s+(?:self\.)?(\w+[?!=]?)`), "method", 1},
+	},
+}
+
+var jsTags = []tag{
+	{regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(\w+)`), "func", 1},
+	{regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`), "class", 1},
+	{regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?(interface|type|enum)\s+(\w+)`), "", 2},
+	{regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+(\w+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function|\([^)]*\)\s*(?::[^=]+)?=>|\w+\s*=>)`), "func", 1},
+	{regexp.MustCompile(`^\s+(?:public\s+|private\s+|protected\s+|static\s+|async\s+|readonly\s+)*(\w+)\s*(?:<[^>]*>)?\([^)]*\)\s*(?::\s*[^{]+)?\{\s*$`), "method", 1},
+}
+
+var jsKeywords = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "function": true, "return": true, "constructor": true}
+
+// parseTags extracts declarations from non-Go source line by line.
+func parseTags(lang string, src []byte) []Symbol {
+	patterns := tagPatterns[lang]
+	var syms []Symbol
+	container := ""
+	for i, line := range strings.Split(string(src), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if lang == "python" && line[0] != ' ' && line[0] != '\t' && !strings.HasPrefix(line, "class ") {
+			container = ""
+		}
+		for _, t := range patterns {
+			m := t.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			kind := t.kind
+			if kind == "" {
+				kind = m[1]
+			}
+			name := m[t.name]
+			if lang == "rust" && kind == "func" && container != "" && (line[0] == ' ' || line[0] == '\t') {
+				kind = "method"
+			}
+			if kind == "method" {
+				if jsKeywords[name] || (lang == "python" && container == "") {
+					break
+				}
+				if container != "" {
+					name = container + "." + name
+				}
+			}
+			if kind == "class" || kind == "impl" || (lang == "ruby" && kind != "method") {
+				container = m[t.name]
+			}
+			sig := strings.TrimSpace(line)
+			sig = strings.TrimSpace(strings.TrimRight(sig, "{:"))
+			if len(sig) > 120 {
+				sig = sig[:117] + "..."
+			}
+			syms = append(syms, Symbol{Name: name, Kind: kind, Signature: sig, Line: i + 1})
+			break
+		}
+	}
+	return syms
+}
+
+// parseGo lists a Go file's declarations with go/parser. Files that do not
+// parse contribute no symbols.
+func parseGo(src []byte) []Symbol {
+	o, err := goedit.ParseOutline(src)
+	if err != nil {
+		return nil
+	}
+	syms := make([]Symbol, 0, len(o.Decls))
+	for _, d := range o.Decls {
+		sig := d.Signature
+		if len(sig) > 160 {
+			sig = sig[:157] + "..."
+		}
+		syms = append(syms, Symbol{Name: d.QualifiedName(), Kind: d.Kind, Signature: sig, Line: d.Line})
+	}
+	return syms
+}
+
+var identRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{2,}`)
+
+// identifiers counts the identifiers of three or more characters in src.
+func identifiers(src []byte) map[string]int {
+	ids := map[string]int{}
+	for _, m := range identRe.FindAll(src, -1) {
+		ids[string(m)]++
+	}
+	return ids
+}
+
+var camelRe = regexp.MustCompile(`[A-Z]+[a-z0-9]*|[a-z0-9]+`)
+
+// words splits an identifier or path into lower-case words at
+// punctuation and camel-case boundaries, keeping each unsplit part too.
+func words(s string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		for _, w := range camelRe.FindAllString(part, -1) {
+			out = append(out, strings.ToLower(w))
+		}
+		if lp := strings.ToLower(part); len(out) == 0 || out[len(out)-1] != lp {
+			out = append(out, lp)
+		}
+	}
+	return out
+}
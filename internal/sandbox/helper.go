@@ -0,0 +1,33 @@
+package sandbox
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// helperArg marks a re-executed agentry process as the sandbox helper.
+const helperArg = "__agentry_sandbox"
+
+var helperReady atomic.Bool
+
+// Init must run at the start of main (and of TestMain in tests that use
+// limits). In a normal process it records that the binary can act as the
+// sandbox helper and returns. In the helper it applies the rlimits from its
+// arguments and execs the command, never returning.
+func Init() {
+	if len(os.Args) > 1 && os.Args[1] == helperArg {
+		os.Exit(runHelper(os.Args[2:]))
+	}
+	helperReady.Store(true)
+}
+
+func helperPath() (string, bool) {
+	if !helperReady.Load() {
+		return "", false
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", false
+	}
+	return exe, true
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func runHelper([]string) int {
+	fmt.Fprintln(os.Stderr, "sandbox: resource limits are not supported on this platform")
+	return 126
+}
+
+func setProcessGroup(*exec.Cmd) {}
@@ -0,0 +1,73 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// runHelper applies limits of the form name=value up to "--" and execs the
+// remaining arguments.
+func runHelper(args []string) int {
+	for len(args) > 0 && args[0] != "--" {
+		name, val, _ := strings.Cut(args[0], "=")
+		n, err := strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: bad limit %q\n", args[0])
+			return 126
+		}
+		var res int
+		switch name {
+		case "cpu":
+			res = unix.RLIMIT_CPU
+		case "as":
+			res = unix.RLIMIT_AS
+		case "fsize":
+			res = unix.RLIMIT_FSIZE
+		case "nproc":
+			res = unix.RLIMIT_NPROC
+		default:
+			fmt.Fprintf(os.Stderr, "sandbox: unknown limit %q\n", name)
+			return 126
+		}
+		if err := unix.Setrlimit(res, &unix.Rlimit{Cur: n, Max: n}); err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: setrlimit %s: %v\n", name, err)
+			return 126
+		}
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "sandbox: no command")
+		return 126
+	}
+	path, err := exec.LookPath(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 127
+	}
+	err = syscall.Exec(path, args[1:], os.Environ())
+	fmt.Fprintf(os.Stderr, "sandbox: exec %s: %v\n", args[1], err)
+	return 126
+}
+
+// setProcessGroup starts the command in its own process group so a timeout
+// kills everything it spawned.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		if cmd.Process == nil {
+			return nil
+		}
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,51 @@
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+var (
+	nsOnce sync.Once
+	nsErr  error
+)
+
+// isolate runs cmd in a new user namespace (mapping the current user to
+// itself) and, if cutNet is set, a new network namespace with only an
+// unconfigured loopback interface.
+func isolate(cmd *exec.Cmd, cutNet bool) error {
+	nsOnce.Do(func() { nsErr = probeNamespaces() })
+	if nsErr != nil {
+		return nsErr
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	applyNamespaces(cmd.SysProcAttr, cutNet)
+	return nil
+}
+
+func applyNamespaces(attr *syscall.SysProcAttr, cutNet bool) {
+	attr.Cloneflags = syscall.CLONE_NEWUSER
+	if cutNet {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+// probeNamespaces checks once whether unprivileged namespaces work here by
+// starting a trivial process in them.
+func probeNamespaces() error {
+	path, err := exec.LookPath("true")
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	applyNamespaces(cmd.SysProcAttr, true)
+	return cmd.Run()
+}
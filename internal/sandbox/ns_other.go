@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func isolate(*exec.Cmd, bool) error {
+	return errors.New("namespaces require Linux")
+}
@@ -0,0 +1,346 @@
+// Package sandbox runs shell and command tools under resource limits.
+//
+// A Policy describes the limits: rlimits for CPU time, address space, file
+// size and process count, a wall-clock timeout, a scrubbed environment, a
+// working directory confined to the workspace and, on Linux, optional user
+// and network namespaces. Rlimits are applied by re-executing the agentry
+// binary as a small helper (see Init) that sets them and then execs the
+// command, so no shell is involved.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/config"
+)
+
+// Engine selects how much isolation a Policy applies.
+type Engine string
+
+const (
+	// EngineDisabled runs commands as before: inherited environment, no
+	// limits and no timeout beyond the caller's context.
+	EngineDisabled Engine = "disabled"
+	// EngineProcess applies rlimits, the timeout, the environment scrub and
+	// the workspace confinement.
+	EngineProcess Engine = "process"
+	// EngineNamespace adds Linux user and network namespaces to
+	// EngineProcess; the network is cut off unless Network is set.
+	EngineNamespace Engine = "namespace"
+)
+
+// ErrUnavailable reports that the requested isolation cannot be provided
+// on this system and the policy is strict.
+var ErrUnavailable = errors.New("sandbox isolation unavailable")
+
+// defaultEnv are the variables a sandboxed command inherits.
+var defaultEnv = []string{"PATH", "HOME", "USER", "LOGNAME", "LANG", "LC_ALL", "LC_CTYPE", "TERM", "TMPDIR", "TZ", "SHELL",
+	"SYSTEMROOT", "COMSPEC", "PATHEXT", "TEMP", "TMP", "USERPROFILE"}
+
+// Policy describes how a command is run. The zero value is EngineDisabled.
+type Policy struct {
+	Engine Engine
+	// Root is the workspace; commands start there and may not be pointed
+	// outside it. Empty means the current directory.
+	Root    string
+	Timeout time.Duration
+	// CPU is the RLIMIT_CPU budget, rounded up to whole seconds.
+	CPU time.Duration
+	// Memory (RLIMIT_AS) and FileSize (RLIMIT_FSIZE) are in bytes.
+	Memory   int64
+	FileSize int64
+	// Procs is RLIMIT_NPROC, which counts every process of the user.
+	Procs int
+	// Network keeps the host network under EngineNamespace.
+	Network bool
+	// Env names variables passed through in addition to the defaults.
+	Env []string
+	// Strict refuses to run when isolation is unavailable instead of
+	// warning and running with what is available.
+	Strict bool
+}
+
+// Warn receives a message the first time a policy has to run with less
+// isolation than requested. It defaults to printing on stderr.
+var Warn = func(msg string) { fmt.Fprintln(os.Stderr, "Warning: sandbox: "+msg) }
+
+var warned sync.Map
+
+func warnOnce(msg string) {
+	if _, dup := warned.LoadOrStore(msg, true); !dup && Warn != nil {
+		Warn(msg)
+	}
+}
+
+// FromConfig builds the default policy from the sandbox section of
+// .agentry.yaml. root is the workspace; an empty root uses c.Workdir or the
+// current directory.
+func FromConfig(c config.Sandbox, root string) (Policy, error) {
+	p := Policy{Engine: Engine(strings.ToLower(c.Engine)), Env: c.Env}
+	if p.Engine == "" {
+		p.Engine = EngineDisabled
+	}
+	switch c.OnUnavailable {
+	case "", "warn":
+	case "refuse":
+		p.Strict = true
+	default:
+		return Policy{}, fmt.Errorf("sandbox.on_unavailable: want warn or refuse, got %q", c.OnUnavailable)
+	}
+	if root == "" {
+		root = c.Workdir
+	}
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return Policy{}, err
+		}
+		root = wd
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return Policy{}, err
+	}
+	p.Root = abs
+	if p.Timeout, err = parseDuration(c.Timeout); err != nil {
+		return Policy{}, fmt.Errorf("sandbox.timeout: %w", err)
+	}
+	if p.CPU, err = parseDuration(c.CPULimit); err != nil {
+		return Policy{}, fmt.Errorf("sandbox.cpu_limit: %w", err)
+	}
+	if p.Memory, err = ParseSize(c.MemLimit); err != nil {
+		return Policy{}, fmt.Errorf("sandbox.mem_limit: %w", err)
+	}
+	if p.FileSize, err = ParseSize(c.FileLimit); err != nil {
+		return Policy{}, fmt.Errorf("sandbox.file_limit: %w", err)
+	}
+	p.Procs = c.ProcLimit
+	if p.Network, err = parseNet(c.Net); err != nil {
+		return Policy{}, fmt.Errorf("sandbox.net: %w", err)
+	}
+	return p, nil
+}
+
+// WithManifest applies a tool manifest's engine, cpu_limit, mem_limit and
+// net overrides.
+func (p Policy) WithManifest(m config.ToolManifest) (Policy, error) {
+	var err error
+	if m.Engine != "" {
+		p.Engine = Engine(strings.ToLower(m.Engine))
+	}
+	if m.CPULimit != "" {
+		if p.CPU, err = parseDuration(m.CPULimit); err != nil {
+			return p, fmt.Errorf("tool %s: cpu_limit: %w", m.Name, err)
+		}
+	}
+	if m.MemLimit != "" {
+		if p.Memory, err = ParseSize(m.MemLimit); err != nil {
+			return p, fmt.Errorf("tool %s: mem_limit: %w", m.Name, err)
+		}
+	}
+	if m.Net != "" {
+		if p.Network, err = parseNet(m.Net); err != nil {
+			return p, fmt.Errorf("tool %s: net: %w", m.Name, err)
+		}
+	}
+	return p, nil
+}
+
+// Run executes argv in dir (relative to Root) and returns its combined
+// output.
+func (p Policy) Run(ctx context.Context, dir string, argv []string) (string, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	cmd, err := p.Command(ctx, dir, argv)
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && p.Timeout > 0 {
+		err = fmt.Errorf("command timed out after %s", p.Timeout)
+	}
+	return string(out), err
+}
+
+// Command prepares argv under the policy without starting it. The caller
+// applies any timeout through ctx (Run uses Policy.Timeout).
+func (p Policy) Command(ctx context.Context, dir string, argv []string) (*exec.Cmd, error) {
+	if len(argv) == 0 {
+		return nil, errors.New("empty command")
+	}
+	if p.Engine == "" || p.Engine == EngineDisabled {
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Dir = dir
+		return cmd, nil
+	}
+
+	if p.Engine != EngineProcess && p.Engine != EngineNamespace {
+		if err := p.degrade(fmt.Sprintf("engine %q is not supported; using process limits", p.Engine)); err != nil {
+			return nil, err
+		}
+	}
+	wd, err := p.Confine(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	name, args := argv[0], argv[1:]
+	if limits := p.limitArgs(); len(limits) > 0 {
+		if exe, ok := helperPath(); ok {
+			args = append(append(append([]string{helperArg}, limits...), "--"), argv...)
+			name = exe
+		} else if err := p.degrade("resource limits need sandbox.Init in main; running without rlimits"); err != nil {
+			return nil, err
+		}
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = wd
+	cmd.Env = p.environ()
+	cmd.WaitDelay = 2 * time.Second
+	setProcessGroup(cmd)
+
+	if p.Engine == EngineNamespace {
+		if err := isolate(cmd, !p.Network); err != nil {
+			if derr := p.degrade("namespaces unavailable (" + err.Error() + "); running without network isolation"); derr != nil {
+				return nil, derr
+			}
+		}
+	}
+	return cmd, nil
+}
+
+func (p Policy) degrade(msg string) error {
+	if p.Strict {
+		return fmt.Errorf("%w: %s", ErrUnavailable, msg)
+	}
+	warnOnce(msg)
+	return nil
+}
+
+// Confine resolves dir against Root and rejects directories outside it,
+// following symlinks.
+func (p Policy) Confine(dir string) (string, error) {
+	root := p.Root
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		root = wd
+	}
+	if dir == "" {
+		return root, nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(realRoot, realDir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("working directory %s is outside the workspace %s", dir, root)
+	}
+	return realDir, nil
+}
+
+func (p Policy) environ() []string {
+	var env []string
+	seen := map[string]bool{}
+	for _, name := range append(append([]string{}, defaultEnv...), p.Env...) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	return env
+}
+
+func (p Policy) limitArgs() []string {
+	var out []string
+	if p.CPU > 0 {
+		out = append(out, "cpu="+strconv.FormatInt(int64((p.CPU+time.Second-1)/time.Second), 10))
+	}
+	if p.Memory > 0 {
+		out = append(out, "as="+strconv.FormatInt(p.Memory, 10))
+	}
+	if p.FileSize > 0 {
+		out = append(out, "fsize="+strconv.FormatInt(p.FileSize, 10))
+	}
+	if p.Procs > 0 {
+		out = append(out, "nproc="+strconv.Itoa(p.Procs))
+	}
+	return out
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// ParseSize parses a byte count with an optional K, M or G suffix (powers
+// of 1024), e.g. 512M.
+func ParseSize(s string) (int64, error) {
+	orig := s
+	s = strings.TrimSpace(strings.ToUpper(s))
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", orig)
+	}
+	return n * mult, nil
+}
+
+func parseNet(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "", "host", "on", "true":
+		return true, nil
+	case "none", "off", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("want host or none, got %q", s)
+}
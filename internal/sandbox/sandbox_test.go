@@ -0,0 +1,174 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/config"
+)
+
+func TestMain(m *testing.M) {
+	Init()
+	os.Exit(m.Run())
+}
+
+func requireUnix(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("sandbox limits need a unix system")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	root := t.TempDir()
+	p, err := FromConfig(config.Sandbox{
+		Engine: "Process", Timeout: "90", CPULimit: "2s", MemLimit: "512M",
+		FileLimit: "1GiB", ProcLimit: 64, Net: "none", OnUnavailable: "refuse",
+	}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Engine != EngineProcess || p.Timeout != 90*time.Second || p.CPU != 2*time.Second ||
+		p.Memory != 512<<20 || p.FileSize != 1<<30 || p.Procs != 64 || p.Network || !p.Strict || p.Root != root {
+		t.Fatalf("unexpected policy %+v", p)
+	}
+	if _, err := FromConfig(config.Sandbox{MemLimit: "lots"}, root); err == nil {
+		t.Fatal("expected error for bad mem_limit")
+	}
+	if _, err := FromConfig(config.Sandbox{OnUnavailable: "panic"}, root); err == nil {
+		t.Fatal("expected error for bad on_unavailable")
+	}
+}
+
+func TestEnvironmentScrubbed(t *testing.T) {
+	requireUnix(t)
+	t.Setenv("AGENTRY_SECRET_TOKEN", "s3cret")
+	t.Setenv("AGENTRY_KEEP", "kept")
+	p := Policy{Engine: EngineProcess, Root: t.TempDir(), Env: []string{"AGENTRY_KEEP"}}
+	out, err := p.Run(context.Background(), "", []string{"env"})
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Fatalf("secret leaked into sandbox env:\n%s", out)
+	}
+	if !strings.Contains(out, "AGENTRY_KEEP=kept") || !strings.Contains(out, "PATH=") {
+		t.Fatalf("expected PATH and AGENTRY_KEEP in env:\n%s", out)
+	}
+}
+
+func TestConfine(t *testing.T) {
+	requireUnix(t)
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	p := Policy{Engine: EngineProcess, Root: root}
+
+	out, err := p.Run(context.Background(), "sub", []string{"pwd"})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if filepath.Base(strings.TrimSpace(out)) != "sub" {
+		t.Fatalf("expected to run in sub, got %q", out)
+	}
+	for _, dir := range []string{"..", outside, "escape"} {
+		if _, err := p.Run(context.Background(), dir, []string{"pwd"}); err == nil {
+			t.Fatalf("expected %q to be rejected", dir)
+		}
+	}
+}
+
+func TestTimeoutKillsProcessGroup(t *testing.T) {
+	requireUnix(t)
+	p := Policy{Engine: EngineProcess, Root: t.TempDir(), Timeout: 200 * time.Millisecond}
+	start := time.Now()
+	_, err := p.Run(context.Background(), "", []string{"sh", "-c", "sleep 30 & sleep 30"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("timeout took %s", time.Since(start))
+	}
+}
+
+func TestFileSizeLimit(t *testing.T) {
+	requireUnix(t)
+	root := t.TempDir()
+	p := Policy{Engine: EngineProcess, Root: root, FileSize: 4096}
+	_, err := p.Run(context.Background(), "", []string{"sh", "-c", "head -c 65536 /dev/zero > big"})
+	if err == nil {
+		t.Fatal("expected the write to fail under the file size limit")
+	}
+	if fi, statErr := os.Stat(filepath.Join(root, "big")); statErr == nil && fi.Size() > 4096 {
+		t.Fatalf("file grew past the limit: %d bytes", fi.Size())
+	}
+}
+
+func TestLimitsWithoutHelper(t *testing.T) {
+	requireUnix(t)
+	helperReady.Store(false)
+	defer helperReady.Store(true)
+
+	p := Policy{Engine: EngineProcess, Root: t.TempDir(), CPU: time.Second, Strict: true}
+	if _, err := p.Run(context.Background(), "", []string{"true"}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	var warnings []string
+	oldWarn := Warn
+	Warn = func(msg string) { warnings = append(warnings, msg) }
+	defer func() { Warn = oldWarn }()
+	p.Strict = false
+	if _, err := p.Run(context.Background(), "", []string{"true"}); err != nil {
+		t.Fatalf("non-strict policy should run: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected one warning, got %v", warnings)
+	}
+}
+
+func TestUnknownEngineStrict(t *testing.T) {
+	p := Policy{Engine: "cri", Root: t.TempDir(), Strict: true}
+	if _, err := p.Command(context.Background(), "", []string{"true"}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestNetworkNamespace(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("namespaces require Linux")
+	}
+	if err := probeNamespaces(); err != nil {
+		t.Skipf("unprivileged namespaces unavailable: %v", err)
+	}
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not found")
+	}
+	p := Policy{Engine: EngineNamespace, Root: t.TempDir(), Strict: true}
+	out, err := p.Run(context.Background(), "", []string{"cat", "/proc/net/dev"})
+	if err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	for _, line := range strings.Split(out, "\n")[2:] {
+		name, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		if name != "" && name != "lo" {
+			t.Fatalf("expected only loopback in the namespace, found %q:\n%s", name, out)
+		}
+	}
+
+	p.Network = true
+	if _, err := p.Run(context.Background(), "", []string{"true"}); err != nil {
+		t.Fatalf("user namespace with host network: %v", err)
+	}
+}
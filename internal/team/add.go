@@ -35,9 +35,12 @@ func (t *Team) AddAgent(name string) (*core.Agent, string) {
 	spawned, err := t.SpawnAgent(context.Background(), name, name)
 	if err != nil {
 		debugPrintf("AddAgent fallback: failed to SpawnAgent(%s): %v", name, err)
-		registry := tool.DefaultRegistry()
+		perms := t.parent.Permissions.Narrow(nil, nil)
+		registry := perms.Apply(tool.DefaultRegistry())
 		delete(registry, "agent")
-		coreAgent := core.New(t.parent.Client, t.parent.ModelName, registry, memory.NewInMemory(), memory.NewInMemoryVector(), t.parent.Tracer)
+		coreAgent := core.New(t.parent.Client, t.parent.ModelName, registry, memory.NewInMemory(), memory.NewDefaultVector(), t.parent.Tracer)
+		coreAgent.Permissions = perms
+		coreAgent.Overlay = t.parent.Overlay
 		t.Add(name, coreAgent)
 		return coreAgent, name
 	}
@@ -65,7 +65,14 @@ func (t *Team) SpawnAgent(ctx context.Context, name, role string) (*Agent, error
 		registry = capRegistry(registry, maxTools)
 	}
 
-	// Apply tool restrictions based on role configuration
+	// Workers inherit the parent's permissions, narrowed by the role's
+	// restrictions; the restricted tools are also hidden from the model.
+	var perms *tool.Permissions
+	if t.parent != nil {
+		perms = t.parent.Permissions
+	}
+	perms = perms.Narrow(nil, roleConfig.RestrictedTools)
+	registry = perms.Apply(registry)
 	if len(roleConfig.RestrictedTools) > 0 {
 		for _, restrictedTool := range roleConfig.RestrictedTools {
 			delete(registry, restrictedTool)
@@ -108,7 +115,13 @@ func (t *Team) SpawnAgent(ctx context.Context, name, role string) (*Agent, error
 		}
 	}
 
-	agent := core.New(client, modelName, registry, memory.NewInMemory(), memory.NewInMemoryVector(), nil)
+	agent := core.New(client, modelName, registry, memory.NewInMemory(), memory.NewDefaultVector(), nil)
+	agent.Permissions = perms
+	// Workers of a dry run, and dry-run roles, write to the team's overlay
+	// so they see each other's proposed changes.
+	if roleConfig.DryRun || (t.parent != nil && t.parent.Overlay != nil) {
+		agent.Overlay = t.overlayLocked(true)
+	}
 	// Ensure we do not allow recursive delegation by default
 	delete(agent.Tools, "agent")
 	agent.InvalidateToolCache()
@@ -172,10 +185,9 @@ func (t *Team) ListAgents() []*Agent {
 // StopAgent stops and removes an agent from the team
 func (t *Team) StopAgent(ctx context.Context, agentID string) error {
 	t.mutex.Lock()
-	defer t.mutex.Unlock()
-
 	agent := t.agents[agentID]
 	if agent == nil {
+		t.mutex.Unlock()
 		return fmt.Errorf("agent %s not found", agentID)
 	}
 
@@ -185,7 +197,12 @@ func (t *Team) StopAgent(ctx context.Context, agentID string) error {
 	// Remove from maps
 	delete(t.agents, agentID)
 	delete(t.agentsByName, agent.Name)
+	t.mutex.Unlock()
 
+	// Stop the agent's shell session and background processes
+	if agent.Agent != nil {
+		tool.ReleaseAgent(agent.Agent.ID.String())
+	}
 	return nil
 }
 
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"time"
 )
 
@@ -26,7 +27,7 @@ func (t *Team) LogCoordinationEvent(eventType, from, to, content string, metadat
 	// Persist the event (best-effort)
 	if t.store != nil {
 		if b, err := json.Marshal(event); err == nil {
-			_ = t.store.Set(t.name, "coord-"+event.ID, b, 0)
+			_ = t.store.Set(t.storeNamespace(), "coord-"+event.ID, b, 0)
 		}
 	}
 
@@ -40,27 +41,22 @@ func (t *Team) loadCoordinationFromStore() {
 	if t.store == nil {
 		return
 	}
-	keys, err := t.store.Keys(t.name)
-	if err != nil || len(keys) == 0 {
+	entries, err := t.store.Scan(t.storeNamespace(), "coord-")
+	if err != nil || len(entries) == 0 {
 		return
 	}
-	// Collect coord-* keys
-	events := make([]CoordinationEvent, 0)
-	for _, k := range keys {
-		if len(k) < 6 || k[:6] != "coord-" {
-			continue
-		}
-		if b, ok, err := t.store.Get(t.name, k); err == nil && ok {
-			var ev CoordinationEvent
-			if err := json.Unmarshal(b, &ev); err == nil {
-				events = append(events, ev)
-			}
+	events := make([]CoordinationEvent, 0, len(entries))
+	for _, e := range entries {
+		var ev CoordinationEvent
+		if err := json.Unmarshal(e.Value, &ev); err == nil {
+			events = append(events, ev)
 		}
 	}
 	if len(events) == 0 {
 		return
 	}
 	// Append to in-memory log, keep order by timestamp
+	sort.Slice(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
 	t.mutex.Lock()
 	t.coordination = append(t.coordination, events...)
 	t.mutex.Unlock()
@@ -134,9 +130,7 @@ func (t *Team) PublishWorkspaceEvent(agentID, eventType, description string, dat
 	events, exists := t.GetSharedData(eventsKey)
 	var eventList []WorkspaceEvent
 	if exists {
-		if oldList, ok := events.([]WorkspaceEvent); ok {
-			eventList = oldList
-		}
+		eventList = asWorkspaceEvents(events)
 	}
 
 	// Limit to last 50 events
@@ -165,11 +159,27 @@ func (t *Team) GetWorkspaceEvents(limit int) []WorkspaceEvent {
 	if !exists {
 		return []WorkspaceEvent{}
 	}
-	if eventList, ok := events.([]WorkspaceEvent); ok {
-		if limit > 0 && len(eventList) > limit {
-			return eventList[len(eventList)-limit:]
-		}
-		return eventList
+	eventList := asWorkspaceEvents(events)
+	if limit > 0 && len(eventList) > limit {
+		return eventList[len(eventList)-limit:]
+	}
+	return eventList
+}
+
+// asWorkspaceEvents accepts either the typed slice kept in memory or the
+// generic form decoded from the shared store (e.g. after another process
+// updated it).
+func asWorkspaceEvents(v interface{}) []WorkspaceEvent {
+	if list, ok := v.([]WorkspaceEvent); ok {
+		return list
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return []WorkspaceEvent{}
+	}
+	var list []WorkspaceEvent
+	if err := json.Unmarshal(b, &list); err != nil {
+		return []WorkspaceEvent{}
 	}
-	return []WorkspaceEvent{}
+	return list
 }
@@ -14,15 +14,19 @@ func curatedToolsForRole(role string) []string {
 	case "coder":
 		return []string{
 			"read_lines", "view", "edit_range", "create", "search_replace", "insert_at", "fileinfo",
-			"bash", "sh",
-			"ls", "find", "glob", "grep",
-			"patch", "branch-tidy",
-			"lsp_diagnostics",
+			"bash", "sh", "shell_session",
+			"proc_start", "proc_output", "proc_input", "proc_wait", "proc_kill",
+			"ls", "find", "glob", "grep", "repo_map",
+			"patch", "undo", "lease",
+			"go_outline", "go_replace_func", "go_add_method", "go_ensure_import", "go_remove_import", "go_rename_local",
+			"git_status", "git_diff", "git_log", "git_show", "git_branch", "git_commit", "git_stash", "git_blame",
+			"lsp_diagnostics", "lsp_definition", "lsp_references", "lsp_hover", "lsp_symbols", "lsp_rename",
+			"run_tests",
 		}
 	case "reviewer", "critic", "editor":
-		return []string{"view", "read_lines", "lsp_diagnostics"}
+		return []string{"view", "read_lines", "lsp_diagnostics", "lsp_definition", "lsp_references", "git_diff", "git_blame"}
 	case "tester":
-		return []string{"view", "read_lines", "lsp_diagnostics"}
+		return []string{"run_tests", "view", "read_lines", "lsp_diagnostics"}
 	case "researcher", "writer":
 		return []string{"web_search", "read_webpage", "api"}
 	default:
@@ -1,11 +1,32 @@
 package team
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 )
 
+// StoreNamespace returns the SharedStore namespace holding a team's shared
+// data and coordination events. Unnamed teams are scoped to the working
+// directory, mirroring the todo namespace.
+func StoreNamespace(name string) string {
+	if name != "" {
+		return name
+	}
+	cwd, _ := os.Getwd()
+	abs, _ := filepath.Abs(cwd)
+	h := sha1.Sum([]byte(abs))
+	return "team:project:" + hex.EncodeToString(h[:8])
+}
+
+func (t *Team) storeNamespace() string {
+	return StoreNamespace(t.name)
+}
+
 // SetSharedData stores data in shared memory accessible to all agents
 func (t *Team) SetSharedData(key string, value interface{}) {
 	t.mutex.Lock()
@@ -15,10 +36,10 @@ func (t *Team) SetSharedData(key string, value interface{}) {
 	// Persist a JSON representation to the shared store (best-effort)
 	if t.store != nil {
 		if b, err := json.Marshal(value); err == nil {
-			_ = t.store.Set(t.name, key, b, 0)
+			_ = t.store.Set(t.storeNamespace(), key, b, 0)
 		} else {
 			// Fallback to string formatting to avoid losing data entirely
-			_ = t.store.Set(t.name, key, []byte(fmt.Sprintf("%v", value)), 0)
+			_ = t.store.Set(t.storeNamespace(), key, []byte(fmt.Sprintf("%v", value)), 0)
 		}
 	}
 
@@ -49,7 +70,7 @@ func (t *Team) GetSharedData(key string) (interface{}, bool) {
 
 	// Try backing store if not present in in-memory map
 	if t.store != nil {
-		if b, ok, err := t.store.Get(t.name, key); err == nil && ok {
+		if b, ok, err := t.store.Get(t.storeNamespace(), key); err == nil && ok {
 			var out interface{}
 			if err := json.Unmarshal(b, &out); err != nil {
 				// treat as plain string
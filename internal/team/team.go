@@ -9,6 +9,7 @@ import (
 	"github.com/marcodenic/agentry/internal/contracts"
 	"github.com/marcodenic/agentry/internal/core"
 	"github.com/marcodenic/agentry/internal/memstore"
+	"github.com/marcodenic/agentry/internal/overlay"
 )
 
 // Compile-time check to ensure Team implements contracts.TeamService
@@ -30,6 +31,7 @@ type Team struct {
 	sharedMemory map[string]interface{} // Shared data between agents
 	store        memstore.SharedStore   // Durable-backed store (in-memory by default)
 	coordination []CoordinationEvent    // Log of coordination events
+	overlay      *overlay.FS            // Proposed changes of dry-run roles
 }
 
 // NewTeam creates a new team with the given parent agent.
@@ -96,6 +98,27 @@ func (t *Team) GetRoles() map[string]*RoleConfig {
 	return out
 }
 
+// Overlay returns the overlay holding the team's dry-run changes: the
+// parent's when it runs dry, otherwise the one shared by dry-run roles. It
+// is nil when nothing in the team runs dry.
+func (t *Team) Overlay() *overlay.FS {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.overlayLocked(false)
+}
+
+// overlayLocked returns the team's overlay, creating it when create is set.
+// The caller holds t.mutex.
+func (t *Team) overlayLocked(create bool) *overlay.FS {
+	if t.parent != nil && t.parent.Overlay != nil {
+		return t.parent.Overlay
+	}
+	if t.overlay == nil && create {
+		t.overlay = overlay.New()
+	}
+	return t.overlay
+}
+
 // Tool curation helpers moved to registry.go
 
 // Add and AddAgent moved to add.go
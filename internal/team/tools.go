@@ -11,15 +11,23 @@ import (
 // This must be called after creating the team to avoid import cycles.
 func (t *Team) RegisterAgentTool(registry tool.Registry) {
 	// Wrap delegation tool as terminal: its result is typically the final answer.
-	registry["agent"] = tool.MarkTerminal(tool.NewWithSchema(
-		"agent",
-		"Delegate work to another agent",
-		agentToolSchema(),
-		agentDelegationExec(t),
-	))
-
-	// Add parallel agent tool via shared helper
-	registry["parallel_agents"] = parallelAgentsToolSpec(t)
+	delegation := tool.Registry{
+		"agent": tool.MarkTerminal(tool.NewWithSchema(
+			"agent",
+			"Delegate work to another agent",
+			agentToolSchema(),
+			agentDelegationExec(t),
+		)),
+		// Add parallel agent tool via shared helper
+		"parallel_agents": parallelAgentsToolSpec(t),
+	}
+	// The delegation tools are subject to the parent agent's permissions.
+	if t.parent != nil {
+		delegation = t.parent.Permissions.Apply(delegation)
+	}
+	for name, tl := range delegation {
+		registry[name] = tl
+	}
 }
 
 // GetAgentToolSpec returns the tool specification for the agent tool
@@ -58,6 +58,9 @@ type RoleConfig struct {
 	RestrictedTools []string              `json:"restricted_tools,omitempty" yaml:"restricted_tools,omitempty"`
 	Capabilities    []string              `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
 	Metadata        map[string]string     `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	// DryRun keeps the role's file changes in the team's overlay instead of
+	// writing them to disk.
+	DryRun bool `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
 }
 
 // CoordinationEvent represents an event in agent coordination
@@ -0,0 +1,53 @@
+package team
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/marcodenic/agentry/internal/memstore"
+)
+
+// Watch subscribes to changes in the team's shared store namespace. Cached
+// shared data is dropped whenever the store holds something newer than the
+// cache (for example a write from another agentry process), so the next
+// GetSharedData call reloads it. Every event is forwarded on the returned
+// channel until ctx is cancelled.
+func (t *Team) Watch(ctx context.Context) (<-chan memstore.Event, error) {
+	if t.store == nil {
+		return nil, errors.New("team has no shared store")
+	}
+	in, err := t.store.Watch(ctx, t.storeNamespace(), "")
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan memstore.Event, cap(in))
+	go func() {
+		defer close(out)
+		for ev := range in {
+			t.invalidateShared(ev)
+			select {
+			case out <- ev:
+			default: // the consumer treats events as refresh hints
+			}
+		}
+	}()
+	return out, nil
+}
+
+// invalidateShared drops a cached shared value that no longer matches the store.
+func (t *Team) invalidateShared(ev memstore.Event) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	cached, ok := t.sharedMemory[ev.Key]
+	if !ok {
+		return
+	}
+	if ev.Type == memstore.EventSet {
+		if b, err := json.Marshal(cached); err == nil && bytes.Equal(b, ev.Value) {
+			return // our own write
+		}
+	}
+	delete(t.sharedMemory, ev.Key)
+}
@@ -0,0 +1,473 @@
+package testrun
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCaseOutput caps the log kept for each case; the tail is kept since
+// that is where failures are reported.
+const maxCaseOutput = 2000
+
+func tail(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= maxCaseOutput {
+		return s
+	}
+	return "... " + s[len(s)-maxCaseOutput:]
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(line)
+}
+
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func stripANSI(s string) string { return ansiRe.ReplaceAllString(s, "") }
+
+// go test -json
+
+type goEvent struct {
+	Action      string
+	Package     string
+	Test        string
+	Output      string
+	Elapsed     float64
+	ImportPath  string
+	FailedBuild string
+}
+
+var (
+	goLogRe   = regexp.MustCompile(`^\s+([\w.\-/]+\.go):(\d+): ?(.*)$`)
+	goFrameRe = regexp.MustCompile(`^\s+(\S+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`)
+	goBuildRe = regexp.MustCompile(`^(\S+\.go):(\d+)(?::\d+)?: (.*)$`)
+)
+
+// ParseGoJSON parses go test -json output. modPath is the module path,
+// used to turn package-relative file names into workspace paths.
+func ParseGoJSON(out []byte, modPath string) Report {
+	type key struct{ pkg, test string }
+	var (
+		r        Report
+		logs     = map[key]*strings.Builder{}
+		builds   = map[string]*strings.Builder{}
+		failed   = map[string]bool{} // packages with a failed test
+		raw      strings.Builder
+		buildFor = func(m map[string]*strings.Builder, k string) *strings.Builder {
+			if m[k] == nil {
+				m[k] = &strings.Builder{}
+			}
+			return m[k]
+		}
+	)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 1<<20), 16<<20)
+	for sc.Scan() {
+		var ev goEvent
+		line := sc.Bytes()
+		if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &ev) != nil {
+			raw.Write(line)
+			raw.WriteByte('\n')
+			continue
+		}
+		k := key{ev.Package, ev.Test}
+		switch ev.Action {
+		case "build-output":
+			buildFor(builds, ev.ImportPath).WriteString(ev.Output)
+		case "output":
+			if logs[k] == nil {
+				logs[k] = &strings.Builder{}
+			}
+			logs[k].WriteString(ev.Output)
+		case "pass", "fail", "skip":
+			if ev.Test == "" {
+				if ev.Action != "fail" || failed[ev.Package] {
+					continue
+				}
+				// The package failed outside any test: a build failure, a
+				// panic in init or TestMain, or a timeout.
+				var text string
+				if ev.FailedBuild != "" {
+					text = buildFor(builds, ev.FailedBuild).String()
+				} else if logs[k] != nil {
+					text = logs[k].String()
+				}
+				if strings.TrimSpace(text) == "" {
+					text = raw.String()
+				}
+				c := Case{Name: "(package)", Suite: ev.Package, Status: Fail, Output: tail(text)}
+				c.File, c.Line, c.Message = goBuildLocation(text)
+				if c.Message == "" {
+					c.Message = goPanic(text)
+				}
+				if c.Message == "" {
+					c.Message = "package failed"
+				}
+				r.Errors = append(r.Errors, c)
+				continue
+			}
+			c := Case{Name: ev.Test, Suite: ev.Package, Status: Status(ev.Action), Duration: time.Duration(ev.Elapsed * float64(time.Second))}
+			if ev.Action == "fail" {
+				failed[ev.Package] = true
+				text := ""
+				if logs[k] != nil {
+					text = logs[k].String()
+				}
+				c.Output = tail(text)
+				c.File, c.Line, c.Message = goFailure(text)
+				if c.File != "" && !strings.Contains(c.File, "/") && modPath != "" {
+					if dir, ok := strings.CutPrefix(ev.Package, modPath); ok {
+						c.File = path.Join(strings.TrimPrefix(dir, "/"), c.File)
+					}
+				}
+			}
+			r.Cases = append(r.Cases, c)
+		}
+	}
+	r.Cases = dropFailedParents(r.Cases)
+	return r
+}
+
+// dropFailedParents removes failed tests whose failure is only that a
+// subtest failed; the subtest carries the location and message.
+func dropFailedParents(cases []Case) []Case {
+	out := cases[:0]
+	for _, c := range cases {
+		if c.Status == Fail && c.Message == "" {
+			child := false
+			for _, d := range cases {
+				if d.Status == Fail && d.Suite == c.Suite && strings.HasPrefix(d.Name, c.Name+"/") {
+					child = true
+					break
+				}
+			}
+			if child {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// goFailure finds the first t.Error/t.Fatal style log line, or a panic,
+// in a test's output.
+func goFailure(text string) (file string, line int, msg string) {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		if m := goLogRe.FindStringSubmatch(l); m != nil {
+			line, _ = strconv.Atoi(m[2])
+			msg = strings.TrimSpace(m[3])
+			if msg == "" && i+1 < len(lines) {
+				msg = strings.TrimSpace(lines[i+1])
+			}
+			return m[1], line, msg
+		}
+	}
+	if msg = goPanic(text); msg != "" {
+		for _, l := range lines {
+			m := goFrameRe.FindStringSubmatch(l)
+			if m == nil || strings.Contains(m[1], "/src/runtime/") || strings.Contains(m[1], "/src/testing/") {
+				continue
+			}
+			line, _ = strconv.Atoi(m[2])
+			return m[1], line, msg
+		}
+	}
+	return "", 0, msg
+}
+
+func goPanic(text string) string {
+	for _, l := range strings.Split(text, "\n") {
+		if strings.HasPrefix(l, "panic: ") {
+			return strings.TrimSpace(l)
+		}
+	}
+	return ""
+}
+
+func goBuildLocation(text string) (string, int, string) {
+	for _, l := range strings.Split(text, "\n") {
+		if m := goBuildRe.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+			n, _ := strconv.Atoi(m[2])
+			return path.Clean(m[1]), n, m[3]
+		}
+	}
+	return "", 0, ""
+}
+
+// JUnit XML (pytest, vitest and others)
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	File   string       `xml:"file,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	File      string       `xml:"file,attr"`
+	Time      string       `xml:"time,attr"`
+	Failure   *junitResult `xml:"failure"`
+	Error     *junitResult `xml:"error"`
+	Skipped   *junitResult `xml:"skipped"`
+	SystemOut string       `xml:"system-out"`
+	SystemErr string       `xml:"system-err"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit parses a JUnit XML report whose root is <testsuites> or a
+// single <testsuite>.
+func ParseJUnit(b []byte) (Report, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(b, &root); err != nil {
+		return Report{}, fmt.Errorf("junit: %w", err)
+	}
+	var r Report
+	var walk func(s junitSuite, file string)
+	walk = func(s junitSuite, file string) {
+		if s.File != "" {
+			file = s.File
+		}
+		for _, jc := range s.Cases {
+			c := Case{Name: jc.Name, Suite: jc.Classname, Status: Pass, File: jc.File}
+			if c.File == "" {
+				c.File = file
+			}
+			if secs, err := strconv.ParseFloat(jc.Time, 64); err == nil {
+				c.Duration = time.Duration(secs * float64(time.Second))
+			}
+			res := jc.Failure
+			if res == nil {
+				res = jc.Error
+			}
+			switch {
+			case res != nil:
+				c.Status = Fail
+				text := stripANSI(res.Text)
+				c.Message = stripANSI(res.Message)
+				if c.Message == "" {
+					c.Message = firstLine(text)
+				}
+				c.Output = tail(strings.TrimSpace(text + "\n" + jc.SystemOut + "\n" + jc.SystemErr))
+				if f, n := traceLocation(text, c.File); f != "" {
+					c.File, c.Line = f, n
+				}
+			case jc.Skipped != nil:
+				c.Status = Skip
+				c.Message = jc.Skipped.Message
+			}
+			r.Cases = append(r.Cases, c)
+		}
+		for _, sub := range s.Suites {
+			walk(sub, file)
+		}
+	}
+	walk(root, "")
+	return r, nil
+}
+
+var traceRe = regexp.MustCompile(`([\w./\\@-]+\.(?:py|[cm]?[jt]sx?|rs|go)):(\d+)`)
+
+// traceLocation picks the failure location from a traceback: in Python the
+// innermost frame is last, in JavaScript it is first. Frames in the test's
+// own file are preferred and dependencies are skipped.
+func traceLocation(text, file string) (string, int) {
+	var frames [][]string
+	for _, m := range traceRe.FindAllStringSubmatch(text, -1) {
+		if strings.Contains(m[1], "node_modules") || strings.Contains(m[1], "site-packages") {
+			continue
+		}
+		frames = append(frames, m)
+	}
+	if file != "" {
+		var own [][]string
+		for _, m := range frames {
+			if strings.HasSuffix(m[1], file) || strings.HasSuffix(file, m[1]) {
+				own = append(own, m)
+			}
+		}
+		if len(own) > 0 {
+			frames = own
+		}
+	}
+	if len(frames) == 0 {
+		return "", 0
+	}
+	m := frames[0]
+	if strings.HasSuffix(m[1], ".py") {
+		m = frames[len(frames)-1]
+	}
+	n, _ := strconv.Atoi(m[2])
+	return strings.TrimPrefix(m[1], "./"), n
+}
+
+// jest --json
+
+type jestReport struct {
+	TestResults []struct {
+		Name             string `json:"name"`
+		Message          string `json:"message"`
+		Status           string `json:"status"`
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Status          string   `json:"status"`
+			FailureMessages []string `json:"failureMessages"`
+			Duration        *float64 `json:"duration"`
+			Location        *struct {
+				Line int `json:"line"`
+			} `json:"location"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// ParseJestJSON parses the report jest writes with --json.
+func ParseJestJSON(b []byte) (Report, error) {
+	var rep jestReport
+	if err := json.Unmarshal(b, &rep); err != nil {
+		return Report{}, fmt.Errorf("jest: %w", err)
+	}
+	var r Report
+	for _, tr := range rep.TestResults {
+		if tr.Status == "failed" && len(tr.AssertionResults) == 0 {
+			msg := stripANSI(tr.Message)
+			c := Case{Name: "(suite)", Suite: tr.Name, Status: Fail, File: tr.Name, Message: firstLine(msg), Output: tail(msg)}
+			if f, n := traceLocation(msg, tr.Name); f != "" {
+				c.File, c.Line = f, n
+			}
+			r.Errors = append(r.Errors, c)
+			continue
+		}
+		for _, a := range tr.AssertionResults {
+			c := Case{Name: a.FullName, Suite: tr.Name, File: tr.Name}
+			if a.Duration != nil {
+				c.Duration = time.Duration(*a.Duration * float64(time.Millisecond))
+			}
+			switch a.Status {
+			case "passed":
+				c.Status = Pass
+			case "failed":
+				c.Status = Fail
+				msg := stripANSI(strings.Join(a.FailureMessages, "\n"))
+				c.Message = firstLine(msg)
+				c.Output = tail(msg)
+				if f, n := traceLocation(msg, tr.Name); f != "" {
+					c.File, c.Line = f, n
+				} else if a.Location != nil {
+					c.Line = a.Location.Line
+				}
+			default: // pending, skipped, todo, disabled
+				c.Status = Skip
+			}
+			r.Cases = append(r.Cases, c)
+		}
+	}
+	return r, nil
+}
+
+// cargo test
+
+var (
+	cargoTestRe    = regexp.MustCompile(`^test (.+) \.\.\. (ok|FAILED|ignored)`)
+	cargoRunRe     = regexp.MustCompile(`^\s*Running (?:unittests )?(\S+)`)
+	cargoDocRe     = regexp.MustCompile(`^\s*Doc-tests (\S+)`)
+	cargoSectionRe = regexp.MustCompile(`^---- (.+) stdout ----$`)
+	cargoPanicRe   = regexp.MustCompile(`panicked at (?:'(.*)', )?(\S+?):(\d+):\d+:?$`)
+	cargoErrRe     = regexp.MustCompile(`^error(?:\[\w+\])?: (.*)$`)
+	cargoLocRe     = regexp.MustCompile(`^\s*--> (\S+?):(\d+):\d+$`)
+)
+
+// ParseCargo parses the human-readable output of cargo test, which is the
+// only stable format.
+func ParseCargo(out []byte) Report {
+	var r Report
+	details := map[string]string{}
+	suite := ""
+	lines := strings.Split(stripANSI(string(out)), "\n")
+	for i := 0; i < len(lines); i++ {
+		l := strings.TrimRight(lines[i], "\r")
+		if m := cargoRunRe.FindStringSubmatch(l); m != nil {
+			suite = m[1]
+			continue
+		}
+		if m := cargoDocRe.FindStringSubmatch(l); m != nil {
+			suite = "doc-tests " + m[1]
+			continue
+		}
+		if m := cargoTestRe.FindStringSubmatch(l); m != nil {
+			st := map[string]Status{"ok": Pass, "FAILED": Fail, "ignored": Skip}[m[2]]
+			r.Cases = append(r.Cases, Case{Name: m[1], Suite: suite, Status: st})
+			continue
+		}
+		if m := cargoSectionRe.FindStringSubmatch(l); m != nil {
+			var sb strings.Builder
+			for i+1 < len(lines) && !cargoSectionRe.MatchString(lines[i+1]) && !strings.HasPrefix(lines[i+1], "failures:") {
+				i++
+				sb.WriteString(lines[i] + "\n")
+			}
+			details[m[1]] = sb.String()
+			continue
+		}
+		if m := cargoErrRe.FindStringSubmatch(l); m != nil && !strings.HasPrefix(m[1], "test failed") {
+			c := Case{Name: "(build)", Suite: suite, Status: Fail, Message: m[1]}
+			var sb strings.Builder
+			sb.WriteString(l + "\n")
+			for j := i + 1; j < len(lines) && j < i+12 && strings.TrimSpace(lines[j]) != ""; j++ {
+				sb.WriteString(lines[j] + "\n")
+				if lm := cargoLocRe.FindStringSubmatch(lines[j]); lm != nil && c.File == "" {
+					c.File = lm[1]
+					c.Line, _ = strconv.Atoi(lm[2])
+				}
+			}
+			c.Output = tail(sb.String())
+			r.Errors = append(r.Errors, c)
+		}
+	}
+	for i, c := range r.Cases {
+		text, ok := details[c.Name]
+		if c.Status != Fail || !ok {
+			continue
+		}
+		r.Cases[i].Output = tail(text)
+		tl := strings.Split(text, "\n")
+		for j, l := range tl {
+			m := cargoPanicRe.FindStringSubmatch(l)
+			if m == nil {
+				continue
+			}
+			r.Cases[i].File = m[2]
+			r.Cases[i].Line, _ = strconv.Atoi(m[3])
+			msg := m[1]
+			if msg == "" && j+1 < len(tl) {
+				// Since Rust 1.73 the message follows on its own lines.
+				var sb []string
+				for _, ml := range tl[j+1:] {
+					if strings.TrimSpace(ml) == "" || strings.HasPrefix(ml, "note:") || strings.HasPrefix(ml, "stack backtrace:") {
+						break
+					}
+					sb = append(sb, strings.TrimSpace(ml))
+				}
+				msg = strings.Join(sb, " ")
+			}
+			r.Cases[i].Message = msg
+			break
+		}
+	}
+	return r
+}
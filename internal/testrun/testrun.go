@@ -0,0 +1,281 @@
+// Package testrun detects a project's test framework, builds the command
+// that runs its tests with machine-readable output, and parses the results
+// into per-test cases with the file and line of each failure.
+package testrun
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Framework names a supported test runner.
+type Framework string
+
+const (
+	Go     Framework = "go"
+	Pytest Framework = "pytest"
+	Jest   Framework = "jest"
+	Vitest Framework = "vitest"
+	Cargo  Framework = "cargo"
+)
+
+// Frameworks lists the supported frameworks.
+func Frameworks() []Framework { return []Framework{Go, Pytest, Jest, Vitest, Cargo} }
+
+// Status is the outcome of a test case.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// Case is one test result. File and Line locate the failing assertion when
+// the runner reports it; Output is the test's own log, truncated.
+type Case struct {
+	Name     string        `json:"name"`
+	Suite    string        `json:"suite,omitempty"` // package, module or test file
+	Status   Status        `json:"status"`
+	File     string        `json:"file,omitempty"`
+	Line     int           `json:"line,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	Output   string        `json:"output,omitempty"`
+	Duration time.Duration `json:"-"`
+}
+
+// Report is the parsed result of a run.
+type Report struct {
+	Cases []Case
+	// Errors are failures outside any test, such as a package that does not
+	// compile or a test file that cannot be imported.
+	Errors []Case
+}
+
+// Counts returns the number of passed, failed and skipped cases.
+func (r Report) Counts() (pass, fail, skip int) {
+	for _, c := range r.Cases {
+		switch c.Status {
+		case Pass:
+			pass++
+		case Fail:
+			fail++
+		case Skip:
+			skip++
+		}
+	}
+	return pass, fail, skip
+}
+
+// Failures returns the failed cases followed by the errors.
+func (r Report) Failures() []Case {
+	var out []Case
+	for _, c := range r.Cases {
+		if c.Status == Fail {
+			out = append(out, c)
+		}
+	}
+	return append(out, r.Errors...)
+}
+
+// Detect returns the frameworks a project in dir uses, most specific first.
+func Detect(dir string) []Framework {
+	var out []Framework
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	if exists("go.mod") {
+		out = append(out, Go)
+	}
+	if exists("Cargo.toml") {
+		out = append(out, Cargo)
+	}
+	if js := detectJS(dir); js != "" {
+		out = append(out, js)
+	}
+	if exists("pytest.ini") || exists("conftest.py") || exists("pyproject.toml") || exists("setup.py") ||
+		exists("setup.cfg") || exists("tox.ini") || exists("requirements.txt") {
+		out = append(out, Pytest)
+	}
+	return out
+}
+
+// detectJS picks jest or vitest from the config files and package.json.
+func detectJS(dir string) Framework {
+	for _, ext := range []string{"ts", "mts", "js", "mjs", "cjs"} {
+		if _, err := os.Stat(filepath.Join(dir, "vitest.config."+ext)); err == nil {
+			return Vitest
+		}
+		if _, err := os.Stat(filepath.Join(dir, "jest.config."+ext)); err == nil {
+			return Jest
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var pkg struct {
+		Scripts         map[string]string `json:"scripts"`
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+		Jest            json.RawMessage   `json:"jest"`
+	}
+	if json.Unmarshal(b, &pkg) != nil {
+		return ""
+	}
+	has := func(name string) bool {
+		_, dev := pkg.DevDependencies[name]
+		_, dep := pkg.Dependencies[name]
+		return dev || dep || strings.Contains(pkg.Scripts["test"], name)
+	}
+	switch {
+	case has("vitest"):
+		return Vitest
+	case has("jest") || len(pkg.Jest) > 0:
+		return Jest
+	}
+	return ""
+}
+
+// Options select what to run.
+type Options struct {
+	// Paths are packages (Go), files or directories to test; empty runs the
+	// whole suite.
+	Paths []string
+	// Filter selects tests by name: go test -run, pytest -k, jest/vitest -t
+	// or the cargo test filter.
+	Filter string
+}
+
+// Plan is a command ready to run and how to read its results.
+type Plan struct {
+	Framework Framework
+	Argv      []string
+	// ResultFile is where the runner writes its report (JUnit XML or jest
+	// JSON); empty when results are read from the output.
+	ResultFile string
+}
+
+// NewPlan builds the command for fw. Report files are written to tmpDir.
+func NewPlan(fw Framework, opts Options, tmpDir string) (Plan, error) {
+	for _, p := range append([]string{opts.Filter}, opts.Paths...) {
+		if strings.HasPrefix(p, "-") {
+			return Plan{}, fmt.Errorf("invalid test selector %q", p)
+		}
+	}
+	p := Plan{Framework: fw}
+	switch fw {
+	case Go:
+		p.Argv = []string{"go", "test", "-json"}
+		if opts.Filter != "" {
+			p.Argv = append(p.Argv, "-run", opts.Filter)
+		}
+		if len(opts.Paths) == 0 {
+			p.Argv = append(p.Argv, "./...")
+		}
+		p.Argv = append(p.Argv, opts.Paths...)
+	case Pytest:
+		p.ResultFile = filepath.Join(tmpDir, "pytest-junit.xml")
+		p.Argv = []string{"python3", "-m", "pytest"}
+		if _, err := exec.LookPath("pytest"); err == nil {
+			p.Argv = []string{"pytest"}
+		}
+		p.Argv = append(p.Argv, "-q", "-rN", "--junitxml="+p.ResultFile, "-o", "junit_family=xunit1")
+		if opts.Filter != "" {
+			p.Argv = append(p.Argv, "-k", opts.Filter)
+		}
+		p.Argv = append(p.Argv, opts.Paths...)
+	case Jest:
+		p.ResultFile = filepath.Join(tmpDir, "jest.json")
+		p.Argv = []string{"npx", "--no-install", "jest", "--ci", "--json", "--testLocationInResults", "--outputFile=" + p.ResultFile}
+		if opts.Filter != "" {
+			p.Argv = append(p.Argv, "-t", opts.Filter)
+		}
+		p.Argv = append(p.Argv, opts.Paths...)
+	case Vitest:
+		p.ResultFile = filepath.Join(tmpDir, "vitest-junit.xml")
+		p.Argv = []string{"npx", "--no-install", "vitest", "run", "--reporter=default", "--reporter=junit", "--outputFile.junit=" + p.ResultFile}
+		if opts.Filter != "" {
+			p.Argv = append(p.Argv, "-t", opts.Filter)
+		}
+		p.Argv = append(p.Argv, opts.Paths...)
+	case Cargo:
+		p.Argv = []string{"cargo", "test", "--no-fail-fast"}
+		if len(opts.Paths) > 0 {
+			return Plan{}, errors.New("cargo: select tests with filter, not paths")
+		}
+		if opts.Filter != "" {
+			p.Argv = append(p.Argv, opts.Filter)
+		}
+	default:
+		return Plan{}, fmt.Errorf("unsupported test framework %q", fw)
+	}
+	return p, nil
+}
+
+// Parse reads the results of a run of p from its output and, for runners
+// that write one, the report in p.ResultFile. root is the project
+// directory, used to make file paths relative.
+func (p Plan) Parse(output []byte, root string) (Report, error) {
+	var (
+		r   Report
+		err error
+	)
+	switch p.Framework {
+	case Go:
+		r = ParseGoJSON(output, goModulePath(root))
+	case Cargo:
+		r = ParseCargo(output)
+	case Pytest, Vitest, Jest:
+		var b []byte
+		if b, err = os.ReadFile(p.ResultFile); err != nil {
+			return Report{}, fmt.Errorf("%s wrote no report: %w", p.Framework, err)
+		}
+		if p.Framework == Jest {
+			r, err = ParseJestJSON(b)
+		} else {
+			r, err = ParseJUnit(b)
+		}
+	}
+	for _, cs := range [][]Case{r.Cases, r.Errors} {
+		for i := range cs {
+			cs[i].File = relPath(root, cs[i].File)
+			cs[i].Suite = relPath(root, cs[i].Suite)
+		}
+	}
+	return r, err
+}
+
+func relPath(root, p string) string {
+	if root == "" || !filepath.IsAbs(p) {
+		return p
+	}
+	if rel, err := filepath.Rel(root, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+// goModulePath reads the module path from root/go.mod.
+func goModulePath(root string) string {
+	f, err := os.Open(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if mod, ok := strings.CutPrefix(strings.TrimSpace(sc.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(mod), `"`)
+		}
+	}
+	return ""
+}
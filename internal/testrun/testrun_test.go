@@ -0,0 +1,190 @@
+package testrun
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := Detect(dir); len(got) != 0 {
+		t.Fatalf("empty dir: %v", got)
+	}
+	write("go.mod", "module example.com/m\n")
+	write("package.json", `{"devDependencies": {"vitest": "^1.0.0"}}`)
+	write("pyproject.toml", "[tool.pytest.ini_options]\n")
+	if got := Detect(dir); !slices.Equal(got, []Framework{Go, Vitest, Pytest}) {
+		t.Fatalf("got %v", got)
+	}
+	write("package.json", `{"scripts": {"test": "jest --coverage"}}`)
+	if got := detectJS(dir); got != Jest {
+		t.Fatalf("jest script: got %q", got)
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	p, err := NewPlan(Go, Options{Paths: []string{"./internal/auth"}, Filter: "TestLogin"}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(p.Argv, " "); got != "go test -json -run TestLogin ./internal/auth" {
+		t.Fatalf("go plan: %s", got)
+	}
+	if _, err := NewPlan(Pytest, Options{Filter: "--rootdir=/"}, t.TempDir()); err == nil {
+		t.Fatal("option-like filter accepted")
+	}
+}
+
+const goJSON = `{"ImportPath":"example.com/m/br [example.com/m/br.test]","Action":"build-output","Output":"# example.com/m/br [example.com/m/br.test]\n"}
+{"ImportPath":"example.com/m/br [example.com/m/br.test]","Action":"build-output","Output":"br/b_test.go:5:28: undefined: undefinedThing\n"}
+{"ImportPath":"example.com/m/br [example.com/m/br.test]","Action":"build-fail"}
+{"Action":"fail","Package":"example.com/m/br","Elapsed":0,"FailedBuild":"example.com/m/br [example.com/m/br.test]"}
+{"Action":"pass","Package":"example.com/m/sub","Test":"TestOK","Elapsed":0}
+{"Action":"output","Package":"example.com/m/sub","Test":"TestBad","Output":"    s_test.go:6: got 1, want 2\n"}
+{"Action":"fail","Package":"example.com/m/sub","Test":"TestBad","Elapsed":0}
+{"Action":"output","Package":"example.com/m/sub","Test":"TestSub/a","Output":"    s_test.go:8: boom\n"}
+{"Action":"fail","Package":"example.com/m/sub","Test":"TestSub/a","Elapsed":0}
+{"Action":"output","Package":"example.com/m/sub","Test":"TestSub","Output":"--- FAIL: TestSub (0.00s)\n"}
+{"Action":"fail","Package":"example.com/m/sub","Test":"TestSub","Elapsed":0}
+{"Action":"skip","Package":"example.com/m/sub","Test":"TestSkip","Elapsed":0}
+{"Action":"output","Package":"example.com/m/sub","Test":"TestPanic","Output":"panic: assignment to entry in nil map\n"}
+{"Action":"output","Package":"example.com/m/sub","Test":"TestPanic","Output":"\t/usr/local/go/src/testing/testing.go:2123 +0x232\n"}
+{"Action":"output","Package":"example.com/m/sub","Test":"TestPanic","Output":"\t/work/m/sub/s_test.go:11 +0x2c\n"}
+{"Action":"fail","Package":"example.com/m/sub","Test":"TestPanic","Elapsed":0}
+{"Action":"fail","Package":"example.com/m/sub","Elapsed":0.006}
+`
+
+func TestParseGoJSON(t *testing.T) {
+	r := ParseGoJSON([]byte(goJSON), "example.com/m")
+	if pass, fail, skip := r.Counts(); pass != 1 || fail != 3 || skip != 1 {
+		t.Fatalf("counts: %d %d %d (%+v)", pass, fail, skip, r.Cases)
+	}
+	want := []struct {
+		name, file string
+		line       int
+		msg        string
+	}{
+		{"TestBad", "sub/s_test.go", 6, "got 1, want 2"},
+		{"TestSub/a", "sub/s_test.go", 8, "boom"},
+		{"TestPanic", "/work/m/sub/s_test.go", 11, "panic: assignment to entry in nil map"},
+		{"(package)", "br/b_test.go", 5, "undefined: undefinedThing"},
+	}
+	got := r.Failures()
+	if len(got) != len(want) {
+		t.Fatalf("failures: %+v", got)
+	}
+	for i, w := range want {
+		if g := got[i]; g.Name != w.name || g.File != w.file || g.Line != w.line || g.Message != w.msg {
+			t.Errorf("failure %d: got %s %s:%d %q, want %s %s:%d %q", i, g.Name, g.File, g.Line, g.Message, w.name, w.file, w.line, w.msg)
+		}
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	pytest := `<?xml version="1.0" encoding="utf-8"?><testsuites><testsuite name="pytest" tests="3">
+<testcase classname="tests.test_calc" name="test_add" file="tests/test_calc.py" line="3" time="0.001"/>
+<testcase classname="tests.test_calc" name="test_sub" file="tests/test_calc.py" line="6" time="0.002"><failure message="assert 1 == 2">def test_sub():
+&gt;       assert sub(3, 1) == 1
+E       assert 2 == 1
+
+tests/test_calc.py:8: AssertionError</failure></testcase>
+<testcase classname="tests.test_calc" name="test_mul" file="tests/test_calc.py" line="9" time="0"><skipped message="not yet"/></testcase>
+</testsuite></testsuites>`
+	r, err := ParseJUnit([]byte(pytest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pass, fail, skip := r.Counts(); pass != 1 || fail != 1 || skip != 1 {
+		t.Fatalf("counts: %d %d %d", pass, fail, skip)
+	}
+	if f := r.Failures()[0]; f.File != "tests/test_calc.py" || f.Line != 8 || f.Message != "assert 1 == 2" {
+		t.Fatalf("pytest failure: %+v", f)
+	}
+
+	vitest := `<testsuite name="src/sum.test.ts" tests="1"><testcase classname="src/sum.test.ts" name="sum &gt; adds" time="0.01"><failure message="expected 3 to be 4" type="AssertionError">AssertionError: expected 3 to be 4
+ ❯ src/sum.test.ts:5:22
+ ❯ node_modules/vitest/dist/index.js:10:3</failure></testcase></testsuite>`
+	r, err = ParseJUnit([]byte(vitest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := r.Failures()[0]; f.File != "src/sum.test.ts" || f.Line != 5 || f.Message != "expected 3 to be 4" {
+		t.Fatalf("vitest failure: %+v", f)
+	}
+}
+
+func TestParseJestJSON(t *testing.T) {
+	rep := `{"testResults":[
+{"name":"/work/app/src/a.test.js","status":"failed","message":"","assertionResults":[
+ {"fullName":"a works","status":"passed","failureMessages":[]},
+ {"fullName":"a fails","status":"failed","failureMessages":["Error: \u001b[2mexpect(\u001b[22mreceived).toBe(expected)\n\n    at Object.<anonymous> (/work/app/src/a.test.js:7:15)\n    at /work/app/node_modules/jest-circus/build/utils.js:1:1"],"location":{"line":6,"column":3}},
+ {"fullName":"a later","status":"todo","failureMessages":[]}]},
+{"name":"/work/app/src/b.test.js","status":"failed","message":"Cannot find module './b' from 'src/b.test.js'","assertionResults":[]}]}`
+	r, err := ParseJestJSON([]byte(rep))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pass, fail, skip := r.Counts(); pass != 1 || fail != 1 || skip != 1 {
+		t.Fatalf("counts: %d %d %d", pass, fail, skip)
+	}
+	f := r.Failures()
+	if len(f) != 2 || f[0].File != "/work/app/src/a.test.js" || f[0].Line != 7 || f[0].Message != "Error: expect(received).toBe(expected)" {
+		t.Fatalf("jest failures: %+v", f)
+	}
+	if f[1].Name != "(suite)" || !strings.Contains(f[1].Message, "Cannot find module") {
+		t.Fatalf("suite error: %+v", f[1])
+	}
+}
+
+func TestParseCargo(t *testing.T) {
+	out := `   Compiling calc v0.1.0 (/work/calc)
+     Running unittests src/lib.rs (target/debug/deps/calc-1234)
+
+running 3 tests
+test tests::adds ... ok
+test tests::subtracts ... FAILED
+test tests::later ... ignored
+
+failures:
+
+---- tests::subtracts stdout ----
+
+thread 'tests::subtracts' panicked at src/lib.rs:14:9:
+assertion ` + "`left == right`" + ` failed
+  left: 2
+ right: 1
+note: run with ` + "`RUST_BACKTRACE=1`" + ` environment variable to display a backtrace
+
+
+failures:
+    tests::subtracts
+
+test result: FAILED. 1 passed; 1 failed; 1 ignored; 0 measured; 0 filtered out; finished in 0.00s
+
+error: test failed, to rerun pass ` + "`--lib`" + `
+`
+	r := ParseCargo([]byte(out))
+	if pass, fail, skip := r.Counts(); pass != 1 || fail != 1 || skip != 1 {
+		t.Fatalf("counts: %d %d %d", pass, fail, skip)
+	}
+	if len(r.Errors) != 0 {
+		t.Fatalf("unexpected errors: %+v", r.Errors)
+	}
+	f := r.Failures()[0]
+	if f.Suite != "src/lib.rs" || f.File != "src/lib.rs" || f.Line != 14 || !strings.HasPrefix(f.Message, "assertion `left == right` failed") {
+		t.Fatalf("cargo failure: %+v", f)
+	}
+
+	build := ParseCargo([]byte("error[E0425]: cannot find value `x` in this scope\n --> src/lib.rs:3:5\n  |\n3 |     x\n  |     ^ not found in this scope\n"))
+	if len(build.Errors) != 1 || build.Errors[0].File != "src/lib.rs" || build.Errors[0].Line != 3 {
+		t.Fatalf("cargo build error: %+v", build.Errors)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/marcodenic/agentry/internal/httpx"
 )
 
 func init() {
@@ -75,7 +77,8 @@ func apiRequestExec(ctx context.Context, args map[string]any) (string, error) {
 		body = strings.NewReader(bodyStr)
 	}
 
-	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, method, urlStr, body)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
@@ -100,16 +103,10 @@ func apiRequestExec(ctx context.Context, args map[string]any) (string, error) {
 	}
 
 	start := time.Now()
-	resp, err := client.Do(req)
+	resp, err := httpx.Default().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
 
 	result := map[string]any{
 		"url":            urlStr,
@@ -117,15 +114,21 @@ func apiRequestExec(ctx context.Context, args map[string]any) (string, error) {
 		"status_code":    resp.StatusCode,
 		"status":         resp.Status,
 		"headers":        resp.Header,
-		"body":           string(respBody),
-		"content_type":   resp.Header.Get("Content-Type"),
-		"content_length": len(respBody),
+		"body":           string(resp.Body),
+		"content_type":   resp.ContentType,
+		"content_length": len(resp.Body),
 		"duration_ms":    time.Since(start).Milliseconds(),
 	}
+	if resp.Truncated {
+		result["truncated"] = true
+	}
+	if resp.Cached {
+		result["cached"] = true
+	}
 
-	if strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
+	if strings.Contains(resp.ContentType, "application/json") && !resp.Truncated {
 		var jsonData any
-		if err := json.Unmarshal(respBody, &jsonData); err == nil {
+		if err := json.Unmarshal(resp.Body, &jsonData); err == nil {
 			result["json"] = jsonData
 		}
 	}
@@ -0,0 +1,44 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Artifacts are files a tool keeps for later inspection, such as the full
+// log of a test run, when only a summary goes back to the model. They live
+// in AGENTRY_ARTIFACTS_DIR, or agentry/artifacts under the user cache
+// directory.
+
+var artifactNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func artifactDir() string {
+	if dir := os.Getenv("AGENTRY_ARTIFACTS_DIR"); dir != "" {
+		return dir
+	}
+	if cache, err := os.UserCacheDir(); err == nil && cache != "" {
+		return filepath.Join(cache, "agentry", "artifacts")
+	}
+	return filepath.Join(os.TempDir(), "agentry-artifacts")
+}
+
+// saveArtifact writes data to a new artifact file whose name starts with
+// name and returns its path.
+func saveArtifact(name, ext string, data []byte) (string, error) {
+	dir := artifactDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("artifact: %w", err)
+	}
+	f, err := os.CreateTemp(dir, artifactNameRe.ReplaceAllString(name, "_")+"-"+time.Now().Format("20060102-150405")+"-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("artifact: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", fmt.Errorf("artifact: %w", err)
+	}
+	return f.Name(), f.Close()
+}
@@ -9,18 +9,41 @@ import (
 
 // AuditEvent represents a tool execution event.
 type AuditEvent struct {
-	Tool      string         `json:"tool"`
-	Args      map[string]any `json:"args"`
-	Duration  int64          `json:"duration_ms"`
-	Error     string         `json:"error,omitempty"`
-	Timestamp time.Time      `json:"ts"`
+	Tool     string         `json:"tool"`
+	Args     map[string]any `json:"args"`
+	Duration int64          `json:"duration_ms"`
+	Error    string         `json:"error,omitempty"`
+	Source   string         `json:"source,omitempty"`
+	// ErrorClass classifies Error; Result holds the structured part of a
+	// result (see Result), without the text the model saw.
+	ErrorClass ErrorClass `json:"error_class,omitempty"`
+	Result     *Result    `json:"result,omitempty"`
+	Timestamp  time.Time  `json:"ts"`
+
+	// Policy decisions (see WrapWithPolicy) set these instead of Duration.
+	Decision string `json:"decision,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Approved *bool  `json:"approved,omitempty"`
+}
+
+type auditSourceKey struct{}
+
+// WithAuditSource tags tool calls made with ctx so audit events record where
+// they came from (e.g. "mcp" for calls from an MCP client).
+func WithAuditSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, auditSourceKey{}, source)
 }
 
 // WrapWithAudit wraps all tools in a registry with audit logging to w.
 func WrapWithAudit(reg Registry, w io.Writer) Registry {
 	out := Registry{}
 	for name, t := range reg {
-		out[name] = auditTool{Tool: t, w: w}
+		var wrapped Tool = auditTool{Tool: t, w: w}
+		if ta, ok := t.(TerminalAware); ok && ta.Terminal() {
+			wrapped = MarkTerminal(wrapped)
+		}
+		out[name] = wrapped
 	}
 	return out
 }
@@ -31,17 +54,33 @@ type auditTool struct {
 }
 
 func (a auditTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	r, err := a.ExecuteResult(ctx, args)
+	return r.Text, err
+}
+
+// ExecPolicy implements PolicyTool for the wrapped tool.
+func (a auditTool) ExecPolicy() ExecPolicy { return PolicyOf(a.Tool) }
+
+// ExecuteResult implements ResultTool.
+func (a auditTool) ExecuteResult(ctx context.Context, args map[string]any) (Result, error) {
 	start := time.Now()
-	res, err := a.Tool.Execute(ctx, args)
+	res, err := Run(ctx, a.Tool, args)
 	evt := AuditEvent{
 		Tool:      a.Name(),
 		Args:      args,
 		Duration:  time.Since(start).Milliseconds(),
 		Timestamp: time.Now().UTC(),
 	}
+	evt.Source, _ = ctx.Value(auditSourceKey{}).(string)
 	if err != nil {
 		evt.Error = err.Error()
 	}
+	evt.ErrorClass = res.ErrorClass
+	if res.Data != nil || len(res.Attachments) > 0 {
+		structured := res
+		structured.Text = ""
+		evt.Result = &structured
+	}
 	b, _ := json.Marshal(evt)
 	_, _ = wWrite(a.w, b)
 	return res, err
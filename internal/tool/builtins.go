@@ -12,11 +12,27 @@
 
 package tool
 
+import "context"
+
 // builtinSpec defines builtin schema and execution.
 type builtinSpec struct {
 	Desc   string
 	Schema map[string]any
 	Exec   ExecFn
+	// Result, when set, returns the structured result of a call; Exec then
+	// returns its text (see textOf).
+	Result func(context.Context, map[string]any) (Result, error)
+	// Policy is the execution policy the agent loop applies; manifests
+	// may override it.
+	Policy ExecPolicy
+	// Commands, when set, lists the command lines a call will run so the
+	// policy can check them (see CommandLister).
+	Commands func(args map[string]any) []string
+}
+
+// newBuiltin returns the tool for spec under name.
+func newBuiltin(name, desc string, spec builtinSpec) Tool {
+	return &simpleTool{name: name, desc: desc, fn: spec.Exec, result: spec.Result, schema: spec.Schema, allowed: true, policy: spec.Policy, commands: spec.Commands}
 }
 
 // builtinMap holds safe builtin tools keyed by name.
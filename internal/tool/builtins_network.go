@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
-	"runtime"
 	"strings"
 	"time"
+
+	"github.com/marcodenic/agentry/internal/httpx"
 )
 
+// webPolicy bounds the read-only network tools, which are safe to retry.
+var webPolicy = ExecPolicy{Timeout: 2 * time.Minute, Retries: 2, Backoff: time.Second}
+
 // getNetworkBuiltins returns network-related builtin tools
 func getNetworkBuiltins() map[string]builtinSpec {
 	return map[string]builtinSpec{
@@ -21,60 +24,68 @@ func getNetworkBuiltins() map[string]builtinSpec {
 				"required":   []string{"url"},
 				"example":    map[string]any{"url": "https://api.github.com/repos/owner/repo"},
 			},
-			Exec: func(ctx context.Context, args map[string]any) (string, error) {
-				url, _ := args["url"].(string)
-				if url == "" {
-					return "", errors.New("missing url")
-				}
-
-				// Validate that this is actually a URL and not a file path
-				if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-					return "", fmt.Errorf("fetch tool requires HTTP/HTTPS URLs, got '%s'. Use 'view' tool for local files", url)
-				}
-
-				// Cross-platform URL fetching
-				if runtime.GOOS == "windows" {
-					// Use PowerShell Invoke-WebRequest
-					cmd := fmt.Sprintf("(Invoke-WebRequest -Uri '%s').Content", url)
-					return ExecDirect(ctx, cmd)
-				} else {
-					// Try curl first, fallback to wget if available
-					result, err := ExecDirect(ctx, "curl -s "+url)
-					if err != nil {
-						// Fallback to wget if curl is not available
-						result, err = ExecDirect(ctx, "wget -qO- "+url)
-					}
-					return result, err
-				}
-			},
+			Policy: webPolicy,
+			Exec:   fetchExec,
 		},
 		"mcp": {
-			Desc: "Execute an MCP command",
+			Desc: "Use a connected MCP server: list or call its tools, read its resources, or render its prompts. Server tools are also registered directly as <server>__<tool>.",
 			Schema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"host":    map[string]any{"type": "string"},
-					"port":    map[string]any{"type": "integer"},
-					"command": map[string]any{"type": "string"},
+					"server": map[string]any{"type": "string", "description": "Name of the server from mcp_servers"},
+					"action": map[string]any{
+						"type": "string",
+						"enum": []string{"list_tools", "call_tool", "list_resources", "read_resource", "list_prompts", "get_prompt"},
+					},
+					"name":      map[string]any{"type": "string", "description": "Tool or prompt name (call_tool, get_prompt)"},
+					"arguments": map[string]any{"type": "object", "description": "Tool or prompt arguments"},
+					"uri":       map[string]any{"type": "string", "description": "Resource URI (read_resource)"},
 				},
-				"required": []string{"host", "port", "command"},
-				"example":  map[string]any{"host": "localhost", "port": 1234, "command": "hello"},
-			},
-			Exec: func(ctx context.Context, args map[string]any) (string, error) {
-				host, _ := args["host"].(string)
-				p, _ := getIntArg(args, "port", 0)
-				cmd, _ := args["command"].(string)
-				addr := net.JoinHostPort(host, fmt.Sprintf("%d", p))
-				conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
-				if err != nil {
-					return "", err
-				}
-				defer conn.Close()
-				_, _ = conn.Write([]byte(cmd + "\n"))
-				buf := make([]byte, 1024)
-				n, _ := conn.Read(buf)
-				return strings.TrimRight(string(buf[:n]), "\r\n"), nil
+				"required": []string{"server", "action"},
+				"example":  map[string]any{"server": "github", "action": "read_resource", "uri": "repo://README.md"},
 			},
+			Exec: mcpExec,
 		},
 	}
 }
+
+// fetchExec returns the body of an HTTP(S) URL as text. Binary content is
+// described rather than returned; download saves it.
+func fetchExec(ctx context.Context, args map[string]any) (string, error) {
+	url := strArg(args, "url")
+	if url == "" {
+		return "", errors.New("missing url")
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "", fmt.Errorf("fetch tool requires HTTP/HTTPS URLs, got '%s'. Use 'view' tool for local files", url)
+	}
+	resp, err := httpx.Default().Get(ctx, url, map[string]string{"User-Agent": "Agentry/1.0"})
+	if err != nil {
+		return "", fmt.Errorf("fetch failed: %w", err)
+	}
+	if !textual(resp.ContentType) {
+		return fmt.Sprintf("%s: %s, %d bytes of binary content; use download to save it", resp.Status, resp.ContentType, len(resp.Body)), nil
+	}
+	body := string(resp.Body)
+	if resp.Truncated {
+		body += fmt.Sprintf("\n... [response truncated at %d bytes]", len(resp.Body))
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP %s: %s", resp.Status, truncateOutput(body, 2000))
+	}
+	return body, nil
+}
+
+// textual reports whether a content type can be shown to the model as text.
+func textual(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	if ct == "" || strings.HasPrefix(ct, "text/") {
+		return true
+	}
+	for _, s := range []string{"json", "xml", "javascript", "yaml", "toml", "x-www-form-urlencoded", "graphql", "csv"} {
+		if strings.Contains(ct, s) {
+			return true
+		}
+	}
+	return false
+}
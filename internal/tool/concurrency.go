@@ -0,0 +1,302 @@
+package tool
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Optimistic concurrency for the file tools. Every agent's last read of a
+// file is remembered by content hash. A write from an agent whose read is
+// stale, because another agent or a person changed the file since, is
+// refused with a diff of what changed and who changed it; the agent
+// re-reads and redoes the edit on the current content. An edit may also
+// name the hash it was based on with expected_hash.
+
+// maxReadCopy bounds the content kept per read for stale-edit diffs, and
+// maxReadBytes the content kept for all reads: past it the oldest reads
+// lose their copy (their hash still guards writes). Past maxReads the
+// oldest reads are forgotten altogether.
+const (
+	maxReadCopy  = 1 << 20
+	maxReadBytes = 64 << 20
+	maxReads     = 10000
+)
+
+// minHashPrefix is the shortest expected_hash accepted.
+const minHashPrefix = 12
+
+// expectedHashSchema describes the expected_hash argument of the writing
+// tools.
+var expectedHashSchema = map[string]any{"type": "string", "description": "Hash of the content this edit is based on, as returned by read_lines or a previous write; defaults to your last read"}
+
+type readKey struct{ agent, path string }
+
+type readState struct {
+	hash    string
+	content []byte // nil when the file was larger than maxReadCopy or evicted
+}
+
+// readLog holds the reads, oldest first, with the total content kept.
+type readLog struct {
+	sync.Mutex
+	m     map[readKey]*list.Element // of readEntry
+	order list.List
+	bytes int
+}
+
+type readEntry struct {
+	key readKey
+	readState
+}
+
+func (l *readLog) store(k readKey, st readState) {
+	l.Lock()
+	defer l.Unlock()
+	if l.m == nil {
+		l.m = map[readKey]*list.Element{}
+	}
+	if el, ok := l.m[k]; ok {
+		l.bytes -= len(el.Value.(*readEntry).content)
+		l.order.Remove(el)
+	}
+	l.m[k] = l.order.PushBack(&readEntry{k, st})
+	l.bytes += len(st.content)
+	for el := l.order.Front(); el != nil && l.bytes > maxReadBytes; el = el.Next() {
+		e := el.Value.(*readEntry)
+		l.bytes -= len(e.content)
+		e.content = nil
+	}
+	for l.order.Len() > maxReads {
+		e := l.order.Remove(l.order.Front()).(*readEntry)
+		l.bytes -= len(e.content)
+		delete(l.m, e.key)
+	}
+}
+
+func (l *readLog) load(k readKey) (readState, bool) {
+	l.Lock()
+	defer l.Unlock()
+	el, ok := l.m[k]
+	if !ok {
+		return readState{}, false
+	}
+	return el.Value.(*readEntry).readState, true
+}
+
+type writeInfo struct {
+	agent string
+	tool  string
+	hash  string
+	at    time.Time
+}
+
+var (
+	reads   readLog
+	writers sync.Map // path → writeInfo, the last file-tool write
+)
+
+// fileLocks serialises writes to each path, so the stale check, the write
+// and recording the new content happen as one step: of two agents writing
+// from the same read, the second is refused instead of overwriting the
+// first.
+var fileLocks = struct {
+	sync.Mutex
+	m map[string]*fileLock
+}{m: map[string]*fileLock{}}
+
+type fileLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockFiles locks paths, in a fixed order so tools writing several files
+// cannot deadlock, and returns the function that unlocks them.
+func lockFiles(paths ...string) (unlock func()) {
+	paths = append([]string(nil), paths...)
+	sort.Strings(paths)
+	paths = slices.Compact(paths)
+	held := make([]*fileLock, 0, len(paths))
+	for _, p := range paths {
+		fileLocks.Lock()
+		l := fileLocks.m[p]
+		if l == nil {
+			l = &fileLock{}
+			fileLocks.m[p] = l
+		}
+		l.refs++
+		fileLocks.Unlock()
+		l.Lock()
+		held = append(held, l)
+	}
+	return func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].Unlock()
+			fileLocks.Lock()
+			if held[i].refs--; held[i].refs == 0 {
+				delete(fileLocks.m, paths[i])
+			}
+			fileLocks.Unlock()
+		}
+	}
+}
+
+func contentHash(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// shortHash is how hashes are shown to the model.
+func shortHash(h string) string {
+	if len(h) > 16 {
+		return h[:16]
+	}
+	return h
+}
+
+// agentName is the calling agent's team name. Agent 0 has none and goes by
+// "agent_0", as do tools called outside an agent.
+func agentName(ctx context.Context) string {
+	if name, ok := ctx.Value(contracts.AgentNameContextKey).(string); ok && name != "" {
+		return name
+	}
+	return "agent_0"
+}
+
+// recordView remembers path's current content as read by the calling
+// agent. File tools call it after reads and after their own writes.
+func recordView(ctx context.Context, path string) error {
+	b, err := readFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	st := readState{hash: contentHash(b)}
+	if len(b) <= maxReadCopy {
+		st.content = b
+	}
+	reads.store(readKey{sessionKey(ctx), path}, st)
+	return nil
+}
+
+// lastRead returns the calling agent's last read of path.
+func lastRead(ctx context.Context, path string) (readState, bool) {
+	return reads.load(readKey{sessionKey(ctx), path})
+}
+
+// noteWrite records who last wrote path, for stale-edit reports.
+func noteWrite(ctx context.Context, tool, path string) {
+	w := writeInfo{agent: agentName(ctx), tool: tool, at: time.Now()}
+	if call, ok := ctx.Value(contracts.ToolCallContextKey).(contracts.ToolCall); ok && call.Name != "" {
+		w.tool = call.Name
+	}
+	if b, err := readFile(ctx, path); err == nil {
+		w.hash = contentHash(b)
+	}
+	writers.Store(path, w)
+}
+
+// checkForOverwrite refuses a write to path when another agent holds a
+// lease on it, or when the file has changed since the hash the edit is
+// based on: args["expected_hash"] if given, else the calling agent's last
+// read. A file the agent never read may be written freely. Callers hold
+// lockFiles(path) from the check until after recordView.
+func checkForOverwrite(ctx context.Context, path string, args map[string]any) error {
+	if err := checkLease(ctx, path); err != nil {
+		return err
+	}
+	st, read := lastRead(ctx, path)
+	expected := strArg(args, "expected_hash")
+	switch {
+	case expected != "" && len(expected) < minHashPrefix:
+		return fmt.Errorf("expected_hash must have at least %d hex digits", minHashPrefix)
+	case expected == "" && !read:
+		return nil
+	case expected == "":
+		expected = st.hash
+	}
+
+	cur, err := readFile(ctx, path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	e := &StaleError{Path: path, Expected: strings.ToLower(expected)}
+	if err == nil {
+		e.Current = contentHash(cur)
+		if strings.HasPrefix(e.Current, e.Expected) {
+			return nil
+		}
+	}
+	if v, ok := writers.Load(path); ok {
+		w := v.(writeInfo)
+		e.ChangedBy = fmt.Sprintf("%s's %s at %s", w.agent, w.tool, w.at.Format("15:04:05"))
+		if w.hash != e.Current {
+			e.ChangedBy = "something outside the file tools, after " + e.ChangedBy
+		}
+	}
+	if read && strings.HasPrefix(st.hash, e.Expected) && st.content != nil {
+		e.Diff = staleDiff(path, st.content, cur)
+	}
+	return e
+}
+
+// StaleError reports an edit based on an outdated version of a file.
+type StaleError struct {
+	Path      string
+	Expected  string // the hash the edit was based on
+	Current   string // the file's hash now; empty if it was removed
+	ChangedBy string // the last write, if a file tool made it
+	Diff      string // from the content the edit was based on to the current one
+}
+
+func (e *StaleError) Error() string {
+	var b strings.Builder
+	what := "changed"
+	if e.Current == "" {
+		what = "been removed"
+	}
+	fmt.Fprintf(&b, "stale edit refused: %s has %s since you last read it (read %s", e.Path, what, shortHash(e.Expected))
+	if e.Current != "" {
+		fmt.Fprintf(&b, ", now %s", shortHash(e.Current))
+	}
+	b.WriteString("); changed by ")
+	if e.ChangedBy != "" {
+		b.WriteString(e.ChangedBy)
+	} else {
+		b.WriteString("something outside the file tools")
+	}
+	b.WriteString(". Re-read the file and redo the edit on its current content.")
+	if e.Diff != "" {
+		b.WriteString("\nChanges since your read:\n")
+		b.WriteString(e.Diff)
+	}
+	return b.String()
+}
+
+// staleDiff is a unified diff from what an agent read to what is there now,
+// cut short for the model.
+func staleDiff(path string, read, cur []byte) string {
+	d, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A: difflib.SplitLines(string(read)), B: difflib.SplitLines(string(cur)),
+		FromFile: path + " (your read)", ToFile: path + " (current)", Context: 2,
+	})
+	if err != nil {
+		return ""
+	}
+	const maxLines = 80
+	if lines := strings.SplitAfter(d, "\n"); len(lines) > maxLines {
+		d = strings.Join(lines[:maxLines], "") + fmt.Sprintf("... (%d more diff lines)\n", len(lines)-maxLines)
+	}
+	return d
+}
@@ -0,0 +1,161 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+)
+
+func agentCtx(name string) context.Context {
+	ctx := context.WithValue(context.Background(), contracts.AgentIDContextKey, name+"-id")
+	if name != "agent_0" {
+		ctx = context.WithValue(ctx, contracts.AgentNameContextKey, name)
+	}
+	return ctx
+}
+
+func TestStaleEditRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.go")
+	if err := os.WriteFile(path, []byte("a\nb\nc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	alice, bob := agentCtx("alice"), agentCtx("bob")
+	run := func(ctx context.Context, name string, args map[string]any) (string, error) {
+		return builtinMap[name].Exec(ctx, args)
+	}
+
+	if _, err := run(alice, "view", map[string]any{"path": path}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(bob, "search_replace", map[string]any{"path": path, "search": "b", "replace": "B"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice's line numbers are from before Bob's change.
+	_, err := run(alice, "edit_range", map[string]any{"path": path, "start_line": 2, "end_line": 2, "content": "x"})
+	var se *StaleError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a stale edit error, got %v", err)
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "bob's search_replace") || !strings.Contains(msg, "-b\n+B\n") {
+		t.Fatalf("message lacks who or what changed:\n%s", msg)
+	}
+	if b, _ := os.ReadFile(path); string(b) != "a\nB\nc\n" {
+		t.Fatalf("stale edit written: %q", b)
+	}
+
+	// After re-reading, the edit goes through, and its result carries the
+	// hash a later edit can name.
+	out, err := run(alice, "read_lines", map[string]any{"path": path, "start_line": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rl struct{ Hash string }
+	_ = json.Unmarshal([]byte(out), &rl)
+	if _, err := run(alice, "edit_range", map[string]any{"path": path, "start_line": 2, "end_line": 2, "content": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(bob, "write", map[string]any{"path": path, "content": "z", "expected_hash": rl.Hash}); !errors.As(err, &se) {
+		t.Fatalf("write with an outdated expected_hash: %v", err)
+	}
+	// A file never read may be written.
+	if _, err := run(bob, "write", map[string]any{"path": filepath.Join(filepath.Dir(path), "new.go"), "content": "z"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParallelWritesFromOneRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "race.txt")
+	if err := os.WriteFile(path, []byte("base\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const n = 8
+	ctxs := make([]context.Context, n)
+	for i := range ctxs {
+		ctxs[i] = agentCtx(fmt.Sprintf("agent%d", i))
+		if _, err := builtinMap["view"].Exec(ctxs[i], map[string]any{"path": path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	var written atomic.Int32
+	for i := range ctxs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := builtinMap["write"].Exec(ctxs[i], map[string]any{"path": path, "content": fmt.Sprintf("agent%d\n", i)})
+			var se *StaleError
+			switch {
+			case err == nil:
+				written.Add(1)
+			case !errors.As(err, &se):
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if written.Load() != 1 {
+		t.Fatalf("%d writes based on the same read went through, want 1", written.Load())
+	}
+}
+
+func TestReadLogEviction(t *testing.T) {
+	var l readLog
+	big := make([]byte, maxReadCopy)
+	for i := range maxReadBytes/maxReadCopy + 2 {
+		l.store(readKey{"a", fmt.Sprint(i)}, readState{hash: fmt.Sprint(i), content: big})
+	}
+	if l.bytes > maxReadBytes {
+		t.Fatalf("kept %d bytes of content", l.bytes)
+	}
+	if st, ok := l.load(readKey{"a", "0"}); !ok || st.content != nil || st.hash != "0" {
+		t.Fatalf("oldest read: %v %+v", ok, st.hash)
+	}
+	for i := range maxReads + 1 {
+		l.store(readKey{"b", fmt.Sprint(i)}, readState{hash: "h"})
+	}
+	if _, ok := l.load(readKey{"a", "0"}); ok || l.order.Len() != maxReads {
+		t.Fatalf("%d reads kept", l.order.Len())
+	}
+}
+
+func TestLeases(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pkg", "auth.go")
+	run := func(ctx context.Context, name string, args map[string]any) (string, error) {
+		return builtinMap[name].Exec(ctx, args)
+	}
+	coordinator, coder, tester := agentCtx("agent_0"), agentCtx("coder"), agentCtx("tester")
+
+	if _, err := run(coordinator, "lease", map[string]any{"paths": []any{filepath.Join(dir, "pkg")}, "owner": "coder"}); err != nil {
+		t.Fatal(err)
+	}
+	defer run(coordinator, "lease", map[string]any{"action": "release", "paths": []any{filepath.Join(dir, "pkg")}})
+
+	if _, err := run(tester, "write", map[string]any{"path": path, "content": "x"}); err == nil || !strings.Contains(err.Error(), "leased to coder") {
+		t.Fatalf("write under another agent's lease: %v", err)
+	}
+	if _, err := run(coder, "write", map[string]any{"path": path, "content": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(tester, "lease", map[string]any{"paths": []any{path}}); err == nil {
+		t.Fatal("overlapping lease granted")
+	}
+	if _, err := run(tester, "lease", map[string]any{"action": "release", "paths": []any{filepath.Join(dir, "pkg")}}); err == nil {
+		t.Fatal("lease released by an agent that neither holds nor assigned it")
+	}
+	out, err := run(tester, "lease", map[string]any{"action": "list"})
+	if err != nil || !strings.Contains(out, `"owner":"coder"`) || !strings.Contains(out, `"assigned_by":"agent_0"`) {
+		t.Fatalf("list: %v %s", err, out)
+	}
+}
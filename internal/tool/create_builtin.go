@@ -5,9 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
+
+	"github.com/marcodenic/agentry/internal/journal"
 )
 
 func init() {
@@ -50,24 +50,22 @@ func createFileExec(ctx context.Context, args map[string]any) (string, error) {
 	overwrite, _ := args["overwrite"].(bool)
 
 	path = absPath(path)
-	if _, err := os.Stat(path); err == nil && !overwrite {
-		return "", fmt.Errorf("file %s already exists (use overwrite=true to replace)", path)
-	}
-
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
-	}
-
-	tempPath := path + ".tmp"
-	if err := os.WriteFile(tempPath, []byte(content), 0644); err != nil {
-		return "", fmt.Errorf("failed to write temp file: %w", err)
+	defer lockFiles(path)()
+	if _, err := statFile(ctx, path); err == nil {
+		if !overwrite {
+			return "", fmt.Errorf("file %s already exists (use overwrite=true to replace)", path)
+		}
+		if err := checkForOverwrite(ctx, path, args); err != nil {
+			return "", err
+		}
 	}
 
-	if err := os.Rename(tempPath, path); err != nil {
-		os.Remove(tempPath)
-		return "", fmt.Errorf("failed to move temp file: %w", err)
+	before := journal.Snapshot(path)
+	if err := writeFile(ctx, path, []byte(content), 0644); err != nil {
+		return "", err
 	}
+	recordWrite(ctx, "create", before)
+	_ = recordView(ctx, path)
 
 	resultInfo := map[string]any{
 		"path":       path,
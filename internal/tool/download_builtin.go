@@ -11,6 +11,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"time"
+
+	"github.com/marcodenic/agentry/internal/httpx"
 )
 
 func init() {
@@ -53,6 +55,9 @@ func init() {
 }
 
 func downloadFileExec(ctx context.Context, args map[string]any) (string, error) {
+	if err := refuseInDryRun(ctx, "download"); err != nil {
+		return "", err
+	}
 	urlStr, _ := args["url"].(string)
 	if urlStr == "" {
 		return "", errors.New("missing url")
@@ -84,7 +89,8 @@ func downloadFileExec(ctx context.Context, args map[string]any) (string, error)
 		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
-	client := &http.Client{Timeout: 5 * time.Minute}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
@@ -93,7 +99,7 @@ func downloadFileExec(ctx context.Context, args map[string]any) (string, error)
 	req.Header.Set("User-Agent", "Agentry/1.0 (File Downloader)")
 
 	start := time.Now()
-	resp, err := client.Do(req)
+	resp, err := httpx.Default().Stream(req)
 	if err != nil {
 		return "", fmt.Errorf("download failed: %w", err)
 	}
@@ -2,12 +2,14 @@ package tool
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
+
+	"github.com/marcodenic/agentry/internal/journal"
 )
 
 func init() {
@@ -34,6 +36,7 @@ func init() {
 					"type":        "string",
 					"description": "New content to replace the range (without trailing newline)",
 				},
+				"expected_hash": expectedHashSchema,
 			},
 			"required": []string{"path", "start_line", "end_line", "content"},
 			"example": map[string]any{
@@ -65,21 +68,22 @@ func editRangeExec(ctx context.Context, args map[string]any) (string, error) {
 	}
 
 	path = absPath(path)
-	if err := checkForOverwrite(path); err != nil {
+	defer lockFiles(path)()
+	if err := checkForOverwrite(ctx, path, args); err != nil {
 		return "", err
 	}
+	before := journal.Snapshot(path)
 
-	file, err := os.Open(path)
+	data, err := readFile(ctx, path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
 	}
 
 	var allLines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		allLines = append(allLines, scanner.Text())
 	}
-	file.Close()
 	if err := scanner.Err(); err != nil {
 		return "", fmt.Errorf("error reading file: %w", err)
 	}
@@ -97,39 +101,13 @@ func editRangeExec(ctx context.Context, args map[string]any) (string, error) {
 	result = append(result, newLines...)
 	result = append(result, allLines[int(endLine):]...)
 
-	tempPath := path + ".tmp"
-	tempFile, err := os.Create(tempPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
-	}
-
-	for i, line := range result {
-		if i > 0 {
-			if _, err := tempFile.WriteString("\n"); err != nil {
-				tempFile.Close()
-				os.Remove(tempPath)
-				return "", fmt.Errorf("failed to write to temp file: %w", err)
-			}
-		}
-		if _, err := tempFile.WriteString(line); err != nil {
-			tempFile.Close()
-			os.Remove(tempPath)
-			return "", fmt.Errorf("failed to write to temp file: %w", err)
-		}
-	}
-
-	if err := tempFile.Close(); err != nil {
-		os.Remove(tempPath)
-		return "", fmt.Errorf("failed to close temp file: %w", err)
-	}
-
-	if err := os.Rename(tempPath, path); err != nil {
-		os.Remove(tempPath)
-		return "", fmt.Errorf("failed to move temp file: %w", err)
+	if err := writeFile(ctx, path, []byte(strings.Join(result, "\n")), 0o644); err != nil {
+		return "", err
 	}
+	recordWrite(ctx, "edit_range", before)
 
 	// Update view record to the new modtime to avoid false "changed since viewed" on follow-ups
-	_ = recordView(path)
+	_ = recordView(ctx, path)
 
 	resultInfo := map[string]any{
 		"path":           path,
@@ -2,18 +2,42 @@ package tool
 
 import (
 	"context"
-	"os/exec"
 	"runtime"
+
+	"github.com/marcodenic/agentry/internal/sandbox"
 )
 
-// ExecDirect runs a shell command directly
-func ExecDirect(ctx context.Context, cmdStr string) (string, error) {
-	var cmd *exec.Cmd
+type sandboxKey struct{}
+
+// WithSandbox returns ctx carrying p, the policy ExecDirect, ExecArgv and
+// command tools called with it run under. Permissions attach their own
+// policy to every call they let through.
+func WithSandbox(ctx context.Context, p sandbox.Policy) context.Context {
+	return context.WithValue(ctx, sandboxKey{}, p)
+}
+
+// sandboxFrom returns the policy attached to ctx. The zero policy, used when
+// none is attached, runs commands unconfined.
+func sandboxFrom(ctx context.Context) sandbox.Policy {
+	p, _ := ctx.Value(sandboxKey{}).(sandbox.Policy)
+	return p
+}
+
+// shellArgv wraps cmdStr for the platform shell.
+func shellArgv(cmdStr string) []string {
 	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "powershell", "-Command", cmdStr)
-	} else {
-		cmd = exec.CommandContext(ctx, "sh", "-c", cmdStr)
+		return []string{"powershell", "-Command", cmdStr}
 	}
-	out, err := cmd.CombinedOutput()
-	return string(out), err
+	return []string{"sh", "-c", cmdStr}
+}
+
+// ExecDirect runs a shell command under the sandbox policy
+func ExecDirect(ctx context.Context, cmdStr string) (string, error) {
+	return sandboxFrom(ctx).Run(ctx, "", shellArgv(cmdStr))
+}
+
+// ExecArgv runs argv[0] with the remaining elements as its arguments,
+// without a shell, under the sandbox policy and returns the combined output.
+func ExecArgv(ctx context.Context, argv []string) (string, error) {
+	return sandboxFrom(ctx).Run(ctx, "", argv)
 }
@@ -0,0 +1,283 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/marcodenic/agentry/internal/config"
+)
+
+// ExecPolicy limits how a tool runs. Builtin specs and tool manifests
+// declare it; the agent loop enforces it with RunWithPolicy.
+type ExecPolicy struct {
+	// Timeout bounds one attempt. A tool that ignores its context is
+	// abandoned when it expires.
+	Timeout time.Duration
+	// Retries is how many more attempts a call that failed with a timeout
+	// or a network error gets. Only idempotent tools should set it.
+	Retries int
+	// Backoff is the delay before the first retry, doubled for each one
+	// after (default 1s, at most 30s).
+	Backoff time.Duration
+	// MaxOutput caps the text returned to the model, in bytes; Truncate
+	// says which part is kept: head (default), tail or middle.
+	MaxOutput int
+	Truncate  string
+	// Concurrency limits the calls of this tool running at once across
+	// all agents.
+	Concurrency int
+}
+
+// IsZero reports a policy that imposes nothing.
+func (p ExecPolicy) IsZero() bool { return p == ExecPolicy{} }
+
+// Summary describes p for trace events.
+func (p ExecPolicy) Summary() map[string]any {
+	s := map[string]any{}
+	if p.Timeout > 0 {
+		s["timeout"] = p.Timeout.String()
+	}
+	if p.Retries > 0 {
+		s["retries"] = p.Retries
+		s["backoff"] = p.backoff(1).String()
+	}
+	if p.MaxOutput > 0 {
+		s["max_output"] = p.MaxOutput
+		s["truncate"] = p.truncate()
+	}
+	if p.Concurrency > 0 {
+		s["concurrency"] = p.Concurrency
+	}
+	return s
+}
+
+func (p ExecPolicy) truncate() string {
+	if p.Truncate == "" {
+		return "head"
+	}
+	return p.Truncate
+}
+
+// backoff is the delay before retry n (from 1).
+func (p ExecPolicy) backoff(n int) time.Duration {
+	d := p.Backoff
+	if d <= 0 {
+		d = time.Second
+	}
+	for i := 1; i < n && d < 30*time.Second; i++ {
+		d *= 2
+	}
+	return min(d, 30*time.Second)
+}
+
+// override returns p with the fields set in o replacing its own.
+func (p ExecPolicy) override(o ExecPolicy) ExecPolicy {
+	if o.Timeout > 0 {
+		p.Timeout = o.Timeout
+	}
+	if o.Retries > 0 {
+		p.Retries = o.Retries
+	}
+	if o.Backoff > 0 {
+		p.Backoff = o.Backoff
+	}
+	if o.MaxOutput > 0 {
+		p.MaxOutput = o.MaxOutput
+	}
+	if o.Truncate != "" {
+		p.Truncate = o.Truncate
+	}
+	if o.Concurrency > 0 {
+		p.Concurrency = o.Concurrency
+	}
+	return p
+}
+
+// PolicyTool is implemented by tools that declare an ExecPolicy.
+type PolicyTool interface {
+	Tool
+	ExecPolicy() ExecPolicy
+}
+
+// PolicyOf returns t's execution policy; tools without one get the zero
+// policy.
+func PolicyOf(t Tool) ExecPolicy {
+	if pt, ok := t.(PolicyTool); ok {
+		return pt.ExecPolicy()
+	}
+	return ExecPolicy{}
+}
+
+// execPolicyFromManifest reads the execution policy fields of m.
+func execPolicyFromManifest(m config.ToolManifest) (ExecPolicy, error) {
+	p := ExecPolicy{Retries: m.Retries, MaxOutput: m.MaxOutput, Truncate: m.Truncate, Concurrency: m.Concurrency}
+	var err error
+	if m.Timeout != "" {
+		if p.Timeout, err = time.ParseDuration(m.Timeout); err != nil || p.Timeout <= 0 {
+			return p, fmt.Errorf("tool %s: invalid timeout %q", m.Name, m.Timeout)
+		}
+	}
+	if m.Backoff != "" {
+		if p.Backoff, err = time.ParseDuration(m.Backoff); err != nil || p.Backoff <= 0 {
+			return p, fmt.Errorf("tool %s: invalid backoff %q", m.Name, m.Backoff)
+		}
+	}
+	switch p.Truncate {
+	case "", "head", "tail", "middle":
+	default:
+		return p, fmt.Errorf("tool %s: truncate: want head, tail or middle, got %q", m.Name, p.Truncate)
+	}
+	if p.Retries < 0 || p.Concurrency < 0 || p.MaxOutput < 0 {
+		return p, fmt.Errorf("tool %s: retries, concurrency and max_output cannot be negative", m.Name)
+	}
+	return p, nil
+}
+
+// Execution reports how RunWithPolicy applied a policy to one call.
+type Execution struct {
+	Attempts  int
+	Queued    time.Duration // waiting for a concurrency slot
+	TimedOut  bool          // the last attempt hit the policy timeout
+	Truncated int           // bytes cut from the text
+}
+
+// Retry describes a failed attempt about to be retried.
+type Retry struct {
+	Attempt int // the attempt that failed, from 1
+	Err     error
+	Class   ErrorClass
+	Delay   time.Duration
+}
+
+var slots sync.Map // "name/limit" → chan struct{}
+
+func slot(name string, limit int) chan struct{} {
+	ch, _ := slots.LoadOrStore(fmt.Sprintf("%s/%d", name, limit), make(chan struct{}, limit))
+	return ch.(chan struct{})
+}
+
+// RunWithPolicy runs t like Run under its ExecPolicy: it waits for a
+// concurrency slot, bounds each attempt by the timeout, retries timeouts
+// and network errors with backoff, and truncates the text. onRetry, if
+// set, is called before each retry.
+func RunWithPolicy(ctx context.Context, t Tool, args map[string]any, onRetry func(Retry)) (Result, Execution, error) {
+	p := PolicyOf(t)
+	var ex Execution
+	var res Result
+	var err error
+	for {
+		ex.Attempts++
+		res, err = attempt(ctx, t, args, p, &ex)
+		if err == nil || ex.Attempts > p.Retries || ctx.Err() != nil {
+			break
+		}
+		if c := res.ErrorClass; c != ErrClassTimeout && c != ErrClassNetwork {
+			break
+		}
+		r := Retry{Attempt: ex.Attempts, Err: err, Class: res.ErrorClass, Delay: p.backoff(ex.Attempts)}
+		if onRetry != nil {
+			onRetry(r)
+		}
+		select {
+		case <-time.After(r.Delay):
+		case <-ctx.Done():
+			return res, ex, err
+		}
+	}
+	if err == nil && p.MaxOutput > 0 {
+		n := len(res.Text)
+		res.Text = truncateText(res.Text, p.MaxOutput, p.truncate())
+		if len(res.Text) < n {
+			ex.Truncated = n - p.MaxOutput
+		}
+	}
+	return res, ex, err
+}
+
+// attempt runs t once in a concurrency slot, abandoning it when the
+// timeout expires even if it does not return. An abandoned run keeps its
+// slot until it does return, so the limit counts every run still going.
+func attempt(ctx context.Context, t Tool, args map[string]any, p ExecPolicy, ex *Execution) (Result, error) {
+	ex.TimedOut = false
+	release := func() {}
+	if p.Concurrency > 0 {
+		ch := slot(t.Name(), p.Concurrency)
+		start := time.Now()
+		select {
+		case ch <- struct{}{}:
+			release = func() { <-ch }
+		case <-ctx.Done():
+			return Result{ErrorClass: Classify(ctx.Err())}, ctx.Err()
+		}
+		ex.Queued += time.Since(start)
+	}
+	if p.Timeout <= 0 {
+		defer release()
+		return Run(ctx, t, args)
+	}
+	actx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	type outcome struct {
+		r   Result
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer release()
+		r, err := Run(actx, t, args)
+		done <- outcome{r, err}
+	}()
+	var o outcome
+	select {
+	case o = <-done:
+	case <-actx.Done():
+		o.err = actx.Err()
+	}
+	// Whatever the tool returned, it ran out of time if our deadline, and
+	// not the caller's, expired.
+	if o.err != nil && errors.Is(actx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		msg := fmt.Sprintf("%s timed out after %s", t.Name(), p.Timeout)
+		if !errors.Is(o.err, context.DeadlineExceeded) {
+			msg += ": " + o.err.Error()
+		}
+		ex.TimedOut = true
+		return Result{Text: o.r.Text, ErrorClass: ErrClassTimeout}, &ClassError{Class: ErrClassTimeout, Err: errors.New(msg)}
+	}
+	return o.r, o.err
+}
+
+// truncateText cuts s to at most max bytes plus a note, keeping its head,
+// its tail, or both ends of it (middle). Cuts fall on rune boundaries.
+func truncateText(s string, max int, mode string) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := len(s) - max
+	switch mode {
+	case "tail":
+		i := len(s) - max
+		for i < len(s) && !utf8.RuneStart(s[i]) {
+			i++
+		}
+		return fmt.Sprintf("[output truncated: first %d bytes omitted]\n", cut) + s[i:]
+	case "middle":
+		head, tail := max/2, len(s)-(max-max/2)
+		for head > 0 && !utf8.RuneStart(s[head]) {
+			head--
+		}
+		for tail < len(s) && !utf8.RuneStart(s[tail]) {
+			tail++
+		}
+		return s[:head] + fmt.Sprintf("\n... [output truncated: %d bytes omitted] ...\n", cut) + s[tail:]
+	default:
+		end := max
+		for end > 0 && !utf8.RuneStart(s[end]) {
+			end--
+		}
+		return s[:end] + fmt.Sprintf("\n... [output truncated at %d bytes]", max)
+	}
+}
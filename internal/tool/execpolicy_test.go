@@ -0,0 +1,123 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/config"
+)
+
+func limitedTool(name string, p ExecPolicy, fn ExecFn) Tool {
+	return &simpleTool{name: name, fn: fn, policy: p, allowed: true}
+}
+
+func TestRunWithPolicyTimeoutAndRetries(t *testing.T) {
+	hung := limitedTool("hung", ExecPolicy{Timeout: 20 * time.Millisecond}, func(context.Context, map[string]any) (string, error) {
+		select {} // ignores its context
+	})
+	r, ex, err := RunWithPolicy(context.Background(), hung, nil, nil)
+	if err == nil || r.ErrorClass != ErrClassTimeout || !ex.TimedOut || !strings.Contains(err.Error(), "timed out after 20ms") {
+		t.Fatalf("hung tool: %v %q %+v", err, r.ErrorClass, ex)
+	}
+
+	var calls atomic.Int32
+	flaky := limitedTool("flaky", ExecPolicy{Retries: 2, Backoff: time.Millisecond}, func(context.Context, map[string]any) (string, error) {
+		if calls.Add(1) < 3 {
+			return "", &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+		}
+		return "ok", nil
+	})
+	var retries []Retry
+	r, ex, err = RunWithPolicy(context.Background(), flaky, nil, func(rt Retry) { retries = append(retries, rt) })
+	if err != nil || r.Text != "ok" || ex.Attempts != 3 || len(retries) != 2 || retries[1].Class != ErrClassNetwork || retries[1].Delay != 2*time.Millisecond {
+		t.Fatalf("flaky tool: %v %q %+v %+v", err, r.Text, ex, retries)
+	}
+
+	calls.Store(0)
+	failing := limitedTool("failing", ExecPolicy{Retries: 2, Backoff: time.Millisecond}, func(context.Context, map[string]any) (string, error) {
+		calls.Add(1)
+		return "", errors.New("exit status 1")
+	})
+	if _, ex, err = RunWithPolicy(context.Background(), failing, nil, nil); err == nil || ex.Attempts != 1 || calls.Load() != 1 {
+		t.Fatalf("failed calls must not be retried: %v %+v", err, ex)
+	}
+}
+
+func TestRunWithPolicyConcurrencyAndTruncation(t *testing.T) {
+	var running, peak atomic.Int32
+	slow := limitedTool("slow", ExecPolicy{Concurrency: 1}, func(context.Context, map[string]any) (string, error) {
+		if n := running.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return "", nil
+	})
+	done := make(chan Execution, 3)
+	for range 3 {
+		go func() {
+			_, ex, _ := RunWithPolicy(context.Background(), slow, nil, nil)
+			done <- ex
+		}()
+	}
+	var queued int
+	for range 3 {
+		if ex := <-done; ex.Queued >= 5*time.Millisecond {
+			queued++
+		}
+	}
+	if peak.Load() != 1 || queued < 2 {
+		t.Fatalf("peak %d, queued %d", peak.Load(), queued)
+	}
+
+	// A run abandoned at its timeout holds its slot until it returns.
+	stuck := limitedTool("stuck", ExecPolicy{Concurrency: 1, Timeout: 10 * time.Millisecond}, func(context.Context, map[string]any) (string, error) {
+		time.Sleep(100 * time.Millisecond)
+		return "", nil
+	})
+	if _, ex, err := RunWithPolicy(context.Background(), stuck, nil, nil); err == nil || !ex.TimedOut {
+		t.Fatalf("stuck tool: %v %+v", err, ex)
+	}
+	if _, ex, _ := RunWithPolicy(context.Background(), stuck, nil, nil); ex.Queued < 50*time.Millisecond {
+		t.Fatalf("second call got a slot after %s while the first still ran", ex.Queued)
+	}
+
+	long := strings.Repeat("a", 50) + strings.Repeat("b", 50)
+	for mode, want := range map[string][2]string{"head": {"aaaa", "truncated at 20"}, "tail": {"omitted]\nbbbb", ""}, "middle": {"aaaa", "\nbbbb"}} {
+		tl := limitedTool("long", ExecPolicy{MaxOutput: 20, Truncate: mode}, func(context.Context, map[string]any) (string, error) {
+			return long, nil
+		})
+		r, ex, err := RunWithPolicy(context.Background(), tl, nil, nil)
+		if err != nil || ex.Truncated != 80 || !strings.Contains(r.Text, want[0]) || !strings.Contains(r.Text, want[1]) {
+			t.Errorf("%s: %v %d %q", mode, err, ex.Truncated, r.Text)
+		}
+	}
+	if got := truncateText("héllo wörld", 2, "head"); !strings.HasPrefix(got, "h\n") {
+		t.Errorf("cut inside a rune: %q", got)
+	}
+}
+
+func TestManifestExecPolicy(t *testing.T) {
+	tl, err := FromManifest(config.ToolManifest{Name: "bash", Type: "builtin", Timeout: "30s", Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := PolicyOf(tl)
+	if p.Timeout != 30*time.Second || p.Concurrency != 2 || p.MaxOutput != 64<<10 || p.Truncate != "middle" {
+		t.Fatalf("bash policy with overrides: %+v", p)
+	}
+	if _, err := FromManifest(config.ToolManifest{Name: "echo", Type: "builtin", Truncate: "sideways"}); err == nil {
+		t.Fatal("expected an error for an unknown truncate mode")
+	}
+	if _, err := FromManifest(config.ToolManifest{Name: "echo", Type: "builtin", Retries: -1}); err == nil {
+		t.Fatal("expected an error for negative retries")
+	}
+	if _, err := FromManifest(config.ToolManifest{Name: "echo", Type: "bultin", Timeout: "5s"}); !errors.Is(err, ErrUnknownManifest) {
+		t.Fatalf("a mistyped type must not become a command tool: %v", err)
+	}
+}
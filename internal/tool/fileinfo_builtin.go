@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 	"unicode/utf8"
@@ -38,12 +37,12 @@ func getFileInfoExec(ctx context.Context, args map[string]any) (string, error) {
 	}
 
 	path = absPath(path)
-	info, err := os.Stat(path)
+	info, err := statFile(ctx, path)
 	if err != nil {
 		return "", fmt.Errorf("failed to stat file: %w", err)
 	}
 
-	content, err := os.ReadFile(path)
+	content, err := readFile(ctx, path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
@@ -109,6 +108,7 @@ func getFileInfoExec(ctx context.Context, args map[string]any) (string, error) {
 		"modified":     info.ModTime().Format("2006-01-02 15:04:05"),
 		"permissions":  info.Mode().String(),
 		"is_directory": info.IsDir(),
+		"hash":         shortHash(contentHash(content)),
 	}
 
 	jsonResult, _ := json.Marshal(resultInfo)
@@ -0,0 +1,84 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/marcodenic/agentry/internal/overlay"
+	"github.com/marcodenic/agentry/internal/patch"
+)
+
+// File tools go through these helpers rather than os directly. In a dry run
+// the tool context carries an overlay: writes land there instead of on disk
+// and later reads see them, so the agent stays consistent with its own
+// proposed changes.
+
+func readFile(ctx context.Context, path string) ([]byte, error) {
+	if o := overlay.FromContext(ctx); o != nil {
+		return o.ReadFile(path)
+	}
+	return os.ReadFile(path)
+}
+
+func statFile(ctx context.Context, path string) (fs.FileInfo, error) {
+	if o := overlay.FromContext(ctx); o != nil {
+		return o.Stat(path)
+	}
+	return os.Stat(path)
+}
+
+// writeFile replaces path's content, creating its directory. On disk the
+// write goes through a temp file and a rename so readers never see a
+// partial file.
+func writeFile(ctx context.Context, path string, data []byte, perm fs.FileMode) error {
+	if o := overlay.FromContext(ctx); o != nil {
+		return o.WriteFile(path, data, perm)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to move temp file: %w", err)
+	}
+	return nil
+}
+
+// applyPatch applies a unified diff to the disk, or to the overlay in a
+// dry run.
+func applyPatch(ctx context.Context, patchStr string, opts patch.Options) (patch.Result, error) {
+	if o := overlay.FromContext(ctx); o != nil {
+		return patch.ApplyWith(patchStr, o, opts)
+	}
+	return patch.ApplyWith(patchStr, patch.OS(), opts)
+}
+
+// writeFiles writes every file or none of them, to the disk or to the
+// overlay in a dry run.
+func writeFiles(ctx context.Context, files []patch.File) error {
+	if o := overlay.FromContext(ctx); o != nil {
+		return patch.WriteFiles(o, files)
+	}
+	return patch.WriteFiles(patch.OS(), files)
+}
+
+// dryRun reports whether ctx belongs to a dry run.
+func dryRun(ctx context.Context) bool {
+	return overlay.FromContext(ctx) != nil
+}
+
+// refuseInDryRun fails tools whose effects the overlay cannot capture:
+// shell and process tools, commands, downloads and git changes.
+func refuseInDryRun(ctx context.Context, what string) error {
+	if !dryRun(ctx) {
+		return nil
+	}
+	return &ClassError{Class: ErrClassDenied, Err: fmt.Errorf("%s is not available in a dry run; only file edits can be proposed", what)}
+}
@@ -0,0 +1,90 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/journal"
+	"github.com/marcodenic/agentry/internal/overlay"
+)
+
+func TestDryRunFileTools(t *testing.T) {
+	j, err := journal.Open(t.TempDir(), "dry-run-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.SetDefault(j)
+	defer journal.SetDefault(nil)
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.txt")
+	if err := os.WriteFile(existing, []byte("alpha\nbeta"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "new.txt")
+
+	o := overlay.New()
+	ctx := overlay.WithContext(context.Background(), o)
+	call := func(name string, args map[string]any) string {
+		t.Helper()
+		out, err := builtinMap[name].Exec(ctx, args)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return out
+	}
+
+	call("create", map[string]any{"path": created, "content": "draft"})
+	call("search_replace", map[string]any{"path": existing, "search": "beta", "replace": "gamma"})
+	call("insert_at", map[string]any{"path": existing, "line": 1, "content": "inserted"})
+
+	// Later reads in the run see the proposed content.
+	if out := call("view", map[string]any{"path": existing, "show_line_numbers": false}); out != "alpha\ninserted\ngamma" {
+		t.Fatalf("view: %q", out)
+	}
+	if out := call("read_lines", map[string]any{"path": created, "start_line": 1}); !strings.Contains(out, "draft") {
+		t.Fatalf("read_lines: %s", out)
+	}
+
+	// The disk and the journal are untouched.
+	if b, _ := os.ReadFile(existing); string(b) != "alpha\nbeta" {
+		t.Fatalf("disk changed: %q", b)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("created file on disk: %v", err)
+	}
+	if es := j.Entries(); len(es) != 0 {
+		t.Fatalf("dry-run writes journaled: %+v", es)
+	}
+	if _, err := builtinMap["undo"].Exec(ctx, map[string]any{}); err == nil {
+		t.Fatal("undo allowed in a dry run")
+	}
+	// Tools whose effects the overlay cannot hold are refused.
+	for name, args := range map[string]map[string]any{
+		"bash":          {"command": "touch " + created},
+		"sh":            {"command": "touch " + created},
+		"shell_session": {"command": "touch " + created},
+		"proc_start":    {"command": "touch " + created},
+		"git_commit":    {"message": "sneak", "all": true},
+		"git_branch":    {"action": "create", "name": "sneak"},
+	} {
+		spec, ok := builtinMap[name]
+		if !ok {
+			continue
+		}
+		if _, err := spec.Exec(ctx, args); Classify(err) != ErrClassDenied {
+			t.Fatalf("%s in a dry run: %v", name, err)
+		}
+	}
+
+	diff, err := o.Diff(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+++ b/new.txt") || !strings.Contains(diff, "+gamma") {
+		t.Fatalf("diff:\n%s", diff)
+	}
+}
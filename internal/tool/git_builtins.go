@@ -0,0 +1,954 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Git builtins run git without a shell under the sandbox policy and return
+// JSON. None of them push, and operations that discard work (deleting an
+// unmerged branch, resetting an existing branch, switching away from local
+// changes) need force, which the built-in policy rules deny unless a
+// configured rule allows it. Each tool reports the git command lines it
+// will run so that command rules in the permissions section apply to it as
+// they do to bash.
+
+const (
+	maxGitPatch     = 64 << 10
+	defaultLogCount = 20
+	maxLogCount     = 200
+	maxBlameLines   = 1000
+)
+
+func init() {
+	builtinMap["git_status"] = builtinSpec{
+		Desc: "Show the working tree status as JSON: branch, upstream, ahead/behind, and staged, unstaged, untracked and conflicted files.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"paths": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Limit to these paths"},
+			},
+		},
+		Commands: func(map[string]any) []string { return []string{"git status"} },
+		Exec:     gitStatus,
+	}
+	builtinMap["git_diff"] = builtinSpec{
+		Desc: "Show changes as JSON: per-file status and line counts plus the unified diff. Unstaged changes by default; staged with staged=true; or against a ref such as HEAD~1 or main...feature.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"staged":  map[string]any{"type": "boolean", "description": "Diff the index against HEAD (or ref) instead of the working tree"},
+				"ref":     map[string]any{"type": "string", "description": "Commit or range to compare against, e.g. HEAD~1, main..feature"},
+				"paths":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Limit to these paths"},
+				"stat":    map[string]any{"type": "boolean", "description": "Only return per-file counts, without the diff text"},
+				"context": map[string]any{"type": "integer", "description": "Lines of context around changes (default 3)"},
+			},
+		},
+		Commands: func(map[string]any) []string { return []string{"git diff"} },
+		Exec:     gitDiff,
+	}
+	builtinMap["git_log"] = builtinSpec{
+		Desc: "List commits as JSON (hash, author, date, parents, subject), newest first.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"ref":       map[string]any{"type": "string", "description": "Commit or range to list (default HEAD)"},
+				"max_count": map[string]any{"type": "integer", "description": "Number of commits (default 20, at most 200)"},
+				"paths":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Only commits touching these paths"},
+				"author":    map[string]any{"type": "string", "description": "Only commits whose author matches this pattern"},
+				"since":     map[string]any{"type": "string", "description": "Only commits after this date, e.g. 2024-01-31 or '2 weeks ago'"},
+				"grep":      map[string]any{"type": "string", "description": "Only commits whose message matches this pattern"},
+			},
+		},
+		Commands: func(map[string]any) []string { return []string{"git log"} },
+		Exec:     gitLog,
+	}
+	builtinMap["git_show"] = builtinSpec{
+		Desc: "Show a commit as JSON (metadata, message, changed files and diff), or with path, the file's content at that commit.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"ref":  map[string]any{"type": "string", "description": "Commit to show (default HEAD)"},
+				"path": map[string]any{"type": "string", "description": "Return this file's content at ref instead of the commit"},
+				"stat": map[string]any{"type": "boolean", "description": "Omit the diff text"},
+			},
+		},
+		Commands: func(map[string]any) []string { return []string{"git show"} },
+		Exec:     gitShow,
+	}
+	builtinMap["git_branch"] = builtinSpec{
+		Desc: "List, create, switch or delete branches. Returns JSON. Deleting an unmerged branch, resetting an existing one or switching away from local changes needs force, which policy denies by default.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"action":      map[string]any{"type": "string", "enum": []string{"list", "create", "switch", "delete"}, "description": "Default list"},
+				"name":        map[string]any{"type": "string", "description": "Branch to create, switch to or delete"},
+				"start_point": map[string]any{"type": "string", "description": "Commit the new branch starts at (default HEAD)"},
+				"switch":      map[string]any{"type": "boolean", "description": "With create, also switch to the new branch"},
+				"all":         map[string]any{"type": "boolean", "description": "With list, include remote-tracking branches"},
+				"force":       map[string]any{"type": "boolean", "description": "Delete unmerged, reset existing, or discard local changes on switch"},
+			},
+			"example": map[string]any{"action": "create", "name": "fix/login-timeout", "switch": true},
+		},
+		Commands: func(args map[string]any) []string {
+			steps, err := gitBranchSteps(args)
+			if err != nil {
+				return nil
+			}
+			return gitCommandLines(steps)
+		},
+		Exec: gitBranch,
+	}
+	builtinMap["git_commit"] = builtinSpec{
+		Desc: "Commit staged changes, optionally staging paths (or everything with all) first. Returns the new commit as JSON.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"message":     map[string]any{"type": "string", "description": "Commit message"},
+				"paths":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Stage these paths before committing"},
+				"all":         map[string]any{"type": "boolean", "description": "Stage every change, including new and deleted files"},
+				"author":      map[string]any{"type": "string", "description": "Override the author, as 'Name <email>'"},
+				"signoff":     map[string]any{"type": "boolean", "description": "Add a Signed-off-by trailer"},
+				"amend":       map[string]any{"type": "boolean", "description": "Replace the last commit"},
+				"allow_empty": map[string]any{"type": "boolean", "description": "Commit even when nothing changed"},
+			},
+			"required": []string{"message"},
+			"example":  map[string]any{"message": "Fix login timeout", "paths": []string{"internal/auth/session.go"}},
+		},
+		Commands: func(args map[string]any) []string {
+			steps, err := gitCommitSteps(args)
+			if err != nil {
+				return nil
+			}
+			return gitCommandLines(steps)
+		},
+		Exec: gitCommit,
+	}
+	builtinMap["git_stash"] = builtinSpec{
+		Desc: "List, push, pop, apply, drop or show stashes. Returns JSON.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"action":            map[string]any{"type": "string", "enum": []string{"list", "push", "pop", "apply", "drop", "show"}, "description": "Default list"},
+				"message":           map[string]any{"type": "string", "description": "With push, the stash message"},
+				"paths":             map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "With push, stash only these paths"},
+				"include_untracked": map[string]any{"type": "boolean", "description": "With push, also stash untracked files"},
+				"keep_index":        map[string]any{"type": "boolean", "description": "With push, leave staged changes in place"},
+				"index":             map[string]any{"type": "integer", "description": "Stash entry for pop, apply, drop and show (default 0, the latest)"},
+			},
+		},
+		Commands: func(args map[string]any) []string {
+			steps, err := gitStashSteps(args)
+			if err != nil {
+				return nil
+			}
+			return gitCommandLines(steps)
+		},
+		Exec: gitStash,
+	}
+	builtinMap["git_blame"] = builtinSpec{
+		Desc: "Show who last changed each line of a file as JSON (commit, author, date, summary, text).",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":       map[string]any{"type": "string", "description": "File to blame"},
+				"start_line": map[string]any{"type": "integer", "description": "First line (1-based)"},
+				"end_line":   map[string]any{"type": "integer", "description": "Last line (inclusive)"},
+				"ref":        map[string]any{"type": "string", "description": "Blame the file as of this commit"},
+			},
+			"required": []string{"path"},
+		},
+		Commands: func(map[string]any) []string { return []string{"git blame"} },
+		Exec:     gitBlame,
+	}
+}
+
+// runGit runs git with args in the workspace and returns its stdout. On
+// failure the error carries git's message.
+func runGit(ctx context.Context, args ...string) (string, error) {
+	p := sandboxFrom(ctx)
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	argv := append([]string{"git", "--no-pager", "-c", "color.ui=false", "-c", "core.quotepath=false"}, args...)
+	cmd, err := p.Command(ctx, "", argv)
+	if err != nil {
+		return "", err
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "GIT_OPTIONAL_LOCKS=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// runGitSteps runs each argument list in order and returns the combined
+// output, stopping at the first failure. The steps change the repository,
+// so a dry run refuses them.
+func runGitSteps(ctx context.Context, steps [][]string) (string, error) {
+	if len(steps) > 0 {
+		if err := refuseInDryRun(ctx, "git "+steps[0][0]); err != nil {
+			return "", err
+		}
+	}
+	var out strings.Builder
+	for _, s := range steps {
+		o, err := runGit(ctx, s...)
+		out.WriteString(o)
+		if err != nil {
+			return out.String(), err
+		}
+	}
+	return out.String(), nil
+}
+
+// gitCommandLines renders steps as command lines for the policy. Free text
+// such as commit and stash messages is added to the arguments only when the
+// command runs, so it cannot trip command rules.
+func gitCommandLines(steps [][]string) []string {
+	out := make([]string, len(steps))
+	for i, s := range steps {
+		words := make([]string, len(s))
+		for j, w := range s {
+			words[j] = gitWord(w)
+		}
+		out[i] = "git " + strings.Join(words, " ")
+	}
+	return out
+}
+
+// gitWord single-quotes w unless it is a plain word, so command rules see a
+// value with spaces, such as an author or path, as one argument.
+func gitWord(w string) string {
+	if w != "" && strings.Trim(w, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./_-") == "" {
+		return w
+	}
+	return "'" + strings.ReplaceAll(w, "'", `'\''`) + "'"
+}
+
+// gitRev rejects revisions that git would parse as options.
+func gitRev(name, v string) error {
+	if strings.HasPrefix(v, "-") {
+		return fmt.Errorf("invalid %s %q", name, v)
+	}
+	return nil
+}
+
+// withPaths appends the pathspec separator and paths.
+func withPaths(args []string, paths []string) []string {
+	if len(paths) == 0 {
+		return args
+	}
+	return append(append(args, "--"), paths...)
+}
+
+type gitCommitInfo struct {
+	Hash    string   `json:"hash"`
+	Short   string   `json:"short"`
+	Author  string   `json:"author"`
+	Email   string   `json:"email"`
+	Date    string   `json:"date"`
+	Parents []string `json:"parents,omitempty"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body,omitempty"`
+}
+
+// gitCommitFormat matches parseGitCommit; records end with \x1e.
+const gitCommitFormat = "%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%P%x1f%s%x1f%b%x1e"
+
+func parseGitCommits(out string) []gitCommitInfo {
+	commits := []gitCommitInfo{}
+	for _, rec := range strings.Split(out, "\x1e") {
+		rec = strings.TrimLeft(rec, "\n")
+		f := strings.Split(rec, "\x1f")
+		if len(f) < 8 {
+			continue
+		}
+		commits = append(commits, gitCommitInfo{
+			Hash:    f[0],
+			Short:   f[1],
+			Author:  f[2],
+			Email:   f[3],
+			Date:    f[4],
+			Parents: strings.Fields(f[5]),
+			Subject: f[6],
+			Body:    strings.TrimSpace(f[7]),
+		})
+	}
+	return commits
+}
+
+type gitFileChange struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+type gitChangeSet struct {
+	Files     []gitFileChange `json:"files"`
+	Additions int             `json:"additions"`
+	Deletions int             `json:"deletions"`
+	Diff      string          `json:"diff,omitempty"`
+	Truncated bool            `json:"truncated,omitempty"`
+}
+
+// gitChanges runs a diff-like command (pre + diff options + post) for file
+// statuses, line counts and, unless stat, the patch.
+func gitChanges(ctx context.Context, pre, post []string, stat bool, patchOpts ...string) (gitChangeSet, error) {
+	run := func(opts ...string) (string, error) {
+		args := append(append(append([]string{}, pre...), opts...), post...)
+		return runGit(ctx, args...)
+	}
+	set := gitChangeSet{Files: []gitFileChange{}}
+	names, err := run("--name-status", "-z", "--no-ext-diff")
+	if err != nil {
+		return set, err
+	}
+	nums, err := run("--numstat", "-z", "--no-ext-diff")
+	if err != nil {
+		return set, err
+	}
+	counts := parseNumstat(nums)
+	f := strings.Split(strings.TrimRight(names, "\x00"), "\x00")
+	for i := 0; i+1 < len(f); i += 2 {
+		code := f[i]
+		if code == "" {
+			continue
+		}
+		c := gitFileChange{Path: f[i+1], Status: gitStatusName(code[0])}
+		if (code[0] == 'R' || code[0] == 'C') && i+2 < len(f) {
+			c.OldPath, c.Path = f[i+1], f[i+2]
+			i++
+		}
+		if n, ok := counts[c.Path]; ok {
+			c.Additions, c.Deletions, c.Binary = n.add, n.del, n.binary
+		}
+		set.Additions += c.Additions
+		set.Deletions += c.Deletions
+		set.Files = append(set.Files, c)
+	}
+	if stat || len(set.Files) == 0 {
+		return set, nil
+	}
+	patch, err := run(append([]string{"--no-ext-diff", "--patch"}, patchOpts...)...)
+	if err != nil {
+		return set, err
+	}
+	if len(patch) > maxGitPatch {
+		patch, set.Truncated = patch[:maxGitPatch], true
+	}
+	set.Diff = patch
+	return set, nil
+}
+
+type numstat struct {
+	add, del int
+	binary   bool
+}
+
+// parseNumstat parses --numstat -z output, keyed by the new path.
+func parseNumstat(out string) map[string]numstat {
+	m := map[string]numstat{}
+	f := strings.Split(out, "\x00")
+	for i := 0; i < len(f); i++ {
+		parts := strings.SplitN(f[i], "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := parts[2]
+		if path == "" && i+2 < len(f) {
+			// Rename or copy: the old and new paths follow.
+			path = f[i+2]
+			i += 2
+		}
+		var n numstat
+		if parts[0] == "-" {
+			n.binary = true
+		} else {
+			n.add, _ = strconv.Atoi(parts[0])
+			n.del, _ = strconv.Atoi(parts[1])
+		}
+		m[path] = n
+	}
+	return m
+}
+
+func gitStatusName(c byte) string {
+	switch c {
+	case 'M':
+		return "modified"
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	case 'T':
+		return "type_changed"
+	case 'U':
+		return "unmerged"
+	}
+	return string(c)
+}
+
+type gitStatusEntry struct {
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	Status  string `json:"status"`
+}
+
+type gitStatusResult struct {
+	Branch     string           `json:"branch"`
+	Commit     string           `json:"commit,omitempty"`
+	Upstream   string           `json:"upstream,omitempty"`
+	Ahead      int              `json:"ahead"`
+	Behind     int              `json:"behind"`
+	Clean      bool             `json:"clean"`
+	Staged     []gitStatusEntry `json:"staged"`
+	Unstaged   []gitStatusEntry `json:"unstaged"`
+	Untracked  []string         `json:"untracked"`
+	Conflicted []string         `json:"conflicted"`
+}
+
+func gitStatus(ctx context.Context, args map[string]any) (string, error) {
+	out, err := runGit(ctx, withPaths([]string{"status", "--porcelain=v2", "--branch", "-z", "--untracked-files=all"}, strSlice(args, "paths"))...)
+	if err != nil {
+		return "", err
+	}
+	return marshal(parseGitStatus(out))
+}
+
+// parseGitStatus parses git status --porcelain=v2 --branch -z.
+func parseGitStatus(out string) gitStatusResult {
+	res := gitStatusResult{Staged: []gitStatusEntry{}, Unstaged: []gitStatusEntry{}, Untracked: []string{}, Conflicted: []string{}}
+	recs := strings.Split(out, "\x00")
+	for i := 0; i < len(recs); i++ {
+		rec := recs[i]
+		if len(rec) < 2 {
+			continue
+		}
+		switch rec[0] {
+		case '#':
+			key, val, _ := strings.Cut(rec[2:], " ")
+			switch key {
+			case "branch.oid":
+				if val != "(initial)" {
+					res.Commit = val
+				}
+			case "branch.head":
+				res.Branch = val
+			case "branch.upstream":
+				res.Upstream = val
+			case "branch.ab":
+				for _, ab := range strings.Fields(val) {
+					n, _ := strconv.Atoi(ab[1:])
+					if ab[0] == '+' {
+						res.Ahead = n
+					} else {
+						res.Behind = n
+					}
+				}
+			}
+		case '1', '2':
+			n := 9
+			if rec[0] == '2' {
+				n = 10
+			}
+			f := strings.SplitN(rec, " ", n)
+			if len(f) < n {
+				continue
+			}
+			e := gitStatusEntry{Path: f[n-1]}
+			if rec[0] == '2' && i+1 < len(recs) {
+				e.OldPath = recs[i+1]
+				i++
+			}
+			xy := f[1]
+			if xy[0] != '.' {
+				s := e
+				s.Status = gitStatusName(xy[0])
+				res.Staged = append(res.Staged, s)
+			}
+			if xy[1] != '.' {
+				u := e
+				u.Status = gitStatusName(xy[1])
+				res.Unstaged = append(res.Unstaged, u)
+			}
+		case 'u':
+			f := strings.SplitN(rec, " ", 11)
+			if len(f) == 11 {
+				res.Conflicted = append(res.Conflicted, f[10])
+			}
+		case '?':
+			res.Untracked = append(res.Untracked, rec[2:])
+		}
+	}
+	res.Clean = len(res.Staged)+len(res.Unstaged)+len(res.Untracked)+len(res.Conflicted) == 0
+	return res
+}
+
+func gitDiff(ctx context.Context, args map[string]any) (string, error) {
+	pre := []string{"diff"}
+	if staged, _ := args["staged"].(bool); staged {
+		pre = append(pre, "--cached")
+	}
+	var post []string
+	if ref := strArg(args, "ref"); ref != "" {
+		if err := gitRev("ref", ref); err != nil {
+			return "", err
+		}
+		post = append(post, ref)
+	}
+	post = withPaths(post, strSlice(args, "paths"))
+	var opts []string
+	if n, ok := getIntArg(args, "context", 3); ok && n >= 0 {
+		opts = append(opts, "-U"+strconv.Itoa(n))
+	}
+	stat, _ := args["stat"].(bool)
+	set, err := gitChanges(ctx, pre, post, stat, opts...)
+	if err != nil {
+		return "", err
+	}
+	return marshal(set)
+}
+
+func gitLog(ctx context.Context, args map[string]any) (string, error) {
+	n, _ := getIntArg(args, "max_count", defaultLogCount)
+	if n <= 0 {
+		n = defaultLogCount
+	}
+	n = min(n, maxLogCount)
+	argv := []string{"log", "--format=" + gitCommitFormat, "-n", strconv.Itoa(n)}
+	for _, k := range []string{"author", "since", "grep"} {
+		if v := strArg(args, k); v != "" {
+			argv = append(argv, "--"+k+"="+v)
+		}
+	}
+	if ref := strArg(args, "ref"); ref != "" {
+		if err := gitRev("ref", ref); err != nil {
+			return "", err
+		}
+		argv = append(argv, ref)
+	}
+	out, err := runGit(ctx, withPaths(argv, strSlice(args, "paths"))...)
+	if err != nil {
+		return "", err
+	}
+	commits := parseGitCommits(out)
+	// Log output is meant to be scanned; bodies are available from git_show.
+	for i := range commits {
+		commits[i].Body = ""
+	}
+	return marshal(map[string]any{"commits": commits})
+}
+
+func gitShow(ctx context.Context, args map[string]any) (string, error) {
+	ref := strArg(args, "ref")
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if err := gitRev("ref", ref); err != nil {
+		return "", err
+	}
+	if path := strArg(args, "path"); path != "" {
+		rel, err := gitRelPath(path)
+		if err != nil {
+			return "", err
+		}
+		content, err := runGit(ctx, "show", ref+":"+rel)
+		if err != nil {
+			return "", err
+		}
+		res := map[string]any{"ref": ref, "path": path, "content": content}
+		if len(content) > maxGitPatch {
+			res["content"], res["truncated"] = content[:maxGitPatch], true
+		}
+		return marshal(res)
+	}
+	out, err := runGit(ctx, "show", "-s", "--format="+gitCommitFormat, ref)
+	if err != nil {
+		return "", err
+	}
+	commits := parseGitCommits(out)
+	if len(commits) == 0 {
+		return "", fmt.Errorf("git show: %s is not a commit", ref)
+	}
+	stat, _ := args["stat"].(bool)
+	set, err := gitChanges(ctx, []string{"show", "--format="}, []string{ref}, stat)
+	if err != nil {
+		return "", err
+	}
+	return marshal(map[string]any{"commit": commits[0], "changes": set})
+}
+
+// gitRelPath makes path relative to the working directory in the form
+// git's rev:path syntax expects ("./" anchors it to the current directory).
+func gitRelPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		if path, err = filepath.Rel(wd, path); err != nil {
+			return "", err
+		}
+	}
+	return "./" + filepath.ToSlash(filepath.Clean(path)), nil
+}
+
+func gitBranchSteps(args map[string]any) ([][]string, error) {
+	action := strArg(args, "action")
+	name := strArg(args, "name")
+	force, _ := args["force"].(bool)
+	if action == "" || action == "list" {
+		return nil, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("git_branch %s: missing name", action)
+	}
+	if err := gitRev("branch name", name); err != nil {
+		return nil, err
+	}
+	switch action {
+	case "create":
+		start := strArg(args, "start_point")
+		if err := gitRev("start_point", start); err != nil {
+			return nil, err
+		}
+		create := []string{"branch"}
+		if force {
+			create = append(create, "-f")
+		}
+		create = append(create, name)
+		if start != "" {
+			create = append(create, start)
+		}
+		steps := [][]string{create}
+		if sw, _ := args["switch"].(bool); sw {
+			steps = append(steps, []string{"switch", name})
+		}
+		return steps, nil
+	case "switch":
+		if force {
+			return [][]string{{"switch", "--force", name}}, nil
+		}
+		return [][]string{{"switch", name}}, nil
+	case "delete":
+		flag := "-d"
+		if force {
+			flag = "-D"
+		}
+		return [][]string{{"branch", flag, name}}, nil
+	}
+	return nil, fmt.Errorf("git_branch: unknown action %q (want list, create, switch or delete)", action)
+}
+
+type gitBranchInfo struct {
+	Name     string `json:"name"`
+	Commit   string `json:"commit"`
+	Subject  string `json:"subject,omitempty"`
+	Upstream string `json:"upstream,omitempty"`
+	Track    string `json:"track,omitempty"`
+	Current  bool   `json:"current,omitempty"`
+	Remote   bool   `json:"remote,omitempty"`
+}
+
+func gitBranch(ctx context.Context, args map[string]any) (string, error) {
+	steps, err := gitBranchSteps(args)
+	if err != nil {
+		return "", err
+	}
+	if len(steps) > 0 {
+		out, err := runGitSteps(ctx, steps)
+		if err != nil {
+			return "", err
+		}
+		current, _ := runGit(ctx, "branch", "--show-current")
+		return marshal(map[string]any{
+			"ok":      true,
+			"action":  strArg(args, "action"),
+			"branch":  strArg(args, "name"),
+			"current": strings.TrimSpace(current),
+			"output":  strings.TrimSpace(out),
+		})
+	}
+
+	refs := []string{"refs/heads"}
+	if all, _ := args["all"].(bool); all {
+		refs = append(refs, "refs/remotes")
+	}
+	format := "%(HEAD)%1f%(refname)%1f%(refname:short)%1f%(objectname:short)%1f%(upstream:short)%1f%(upstream:track,nobracket)%1f%(contents:subject)"
+	out, err := runGit(ctx, append([]string{"for-each-ref", "--format=" + format}, refs...)...)
+	if err != nil {
+		return "", err
+	}
+	branches := []gitBranchInfo{}
+	current := ""
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(line, "\x1f")
+		if len(f) != 7 || strings.HasSuffix(f[1], "/HEAD") {
+			continue
+		}
+		b := gitBranchInfo{
+			Name:     f[2],
+			Commit:   f[3],
+			Upstream: f[4],
+			Track:    f[5],
+			Subject:  f[6],
+			Current:  f[0] == "*",
+			Remote:   strings.HasPrefix(f[1], "refs/remotes/"),
+		}
+		if b.Current {
+			current = b.Name
+		}
+		branches = append(branches, b)
+	}
+	return marshal(map[string]any{"current": current, "branches": branches})
+}
+
+func gitCommitSteps(args map[string]any) ([][]string, error) {
+	if strArg(args, "message") == "" {
+		return nil, errors.New("git_commit: missing message")
+	}
+	var steps [][]string
+	if all, _ := args["all"].(bool); all {
+		steps = append(steps, []string{"add", "--all"})
+	} else if paths := strSlice(args, "paths"); len(paths) > 0 {
+		steps = append(steps, append([]string{"add", "--"}, paths...))
+	}
+	commit := []string{"commit"}
+	if author := strArg(args, "author"); author != "" {
+		commit = append(commit, "--author="+author)
+	}
+	for _, flag := range []string{"signoff", "amend", "allow_empty"} {
+		if on, _ := args[flag].(bool); on {
+			commit = append(commit, "--"+strings.ReplaceAll(flag, "_", "-"))
+		}
+	}
+	return append(steps, commit), nil
+}
+
+func gitCommit(ctx context.Context, args map[string]any) (string, error) {
+	steps, err := gitCommitSteps(args)
+	if err != nil {
+		return "", err
+	}
+	last := len(steps) - 1
+	steps[last] = append(steps[last], "-m", strArg(args, "message"))
+	if _, err := runGitSteps(ctx, steps); err != nil {
+		return "", err
+	}
+	out, err := runGit(ctx, "show", "-s", "--format="+gitCommitFormat, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	commits := parseGitCommits(out)
+	if len(commits) == 0 {
+		return "", errors.New("git_commit: cannot read the new commit")
+	}
+	set, err := gitChanges(ctx, []string{"show", "--format="}, []string{"HEAD"}, true)
+	if err != nil {
+		return "", err
+	}
+	branch, _ := runGit(ctx, "branch", "--show-current")
+	return marshal(map[string]any{
+		"ok":        true,
+		"commit":    commits[0],
+		"branch":    strings.TrimSpace(branch),
+		"files":     set.Files,
+		"additions": set.Additions,
+		"deletions": set.Deletions,
+	})
+}
+
+func gitStashSteps(args map[string]any) ([][]string, error) {
+	action := strArg(args, "action")
+	idx, _ := getIntArg(args, "index", 0)
+	if idx < 0 {
+		return nil, fmt.Errorf("git_stash: invalid index %d", idx)
+	}
+	ref := fmt.Sprintf("stash@{%d}", idx)
+	switch action {
+	case "", "list", "show":
+		return nil, nil
+	case "push":
+		push := []string{"stash", "push"}
+		if on, _ := args["include_untracked"].(bool); on {
+			push = append(push, "--include-untracked")
+		}
+		if on, _ := args["keep_index"].(bool); on {
+			push = append(push, "--keep-index")
+		}
+		return [][]string{withPaths(push, strSlice(args, "paths"))}, nil
+	case "pop", "apply", "drop":
+		return [][]string{{"stash", action, ref}}, nil
+	}
+	return nil, fmt.Errorf("git_stash: unknown action %q (want list, push, pop, apply, drop or show)", action)
+}
+
+func gitStash(ctx context.Context, args map[string]any) (string, error) {
+	steps, err := gitStashSteps(args)
+	if err != nil {
+		return "", err
+	}
+	action := strArg(args, "action")
+	idx, _ := getIntArg(args, "index", 0)
+	ref := fmt.Sprintf("stash@{%d}", idx)
+	switch {
+	case len(steps) > 0:
+		if msg := strArg(args, "message"); action == "push" && msg != "" {
+			steps[0] = append(steps[0][:2], append([]string{"--message=" + msg}, steps[0][2:]...)...)
+		}
+		out, err := runGitSteps(ctx, steps)
+		if err != nil {
+			return "", err
+		}
+		res := map[string]any{"ok": true, "action": action, "output": strings.TrimSpace(out)}
+		if action != "push" {
+			res["ref"] = ref
+		}
+		return marshal(res)
+	case action == "show":
+		set, err := gitChanges(ctx, []string{"stash", "show"}, []string{ref}, false)
+		if err != nil {
+			return "", err
+		}
+		return marshal(map[string]any{"ref": ref, "changes": set})
+	}
+	out, err := runGit(ctx, "stash", "list", "--format=%gd%x1f%H%x1f%aI%x1f%gs")
+	if err != nil {
+		return "", err
+	}
+	type stashEntry struct {
+		Ref     string `json:"ref"`
+		Commit  string `json:"commit"`
+		Date    string `json:"date"`
+		Message string `json:"message"`
+	}
+	entries := []stashEntry{}
+	for _, line := range strings.Split(out, "\n") {
+		if f := strings.Split(line, "\x1f"); len(f) == 4 {
+			entries = append(entries, stashEntry{Ref: f[0], Commit: f[1], Date: f[2], Message: f[3]})
+		}
+	}
+	return marshal(map[string]any{"stashes": entries})
+}
+
+type blameLine struct {
+	Line    int    `json:"line"`
+	Commit  string `json:"commit"`
+	Author  string `json:"author"`
+	Date    string `json:"date"`
+	Summary string `json:"summary"`
+	Text    string `json:"text"`
+}
+
+func gitBlame(ctx context.Context, args map[string]any) (string, error) {
+	path := strArg(args, "path")
+	if path == "" {
+		return "", errors.New("git_blame: missing path")
+	}
+	argv := []string{"blame", "--porcelain"}
+	start, hasStart := getIntArg(args, "start_line", 1)
+	end, hasEnd := getIntArg(args, "end_line", 0)
+	if hasStart || hasEnd {
+		if start < 1 || (hasEnd && end < start) {
+			return "", fmt.Errorf("git_blame: invalid line range %d-%d", start, end)
+		}
+		r := strconv.Itoa(start) + ","
+		if hasEnd {
+			r += strconv.Itoa(end)
+		}
+		argv = append(argv, "-L", r)
+	}
+	if ref := strArg(args, "ref"); ref != "" {
+		if err := gitRev("ref", ref); err != nil {
+			return "", err
+		}
+		argv = append(argv, ref)
+	}
+	out, err := runGit(ctx, append(argv, "--", path)...)
+	if err != nil {
+		return "", err
+	}
+	lines := parseBlame(out)
+	res := map[string]any{"path": path, "lines": lines}
+	if len(lines) > maxBlameLines {
+		res["lines"], res["truncated"] = lines[:maxBlameLines], true
+	}
+	return marshal(res)
+}
+
+// parseBlame parses git blame --porcelain output. Commit details are given
+// only the first time a commit appears.
+func parseBlame(out string) []blameLine {
+	type info struct{ author, date, summary string }
+	commits := map[string]*info{}
+	lines := []blameLine{}
+	var cur blameLine
+	var ci *info
+	for _, l := range strings.Split(out, "\n") {
+		if strings.HasPrefix(l, "\t") {
+			cur.Text = l[1:]
+			if ci != nil {
+				cur.Author, cur.Date, cur.Summary = ci.author, ci.date, ci.summary
+			}
+			lines = append(lines, cur)
+			continue
+		}
+		key, val, _ := strings.Cut(l, " ")
+		if len(key) == 40 && strings.Trim(key, "0123456789abcdef") == "" {
+			f := strings.Fields(val)
+			if len(f) < 2 {
+				continue
+			}
+			n, _ := strconv.Atoi(f[1])
+			cur = blameLine{Line: n, Commit: key[:12]}
+			if ci = commits[key]; ci == nil {
+				ci = &info{}
+				commits[key] = ci
+			}
+			continue
+		}
+		if ci == nil {
+			continue
+		}
+		switch key {
+		case "author":
+			ci.author = val
+		case "author-time":
+			if sec, err := strconv.ParseInt(val, 10, 64); err == nil {
+				ci.date = time.Unix(sec, 0).UTC().Format(time.RFC3339)
+			}
+		case "summary":
+			ci.summary = val
+		}
+	}
+	return lines
+}
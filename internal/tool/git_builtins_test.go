@@ -0,0 +1,148 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/config"
+	"github.com/marcodenic/agentry/internal/policy"
+)
+
+func gitCall(t *testing.T, name string, args map[string]any) map[string]any {
+	t.Helper()
+	out, err := builtinMap[name].Exec(context.Background(), args)
+	if err != nil {
+		t.Fatalf("%s %v: %v", name, args, err)
+	}
+	var res map[string]any
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("%s: bad JSON %q: %v", name, out, err)
+	}
+	return res
+}
+
+func initGitRepo(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Chdir(t.TempDir())
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.name", "Test User"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "commit.gpgsign", "false"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestGitTools(t *testing.T) {
+	initGitRepo(t)
+	if err := os.WriteFile("a.txt", []byte("first\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	st := gitCall(t, "git_status", nil)
+	if st["branch"] != "main" || st["clean"] != false || len(st["untracked"].([]any)) != 1 {
+		t.Fatalf("status before commit: %v", st)
+	}
+
+	c := gitCall(t, "git_commit", map[string]any{"message": "Add a", "all": true, "signoff": true})
+	if c["commit"].(map[string]any)["subject"] != "Add a" || c["additions"] != float64(1) {
+		t.Fatalf("commit: %v", c)
+	}
+	if st := gitCall(t, "git_status", nil); st["clean"] != true {
+		t.Fatalf("status after commit: %v", st)
+	}
+
+	if err := os.WriteFile("a.txt", []byte("first\nsecond\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := gitCall(t, "git_diff", nil)
+	files := d["files"].([]any)
+	if len(files) != 1 || files[0].(map[string]any)["status"] != "modified" || !strings.Contains(d["diff"].(string), "+second") {
+		t.Fatalf("diff: %v", d)
+	}
+	if d := gitCall(t, "git_diff", map[string]any{"staged": true}); len(d["files"].([]any)) != 0 {
+		t.Fatalf("staged diff should be empty: %v", d)
+	}
+
+	gitCall(t, "git_stash", map[string]any{"action": "push", "message": "wip -f"})
+	stashes := gitCall(t, "git_stash", nil)["stashes"].([]any)
+	if len(stashes) != 1 || !strings.Contains(stashes[0].(map[string]any)["message"].(string), "wip -f") {
+		t.Fatalf("stash list: %v", stashes)
+	}
+	if sh := gitCall(t, "git_stash", map[string]any{"action": "show"}); sh["changes"].(map[string]any)["additions"] != float64(1) {
+		t.Fatalf("stash show: %v", sh)
+	}
+	gitCall(t, "git_stash", map[string]any{"action": "pop"})
+	if st := gitCall(t, "git_status", nil); len(st["unstaged"].([]any)) != 1 {
+		t.Fatalf("stash pop did not restore the change: %v", st)
+	}
+
+	b := gitCall(t, "git_branch", map[string]any{"action": "create", "name": "feature", "switch": true})
+	if b["current"] != "feature" {
+		t.Fatalf("branch create: %v", b)
+	}
+	if l := gitCall(t, "git_branch", nil); l["current"] != "feature" || len(l["branches"].([]any)) != 2 {
+		t.Fatalf("branch list: %v", l)
+	}
+
+	log := gitCall(t, "git_log", nil)["commits"].([]any)
+	if len(log) != 1 || log[0].(map[string]any)["subject"] != "Add a" {
+		t.Fatalf("log: %v", log)
+	}
+	show := gitCall(t, "git_show", map[string]any{"path": "a.txt"})
+	if show["content"] != "first\n" {
+		t.Fatalf("show path: %v", show)
+	}
+	if show := gitCall(t, "git_show", nil); !strings.Contains(show["commit"].(map[string]any)["body"].(string), "Signed-off-by: Test User") {
+		t.Fatalf("show: %v", show)
+	}
+
+	if out, err := exec.Command("git", "mv", "a.txt", "b.txt").CombinedOutput(); err != nil {
+		t.Fatalf("git mv: %v\n%s", err, out)
+	}
+	d = gitCall(t, "git_diff", map[string]any{"staged": true, "stat": true})
+	if f := d["files"].([]any)[0].(map[string]any); f["status"] != "renamed" || f["old_path"] != "a.txt" || f["path"] != "b.txt" {
+		t.Fatalf("staged rename: %v", d)
+	}
+
+	lines := gitCall(t, "git_blame", map[string]any{"path": "a.txt", "ref": "HEAD"})["lines"].([]any)
+	if len(lines) != 1 || lines[0].(map[string]any)["summary"] != "Add a" || lines[0].(map[string]any)["text"] != "first" {
+		t.Fatalf("blame: %v", lines)
+	}
+}
+
+func TestGitToolsPolicy(t *testing.T) {
+	initGitRepo(t)
+	if err := os.WriteFile("a.txt", []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitCall(t, "git_commit", map[string]any{"message": "init", "all": true})
+	gitCall(t, "git_branch", map[string]any{"action": "create", "name": "old"})
+
+	e, err := policy.New(config.Permissions{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := WrapWithPolicy(Registry{"git_branch": newBuiltin("git_branch", "", builtinMap["git_branch"])}, e, nil)
+	ctx := context.Background()
+	if _, err := reg["git_branch"].Execute(ctx, map[string]any{"action": "delete", "name": "old", "force": true}); !errors.Is(err, ErrToolDenied) {
+		t.Fatalf("forced delete: expected denial, got %v", err)
+	}
+	if _, err := reg["git_branch"].Execute(ctx, map[string]any{"action": "delete", "name": "old"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := reg["git_branch"].Execute(ctx, map[string]any{"action": "switch", "name": "--orphan"}); err == nil {
+		t.Fatal("option-like branch name accepted")
+	}
+}
@@ -0,0 +1,158 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/marcodenic/agentry/internal/goedit"
+)
+
+// Structural Go edits. Each tool parses the file, splices the change in,
+// gofmts the result and checks that it still parses before writing it
+// through the file tools; a refused edit leaves the file untouched.
+
+func goSchema(props map[string]any, required []string, example map[string]any) map[string]any {
+	props["path"] = map[string]any{"type": "string", "description": "Go source file"}
+	return map[string]any{"type": "object", "properties": props, "required": append([]string{"path"}, required...), "example": example}
+}
+
+func init() {
+	builtinMap["go_outline"] = builtinSpec{
+		Desc:   "Outline a Go file: package, imports, and each function, method, type, var and const with its signature, doc line and line range",
+		Schema: goSchema(map[string]any{}, nil, map[string]any{"path": "internal/auth/login.go"}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			path, src, err := readGoFile(ctx, args)
+			if err != nil {
+				return "", err
+			}
+			o, err := goedit.ParseOutline(src)
+			if err != nil {
+				return "", err
+			}
+			_ = recordView(ctx, path)
+			return marshal(o)
+		},
+	}
+
+	builtinMap["go_replace_func"] = builtinSpec{
+		Desc: "Replace a Go function or method by name. code is either the whole declaration (starting with func or its doc comment) or just the new body.",
+		Schema: goSchema(map[string]any{
+			"name": map[string]any{"type": "string", "description": "Function name, or Type.Method for a method"},
+			"code": map[string]any{"type": "string", "description": "New declaration or body"},
+		}, []string{"name", "code"}, map[string]any{"path": "calc.go", "name": "Add", "code": "return a + b"}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			name := strArg(args, "name")
+			return goEdit(ctx, args, name, func(src []byte) ([]byte, map[string]any, error) {
+				out, err := goedit.ReplaceFunc(src, name, strArg(args, "code"))
+				return out, nil, err
+			})
+		},
+	}
+
+	builtinMap["go_add_method"] = builtinSpec{
+		Desc: "Add a method to a Go type, placed after the type's existing methods. code is the full method declaration including receiver and doc comment.",
+		Schema: goSchema(map[string]any{
+			"type": map[string]any{"type": "string", "description": "Receiver type name (optional; checked against the receiver in code)"},
+			"code": map[string]any{"type": "string", "description": "Method declaration"},
+		}, []string{"code"}, map[string]any{"path": "cart.go", "type": "Cart", "code": "// Len returns the number of items.\nfunc (c *Cart) Len() int { return len(c.Items) }"}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			return goEdit(ctx, args, "", func(src []byte) ([]byte, map[string]any, error) {
+				out, err := goedit.AddMethod(src, strArg(args, "type"), strArg(args, "code"))
+				return out, nil, err
+			})
+		},
+	}
+
+	builtinMap["go_ensure_import"] = builtinSpec{
+		Desc: "Add an import to a Go file unless it is already there",
+		Schema: goSchema(map[string]any{
+			"import": map[string]any{"type": "string", "description": "Import path"},
+			"name":   map[string]any{"type": "string", "description": "Optional package name (alias, _ or .)"},
+		}, []string{"import"}, map[string]any{"path": "main.go", "import": "strings"}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			return goEdit(ctx, args, "", func(src []byte) ([]byte, map[string]any, error) {
+				out, _, err := goedit.EnsureImport(src, strArg(args, "import"), strArg(args, "name"))
+				return out, nil, err
+			})
+		},
+	}
+
+	builtinMap["go_remove_import"] = builtinSpec{
+		Desc: "Remove an import from a Go file; refused while the package is still used",
+		Schema: goSchema(map[string]any{
+			"import": map[string]any{"type": "string", "description": "Import path"},
+		}, []string{"import"}, map[string]any{"path": "main.go", "import": "fmt"}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			return goEdit(ctx, args, "", func(src []byte) ([]byte, map[string]any, error) {
+				out, _, err := goedit.RemoveImport(src, strArg(args, "import"))
+				return out, nil, err
+			})
+		},
+	}
+
+	builtinMap["go_rename_local"] = builtinSpec{
+		Desc: "Rename a parameter, variable, constant or label inside one Go function, respecting scopes; refused if the new name would shadow or be shadowed",
+		Schema: goSchema(map[string]any{
+			"func": map[string]any{"type": "string", "description": "Enclosing function, or Type.Method"},
+			"old":  map[string]any{"type": "string", "description": "Current name"},
+			"new":  map[string]any{"type": "string", "description": "New name"},
+			"line": map[string]any{"type": "integer", "description": "Line of the declaration, when several locals share the name"},
+		}, []string{"func", "old", "new"}, map[string]any{"path": "calc.go", "func": "Total", "old": "s", "new": "sum"}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			fn := strArg(args, "func")
+			return goEdit(ctx, args, fn, func(src []byte) ([]byte, map[string]any, error) {
+				line, _ := getIntArg(args, "line", 0)
+				out, n, err := goedit.RenameLocal(src, fn, strArg(args, "old"), strArg(args, "new"), line)
+				return out, map[string]any{"renamed": n}, err
+			})
+		},
+	}
+}
+
+func readGoFile(ctx context.Context, args map[string]any) (string, []byte, error) {
+	p := strArg(args, "path")
+	if p == "" {
+		return "", nil, errors.New("missing path")
+	}
+	path := absPath(p)
+	if filepath.Ext(path) != ".go" {
+		return "", nil, fmt.Errorf("%s is not a .go file", p)
+	}
+	src, err := readFile(ctx, path)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, src, nil
+}
+
+// goEdit applies edit to the file and writes the result. When decl names a
+// function, its new line range is reported.
+func goEdit(ctx context.Context, args map[string]any, decl string, edit func([]byte) ([]byte, map[string]any, error)) (string, error) {
+	path, src, err := readGoFile(ctx, args)
+	if err != nil {
+		return "", err
+	}
+	out, extra, err := edit(src)
+	if err != nil {
+		return "", err
+	}
+	res := map[string]any{"path": strArg(args, "path"), "changed": string(out) != string(src)}
+	for k, v := range extra {
+		res[k] = v
+	}
+	if res["changed"] == true {
+		if _, err := writeFileExec(ctx, map[string]any{"path": path, "content": string(out)}); err != nil {
+			return "", err
+		}
+	}
+	if decl != "" {
+		if o, err := goedit.ParseOutline(out); err == nil {
+			if d, ok := o.Find(decl); ok {
+				res["line"], res["end_line"] = d.Line, d.EndLine
+			}
+		}
+	}
+	return marshal(res)
+}
@@ -0,0 +1,44 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoEditTools(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "calc.go")
+	src := "package calc\n\nfunc Add(a, b int) int {\n\treturn a - b\n}\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	out, err := builtinMap["go_replace_func"].Exec(ctx, map[string]any{"path": path, "name": "Add", "code": "return a + b"})
+	if err != nil || !strings.Contains(out, `"changed":true`) || !strings.Contains(out, `"line":3`) {
+		t.Fatalf("replace: %s %v", out, err)
+	}
+	if _, err := builtinMap["go_ensure_import"].Exec(ctx, map[string]any{"path": path, "import": "fmt"}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	if want := "package calc\n\nimport \"fmt\"\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"; string(b) != want {
+		t.Fatalf("file:\n%s", b)
+	}
+
+	// A refused edit leaves the file as it was.
+	if _, err := builtinMap["go_replace_func"].Exec(ctx, map[string]any{"path": path, "name": "Add", "code": "return a +"}); err == nil {
+		t.Fatal("broken body accepted")
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(b) {
+		t.Fatalf("file changed by a refused edit:\n%s", after)
+	}
+
+	out, err = builtinMap["go_outline"].Exec(ctx, map[string]any{"path": path})
+	if err != nil || !strings.Contains(out, `"signature":"func Add(a, b int) int"`) {
+		t.Fatalf("outline: %s %v", out, err)
+	}
+}
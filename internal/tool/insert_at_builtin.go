@@ -2,12 +2,14 @@ package tool
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
+
+	"github.com/marcodenic/agentry/internal/journal"
 )
 
 func init() {
@@ -29,6 +31,7 @@ func init() {
 					"type":        "string",
 					"description": "Content to insert (without trailing newline)",
 				},
+				"expected_hash": expectedHashSchema,
 			},
 			"required": []string{"path", "line", "content"},
 			"example": map[string]any{
@@ -55,21 +58,22 @@ func insertAtExec(ctx context.Context, args map[string]any) (string, error) {
 	}
 
 	path = absPath(path)
-	if err := checkForOverwrite(path); err != nil {
+	defer lockFiles(path)()
+	if err := checkForOverwrite(ctx, path, args); err != nil {
 		return "", err
 	}
+	before := journal.Snapshot(path)
 
-	file, err := os.Open(path)
+	data, err := readFile(ctx, path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
 	}
 
 	var allLines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		allLines = append(allLines, scanner.Text())
 	}
-	file.Close()
 	if err := scanner.Err(); err != nil {
 		return "", fmt.Errorf("error reading file: %w", err)
 	}
@@ -84,39 +88,13 @@ func insertAtExec(ctx context.Context, args map[string]any) (string, error) {
 	result = append(result, newLines...)
 	result = append(result, allLines[int(line):]...)
 
-	tempPath := path + ".tmp"
-	tempFile, err := os.Create(tempPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
-	}
-
-	for i, lineText := range result {
-		if i > 0 {
-			if _, err := tempFile.WriteString("\n"); err != nil {
-				tempFile.Close()
-				os.Remove(tempPath)
-				return "", fmt.Errorf("failed to write to temp file: %w", err)
-			}
-		}
-		if _, err := tempFile.WriteString(lineText); err != nil {
-			tempFile.Close()
-			os.Remove(tempPath)
-			return "", fmt.Errorf("failed to write to temp file: %w", err)
-		}
-	}
-
-	if err := tempFile.Close(); err != nil {
-		os.Remove(tempPath)
-		return "", fmt.Errorf("failed to close temp file: %w", err)
-	}
-
-	if err := os.Rename(tempPath, path); err != nil {
-		os.Remove(tempPath)
-		return "", fmt.Errorf("failed to move temp file: %w", err)
+	if err := writeFile(ctx, path, []byte(strings.Join(result, "\n")), 0o644); err != nil {
+		return "", err
 	}
+	recordWrite(ctx, "insert_at", before)
 
 	// Update viewed timestamp after modification
-	_ = recordView(path)
+	_ = recordView(ctx, path)
 
 	resultInfo := map[string]any{
 		"path":           path,
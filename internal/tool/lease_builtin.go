@@ -0,0 +1,161 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Advisory file leases. While several agents edit one tree, Agent 0 can
+// assign files or directories to the worker that owns them; the file tools
+// then refuse writes from other agents until the lease is released or
+// expires. Reads are never blocked.
+
+type lease struct {
+	Path    string    `json:"path"`
+	Owner   string    `json:"owner"`
+	By      string    `json:"assigned_by"`
+	Expires time.Time `json:"expires"`
+}
+
+var leases = struct {
+	sync.Mutex
+	m map[string]lease
+}{m: map[string]lease{}}
+
+// within reports whether path is p or inside directory p.
+func within(path, p string) bool {
+	return path == p || strings.HasPrefix(path, p+string(filepath.Separator))
+}
+
+// pruneLeases drops expired leases. The caller holds leases.
+func pruneLeases(now time.Time) {
+	for p, l := range leases.m {
+		if now.After(l.Expires) {
+			delete(leases.m, p)
+		}
+	}
+}
+
+// leaseOn returns the lease covering path, the most specific first. The
+// caller holds leases.
+func leaseOn(path string, now time.Time) (lease, bool) {
+	pruneLeases(now)
+	var best lease
+	found := false
+	for p, l := range leases.m {
+		if within(path, p) && (!found || len(p) > len(best.Path)) {
+			best, found = l, true
+		}
+	}
+	return best, found
+}
+
+// checkLease refuses a write to path by anyone but its lease holder.
+func checkLease(ctx context.Context, path string) error {
+	leases.Lock()
+	l, ok := leaseOn(filepath.Clean(path), time.Now())
+	leases.Unlock()
+	if !ok || l.Owner == agentName(ctx) {
+		return nil
+	}
+	return &ClassError{Class: ErrClassConflict, Err: fmt.Errorf("%s is leased to %s until %s (assigned by %s); leave it to %s or have the lease released",
+		path, l.Owner, l.Expires.Format("15:04:05"), l.By, l.Owner)}
+}
+
+func init() {
+	builtinMap["lease"] = builtinSpec{
+		Desc: "Advisory file ownership for parallel work: acquire or assign files and directories to an agent so other agents cannot write them, release them, or list current leases",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"action":      map[string]any{"type": "string", "enum": []string{"acquire", "release", "list"}, "description": "Default acquire"},
+				"paths":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Files or directories"},
+				"owner":       map[string]any{"type": "string", "description": "Agent that owns the paths (default: yourself)"},
+				"ttl_minutes": map[string]any{"type": "integer", "description": "Lease duration; default 30"},
+				"force":       map[string]any{"type": "boolean", "description": "Release even leases you neither hold nor assigned"},
+			},
+			"required": []string{},
+			"example":  map[string]any{"action": "acquire", "paths": []string{"internal/auth"}, "owner": "coder"},
+		},
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			me := agentName(ctx)
+			var paths []string
+			for _, p := range append(strSlice(args, "paths"), strArg(args, "path")) {
+				if p != "" {
+					paths = append(paths, filepath.Clean(absPath(p)))
+				}
+			}
+			now := time.Now()
+			leases.Lock()
+			defer leases.Unlock()
+
+			switch action := strArg(args, "action"); action {
+			case "list":
+				pruneLeases(now)
+				out := make([]lease, 0, len(leases.m))
+				for _, l := range leases.m {
+					out = append(out, l)
+				}
+				sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+				return marshal(map[string]any{"leases": out})
+
+			case "release":
+				if len(paths) == 0 {
+					return "", errors.New("missing paths")
+				}
+				force, _ := args["force"].(bool)
+				var released []string
+				for _, p := range paths {
+					l, ok := leases.m[p]
+					if !ok {
+						continue
+					}
+					if l.Owner != me && l.By != me && !force {
+						return "", fmt.Errorf("%s is leased to %s by %s; only they can release it (or use force)", p, l.Owner, l.By)
+					}
+					delete(leases.m, p)
+					released = append(released, p)
+				}
+				return marshal(map[string]any{"released": released})
+
+			case "", "acquire":
+				if len(paths) == 0 {
+					return "", errors.New("missing paths")
+				}
+				owner := strArg(args, "owner")
+				if owner == "" {
+					owner = me
+				}
+				ttl, _ := getIntArg(args, "ttl_minutes", 30)
+				if ttl <= 0 {
+					return "", errors.New("ttl_minutes must be positive")
+				}
+				pruneLeases(now)
+				// All or nothing: no path may overlap another owner's lease.
+				for _, p := range paths {
+					for q, l := range leases.m {
+						if l.Owner != owner && (within(p, q) || within(q, p)) {
+							return "", fmt.Errorf("%s overlaps %s, leased to %s until %s", p, q, l.Owner, l.Expires.Format("15:04:05"))
+						}
+					}
+				}
+				out := make([]lease, 0, len(paths))
+				for _, p := range paths {
+					l := lease{Path: p, Owner: owner, By: me, Expires: now.Add(time.Duration(ttl) * time.Minute)}
+					leases.m[p] = l
+					out = append(out, l)
+				}
+				return marshal(map[string]any{"leased": out})
+
+			default:
+				return "", fmt.Errorf("unknown action %q", action)
+			}
+		},
+	}
+}
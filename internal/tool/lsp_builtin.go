@@ -12,10 +12,10 @@ import (
 	"github.com/marcodenic/agentry/internal/lsp"
 )
 
-// Register a simple LSP diagnostics builtin that shells out to language-specific checkers
+// Register the LSP diagnostics builtin, backed by language servers or language-specific checkers
 func init() {
 	builtinMap["lsp_diagnostics"] = builtinSpec{
-		Desc: "Run language diagnostics (Go: gopls check, TypeScript: tsc --noEmit, Python: pyright, Rust: cargo check, JavaScript: eslint)",
+		Desc: "Run language diagnostics. Listed paths are checked by their language server (gopls, typescript-language-server, pyright, rust-analyzer) when installed; otherwise, and for a workspace scan, by gopls check, tsc --noEmit, pyright, cargo check or eslint.",
 		Schema: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
@@ -26,61 +26,145 @@ func init() {
 				},
 				"timeout_ms": map[string]any{
 					"type":        "integer",
-					"description": "Optional timeout in milliseconds",
+					"description": "Optional timeout in milliseconds; diagnostics gathered by then are returned",
 				},
 			},
 		},
-		Exec: func(ctx context.Context, args map[string]any) (string, error) {
-			// Collect file list
-			files, err := expandPaths(args["paths"])
-			if err != nil {
-				return "", err
-			}
-			if len(files) == 0 {
-				// Auto-discover supported files
-				files, _ = discoverWorkspaceFiles()
-			}
-			if len(files) == 0 {
-				return marshal(map[string]any{
-					"ok":          true,
-					"message":     "no supported files found",
-					"languages":   lsp.Languages(),
-					"diagnostics": []any{},
-					"counts":      map[string]any{"files": 0, "errors": 0, "warnings": 0},
-				})
-			}
+		Exec:   textOf(lspDiagnostics),
+		Result: lspDiagnostics,
+	}
+}
 
-			// Optional timeout (not wired into lsp.Check yet; placeholder for future client-based impl)
-			_ = parseTimeout(args["timeout_ms"]) // no-op for now
+// DiagnosticsReport is the structured result of lsp_diagnostics.
+type DiagnosticsReport struct {
+	OK          bool             `json:"ok"`
+	Error       string           `json:"error,omitempty"`
+	Message     string           `json:"message,omitempty"`
+	Output      string           `json:"output,omitempty"`
+	Languages   []string         `json:"languages"`
+	Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+	Counts      DiagnosticCounts `json:"counts"`
+	Incomplete  bool             `json:"incomplete,omitempty"`
+}
 
-			out, runErr := lsp.Check(files)
-			diags := lsp.ParseDiagnostics(out)
-			// aggregate counts
-			errCount := 0
-			warnCount := 0
-			fileSet := map[string]struct{}{}
-			for _, d := range diags {
-				fileSet[d.File] = struct{}{}
-				if strings.EqualFold(d.Severity, "warning") {
-					warnCount++
-				} else {
-					errCount++
-				}
-			}
-			res := map[string]any{
-				"ok":          runErr == nil,
-				"error":       nil,
-				"output":      out,
-				"languages":   lsp.Languages(),
-				"diagnostics": diags,
-				"counts":      map[string]any{"files": len(fileSet), "errors": errCount, "warnings": warnCount},
-			}
-			if runErr != nil {
-				res["error"] = runErr.Error()
+// DiagnosticCounts summarises a DiagnosticsReport.
+type DiagnosticCounts struct {
+	Files    int `json:"files"`
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+}
+
+func lspDiagnostics(ctx context.Context, args map[string]any) (Result, error) {
+	// Collect file list
+	files, err := expandPaths(args["paths"])
+	if err != nil {
+		return Result{}, err
+	}
+	explicit := len(files) > 0
+	if !explicit {
+		// Auto-discover supported files
+		files, _ = discoverWorkspaceFiles()
+	}
+	if len(files) == 0 {
+		return jsonResult(DiagnosticsReport{
+			OK:          true,
+			Message:     "no supported files found",
+			Languages:   lsp.Languages(),
+			Diagnostics: []lsp.Diagnostic{},
+		})
+	}
+
+	if timeout := parseTimeout(args["timeout_ms"]); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Explicit files go to their running language servers where one
+	// is installed; a workspace scan uses the project-wide checkers.
+	var diags []lsp.Diagnostic
+	incomplete := false
+	if explicit {
+		files, diags, incomplete = serverDiagnostics(lspFiles(ctx), files)
+	}
+	var out string
+	var runErr error
+	if len(files) > 0 {
+		out, runErr = lsp.CheckContext(ctx, files)
+		diags = append(diags, lsp.ParseDiagnostics(out)...)
+	}
+	if ctx.Err() != nil && runErr != nil {
+		runErr = fmt.Errorf("diagnostics timed out: %w", ctx.Err())
+	}
+	if diags == nil {
+		diags = []lsp.Diagnostic{}
+	}
+	rep := DiagnosticsReport{
+		OK:          runErr == nil,
+		Output:      out,
+		Languages:   lsp.Languages(),
+		Diagnostics: diags,
+		Incomplete:  incomplete,
+	}
+	// aggregate counts
+	fileSet := map[string]struct{}{}
+	for _, d := range diags {
+		fileSet[d.File] = struct{}{}
+		if strings.EqualFold(d.Severity, "warning") {
+			rep.Counts.Warnings++
+		} else {
+			rep.Counts.Errors++
+		}
+	}
+	rep.Counts.Files = len(fileSet)
+	if runErr != nil {
+		rep.Error = runErr.Error()
+	}
+	r, err := jsonResult(rep)
+	if runErr != nil {
+		r.ErrorClass = Classify(runErr)
+	}
+	return r, err
+}
+
+// serverDiagnostics collects published diagnostics for the files that have
+// an installed language server and returns the rest for the checkers.
+// incomplete is set when a server did not report every file before ctx
+// ended.
+func serverDiagnostics(ctx context.Context, files []string) (rest []string, diags []lsp.Diagnostic, incomplete bool) {
+	root, err := lspRoot(ctx)
+	if err != nil {
+		return files, nil, false
+	}
+	groups := map[*lsp.Client][]string{}
+	for _, f := range files {
+		path := absPath(f)
+		if _, err := lsp.ServerFor(path); err != nil {
+			rest = append(rest, f)
+			continue
+		}
+		c, err := lspManager(ctx).Client(ctx, root, path)
+		if err != nil {
+			rest = append(rest, f)
+			continue
+		}
+		groups[c] = append(groups[c], path)
+	}
+	for c, paths := range groups {
+		ds, complete, err := c.Diagnostics(ctx, paths)
+		if err != nil {
+			for _, p := range paths {
+				rest = append(rest, relToRoot(root, p))
 			}
-			return marshal(res)
-		},
+			continue
+		}
+		for i := range ds {
+			ds[i].File = relToRoot(root, ds[i].File)
+		}
+		diags = append(diags, ds...)
+		incomplete = incomplete || !complete
 	}
+	return rest, diags, incomplete
 }
 
 func expandPaths(v any) ([]string, error) {
@@ -147,17 +231,8 @@ func approximateGlob(pat string) []string {
 }
 
 func parseTimeout(v any) time.Duration {
-	if v == nil {
-		return 0
-	}
-	switch t := v.(type) {
-	case float64:
-		return time.Duration(int64(t)) * time.Millisecond
-	case int64:
-		return time.Duration(t) * time.Millisecond
-	default:
-		return 0
-	}
+	ms, _ := getIntArg(map[string]any{"ms": v}, "ms", 0)
+	return time.Duration(ms) * time.Millisecond
 }
 
 func marshal(v any) (string, error) {
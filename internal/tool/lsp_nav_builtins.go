@@ -0,0 +1,444 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/marcodenic/agentry/internal/journal"
+	"github.com/marcodenic/agentry/internal/lsp"
+	"github.com/marcodenic/agentry/internal/overlay"
+	"github.com/marcodenic/agentry/internal/patch"
+)
+
+// Navigation and rename tools talk to real language servers (gopls,
+// typescript-language-server, pyright, rust-analyzer) started on first use
+// and kept running per workspace root until CloseLanguageServers.
+
+const (
+	defaultLSPTimeout = time.Minute
+	maxLSPReferences  = 200
+)
+
+// lspServers holds a Manager per sandbox policy, so a language server only
+// serves calls made under the policy it was started with.
+var lspServers = struct {
+	sync.Mutex
+	m map[string]*lsp.Manager
+}{m: map[string]*lsp.Manager{}}
+
+// lspManager returns the Manager for the sandbox policy attached to ctx.
+func lspManager(ctx context.Context) *lsp.Manager {
+	p := sandboxFrom(ctx)
+	key := fmt.Sprintf("%#v", p)
+	lspServers.Lock()
+	defer lspServers.Unlock()
+	if m := lspServers.m[key]; m != nil {
+		return m
+	}
+	m := lsp.NewManager(func(root string, argv []string) (*exec.Cmd, error) {
+		return p.Command(context.Background(), root, argv)
+	})
+	lspServers.m[key] = m
+	return m
+}
+
+// lspChanged tells every running language server that paths were written.
+func lspChanged(paths []string) {
+	lspServers.Lock()
+	defer lspServers.Unlock()
+	for _, m := range lspServers.m {
+		m.Changed(paths)
+	}
+}
+
+// CloseLanguageServers shuts down every language server started by the lsp_* tools.
+func CloseLanguageServers() {
+	lspServers.Lock()
+	managers := lspServers.m
+	lspServers.m = map[string]*lsp.Manager{}
+	lspServers.Unlock()
+	for _, m := range managers {
+		m.Close()
+	}
+}
+
+func lspPositionSchema(extra map[string]any, example map[string]any) map[string]any {
+	props := map[string]any{
+		"path":       map[string]any{"type": "string", "description": "File containing the symbol"},
+		"line":       map[string]any{"type": "integer", "description": "1-based line of the symbol; optional when symbol is given"},
+		"column":     map[string]any{"type": "integer", "description": "1-based character column; defaults to the first occurrence of symbol on the line"},
+		"symbol":     map[string]any{"type": "string", "description": "Identifier to position on; found on line, or its first occurrence in the file"},
+		"timeout_ms": map[string]any{"type": "integer", "description": "Timeout in milliseconds, including server start (default 60000)"},
+	}
+	for k, v := range extra {
+		props[k] = v
+	}
+	return map[string]any{"type": "object", "properties": props, "required": []string{"path"}, "example": example}
+}
+
+func init() {
+	builtinMap["lsp_definition"] = builtinSpec{
+		Desc:   "Find where a symbol is defined, using the language server for the file (gopls, typescript-language-server, pyright, rust-analyzer)",
+		Schema: lspPositionSchema(nil, map[string]any{"path": "internal/auth/login.go", "line": 42, "symbol": "ValidateToken"}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			ctx, cancel := lspContext(ctx, args)
+			defer cancel()
+			c, path, line, col, err := lspPosition(ctx, args)
+			if err != nil {
+				return "", err
+			}
+			locs, err := c.Definition(ctx, path, line, col)
+			if err != nil {
+				return "", err
+			}
+			return marshal(map[string]any{"definitions": relLocations(c.Root, locs)})
+		},
+	}
+
+	builtinMap["lsp_references"] = builtinSpec{
+		Desc: "List every reference to a symbol across the workspace, using the language server for the file",
+		Schema: lspPositionSchema(map[string]any{
+			"include_declaration": map[string]any{"type": "boolean", "description": "Include the declaration itself (default true)"},
+		}, map[string]any{"path": "internal/auth/login.go", "symbol": "ValidateToken"}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			ctx, cancel := lspContext(ctx, args)
+			defer cancel()
+			c, path, line, col, err := lspPosition(ctx, args)
+			if err != nil {
+				return "", err
+			}
+			includeDecl := true
+			if v, ok := args["include_declaration"].(bool); ok {
+				includeDecl = v
+			}
+			locs, err := c.References(ctx, path, line, col, includeDecl)
+			if err != nil {
+				return "", err
+			}
+			files := map[string]bool{}
+			for _, l := range locs {
+				files[l.File] = true
+			}
+			res := map[string]any{"count": len(locs), "files": len(files)}
+			if len(locs) > maxLSPReferences {
+				res["truncated"] = true
+				locs = locs[:maxLSPReferences]
+			}
+			res["references"] = relLocations(c.Root, locs)
+			return marshal(res)
+		},
+	}
+
+	builtinMap["lsp_hover"] = builtinSpec{
+		Desc:   "Show the type signature and documentation of a symbol, using the language server for the file",
+		Schema: lspPositionSchema(nil, map[string]any{"path": "main.go", "line": 10, "column": 6}),
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			ctx, cancel := lspContext(ctx, args)
+			defer cancel()
+			c, path, line, col, err := lspPosition(ctx, args)
+			if err != nil {
+				return "", err
+			}
+			text, loc, err := c.Hover(ctx, path, line, col)
+			if err != nil {
+				return "", err
+			}
+			res := map[string]any{"hover": text}
+			if loc != nil {
+				res["range"] = relLocations(c.Root, []lsp.Location{*loc})[0]
+			}
+			return marshal(res)
+		},
+	}
+
+	builtinMap["lsp_symbols"] = builtinSpec{
+		Desc: "List the declarations in a file (path), or search the workspace for symbols by name (query)",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path":       map[string]any{"type": "string", "description": "File to outline"},
+				"query":      map[string]any{"type": "string", "description": "Workspace symbol search; path or language picks the server"},
+				"language":   map[string]any{"type": "string", "description": "Server for a workspace search without path: go, typescript, python or rust"},
+				"timeout_ms": map[string]any{"type": "integer", "description": "Timeout in milliseconds, including server start (default 60000)"},
+			},
+			"example": map[string]any{"query": "Handler", "language": "go"},
+		},
+		Exec: lspSymbols,
+	}
+
+	renameSchema := lspPositionSchema(map[string]any{
+		"new_name": map[string]any{"type": "string", "description": "New identifier"},
+		"dry_run":  map[string]any{"type": "boolean", "description": "Return the edits without writing them"},
+	}, map[string]any{"path": "internal/auth/login.go", "symbol": "ValidateToken", "new_name": "VerifyToken"})
+	renameSchema["required"] = []string{"path", "new_name"}
+	builtinMap["lsp_rename"] = builtinSpec{
+		Desc:   "Rename a symbol everywhere it is used, using the language server for the file. The edits are written through the file tools; set dry_run to only list them.",
+		Schema: renameSchema,
+		Exec:   lspRename,
+	}
+}
+
+func lspContext(ctx context.Context, args map[string]any) (context.Context, context.CancelFunc) {
+	timeout := parseTimeout(args["timeout_ms"])
+	if timeout <= 0 {
+		timeout = defaultLSPTimeout
+	}
+	return context.WithTimeout(lspFiles(ctx), timeout)
+}
+
+// lspFiles shows language servers a dry run's proposed contents, so the
+// positions and edits they return match what readFile sees.
+func lspFiles(ctx context.Context) context.Context {
+	if o := overlay.FromContext(ctx); o != nil {
+		return lsp.WithFiles(ctx, o)
+	}
+	return ctx
+}
+
+func lspRoot(ctx context.Context) (string, error) {
+	return sandboxFrom(ctx).Confine("")
+}
+
+// lspPosition returns the client for args["path"] and the 1-based line and
+// column named by the line, column and symbol arguments.
+func lspPosition(ctx context.Context, args map[string]any) (*lsp.Client, string, int, int, error) {
+	p := strArg(args, "path")
+	if p == "" {
+		return nil, "", 0, 0, errors.New("missing path")
+	}
+	path := absPath(p)
+	b, err := readFile(ctx, path)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+	line, hasLine := getIntArg(args, "line", 0)
+	col, _ := getIntArg(args, "column", 1)
+	symbol := strArg(args, "symbol")
+	switch {
+	case symbol != "":
+		line, col, err = findSymbol(string(b), symbol, line, col)
+		if err != nil {
+			return nil, "", 0, 0, err
+		}
+	case !hasLine || line < 1:
+		return nil, "", 0, 0, errors.New("line (or symbol) is required")
+	}
+	root, err := lspRoot(ctx)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+	c, err := lspManager(ctx).Client(ctx, root, path)
+	if err != nil {
+		return nil, "", 0, 0, err
+	}
+	return c, path, line, col, nil
+}
+
+// findSymbol locates symbol as a whole word on line (at or after col), or
+// anywhere in text when line is 0, and returns its 1-based line and column.
+func findSymbol(text, symbol string, line, col int) (int, int, error) {
+	re := regexp.MustCompile(`(^|\W)(` + regexp.QuoteMeta(symbol) + `)($|\W)`)
+	lines := strings.Split(text, "\n")
+	find := func(s string, from int) int {
+		if m := re.FindStringSubmatchIndex(s[from:]); m != nil {
+			return from + m[4]
+		}
+		return -1
+	}
+	if line > 0 {
+		if line > len(lines) {
+			return 0, 0, fmt.Errorf("line %d is past the end of the file", line)
+		}
+		s := lines[line-1]
+		from := 0
+		for i := 1; i < col && from < len(s); i++ {
+			_, size := utf8.DecodeRuneInString(s[from:])
+			from += size
+		}
+		if i := find(s, from); i >= 0 {
+			return line, utf8.RuneCountInString(s[:i]) + 1, nil
+		}
+		return 0, 0, fmt.Errorf("symbol %q not found on line %d", symbol, line)
+	}
+	for n, s := range lines {
+		if i := find(s, 0); i >= 0 {
+			return n + 1, utf8.RuneCountInString(s[:i]) + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("symbol %q not found", symbol)
+}
+
+// relLocations reports files relative to root when they are inside it.
+func relLocations(root string, locs []lsp.Location) []lsp.Location {
+	out := make([]lsp.Location, len(locs))
+	for i, l := range locs {
+		l.File = relToRoot(root, l.File)
+		out[i] = l
+	}
+	return out
+}
+
+func relToRoot(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func lspSymbols(ctx context.Context, args map[string]any) (string, error) {
+	ctx, cancel := lspContext(ctx, args)
+	defer cancel()
+	root, err := lspRoot(ctx)
+	if err != nil {
+		return "", err
+	}
+	path, query := strArg(args, "path"), strArg(args, "query")
+	if path == "" && query == "" {
+		return "", errors.New("path or query is required")
+	}
+
+	if query == "" {
+		path = absPath(path)
+		c, err := lspManager(ctx).Client(ctx, root, path)
+		if err != nil {
+			return "", err
+		}
+		syms, err := c.DocumentSymbols(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		for i := range syms {
+			syms[i].File = relToRoot(root, syms[i].File)
+		}
+		return marshal(map[string]any{"path": relToRoot(root, path), "symbols": syms})
+	}
+
+	// A workspace search needs a file of the right language to pick the
+	// server; fall back to the languages detected in the workspace.
+	var c *lsp.Client
+	if path != "" {
+		c, err = lspManager(ctx).Client(ctx, root, absPath(path))
+	} else {
+		langs := lsp.Languages()
+		if lang := strArg(args, "language"); lang != "" {
+			langs = []string{lang}
+		}
+		err = errors.New("no language detected in the workspace; pass path or language")
+		for _, lang := range langs {
+			if lang == "javascript" {
+				lang = "typescript"
+			}
+			for _, srv := range lsp.Servers {
+				if srv.Language == lang && srv.Installed() {
+					c, err = lspManager(ctx).Client(ctx, root, filepath.Join(root, "x"+srv.Extensions[0]))
+					break
+				}
+			}
+			if c != nil {
+				break
+			}
+		}
+		if c == nil && err == nil {
+			err = fmt.Errorf("no language server installed for %s", strings.Join(langs, ", "))
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	syms, err := c.WorkspaceSymbols(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	res := map[string]any{"query": query, "count": len(syms)}
+	if len(syms) > maxLSPReferences {
+		res["truncated"] = true
+		syms = syms[:maxLSPReferences]
+	}
+	for i := range syms {
+		syms[i].File = relToRoot(root, syms[i].File)
+	}
+	res["symbols"] = syms
+	return marshal(res)
+}
+
+func lspRename(ctx context.Context, args map[string]any) (string, error) {
+	newName := strArg(args, "new_name")
+	if newName == "" {
+		return "", errors.New("missing new_name")
+	}
+	lctx, cancel := lspContext(ctx, args)
+	defer cancel()
+	c, path, line, col, err := lspPosition(lctx, args)
+	if err != nil {
+		return "", err
+	}
+	edits, err := c.Rename(lctx, path, line, col, newName)
+	if err != nil {
+		return "", err
+	}
+
+	dry, _ := args["dry_run"].(bool)
+	paths := make([]string, len(edits))
+	for i, fe := range edits {
+		paths[i] = fe.Path
+	}
+	if !dry {
+		// The policy saw only the position's file; it must allow them all.
+		if err := checkPaths(ctx, paths); err != nil {
+			return "", err
+		}
+		defer lockFiles(paths...)()
+	}
+
+	// Compute every file's new content before writing any of them.
+	writes := make([]patch.File, len(edits))
+	files := make([]map[string]any, len(edits))
+	total := 0
+	for i, fe := range edits {
+		if !dry {
+			if err := checkForOverwrite(ctx, fe.Path, nil); err != nil {
+				return "", err
+			}
+		}
+		b, err := readFile(ctx, fe.Path)
+		if err != nil {
+			return "", err
+		}
+		content, err := lsp.ApplyEdits(string(b), fe.Edits)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", fe.Path, err)
+		}
+		writes[i] = patch.File{Path: fe.Path, Content: []byte(content)}
+		files[i] = map[string]any{"path": relToRoot(c.Root, fe.Path), "edits": len(fe.Edits)}
+		total += len(fe.Edits)
+	}
+	res := map[string]any{"new_name": newName, "files": files, "edits": total}
+	if dry {
+		res["applied"] = false
+		return marshal(res)
+	}
+
+	// All files are written or, if one fails, none are.
+	before := make([]journal.Change, len(paths))
+	for i, p := range paths {
+		before[i] = journal.Snapshot(p)
+	}
+	if err := writeFiles(ctx, writes); err != nil {
+		return "", fmt.Errorf("rename not applied: %w", err)
+	}
+	for i, p := range paths {
+		recordWrite(ctx, "lsp_rename", before[i])
+		_ = recordView(ctx, p)
+	}
+	lspChanged(paths)
+	res["applied"] = true
+	return marshal(res)
+}
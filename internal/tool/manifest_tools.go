@@ -0,0 +1,561 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/config"
+	"github.com/marcodenic/agentry/internal/httpx"
+	"github.com/marcodenic/agentry/internal/sandbox"
+)
+
+// defaultManifestTimeout bounds command and http manifest tools that set no
+// timeout.
+const defaultManifestTimeout = 60 * time.Second
+
+// placeholder matches {{name}} in manifest templates.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// manifestSchema turns a manifest's args into a JSON Schema. Each entry is a
+// schema fragment ({type, description, enum, default, required: true}) or a
+// bare type name; a complete object schema is used as is.
+func manifestSchema(args map[string]any) (map[string]any, error) {
+	if _, ok := args["properties"]; ok && args["type"] == "object" {
+		return args, nil
+	}
+	props := map[string]any{}
+	var required []string
+	for name, spec := range args {
+		switch v := spec.(type) {
+		case string:
+			props[name] = map[string]any{"type": v}
+		case map[string]any:
+			p := make(map[string]any, len(v))
+			for k, e := range v {
+				if k == "required" {
+					if b, _ := e.(bool); b {
+						required = append(required, name)
+					}
+					continue
+				}
+				p[k] = e
+			}
+			if _, ok := p["type"]; !ok {
+				p["type"] = "string"
+			}
+			props[name] = p
+		case nil:
+			props[name] = map[string]any{"type": "string"}
+		default:
+			return nil, fmt.Errorf("args.%s: expected a type name or schema, got %T", name, spec)
+		}
+	}
+	sort.Strings(required)
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// prepareArgs applies schema defaults and checks required arguments.
+func prepareArgs(schema map[string]any, args map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		out[k] = v
+	}
+	props, _ := schema["properties"].(map[string]any)
+	for name, p := range props {
+		if d, ok := p.(map[string]any)["default"]; ok {
+			if _, set := out[name]; !set {
+				out[name] = d
+			}
+		}
+	}
+	var missing []string
+	for _, r := range requiredNames(schema) {
+		if v, ok := out[r]; !ok || v == nil {
+			missing = append(missing, r)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required argument(s): %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+func requiredNames(schema map[string]any) []string {
+	switch r := schema["required"].(type) {
+	case []string:
+		return r
+	case []any:
+		out := make([]string, 0, len(r))
+		for _, e := range r {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// expandTemplate replaces each {{name}} in tmpl with quote(value). It
+// reports false if a referenced argument is absent, and records every name
+// it references in used.
+func expandTemplate(tmpl string, args map[string]any, quote func(string) string, used map[string]bool) (string, bool) {
+	complete := true
+	out := placeholder.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		used[name] = true
+		v, ok := args[name]
+		if !ok || v == nil {
+			complete = false
+			return ""
+		}
+		return quote(paramString(v))
+	})
+	return out, complete
+}
+
+func identity(s string) string { return s }
+
+// expandArgv builds an argument vector from templates. Every element stays
+// a single argument whatever the values contain; an element that is exactly
+// {{name}} with a list value expands to one argument per item, and elements
+// referring to absent optional arguments are dropped.
+func expandArgv(tmpl []string, args map[string]any) ([]string, error) {
+	used := map[string]bool{}
+	var argv []string
+	for i, t := range tmpl {
+		if m := placeholder.FindStringSubmatch(t); m != nil && m[0] == strings.TrimSpace(t) {
+			if list, ok := args[m[1]].([]any); ok {
+				for _, e := range list {
+					argv = append(argv, paramString(e))
+				}
+				continue
+			}
+		}
+		s, complete := expandTemplate(t, args, identity, used)
+		if !complete {
+			if i == 0 {
+				return nil, fmt.Errorf("argv[0] %q needs an argument that was not given", t)
+			}
+			continue
+		}
+		argv = append(argv, s)
+	}
+	if len(argv) == 0 || argv[0] == "" {
+		return nil, errors.New("empty argv")
+	}
+	return argv, nil
+}
+
+// expandURL expands a URL template, escaping values as a path segment before
+// the '?' and as a query component after it, so a value can neither add path
+// segments nor smuggle in extra parameters.
+func expandURL(tmpl string, args map[string]any, used map[string]bool) (string, bool) {
+	path, query, hasQuery := strings.Cut(tmpl, "?")
+	out, complete := expandTemplate(path, args, url.PathEscape, used)
+	if hasQuery {
+		q, ok := expandTemplate(query, args, url.QueryEscape, used)
+		out, complete = out+"?"+q, complete && ok
+	}
+	return out, complete
+}
+
+// checkCommandTemplate rejects placeholders inside quotes or after a
+// backslash. Values are already shell-quoted, and within double quotes or
+// backticks that quoting is literal text, so a value like $(id) would run.
+func checkCommandTemplate(cmd string) error {
+	starts := map[int]string{}
+	for _, loc := range placeholder.FindAllStringIndex(cmd, -1) {
+		starts[loc[0]] = cmd[loc[0]:loc[1]]
+	}
+	var quote byte
+	escaped := false
+	for i := 0; i < len(cmd); i++ {
+		if p, ok := starts[i]; ok && (quote != 0 || escaped) {
+			return fmt.Errorf("%s must not be quoted or escaped; values are shell-quoted already", p)
+		}
+		switch c := cmd[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+		case quote == 0 && (c == '\'' || c == '"' || c == '`'):
+			quote = c
+		case c == quote:
+			quote = 0
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s as one word for the shell ExecDirect uses.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func manifestTimeout(m config.ToolManifest) (time.Duration, error) {
+	if m.Timeout == "" {
+		return defaultManifestTimeout, nil
+	}
+	d, err := time.ParseDuration(m.Timeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("tool %s: invalid timeout %q", m.Name, m.Timeout)
+	}
+	return d, nil
+}
+
+// truncateOutput caps s at max bytes (no cap when max <= 0).
+func truncateOutput(s string, max int) string {
+	return truncateText(s, max, "head")
+}
+
+func manifestAllowed(m config.ToolManifest) bool {
+	if m.Permissions.Allow != nil {
+		return *m.Permissions.Allow
+	}
+	return true
+}
+
+// commandManifestTool runs m.Argv directly, or m.Command through the shell
+// with each {{name}} replaced by the shell-quoted argument.
+func commandManifestTool(m config.ToolManifest) (Tool, error) {
+	schema, err := manifestSchema(m.Args)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", m.Name, err)
+	}
+	timeout, err := manifestTimeout(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCommandTemplate(m.Command); err != nil {
+		return nil, fmt.Errorf("tool %s: command: %w", m.Name, err)
+	}
+	// Validate the sandbox overrides now; the policy itself is resolved per
+	// call from the caller's permissions.
+	if _, err := manifestPolicy(m, sandbox.Policy{}); err != nil {
+		return nil, err
+	}
+	tl := NewWithSchema(m.Name, m.Description, schema, func(ctx context.Context, raw map[string]any) (string, error) {
+		args, err := prepareArgs(schema, raw)
+		if err != nil {
+			return "", err
+		}
+		if err := refuseInDryRun(ctx, m.Name); err != nil {
+			return "", err
+		}
+		policy, err := manifestPolicy(m, sandboxFrom(ctx))
+		if err != nil {
+			return "", err
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		var out string
+		if len(m.Argv) > 0 {
+			argv, err := expandArgv(m.Argv, args)
+			if err != nil {
+				return "", err
+			}
+			out, err = policy.Run(ctx, "", argv)
+			if err != nil {
+				return truncateText(out, m.MaxOutput, m.Truncate), commandError(ctx, err, timeout)
+			}
+			return truncateText(out, m.MaxOutput, m.Truncate), nil
+		}
+		cmdLine, _ := expandTemplate(m.Command, args, shellQuote, map[string]bool{})
+		out, err = policy.Run(ctx, "", shellArgv(cmdLine))
+		if err != nil {
+			return truncateText(out, m.MaxOutput, m.Truncate), commandError(ctx, err, timeout)
+		}
+		return truncateText(out, m.MaxOutput, m.Truncate), nil
+	})
+	tl.(*simpleTool).allowed = manifestAllowed(m)
+	return tl, nil
+}
+
+// manifestPolicy is the sandbox policy for a command manifest: privileged
+// tools run unconfined, others apply their engine and limit overrides.
+func manifestPolicy(m config.ToolManifest, base sandbox.Policy) (sandbox.Policy, error) {
+	if m.Privileged {
+		return sandbox.Policy{Engine: sandbox.EngineDisabled}, nil
+	}
+	return base.WithManifest(m)
+}
+
+func commandError(ctx context.Context, err error, timeout time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("command timed out after %s", timeout)
+	}
+	return err
+}
+
+// httpManifestTool sends a request built from templates: {{name}} in the URL
+// is path-escaped; header and query values are expanded verbatim and query
+// entries whose arguments are absent are left out. Arguments not used by a
+// template form the JSON body, or extra query parameters for GET, HEAD and
+// DELETE.
+func httpManifestTool(m config.ToolManifest, creds map[string]map[string]string) (Tool, error) {
+	schema, err := manifestSchema(m.Args)
+	if err != nil {
+		return nil, fmt.Errorf("tool %s: %w", m.Name, err)
+	}
+	timeout, err := manifestTimeout(m)
+	if err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(m.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	credName := m.Auth
+	if credName == "" {
+		credName = m.Name
+	}
+	cred := creds[credName]
+	if m.Auth != "" && cred == nil {
+		return nil, fmt.Errorf("tool %s: no credentials named %q", m.Name, m.Auth)
+	}
+	var extract []pathStep
+	if m.Extract != "" {
+		if extract, err = parseJSONPath(m.Extract); err != nil {
+			return nil, fmt.Errorf("tool %s: extract: %w", m.Name, err)
+		}
+	}
+	maxOut := m.MaxOutput
+	if maxOut <= 0 {
+		maxOut = defaultOpenAPIMaxOutput
+	}
+
+	tl := NewWithSchema(m.Name, m.Description, schema, func(ctx context.Context, raw map[string]any) (string, error) {
+		args, err := prepareArgs(schema, raw)
+		if err != nil {
+			return "", err
+		}
+		used := map[string]bool{}
+		target, complete := expandURL(m.HTTP, args, used)
+		if !complete {
+			return "", fmt.Errorf("url %s needs arguments that were not given", m.HTTP)
+		}
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		for k, tmpl := range m.Query {
+			if v, ok := expandTemplate(tmpl, args, identity, used); ok {
+				query.Set(k, v)
+			}
+		}
+		headers := http.Header{}
+		for k, tmpl := range m.Headers {
+			// $VAR references in the manifest are expanded, never in arguments.
+			v, _ := expandTemplate(os.ExpandEnv(tmpl), args, identity, used)
+			if strings.ContainsAny(v, "\r\n") {
+				return "", fmt.Errorf("header %s: value must not contain line breaks", k)
+			}
+			headers.Set(k, v)
+		}
+		rest := map[string]any{}
+		for k, v := range args {
+			if !used[k] {
+				rest[k] = v
+			}
+		}
+
+		var body io.Reader
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete:
+			for k, v := range rest {
+				query.Set(k, paramString(v))
+			}
+		default:
+			b, err := json.Marshal(rest)
+			if err != nil {
+				return "", err
+			}
+			body = bytes.NewReader(b)
+			if headers.Get("Content-Type") == "" {
+				headers.Set("Content-Type", "application/json")
+			}
+		}
+		u.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+		if err != nil {
+			return "", err
+		}
+		req.Header = headers
+		if req.Header.Get("User-Agent") == "" {
+			req.Header.Set("User-Agent", "Agentry/1.0")
+		}
+		applyCredential(req, cred)
+
+		rctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		resp, err := httpx.Default().Do(req.WithContext(rctx))
+		if err != nil {
+			return "", fmt.Errorf("request failed: %w", err)
+		}
+		rb := resp.Body
+		if resp.StatusCode >= 400 {
+			return "", fmt.Errorf("%s %s: %s: %s", method, m.HTTP, resp.Status, strings.TrimSpace(truncateOutput(string(rb), 2048)))
+		}
+		if extract == nil {
+			return truncateText(string(rb), maxOut, m.Truncate), nil
+		}
+		var doc any
+		if err := json.Unmarshal(rb, &doc); err != nil {
+			return "", fmt.Errorf("extract %s: response is not JSON", m.Extract)
+		}
+		v, err := evalJSONPath(doc, extract)
+		if err != nil {
+			return "", fmt.Errorf("extract %s: %w", m.Extract, err)
+		}
+		if s, ok := v.(string); ok {
+			return truncateText(s, maxOut, m.Truncate), nil
+		}
+		b, _ := json.Marshal(v)
+		return truncateText(string(b), maxOut, m.Truncate), nil
+	})
+	tl.(*simpleTool).allowed = manifestAllowed(m)
+	return tl, nil
+}
+
+// applyCredential authenticates req from a credentials entry when the API
+// declares no scheme of its own: token becomes a bearer token,
+// username/password basic auth, and api_key the header named by header
+// (default X-API-Key). Values may reference environment variables as $VAR.
+func applyCredential(req *http.Request, cred map[string]string) {
+	get := func(k string) string { return os.ExpandEnv(cred[k]) }
+	switch {
+	case get("token") != "":
+		req.Header.Set("Authorization", "Bearer "+get("token"))
+	case get("username") != "":
+		req.SetBasicAuth(get("username"), get("password"))
+	case get("api_key") != "":
+		header := cred["header"]
+		if header == "" {
+			header = "X-API-Key"
+		}
+		req.Header.Set(header, get("api_key"))
+	}
+}
+
+// pathStep is one step of a JSON path: a field name, an index, or a
+// wildcard over every element.
+type pathStep struct {
+	field string
+	index int
+	all   bool
+}
+
+// parseJSONPath accepts dotted paths with optional brackets, e.g.
+// $.data.items[0].name, items[*].id or items.*.id.
+func parseJSONPath(p string) ([]pathStep, error) {
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "$"), ".")
+	var steps []pathStep
+	for p != "" {
+		switch {
+		case p[0] == '.':
+			p = p[1:]
+		case p[0] == '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, errors.New("unclosed [")
+			}
+			inner := strings.Trim(p[1:end], `'"`)
+			p = p[end+1:]
+			if inner == "*" {
+				steps = append(steps, pathStep{all: true})
+			} else if n, err := strconv.Atoi(inner); err == nil {
+				steps = append(steps, pathStep{index: n})
+			} else {
+				steps = append(steps, pathStep{field: inner, index: -1})
+			}
+		default:
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			name := p[:end]
+			p = p[end:]
+			if name == "*" {
+				steps = append(steps, pathStep{all: true})
+			} else {
+				steps = append(steps, pathStep{field: name, index: -1})
+			}
+		}
+	}
+	return steps, nil
+}
+
+func evalJSONPath(v any, steps []pathStep) (any, error) {
+	for i, s := range steps {
+		switch {
+		case s.all:
+			var items []any
+			switch x := v.(type) {
+			case []any:
+				items = x
+			case map[string]any:
+				keys := make([]string, 0, len(x))
+				for k := range x {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					items = append(items, x[k])
+				}
+			default:
+				return nil, fmt.Errorf("cannot iterate over %T", v)
+			}
+			out := make([]any, 0, len(items))
+			for _, it := range items {
+				r, err := evalJSONPath(it, steps[i+1:])
+				if err != nil {
+					continue
+				}
+				out = append(out, r)
+			}
+			return out, nil
+		case s.index >= 0 && s.field == "":
+			arr, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("[%d]: not an array", s.index)
+			}
+			if s.index >= len(arr) {
+				return nil, fmt.Errorf("[%d]: index out of range (%d items)", s.index, len(arr))
+			}
+			v = arr[s.index]
+		default:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: not an object", s.field)
+			}
+			if v, ok = obj[s.field]; !ok {
+				return nil, fmt.Errorf("%s: no such field", s.field)
+			}
+		}
+	}
+	return v, nil
+}
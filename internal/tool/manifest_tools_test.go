@@ -0,0 +1,142 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/config"
+)
+
+func TestCommandManifestArgs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX printf")
+	}
+	ctx := context.Background()
+	args := map[string]any{
+		"text":  map[string]any{"type": "string", "required": true},
+		"count": map[string]any{"type": "integer", "default": 2},
+		"extra": "string",
+	}
+
+	// argv elements are single arguments: no shell, no word splitting.
+	argvTool, err := FromManifest(config.ToolManifest{
+		Name: "say", Args: args,
+		Argv: []string{"printf", "%s|", "{{text}}", "n={{count}}", "--extra={{extra}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := argvTool.JSONSchema()["required"]; len(req.([]string)) != 1 {
+		t.Fatalf("schema: %v", argvTool.JSONSchema())
+	}
+	out, err := argvTool.Execute(ctx, map[string]any{"text": "a b; echo pwned $(id)"})
+	if err != nil || out != "a b; echo pwned $(id)|n=2|" {
+		t.Fatalf("argv: %q %v", out, err)
+	}
+	if _, err := argvTool.Execute(ctx, map[string]any{}); err == nil || !strings.Contains(err.Error(), "text") {
+		t.Fatalf("expected missing argument error, got %v", err)
+	}
+
+	// Shell commands get each argument quoted.
+	shellTool, err := FromManifest(config.ToolManifest{
+		Name: "say_sh", Args: args, Command: "printf '%s|' {{text}} {{count}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = shellTool.Execute(ctx, map[string]any{"text": "it's; echo pwned", "count": 3.0})
+	if err != nil || out != "it's; echo pwned|3|" {
+		t.Fatalf("shell: %q %v", out, err)
+	}
+
+	slow, _ := FromManifest(config.ToolManifest{Name: "slow", Argv: []string{"sleep", "5"}, Timeout: "50ms"})
+	if _, err := slow.Execute(ctx, nil); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	for _, cmd := range []string{`echo "{{text}}"`, "echo `{{text}}`", `echo '{{text}}'`, `echo \{{text}}`} {
+		if _, err := FromManifest(config.ToolManifest{Name: "quoted", Args: args, Command: cmd}); err == nil {
+			t.Errorf("%s: a quoted placeholder should be rejected", cmd)
+		}
+	}
+	if _, err := FromManifest(config.ToolManifest{Name: "both", Command: "true", Argv: []string{"true"}}); err == nil {
+		t.Fatal("command and argv together should be rejected")
+	}
+}
+
+func TestHTTPManifest(t *testing.T) {
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got, body = r, string(b)
+		if r.URL.Path == "/missing" {
+			http.Error(w, "gone", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data":{"items":[{"id":1,"name":"a"},{"id":2,"name":"b"}]}}`)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	creds := map[string]map[string]string{"svc": {"token": "$SVC_TOKEN"}}
+	t.Setenv("SVC_TOKEN", "tok")
+
+	search, err := FromManifestWithCredentials(config.ToolManifest{
+		Name:    "search",
+		HTTP:    srv.URL + "/repos/{{owner}}/items",
+		Method:  "get",
+		Args:    map[string]any{"owner": map[string]any{"required": true}, "q": "string", "limit": "integer"},
+		Query:   map[string]string{"search": "{{q}}"},
+		Headers: map[string]string{"X-Owner": "{{owner}}"},
+		Auth:    "svc",
+		Extract: "$.data.items[*].name",
+	}, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := search.Execute(ctx, map[string]any{"owner": "a/b c", "limit": 5.0})
+	if err != nil || out != `["a","b"]` {
+		t.Fatalf("search: %q %v", out, err)
+	}
+	if got.Method != http.MethodGet || got.URL.EscapedPath() != "/repos/a%2Fb%20c/items" {
+		t.Fatalf("unexpected request %s %s", got.Method, got.URL.EscapedPath())
+	}
+	if q := got.URL.Query(); q.Has("search") || q.Get("limit") != "5" {
+		t.Fatalf("query: %v", q)
+	}
+	if got.Header.Get("Authorization") != "Bearer tok" || got.Header.Get("X-Owner") != "a/b c" {
+		t.Fatalf("headers: %v", got.Header)
+	}
+
+	inline, _ := FromManifest(config.ToolManifest{Name: "inline", HTTP: srv.URL + "/items?q={{q}}", Method: "GET"})
+	if _, err := inline.Execute(ctx, map[string]any{"q": "a&admin=1"}); err != nil {
+		t.Fatal(err)
+	}
+	if q := got.URL.Query(); q.Get("q") != "a&admin=1" || q.Has("admin") {
+		t.Fatalf("query value escaped wrongly: %v", q)
+	}
+
+	create, err := FromManifest(config.ToolManifest{Name: "create", HTTP: srv.URL + "/items", Extract: "data.items[1].id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = create.Execute(ctx, map[string]any{"name": "x"})
+	var sent map[string]any
+	if err != nil || out != "2" || json.Unmarshal([]byte(body), &sent) != nil || sent["name"] != "x" || got.Method != http.MethodPost {
+		t.Fatalf("create: %q %v body=%q", out, err, body)
+	}
+
+	missing, _ := FromManifest(config.ToolManifest{Name: "missing", HTTP: srv.URL + "/missing", Method: "GET"})
+	if _, err := missing.Execute(ctx, nil); err == nil || !strings.Contains(err.Error(), "gone") {
+		t.Fatalf("expected HTTP error, got %v", err)
+	}
+	if _, err := FromManifestWithCredentials(config.ToolManifest{Name: "x", HTTP: srv.URL, Auth: "nope"}, creds); err == nil {
+		t.Fatal("expected error for unknown credentials")
+	}
+}
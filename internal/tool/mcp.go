@@ -0,0 +1,190 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/marcodenic/agentry/internal/mcp"
+)
+
+// mcpClients holds the servers connected by RegisterMCPServers so the mcp
+// builtin can reach their resources and prompts.
+var (
+	mcpMu      sync.RWMutex
+	mcpClients = map[string]*mcp.Client{}
+)
+
+var unsafeToolChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// MCPToolName returns the registry name for tool on server: server__tool,
+// limited to the characters and length model APIs accept for functions.
+func MCPToolName(server, tool string) string {
+	name := unsafeToolChars.ReplaceAllString(server, "_") + "__" + unsafeToolChars.ReplaceAllString(tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// RegisterMCPServers connects to each server (name -> URL or command line),
+// lists its tools and adds them to reg under namespaced names using the
+// server's own input schemas. Servers that fail are skipped; their errors
+// are joined in the result.
+func RegisterMCPServers(ctx context.Context, reg Registry, servers map[string]string) error {
+	names := make([]string, 0, len(servers))
+	for n := range servers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		c := mcp.NewClient(name, servers[name])
+		tools, err := c.ListTools(ctx)
+		if err != nil {
+			_ = c.Close()
+			errs = append(errs, err)
+			continue
+		}
+		for _, t := range tools {
+			reg[MCPToolName(name, t.Name)] = newMCPTool(c, t)
+		}
+		mcpMu.Lock()
+		if old := mcpClients[name]; old != nil {
+			_ = old.Close()
+		}
+		mcpClients[name] = c
+		mcpMu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// CloseMCPServers disconnects every registered server.
+func CloseMCPServers() {
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+	for name, c := range mcpClients {
+		_ = c.Close()
+		delete(mcpClients, name)
+	}
+}
+
+func newMCPTool(c *mcp.Client, t mcp.Tool) Tool {
+	schema := t.InputSchema
+	if schema == nil {
+		schema = map[string]any{}
+	}
+	if _, ok := schema["type"]; !ok {
+		schema["type"] = "object"
+	}
+	desc := t.Description
+	if desc == "" {
+		desc = t.Name
+	}
+	desc = fmt.Sprintf("[MCP %s] %s", c.Name, desc)
+	// The server's structured content becomes the Result's data.
+	call := func(ctx context.Context, args map[string]any) (Result, error) {
+		res, err := c.CallTool(ctx, t.Name, args)
+		if err != nil {
+			return Result{}, err
+		}
+		if res.IsError {
+			return Result{}, errors.New(res.Text())
+		}
+		return Result{Text: res.Text(), Data: res.StructuredContent}, nil
+	}
+	return &simpleTool{name: MCPToolName(c.Name, t.Name), desc: desc, schema: schema, fn: textOf(call), result: call, allowed: true}
+}
+
+func mcpClient(name string) (*mcp.Client, error) {
+	mcpMu.RLock()
+	defer mcpMu.RUnlock()
+	if c, ok := mcpClients[name]; ok {
+		return c, nil
+	}
+	known := make([]string, 0, len(mcpClients))
+	for n := range mcpClients {
+		known = append(known, n)
+	}
+	sort.Strings(known)
+	if len(known) == 0 {
+		return nil, errors.New("no MCP servers configured (set mcp_servers in .agentry.yaml or pass --mcp)")
+	}
+	return nil, fmt.Errorf("unknown MCP server %q (connected: %s)", name, strings.Join(known, ", "))
+}
+
+// mcpExec implements the mcp builtin: generic access to a connected
+// server's tools, resources and prompts.
+func mcpExec(ctx context.Context, args map[string]any) (string, error) {
+	c, err := mcpClient(strArg(args, "server"))
+	if err != nil {
+		return "", err
+	}
+	switch action := strArg(args, "action"); action {
+	case "list_tools":
+		tools, err := c.ListTools(ctx)
+		if err != nil {
+			return "", err
+		}
+		return marshal(tools)
+	case "call_tool":
+		name := strArg(args, "name")
+		if name == "" {
+			return "", errors.New("name is required for call_tool")
+		}
+		toolArgs, _ := args["arguments"].(map[string]any)
+		res, err := c.CallTool(ctx, name, toolArgs)
+		if err != nil {
+			return "", err
+		}
+		if res.IsError {
+			return "", errors.New(res.Text())
+		}
+		return res.Text(), nil
+	case "list_resources":
+		res, err := c.ListResources(ctx)
+		if err != nil {
+			return "", err
+		}
+		return marshal(res)
+	case "read_resource":
+		uri := strArg(args, "uri")
+		if uri == "" {
+			return "", errors.New("uri is required for read_resource")
+		}
+		res, err := c.ReadResource(ctx, uri)
+		if err != nil {
+			return "", err
+		}
+		return marshal(res.Contents)
+	case "list_prompts":
+		res, err := c.ListPrompts(ctx)
+		if err != nil {
+			return "", err
+		}
+		return marshal(res)
+	case "get_prompt":
+		name := strArg(args, "name")
+		if name == "" {
+			return "", errors.New("name is required for get_prompt")
+		}
+		promptArgs := map[string]string{}
+		if m, ok := args["arguments"].(map[string]any); ok {
+			for k, v := range m {
+				promptArgs[k] = fmt.Sprint(v)
+			}
+		}
+		res, err := c.GetPrompt(ctx, name, promptArgs)
+		if err != nil {
+			return "", err
+		}
+		return marshal(res)
+	default:
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+}
@@ -0,0 +1,57 @@
+package tool
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/mcp/mcptest"
+)
+
+func TestRegisterMCPServers(t *testing.T) {
+	srv := httptest.NewServer(mcptest.New())
+	defer srv.Close()
+	defer CloseMCPServers()
+
+	reg := Registry{}
+	err := RegisterMCPServers(context.Background(), reg, map[string]string{
+		"fake": srv.URL,
+		"down": "http://127.0.0.1:1/mcp",
+	})
+	if err == nil || !strings.Contains(err.Error(), "down") {
+		t.Fatalf("expected error naming the unreachable server, got %v", err)
+	}
+
+	echo, ok := reg["fake__echo"]
+	if !ok {
+		t.Fatalf("namespaced tool missing; registry has %v", reg)
+	}
+	if echo.JSONSchema()["required"] == nil {
+		t.Fatalf("server schema not used: %v", echo.JSONSchema())
+	}
+	out, err := echo.Execute(context.Background(), map[string]any{"text": "hi"})
+	if err != nil || out != "hi" {
+		t.Fatalf("echo: %q %v", out, err)
+	}
+	if _, err := reg["fake__fail"].Execute(context.Background(), nil); err == nil {
+		t.Fatal("tool errors should surface as errors")
+	}
+
+	out, err = mcpExec(context.Background(), map[string]any{"server": "fake", "action": "read_resource", "uri": "mem://greeting"})
+	if err != nil || !strings.Contains(out, "hello from mcptest") {
+		t.Fatalf("read_resource: %q %v", out, err)
+	}
+	if _, err := mcpExec(context.Background(), map[string]any{"server": "down", "action": "list_tools"}); err == nil {
+		t.Fatal("expected unknown server error")
+	}
+}
+
+func TestMCPToolName(t *testing.T) {
+	if got := MCPToolName("git hub", "repo.search"); got != "git_hub__repo_search" {
+		t.Fatalf("got %q", got)
+	}
+	if got := MCPToolName(strings.Repeat("s", 40), strings.Repeat("t", 40)); len(got) != 64 {
+		t.Fatalf("expected name capped at 64, got %d", len(got))
+	}
+}
@@ -0,0 +1,661 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/config"
+	"github.com/marcodenic/agentry/internal/httpx"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultOpenAPIMaxOutput is the response size kept when a manifest sets
+	// no max_output.
+	defaultOpenAPIMaxOutput = 16 << 10
+	openAPITimeout          = 60 * time.Second
+	// maxRefDepth bounds $ref inlining so recursive schemas terminate.
+	maxRefDepth = 6
+)
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+type oaDoc struct {
+	Servers    []oaServer                            `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Security   []map[string][]string                 `json:"security"`
+	Components struct {
+		SecuritySchemes map[string]oaSecurityScheme `json:"securitySchemes"`
+	} `json:"components"`
+}
+
+type oaServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	Variables   map[string]struct {
+		Default string `json:"default"`
+	} `json:"variables"`
+}
+
+type oaSecurityScheme struct {
+	Type   string `json:"type"`   // http, apiKey, oauth2, openIdConnect
+	Scheme string `json:"scheme"` // bearer, basic (type http)
+	In     string `json:"in"`     // header, query, cookie (type apiKey)
+	Name   string `json:"name"`
+}
+
+type oaParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"` // path, query, header, cookie
+	Description string         `json:"description"`
+	Required    bool           `json:"required"`
+	Schema      map[string]any `json:"schema"`
+}
+
+type oaOperation struct {
+	OperationID string        `json:"operationId"`
+	Summary     string        `json:"summary"`
+	Description string        `json:"description"`
+	Tags        []string      `json:"tags"`
+	Parameters  []oaParameter `json:"parameters"`
+	RequestBody *struct {
+		Description string `json:"description"`
+		Required    bool   `json:"required"`
+		Content     map[string]struct {
+			Schema map[string]any `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Security *[]map[string][]string `json:"security"`
+}
+
+// openAPIOp is everything needed to execute one operation.
+type openAPIOp struct {
+	method, path string
+	params       []oaParameter
+	// bodyType is the request media type; bodyArg is the argument holding
+	// the body, or "" when the body's properties are top-level arguments.
+	bodyType, bodyArg string
+	bodyProps         []string
+	hasBody           bool
+	security          []map[string][]string
+}
+
+// FromOpenAPI reads the OpenAPI 3 spec named by m.OpenAPI and returns one
+// tool per operation, keeping only operations tagged with one of m.Tags or
+// listed in m.Operations when those are set. Tools are named after the
+// operationId (or method and path), prefixed with m.Name when it is set.
+// Requests authenticate with creds[m.Auth], or creds[m.Name].
+func FromOpenAPI(ctx context.Context, m config.ToolManifest, creds map[string]map[string]string) (Registry, error) {
+	policy, err := execPolicyFromManifest(m)
+	if err != nil {
+		return nil, err
+	}
+	// Responses are truncated as they are read (see maxOut).
+	policy.MaxOutput = 0
+	raw, base, err := loadOpenAPI(ctx, m.OpenAPI)
+	if err != nil {
+		return nil, fmt.Errorf("openapi %s: %w", m.OpenAPI, err)
+	}
+	// Only paths are inlined; components are reached through them.
+	inlined := map[string]any{}
+	for k, v := range raw {
+		inlined[k] = v
+	}
+	inlined["paths"] = inlineRefs(raw, raw["paths"], 0)
+	b, err := json.Marshal(inlineRefs(raw, inlined, maxRefDepth))
+	if err != nil {
+		return nil, fmt.Errorf("openapi %s: %w", m.OpenAPI, err)
+	}
+	var doc oaDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("openapi %s: %w", m.OpenAPI, err)
+	}
+	server, err := selectServer(doc.Servers, m.Server, base)
+	if err != nil {
+		return nil, fmt.Errorf("openapi %s: %w", m.OpenAPI, err)
+	}
+	credName := m.Auth
+	if credName == "" {
+		credName = m.Name
+	}
+	cred := creds[credName]
+	if m.Auth != "" && cred == nil {
+		return nil, fmt.Errorf("openapi %s: no credentials named %q", m.OpenAPI, m.Auth)
+	}
+	maxOut := m.MaxOutput
+	if maxOut <= 0 {
+		maxOut = defaultOpenAPIMaxOutput
+	}
+	allowed := true
+	if m.Permissions.Allow != nil {
+		allowed = *m.Permissions.Allow
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	reg := Registry{}
+	for _, p := range paths {
+		item := doc.Paths[p]
+		var shared []oaParameter
+		if rawParams, ok := item["parameters"]; ok {
+			_ = json.Unmarshal(rawParams, &shared)
+		}
+		for _, method := range httpMethods {
+			rawOp, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op oaOperation
+			if err := json.Unmarshal(rawOp, &op); err != nil {
+				return nil, fmt.Errorf("openapi %s: %s %s: %w", m.OpenAPI, strings.ToUpper(method), p, err)
+			}
+			if !matchesFilter(op, m.Tags, m.Operations) {
+				continue
+			}
+			id := op.OperationID
+			if id == "" {
+				id = method + "_" + strings.Trim(p, "/")
+			}
+			name := unsafeToolChars.ReplaceAllString(id, "_")
+			if m.Name != "" {
+				name = MCPToolName(m.Name, id)
+			}
+			exec, schema := buildOpenAPIOp(method, p, mergeParams(shared, op.Parameters), op, doc.Security)
+			desc := firstNonEmpty(op.Summary, op.Description, strings.ToUpper(method)+" "+p)
+			if m.Description != "" {
+				desc = m.Description + ": " + desc
+			}
+			tl := NewWithSchema(name, desc, schema, func(ctx context.Context, args map[string]any) (string, error) {
+				return exec.do(ctx, server, doc.Components.SecuritySchemes, cred, maxOut, args)
+			})
+			tl.(*simpleTool).allowed = allowed
+			tl.(*simpleTool).policy = policy
+			reg[name] = tl
+		}
+	}
+	if len(reg) == 0 {
+		return nil, fmt.Errorf("openapi %s: no operations matched", m.OpenAPI)
+	}
+	return reg, nil
+}
+
+// loadOpenAPI reads a YAML or JSON spec from a file or http(s) URL. base is
+// the URL the spec was fetched from, used to resolve relative server URLs.
+func loadOpenAPI(ctx context.Context, src string) (map[string]any, *url.URL, error) {
+	if src == "" {
+		return nil, nil, errors.New("spec path or URL required")
+	}
+	var data []byte
+	var base *url.URL
+	if u, err := url.Parse(src); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		rctx, cancel := context.WithTimeout(ctx, openAPITimeout)
+		defer cancel()
+		resp, err := httpx.Default().Do(req.WithContext(rctx))
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp.StatusCode >= 300 {
+			return nil, nil, fmt.Errorf("fetch: %s", resp.Status)
+		}
+		if resp.Truncated {
+			return nil, nil, fmt.Errorf("fetch: spec larger than the %d byte network.max_body", len(resp.Body))
+		}
+		data = resp.Body
+		base = u
+	} else {
+		var err error
+		if data, err = os.ReadFile(src); err != nil {
+			return nil, nil, err
+		}
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if v := fmt.Sprint(doc["openapi"]); !strings.HasPrefix(v, "3.") {
+		return nil, nil, fmt.Errorf("not an OpenAPI 3 document (openapi: %v)", doc["openapi"])
+	}
+	return doc, base, nil
+}
+
+// inlineRefs replaces local "#/..." references with their targets and
+// normalizes map keys to strings. Deeply nested or recursive references
+// become a plain object schema.
+func inlineRefs(root map[string]any, v any, depth int) any {
+	switch x := v.(type) {
+	case map[string]any:
+		if ref, ok := x["$ref"].(string); ok {
+			if depth >= maxRefDepth || !strings.HasPrefix(ref, "#/") {
+				return map[string]any{"type": "object"}
+			}
+			target, ok := lookupPointer(root, ref)
+			if !ok {
+				return map[string]any{"type": "object"}
+			}
+			return inlineRefs(root, target, depth+1)
+		}
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = inlineRefs(root, e, depth)
+		}
+		return out
+	case map[any]any: // YAML mappings with non-string keys, e.g. 200:
+		m := make(map[string]any, len(x))
+		for k, e := range x {
+			m[fmt.Sprint(k)] = e
+		}
+		return inlineRefs(root, m, depth)
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = inlineRefs(root, e, depth)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func lookupPointer(root map[string]any, ref string) (any, bool) {
+	var cur any = root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// selectServer picks the base URL: an explicit URL, an index or description
+// match from want, or the spec's first server. Server variables take their
+// defaults and relative URLs resolve against the spec's own URL.
+func selectServer(servers []oaServer, want string, base *url.URL) (string, error) {
+	var chosen *oaServer
+	switch {
+	case strings.HasPrefix(want, "http://") || strings.HasPrefix(want, "https://"):
+		return strings.TrimRight(want, "/"), nil
+	case want != "":
+		if i, err := strconv.Atoi(want); err == nil {
+			if i < 0 || i >= len(servers) {
+				return "", fmt.Errorf("server index %d out of range (%d servers)", i, len(servers))
+			}
+			chosen = &servers[i]
+			break
+		}
+		for i := range servers {
+			if strings.Contains(strings.ToLower(servers[i].Description+" "+servers[i].URL), strings.ToLower(want)) {
+				chosen = &servers[i]
+				break
+			}
+		}
+		if chosen == nil {
+			return "", fmt.Errorf("no server matches %q", want)
+		}
+	case len(servers) > 0:
+		chosen = &servers[0]
+	default:
+		if base == nil {
+			return "", errors.New("spec lists no servers; set server: in the manifest")
+		}
+		return base.Scheme + "://" + base.Host, nil
+	}
+	u := chosen.URL
+	for k, v := range chosen.Variables {
+		u = strings.ReplaceAll(u, "{"+k+"}", v.Default)
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	if !parsed.IsAbs() {
+		if base == nil {
+			return "", fmt.Errorf("server %q is relative; set server: in the manifest", u)
+		}
+		parsed = base.ResolveReference(parsed)
+	}
+	return strings.TrimRight(parsed.String(), "/"), nil
+}
+
+func matchesFilter(op oaOperation, tags, ops []string) bool {
+	if len(tags) == 0 && len(ops) == 0 {
+		return true
+	}
+	for _, id := range ops {
+		if id == op.OperationID {
+			return true
+		}
+	}
+	for _, want := range tags {
+		for _, t := range op.Tags {
+			if strings.EqualFold(t, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mergeParams applies operation parameters over path-level ones.
+func mergeParams(shared, own []oaParameter) []oaParameter {
+	out := append([]oaParameter(nil), own...)
+	for _, s := range shared {
+		dup := false
+		for _, o := range own {
+			if o.Name == s.Name && o.In == s.In {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// buildOpenAPIOp derives the tool schema for an operation. Parameters
+// become top-level arguments; an object request body contributes its
+// properties too unless a name collides, in which case it is passed as
+// "body".
+func buildOpenAPIOp(method, path string, params []oaParameter, op oaOperation, global []map[string][]string) (*openAPIOp, map[string]any) {
+	o := &openAPIOp{method: strings.ToUpper(method), path: path, params: params, security: global}
+	if op.Security != nil {
+		o.security = *op.Security
+	}
+	props := map[string]any{}
+	var required []string
+	for _, p := range params {
+		s := map[string]any{"type": "string"}
+		if p.Schema != nil {
+			s = copySchema(p.Schema)
+		}
+		if p.Description != "" {
+			s["description"] = p.Description
+		}
+		props[p.Name] = s
+		if p.Required || p.In == "path" {
+			required = append(required, p.Name)
+		}
+	}
+
+	if rb := op.RequestBody; rb != nil && len(rb.Content) > 0 {
+		o.hasBody = true
+		o.bodyType = pickMediaType(rb.Content)
+		schema := rb.Content[o.bodyType].Schema
+		bodyProps, _ := schema["properties"].(map[string]any)
+		flat := len(bodyProps) > 0
+		for k := range bodyProps {
+			if _, clash := props[k]; clash {
+				flat = false
+			}
+		}
+		if flat {
+			for k, v := range bodyProps {
+				props[k] = v
+				o.bodyProps = append(o.bodyProps, k)
+			}
+			if rb.Required {
+				if req, ok := schema["required"].([]any); ok {
+					for _, r := range req {
+						if s, ok := r.(string); ok {
+							required = append(required, s)
+						}
+					}
+				}
+			}
+		} else {
+			o.bodyArg = "body"
+			if _, clash := props["body"]; clash {
+				o.bodyArg = "request_body"
+			}
+			s := map[string]any{"description": firstNonEmpty(rb.Description, "Request body ("+o.bodyType+")")}
+			if schema != nil {
+				s = copySchema(schema)
+				if _, ok := s["description"]; !ok && rb.Description != "" {
+					s["description"] = rb.Description
+				}
+			}
+			props[o.bodyArg] = s
+			if rb.Required {
+				required = append(required, o.bodyArg)
+			}
+		}
+	}
+	sort.Strings(required)
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return o, schema
+}
+
+func copySchema(s map[string]any) map[string]any {
+	out := make(map[string]any, len(s)+1)
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+// pickMediaType prefers JSON, then form encoding, then whatever is listed.
+func pickMediaType(content map[string]struct {
+	Schema map[string]any `json:"schema"`
+}) string {
+	types := make([]string, 0, len(content))
+	for t := range content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		if t == "application/json" || strings.HasSuffix(t, "+json") {
+			return t
+		}
+	}
+	for _, t := range types {
+		if t == "application/x-www-form-urlencoded" {
+			return t
+		}
+	}
+	return types[0]
+}
+
+func (o *openAPIOp) do(ctx context.Context, server string, schemes map[string]oaSecurityScheme, cred map[string]string, maxOut int, args map[string]any) (string, error) {
+	path := o.path
+	query := url.Values{}
+	headers := http.Header{}
+	var cookies []*http.Cookie
+	for _, p := range o.params {
+		v, ok := args[p.Name]
+		if !ok || v == nil {
+			if p.Required || p.In == "path" {
+				return "", fmt.Errorf("missing required %s parameter %q", p.In, p.Name)
+			}
+			continue
+		}
+		switch p.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(paramString(v)))
+		case "query":
+			if list, ok := v.([]any); ok {
+				for _, e := range list {
+					query.Add(p.Name, paramString(e))
+				}
+			} else {
+				query.Set(p.Name, paramString(v))
+			}
+		case "header":
+			headers.Set(p.Name, paramString(v))
+		case "cookie":
+			cookies = append(cookies, &http.Cookie{Name: p.Name, Value: paramString(v)})
+		}
+	}
+
+	var body io.Reader
+	if o.hasBody {
+		var payload any
+		if o.bodyArg != "" {
+			payload = args[o.bodyArg]
+		} else {
+			obj := map[string]any{}
+			for _, k := range o.bodyProps {
+				if v, ok := args[k]; ok {
+					obj[k] = v
+				}
+			}
+			if len(obj) > 0 {
+				payload = obj
+			}
+		}
+		if payload != nil {
+			b, err := encodeBody(o.bodyType, payload)
+			if err != nil {
+				return "", err
+			}
+			body = bytes.NewReader(b)
+			headers.Set("Content-Type", o.bodyType)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, o.method, server+path, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header = headers
+	req.Header.Set("User-Agent", "Agentry/1.0")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	applyOpenAPIAuth(req, query, o.security, schemes, cred)
+	if len(query) > 0 {
+		req.URL.RawQuery = query.Encode()
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, openAPITimeout)
+	defer cancel()
+	resp, err := httpx.Default().Do(req.WithContext(rctx))
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	rb := resp.Body
+	out := string(rb)
+	if len(rb) > maxOut {
+		out = string(rb[:maxOut]) + fmt.Sprintf("\n... [response truncated at %d bytes]", maxOut)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("%s %s: %s: %s", o.method, path, resp.Status, strings.TrimSpace(out))
+	}
+	return out, nil
+}
+
+func encodeBody(mediaType string, payload any) ([]byte, error) {
+	if s, ok := payload.(string); ok && !strings.Contains(mediaType, "json") {
+		return []byte(s), nil
+	}
+	if mediaType == "application/x-www-form-urlencoded" {
+		obj, ok := payload.(map[string]any)
+		if !ok {
+			return nil, errors.New("form body must be an object")
+		}
+		form := url.Values{}
+		for k, v := range obj {
+			form.Set(k, paramString(v))
+		}
+		return []byte(form.Encode()), nil
+	}
+	return json.Marshal(payload)
+}
+
+// paramString formats a scalar argument for a URL, header or form field.
+func paramString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case nil:
+		return ""
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+// applyOpenAPIAuth authenticates req from a credentials entry. Keys:
+// token (bearer/oauth2), username+password (basic) and api_key (apiKey
+// schemes, or an X-API-Key header when the spec declares none). Values may
+// reference environment variables as $VAR. Without a usable scheme the
+// credential is applied as by applyCredential.
+func applyOpenAPIAuth(req *http.Request, query url.Values, security []map[string][]string, schemes map[string]oaSecurityScheme, cred map[string]string) {
+	if len(cred) == 0 {
+		return
+	}
+	get := func(k string) string { return os.ExpandEnv(cred[k]) }
+	for _, requirement := range security {
+		names := make([]string, 0, len(requirement))
+		for n := range requirement {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		applied := false
+		for _, n := range names {
+			s := schemes[n]
+			switch {
+			case s.Type == "http" && strings.EqualFold(s.Scheme, "basic") && get("username") != "":
+				req.SetBasicAuth(get("username"), get("password"))
+				applied = true
+			case (s.Type == "http" || s.Type == "oauth2" || s.Type == "openIdConnect") && get("token") != "":
+				req.Header.Set("Authorization", "Bearer "+get("token"))
+				applied = true
+			case s.Type == "apiKey" && get("api_key") != "":
+				switch s.In {
+				case "query":
+					query.Set(s.Name, get("api_key"))
+				case "cookie":
+					req.AddCookie(&http.Cookie{Name: s.Name, Value: get("api_key")})
+				default:
+					req.Header.Set(s.Name, get("api_key"))
+				}
+				applied = true
+			}
+		}
+		if applied {
+			return
+		}
+	}
+	applyCredential(req, cred)
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/config"
+)
+
+const petSpec = `openapi: 3.0.3
+servers:
+  - url: https://prod.invalid
+    description: production
+  - url: "{base}"
+    description: local
+    variables:
+      base:
+        default: BASE
+security:
+  - key: []
+components:
+  securitySchemes:
+    key: {type: apiKey, in: header, name: X-Pet-Key}
+  schemas:
+    Pet:
+      type: object
+      properties:
+        name: {type: string}
+        tag: {type: string}
+      required: [name]
+paths:
+  /pets/{id}:
+    parameters:
+      - {name: id, in: path, required: true, schema: {type: integer}}
+    get:
+      operationId: getPet
+      tags: [pets]
+      parameters:
+        - {name: fields, in: query, schema: {type: array, items: {type: string}}}
+        - {name: X-Trace, in: header, schema: {type: string}}
+      responses:
+        200: {description: ok}
+  /pets:
+    post:
+      operationId: createPet
+      tags: [pets, admin]
+      summary: Create a pet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+      responses:
+        '201': {description: created}
+  /big:
+    get:
+      operationId: big
+      responses:
+        '200': {description: ok}
+`
+
+func TestFromOpenAPI(t *testing.T) {
+	var last *http.Request
+	var lastBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		last, lastBody = r, string(b)
+		switch {
+		case r.URL.Path == "/big":
+			io.WriteString(w, strings.Repeat("x", 100))
+		case r.URL.Path == "/pets/404":
+			http.Error(w, "no such pet", http.StatusNotFound)
+		default:
+			io.WriteString(w, `{"ok":true}`)
+		}
+	}))
+	defer srv.Close()
+
+	spec := filepath.Join(t.TempDir(), "pets.yaml")
+	if err := os.WriteFile(spec, []byte(strings.ReplaceAll(petSpec, "BASE", srv.URL)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	creds := map[string]map[string]string{"pets": {"api_key": "$PET_KEY"}}
+	t.Setenv("PET_KEY", "s3cret")
+	ctx := context.Background()
+
+	reg, err := FromOpenAPI(ctx, config.ToolManifest{Name: "pets", OpenAPI: spec, Server: "local", MaxOutput: 50}, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reg) != 3 {
+		t.Fatalf("expected 3 tools, got %v", reg)
+	}
+
+	get := reg["pets__getPet"]
+	if req := get.JSONSchema()["required"]; len(req.([]string)) != 1 {
+		t.Fatalf("path parameter should be required: %v", get.JSONSchema())
+	}
+	out, err := get.Execute(ctx, map[string]any{"id": 7.0, "fields": []any{"name", "tag"}, "X-Trace": "abc"})
+	if err != nil || out != `{"ok":true}` {
+		t.Fatalf("getPet: %q %v", out, err)
+	}
+	if last.Method != http.MethodGet || last.URL.Path != "/pets/7" || last.URL.RawQuery != "fields=name&fields=tag" {
+		t.Fatalf("unexpected request %s %s", last.Method, last.URL)
+	}
+	if last.Header.Get("X-Trace") != "abc" || last.Header.Get("X-Pet-Key") != "s3cret" {
+		t.Fatalf("headers not applied: %v", last.Header)
+	}
+	if _, err := get.Execute(ctx, map[string]any{"id": "404"}); err == nil || !strings.Contains(err.Error(), "no such pet") {
+		t.Fatalf("expected HTTP error with body, got %v", err)
+	}
+	if _, err := get.Execute(ctx, map[string]any{}); err == nil {
+		t.Fatal("missing path parameter should fail")
+	}
+
+	// An object body is flattened into top-level arguments.
+	create := reg["pets__createPet"]
+	if props := create.JSONSchema()["properties"].(map[string]any); props["name"] == nil {
+		t.Fatalf("body properties not flattened: %v", props)
+	}
+	if _, err := create.Execute(ctx, map[string]any{"name": "Rex"}); err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	if err := json.Unmarshal([]byte(lastBody), &body); err != nil || body["name"] != "Rex" || last.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected body %q (%v)", lastBody, last.Header)
+	}
+
+	out, err = reg["pets__big"].Execute(ctx, nil)
+	if err != nil || !strings.HasPrefix(out, strings.Repeat("x", 50)+"\n") || !strings.Contains(out, "truncated") {
+		t.Fatalf("response not truncated: %q %v", out, err)
+	}
+
+	// Filters by tag and operationId.
+	reg, err = FromOpenAPI(ctx, config.ToolManifest{OpenAPI: spec, Server: srv.URL, Tags: []string{"admin"}, Operations: []string{"big"}}, nil)
+	if err != nil || len(reg) != 2 || reg["createPet"] == nil || reg["big"] == nil {
+		t.Fatalf("filtered registry: %v %v", reg, err)
+	}
+	if _, err := FromOpenAPI(ctx, config.ToolManifest{OpenAPI: spec, Server: "staging"}, nil); err == nil {
+		t.Fatal("expected error for unknown server")
+	}
+}
+
+func TestFromOpenAPIExample(t *testing.T) {
+	reg, err := FromOpenAPI(context.Background(), config.ToolManifest{OpenAPI: "../../examples/echo-openapi.yaml"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo, ok := reg["echo"]
+	if !ok {
+		t.Fatalf("echo operation missing: %v", reg)
+	}
+	if req, _ := echo.JSONSchema()["required"].([]string); len(req) != 1 || req[0] != "text" {
+		t.Fatalf("unexpected schema %v", echo.JSONSchema())
+	}
+}
@@ -0,0 +1,166 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/marcodenic/agentry/internal/policy"
+	"github.com/marcodenic/agentry/internal/sandbox"
+)
+
+// Permissions is the set of tools an agent may run: an optional allowlist,
+// a denylist, the argument-level policy from .agentry.yaml and the sandbox
+// its commands run under. A Permissions value never changes after
+// construction, so agents running concurrently can each hold their own. A
+// nil *Permissions allows every tool.
+type Permissions struct {
+	allow   map[string]bool // nil: every tool not denied
+	deny    map[string]bool
+	policy  *policy.Engine
+	log     io.Writer // receives policy decisions; may be nil
+	sandbox sandbox.Policy
+}
+
+// NewPermissions returns permissions allowing the named tools (all tools
+// when allow is empty) and checking calls against engine, whose decisions
+// are written to log.
+func NewPermissions(allow []string, engine *policy.Engine, log io.Writer) *Permissions {
+	p := &Permissions{policy: engine, log: log}
+	if len(allow) > 0 {
+		p.allow = toSet(allow)
+	}
+	return p
+}
+
+func toSet(names []string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		m[n] = true
+	}
+	return m
+}
+
+// Allows reports whether the named tool may run (before argument checks).
+func (p *Permissions) Allows(name string) bool {
+	if p == nil {
+		return true
+	}
+	if p.deny[name] {
+		return false
+	}
+	return p.allow == nil || p.allow[name]
+}
+
+// Narrow returns permissions that allow only what p allows and, when allow
+// is non-empty, is also in allow; deny is added to the denylist. The
+// policy and sandbox carry over. Narrow never grants anything p does not.
+func (p *Permissions) Narrow(allow, deny []string) *Permissions {
+	out := &Permissions{}
+	if p != nil {
+		*out = Permissions{policy: p.policy, log: p.log, sandbox: p.sandbox}
+		if p.allow != nil {
+			out.allow = make(map[string]bool, len(p.allow))
+			for n := range p.allow {
+				out.allow[n] = true
+			}
+		}
+		out.deny = make(map[string]bool, len(p.deny)+len(deny))
+		for n := range p.deny {
+			out.deny[n] = true
+		}
+	}
+	if len(allow) > 0 {
+		want := toSet(allow)
+		if out.allow == nil {
+			out.allow = want
+		} else {
+			for n := range out.allow {
+				if !want[n] {
+					delete(out.allow, n)
+				}
+			}
+		}
+	}
+	if out.deny == nil && len(deny) > 0 {
+		out.deny = make(map[string]bool, len(deny))
+	}
+	for _, n := range deny {
+		out.deny[n] = true
+	}
+	return out
+}
+
+// Sandboxed returns a copy of p whose tools run commands under sb, such as
+// a tighter policy for a spawned agent.
+func (p *Permissions) Sandboxed(sb sandbox.Policy) *Permissions {
+	out := p.Narrow(nil, nil)
+	out.sandbox = sb
+	return out
+}
+
+// Sandbox returns the policy p's tools run commands under.
+func (p *Permissions) Sandbox() sandbox.Policy {
+	if p == nil {
+		return sandbox.Policy{}
+	}
+	return p.sandbox
+}
+
+// Allowed lists the allowlist, or returns nil when every tool not denied is
+// allowed.
+func (p *Permissions) Allowed() []string {
+	if p == nil || p.allow == nil {
+		return nil
+	}
+	names := make([]string, 0, len(p.allow))
+	for n := range p.allow {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply returns a registry whose tools check p before running and run their
+// commands under p's sandbox. Applying to a registry that is already
+// restricted only narrows the tools allowed; the sandbox applied first
+// stays in force.
+func (p *Permissions) Apply(reg Registry) Registry {
+	if p == nil {
+		return reg
+	}
+	if p.policy != nil {
+		reg = WrapWithPolicy(reg, p.policy, p.log)
+	}
+	out := make(Registry, len(reg))
+	for name, t := range reg {
+		var wrapped Tool = permittedTool{Tool: t, perms: p}
+		if ta, ok := t.(TerminalAware); ok && ta.Terminal() {
+			wrapped = MarkTerminal(wrapped)
+		}
+		out[name] = wrapped
+	}
+	return out
+}
+
+type permittedTool struct {
+	Tool
+	perms *Permissions
+}
+
+func (t permittedTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	r, err := t.ExecuteResult(ctx, args)
+	return r.Text, err
+}
+
+// ExecPolicy implements PolicyTool for the wrapped tool.
+func (t permittedTool) ExecPolicy() ExecPolicy { return PolicyOf(t.Tool) }
+
+// ExecuteResult implements ResultTool.
+func (t permittedTool) ExecuteResult(ctx context.Context, args map[string]any) (Result, error) {
+	if !t.perms.Allows(t.Name()) {
+		return Result{ErrorClass: ErrClassDenied}, fmt.Errorf("%w: %s", ErrToolDenied, t.Name())
+	}
+	return Run(WithSandbox(ctx, t.perms.sandbox), t.Tool, args)
+}
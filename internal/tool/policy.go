@@ -0,0 +1,131 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/policy"
+)
+
+// WrapWithPolicy checks every call to the tools in reg against e before it
+// runs and records each decision on w (the audit log; may be nil). Calls
+// the policy asks about run only if the Approver attached to the context
+// approves them.
+func WrapWithPolicy(reg Registry, e *policy.Engine, w io.Writer) Registry {
+	if e == nil {
+		return reg
+	}
+	out := Registry{}
+	for name, t := range reg {
+		var wrapped Tool = policyTool{Tool: t, engine: e, w: w}
+		if ta, ok := t.(TerminalAware); ok && ta.Terminal() {
+			wrapped = MarkTerminal(wrapped)
+		}
+		out[name] = wrapped
+	}
+	return out
+}
+
+// CommandLister is implemented by tools that run commands their arguments
+// do not spell out, so that command rules can match them.
+type CommandLister interface {
+	Commands(args map[string]any) []string
+}
+
+type policyTool struct {
+	Tool
+	engine *policy.Engine
+	w      io.Writer
+}
+
+func (p policyTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	r, err := p.ExecuteResult(ctx, args)
+	return r.Text, err
+}
+
+// ExecPolicy implements PolicyTool for the wrapped tool.
+func (p policyTool) ExecPolicy() ExecPolicy { return PolicyOf(p.Tool) }
+
+// ExecuteResult implements ResultTool.
+func (p policyTool) ExecuteResult(ctx context.Context, args map[string]any) (Result, error) {
+	call := policy.Call{Tool: p.Name(), Args: args}
+	if cl, ok := p.Tool.(CommandLister); ok {
+		call.Commands = cl.Commands(args)
+	}
+	if err := p.decide(ctx, call); err != nil {
+		return Result{ErrorClass: ErrClassDenied}, err
+	}
+	ctx = context.WithValue(ctx, policyCheckKey{}, func(ctx context.Context, paths []string) error {
+		return p.decide(ctx, policy.Call{Tool: p.Name(), Args: map[string]any{"paths": paths}})
+	})
+	return Run(ctx, p.Tool, args)
+}
+
+type policyCheckKey struct{}
+
+// checkPaths checks files a call is about to write that its arguments did
+// not name, such as those lsp_rename rewrites, against the policy of the
+// tool being called. Calls made without a policy may write anything.
+func checkPaths(ctx context.Context, paths []string) error {
+	if check, ok := ctx.Value(policyCheckKey{}).(func(context.Context, []string) error); ok {
+		return check(ctx, paths)
+	}
+	return nil
+}
+
+// decide evaluates call, asks the context's Approver when the policy says
+// to, and records the decision in the audit log.
+func (p policyTool) decide(ctx context.Context, call policy.Call) error {
+	d := p.engine.Evaluate(call)
+	evt := AuditEvent{
+		Tool:      call.Tool,
+		Args:      call.Args,
+		Decision:  string(d.Action),
+		Rule:      d.Rule,
+		Reason:    d.Reason,
+		Timestamp: time.Now().UTC(),
+	}
+	evt.Source, _ = ctx.Value(auditSourceKey{}).(string)
+
+	var err error
+	switch d.Action {
+	case policy.Deny:
+		err = fmt.Errorf("%w: %s blocked by policy%s", ErrToolDenied, call.Tool, describeDecision(d))
+	case policy.Ask:
+		approved := false
+		if approve := policy.ApproverFrom(ctx); approve != nil {
+			approved = approve(ctx, call, d)
+			if !approved {
+				err = fmt.Errorf("%w: %s was not approved%s", ErrToolDenied, call.Tool, describeDecision(d))
+			}
+		} else {
+			err = fmt.Errorf("%w: %s needs approval%s and no one is available to approve it", ErrToolDenied, call.Tool, describeDecision(d))
+		}
+		evt.Approved = &approved
+	}
+	if err != nil {
+		evt.Error = err.Error()
+	}
+	if p.w != nil {
+		b, _ := json.Marshal(evt)
+		_, _ = wWrite(p.w, b)
+	}
+	return err
+}
+
+func describeDecision(d policy.Decision) string {
+	s := ""
+	if d.Rule != "" {
+		s = " (" + d.Rule
+		if d.Reason != "" {
+			s += ": " + d.Reason
+		}
+		s += ")"
+	} else if d.Reason != "" {
+		s = " (" + d.Reason + ")"
+	}
+	return s
+}
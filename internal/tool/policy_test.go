@@ -0,0 +1,96 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/config"
+	"github.com/marcodenic/agentry/internal/policy"
+)
+
+func TestWrapWithPolicy(t *testing.T) {
+	e, err := policy.New(config.Permissions{Rules: []config.PolicyRule{
+		{Name: "no-force", Tools: []string{"run"}, Commands: []string{"git push --force"}, Action: "deny"},
+		{Name: "push", Tools: []string{"run"}, Commands: []string{"git push"}, Action: "ask", Reason: "publishes commits"},
+	}}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := 0
+	reg := Registry{"run": New("run", "test", func(context.Context, map[string]any) (string, error) {
+		ran++
+		return "ok", nil
+	})}
+	var log bytes.Buffer
+	reg = WrapWithPolicy(reg, e, &log)
+	run := func(ctx context.Context, cmd string) error {
+		_, err := reg["run"].Execute(ctx, map[string]any{"command": cmd})
+		return err
+	}
+
+	ctx := context.Background()
+	if err := run(ctx, "git status"); err != nil {
+		t.Fatalf("allowed call failed: %v", err)
+	}
+	if err := run(ctx, "git push --force origin"); !errors.Is(err, ErrToolDenied) || !strings.Contains(err.Error(), "no-force") {
+		t.Fatalf("expected denial naming the rule, got %v", err)
+	}
+	if err := run(ctx, "git push"); !errors.Is(err, ErrToolDenied) {
+		t.Fatalf("ask without an approver should deny, got %v", err)
+	}
+	var asked []string
+	approve := func(_ context.Context, c policy.Call, d policy.Decision) bool {
+		asked = append(asked, d.Rule)
+		return c.Args["command"] == "git push origin main"
+	}
+	actx := policy.WithApprover(ctx, approve)
+	if err := run(actx, "git push origin main"); err != nil {
+		t.Fatalf("approved call failed: %v", err)
+	}
+	if err := run(actx, "git push origin other"); !errors.Is(err, ErrToolDenied) {
+		t.Fatalf("rejected call should be denied, got %v", err)
+	}
+	if ran != 2 || len(asked) != 2 {
+		t.Fatalf("ran=%d asked=%v", ran, asked)
+	}
+
+	var decisions []string
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		var evt AuditEvent
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			t.Fatal(err)
+		}
+		d := evt.Decision + ":" + evt.Rule
+		if evt.Approved != nil {
+			d += map[bool]string{true: "+", false: "-"}[*evt.Approved]
+		}
+		decisions = append(decisions, d)
+	}
+	want := "allow:default deny:no-force ask:push- ask:push+ ask:push-"
+	if got := strings.Join(decisions, " "); got != want {
+		t.Fatalf("audit decisions %q, want %q", got, want)
+	}
+}
+
+func TestPolicyChecksPathsFoundDuringCall(t *testing.T) {
+	e, err := policy.New(config.Permissions{Rules: []config.PolicyRule{
+		{Name: "secrets", Paths: []string{"secrets/**"}, Action: "deny"},
+	}}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rename := New("rename", "test", func(ctx context.Context, args map[string]any) (string, error) {
+		return "", checkPaths(ctx, []string{"main.go", "secrets/key.go"})
+	})
+	reg := WrapWithPolicy(Registry{"rename": rename}, e, nil)
+	if _, err := reg["rename"].Execute(context.Background(), map[string]any{"path": "main.go"}); !errors.Is(err, ErrToolDenied) || !strings.Contains(err.Error(), "secrets") {
+		t.Fatalf("expected the second file to be denied, got %v", err)
+	}
+	if err := checkPaths(context.Background(), []string{"secrets/key.go"}); err != nil {
+		t.Fatalf("without a policy every path is allowed: %v", err)
+	}
+}
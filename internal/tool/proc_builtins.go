@@ -0,0 +1,474 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The proc_* builtins manage background processes (dev servers, watchers)
+// that outlive a single tool call. Processes live in one table for the
+// agentry session; each remembers the agent that started it so it can be
+// cleaned up when that agent stops (ReleaseAgent) or agentry exits
+// (StopProcesses).
+
+const (
+	procBufferSize     = 256 << 10
+	defaultProcWait    = 30 * time.Second
+	procKillGrace      = 3 * time.Second
+	maxProcOutputChunk = 32 << 10
+)
+
+// ringBuffer keeps the last size bytes written and the total written, so
+// readers can poll with an offset and learn what they missed.
+type ringBuffer struct {
+	mu    sync.Mutex
+	buf   []byte
+	size  int
+	total int64
+	wake  chan struct{} // closed and replaced on every write
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size, wake: make(chan struct{})}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf = append(r.buf, p...)
+	if over := len(r.buf) - r.size; over > 0 {
+		n := copy(r.buf, r.buf[over:])
+		r.buf = r.buf[:n]
+	}
+	r.total += int64(len(p))
+	close(r.wake)
+	r.wake = make(chan struct{})
+	return len(p), nil
+}
+
+// since returns the output from offset on, the offset to poll from next and
+// how many bytes after offset were already discarded.
+func (r *ringBuffer) since(offset int64) (data []byte, next, dropped int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := r.total - int64(len(r.buf))
+	if offset < start {
+		dropped = start - offset
+		offset = start
+	}
+	if offset > r.total {
+		offset = r.total
+	}
+	return append([]byte(nil), r.buf[offset-start:]...), r.total, dropped
+}
+
+func (r *ringBuffer) changed() <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.wake
+}
+
+type managedProc struct {
+	ID      string
+	Name    string
+	Command string
+	Owner   string
+	Started time.Time
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   *ringBuffer
+	done  chan struct{}
+	exit  int
+	err   error
+}
+
+func (p *managedProc) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// status describes the process for the model.
+func (p *managedProc) status() map[string]any {
+	m := map[string]any{
+		"id":      p.ID,
+		"name":    p.Name,
+		"command": p.Command,
+		"pid":     p.cmd.Process.Pid,
+		"running": p.running(),
+		"uptime":  time.Since(p.Started).Round(time.Second).String(),
+	}
+	if p.running() {
+		if ports := listeningPorts(p.cmd.Process.Pid); len(ports) > 0 {
+			m["ports"] = ports
+		} else if ports := outputPorts(p.out); len(ports) > 0 {
+			m["ports"] = ports
+		}
+	} else {
+		m["exit_code"] = p.exit
+		if p.err != nil && p.exit < 0 {
+			m["error"] = p.err.Error()
+		}
+	}
+	return m
+}
+
+var procs = struct {
+	sync.Mutex
+	m    map[string]*managedProc
+	next int
+}{m: map[string]*managedProc{}}
+
+func init() {
+	builtinMap["proc_start"] = builtinSpec{
+		Desc: "Start a background process (dev server, watcher) and return its id immediately; poll it with proc_output",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string", "description": "Shell command to run"},
+				"name":    map[string]any{"type": "string", "description": "Optional label"},
+				"cwd":     map[string]any{"type": "string", "description": "Working directory inside the workspace"},
+			},
+			"required": []string{"command"},
+			"example":  map[string]any{"command": "npm run dev", "name": "web"},
+		},
+		Exec: procStartExec,
+	}
+	builtinMap["proc_output"] = builtinSpec{
+		Desc: "Read a background process's output from an offset (use the returned next value to poll incrementally), with its status and listening ports. Without id, lists all processes",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":    map[string]any{"type": "string"},
+				"since": map[string]any{"type": "integer", "description": "Output offset from a previous call's next (default 0)"},
+			},
+			"example": map[string]any{"id": "p1", "since": 0},
+		},
+		Exec: procOutputExec,
+	}
+	builtinMap["proc_input"] = builtinSpec{
+		Desc: "Write to a background process's stdin",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":    map[string]any{"type": "string"},
+				"input": map[string]any{"type": "string", "description": "Text to send; include \\n to submit a line"},
+				"eof":   map[string]any{"type": "boolean", "description": "Close stdin after writing"},
+			},
+			"required": []string{"id"},
+			"example":  map[string]any{"id": "p1", "input": "q\n"},
+		},
+		Exec: procInputExec,
+	}
+	builtinMap["proc_wait"] = builtinSpec{
+		Desc: "Wait until a background process exits or its output matches a pattern, up to a timeout",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":      map[string]any{"type": "string"},
+				"until":   map[string]any{"type": "string", "description": "Regular expression to wait for in new output (e.g. 'listening on')"},
+				"since":   map[string]any{"type": "integer", "description": "Offset to match from (default: current end of output)"},
+				"timeout": map[string]any{"type": "integer", "description": "Seconds to wait (default 30)"},
+			},
+			"required": []string{"id"},
+			"example":  map[string]any{"id": "p1", "until": "ready", "timeout": 60},
+		},
+		Exec: procWaitExec,
+	}
+	builtinMap["proc_kill"] = builtinSpec{
+		Desc: "Stop a background process and everything it started (SIGTERM, then SIGKILL after 3s)",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":    map[string]any{"type": "string"},
+				"force": map[string]any{"type": "boolean", "description": "Send SIGKILL immediately"},
+			},
+			"required": []string{"id"},
+			"example":  map[string]any{"id": "p1"},
+		},
+		Exec: procKillExec,
+	}
+}
+
+func procStartExec(ctx context.Context, args map[string]any) (string, error) {
+	command := strArg(args, "command")
+	if command == "" {
+		return "", errors.New("missing command")
+	}
+	if err := refuseInDryRun(ctx, "proc_start"); err != nil {
+		return "", err
+	}
+	cmd, err := sandboxFrom(ctx).Command(context.Background(), strArg(args, "cwd"), shellArgv(command))
+	if err != nil {
+		return "", err
+	}
+	setSessionGroup(cmd)
+	out := newRingBuffer(procBufferSize)
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.WaitDelay = 2 * time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("start: %w", err)
+	}
+
+	procs.Lock()
+	procs.next++
+	p := &managedProc{
+		ID:      "p" + strconv.Itoa(procs.next),
+		Name:    strArg(args, "name"),
+		Command: command,
+		Owner:   sessionKey(ctx),
+		Started: time.Now(),
+		cmd:     cmd,
+		stdin:   stdin,
+		out:     out,
+		done:    make(chan struct{}),
+	}
+	procs.m[p.ID] = p
+	procs.Unlock()
+
+	go func() {
+		p.err = cmd.Wait()
+		p.exit = -1
+		if cmd.ProcessState != nil {
+			p.exit = cmd.ProcessState.ExitCode()
+		}
+		close(p.done)
+	}()
+	return marshal(map[string]any{"id": p.ID, "pid": cmd.Process.Pid, "name": p.Name})
+}
+
+func lookupProc(args map[string]any) (*managedProc, error) {
+	id := strArg(args, "id")
+	if id == "" {
+		return nil, errors.New("missing id")
+	}
+	procs.Lock()
+	defer procs.Unlock()
+	p := procs.m[id]
+	if p == nil {
+		return nil, fmt.Errorf("no process %q", id)
+	}
+	return p, nil
+}
+
+func procOutputExec(ctx context.Context, args map[string]any) (string, error) {
+	if strArg(args, "id") == "" {
+		procs.Lock()
+		list := make([]*managedProc, 0, len(procs.m))
+		for _, p := range procs.m {
+			list = append(list, p)
+		}
+		procs.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+		out := make([]map[string]any, 0, len(list))
+		for _, p := range list {
+			out = append(out, p.status())
+		}
+		return marshal(map[string]any{"processes": out})
+	}
+	p, err := lookupProc(args)
+	if err != nil {
+		return "", err
+	}
+	since, _ := getIntArg(args, "since", 0)
+	data, next, dropped := p.out.since(int64(since))
+	if len(data) > maxProcOutputChunk {
+		// Hand out the oldest part; the caller polls again from next.
+		next -= int64(len(data) - maxProcOutputChunk)
+		data = data[:maxProcOutputChunk]
+	}
+	res := p.status()
+	res["output"] = string(data)
+	res["next"] = next
+	if dropped > 0 {
+		res["dropped"] = dropped
+	}
+	return marshal(res)
+}
+
+func procInputExec(ctx context.Context, args map[string]any) (string, error) {
+	p, err := lookupProc(args)
+	if err != nil {
+		return "", err
+	}
+	if !p.running() {
+		return "", fmt.Errorf("process %s has exited", p.ID)
+	}
+	input := strArg(args, "input")
+	if input != "" {
+		if _, err := io.WriteString(p.stdin, input); err != nil {
+			return "", fmt.Errorf("write stdin: %w", err)
+		}
+	}
+	if eof, _ := args["eof"].(bool); eof {
+		if err := p.stdin.Close(); err != nil {
+			return "", fmt.Errorf("close stdin: %w", err)
+		}
+	}
+	return marshal(map[string]any{"id": p.ID, "written": len(input)})
+}
+
+func procWaitExec(ctx context.Context, args map[string]any) (string, error) {
+	p, err := lookupProc(args)
+	if err != nil {
+		return "", err
+	}
+	var until *regexp.Regexp
+	if pat := strArg(args, "until"); pat != "" {
+		if until, err = regexp.Compile(pat); err != nil {
+			return "", fmt.Errorf("invalid until pattern: %w", err)
+		}
+	}
+	timeout := defaultProcWait
+	if secs, ok := getIntArg(args, "timeout", 0); ok && secs > 0 {
+		timeout = time.Duration(secs) * time.Second
+	}
+	_, offset, _ := p.out.since(1 << 62)
+	if s, ok := getIntArg(args, "since", 0); ok {
+		offset = int64(s)
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		changed := p.out.changed()
+		if until != nil {
+			data, _, _ := p.out.since(offset)
+			if loc := until.FindIndex(data); loc != nil {
+				res := p.status()
+				res["matched"] = string(data[loc[0]:loc[1]])
+				return marshal(res)
+			}
+		}
+		select {
+		case <-p.done:
+			res := p.status()
+			if until != nil {
+				data, _, _ := p.out.since(offset)
+				res["matched"] = until.Find(data) != nil
+			}
+			return marshal(res)
+		case <-changed:
+		case <-timer.C:
+			res := p.status()
+			res["timed_out"] = true
+			return marshal(res)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func procKillExec(ctx context.Context, args map[string]any) (string, error) {
+	p, err := lookupProc(args)
+	if err != nil {
+		return "", err
+	}
+	force, _ := args["force"].(bool)
+	p.stop(force)
+	procs.Lock()
+	delete(procs.m, p.ID)
+	procs.Unlock()
+	data, _, _ := p.out.since(0)
+	res := p.status()
+	res["output_tail"] = string(tail(data, 2048))
+	return marshal(res)
+}
+
+// stop terminates the process group, escalating to SIGKILL if it is still
+// running after procKillGrace.
+func (p *managedProc) stop(force bool) {
+	if !p.running() {
+		return
+	}
+	_ = p.stdin.Close()
+	if force {
+		killSessionGroup(p.cmd)
+	} else {
+		terminateSessionGroup(p.cmd)
+	}
+	select {
+	case <-p.done:
+	case <-time.After(procKillGrace):
+		killSessionGroup(p.cmd)
+		<-p.done
+	}
+}
+
+func tail(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[len(b)-n:]
+	}
+	return b
+}
+
+// StopProcesses stops every background process started by proc_start.
+func StopProcesses() {
+	stopProcs(func(*managedProc) bool { return true })
+}
+
+// ReleaseAgent cleans up after an agent that has stopped: its persistent
+// shell and the background processes it started.
+func ReleaseAgent(agentID string) {
+	closeShellSession(agentID)
+	stopProcs(func(p *managedProc) bool { return p.Owner == agentID })
+}
+
+func stopProcs(match func(*managedProc) bool) {
+	procs.Lock()
+	var victims []*managedProc
+	for id, p := range procs.m {
+		if match(p) {
+			victims = append(victims, p)
+			delete(procs.m, id)
+		}
+	}
+	procs.Unlock()
+	var wg sync.WaitGroup
+	for _, p := range victims {
+		wg.Add(1)
+		go func(p *managedProc) {
+			defer wg.Done()
+			p.stop(false)
+		}(p)
+	}
+	wg.Wait()
+}
+
+var portPattern = regexp.MustCompile(`(?i)(?:localhost|127\.0\.0\.1|0\.0\.0\.0|\[::\]|\[::1\]):(\d{2,5})\b|\bport\s+(\d{2,5})\b`)
+
+// outputPorts finds ports a process announced in its output, for systems
+// where listening sockets cannot be inspected.
+func outputPorts(out *ringBuffer) []int {
+	data, _, _ := out.since(0)
+	seen := map[int]bool{}
+	var ports []int
+	for _, m := range portPattern.FindAllSubmatch(data, -1) {
+		s := m[1]
+		if len(s) == 0 {
+			s = m[2]
+		}
+		if n, err := strconv.Atoi(string(s)); err == nil && n > 0 && n < 65536 && !seen[n] {
+			seen[n] = true
+			ports = append(ports, n)
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
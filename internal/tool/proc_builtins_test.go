@@ -0,0 +1,136 @@
+//go:build unix
+
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+)
+
+func procCall(t *testing.T, ctx context.Context, name string, args map[string]any) map[string]any {
+	t.Helper()
+	out, err := builtinMap[name].Exec(ctx, args)
+	if err != nil {
+		t.Fatalf("%s %v: %v", name, args, err)
+	}
+	var res map[string]any
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("%s: bad JSON %q: %v", name, out, err)
+	}
+	return res
+}
+
+func TestProcTools(t *testing.T) {
+	defer StopProcesses()
+	ctx := context.Background()
+
+	started := procCall(t, ctx, "proc_start", map[string]any{
+		"command": `echo ready; read line; echo "got $line"; sleep 30`,
+		"name":    "echoer",
+	})
+	id := started["id"].(string)
+
+	res := procCall(t, ctx, "proc_wait", map[string]any{"id": id, "until": "ready", "since": 0, "timeout": 5})
+	if res["matched"] != "ready" || res["running"] != true {
+		t.Fatalf("expected ready match, got %v", res)
+	}
+	res = procCall(t, ctx, "proc_output", map[string]any{"id": id})
+	next := res["next"].(float64)
+	if res["output"] != "ready\n" {
+		t.Fatalf("unexpected output %v", res)
+	}
+
+	procCall(t, ctx, "proc_input", map[string]any{"id": id, "input": "hello\n"})
+	// The reply may already be out by the time proc_wait looks, so match
+	// from before the input rather than from the end of the output.
+	res = procCall(t, ctx, "proc_wait", map[string]any{"id": id, "until": "got \\w+", "since": next, "timeout": 5})
+	if res["matched"] != "got hello" {
+		t.Fatalf("expected echo of input, got %v", res)
+	}
+	res = procCall(t, ctx, "proc_output", map[string]any{"id": id, "since": next})
+	if res["output"] != "got hello\n" {
+		t.Fatalf("expected incremental output, got %v", res)
+	}
+
+	res = procCall(t, ctx, "proc_wait", map[string]any{"id": id, "timeout": 1})
+	if res["timed_out"] != true {
+		t.Fatalf("expected timeout while running, got %v", res)
+	}
+
+	list := procCall(t, ctx, "proc_output", map[string]any{})
+	if n := len(list["processes"].([]any)); n != 1 {
+		t.Fatalf("expected one process listed, got %v", list)
+	}
+
+	res = procCall(t, ctx, "proc_kill", map[string]any{"id": id})
+	if res["running"] != false || !strings.Contains(res["output_tail"].(string), "got hello") {
+		t.Fatalf("unexpected kill result %v", res)
+	}
+	if _, err := builtinMap["proc_output"].Exec(ctx, map[string]any{"id": id}); err == nil {
+		t.Fatal("killed process should be removed from the table")
+	}
+
+	short := procCall(t, ctx, "proc_start", map[string]any{"command": "exit 7"})
+	res = procCall(t, ctx, "proc_wait", map[string]any{"id": short["id"], "timeout": 5})
+	if res["running"] != false || res["exit_code"] != float64(7) {
+		t.Fatalf("expected exit code 7, got %v", res)
+	}
+}
+
+func TestReleaseAgentStopsOwnProcesses(t *testing.T) {
+	defer StopProcesses()
+	mine := context.WithValue(context.Background(), contracts.AgentIDContextKey, "agent-a")
+	other := context.WithValue(context.Background(), contracts.AgentIDContextKey, "agent-b")
+	a := procCall(t, mine, "proc_start", map[string]any{"command": "sleep 30"})
+	b := procCall(t, other, "proc_start", map[string]any{"command": "sleep 30"})
+
+	ReleaseAgent("agent-a")
+	if _, err := lookupProc(map[string]any{"id": a["id"]}); err == nil {
+		t.Fatal("agent-a's process should be gone")
+	}
+	if _, err := lookupProc(map[string]any{"id": b["id"]}); err != nil {
+		t.Fatalf("agent-b's process should survive: %v", err)
+	}
+}
+
+func TestRingBufferSince(t *testing.T) {
+	r := newRingBuffer(8)
+	r.Write([]byte("abcdef"))
+	r.Write([]byte("ghijkl"))
+	data, next, dropped := r.since(2)
+	if string(data) != "efghijkl" || next != 12 || dropped != 2 {
+		t.Fatalf("got %q next=%d dropped=%d", data, next, dropped)
+	}
+	data, next, dropped = r.since(10)
+	if string(data) != "kl" || next != 12 || dropped != 0 {
+		t.Fatalf("got %q next=%d dropped=%d", data, next, dropped)
+	}
+}
+
+func TestPortDetection(t *testing.T) {
+	r := newRingBuffer(1024)
+	r.Write([]byte("Server listening on http://localhost:5173/\nAPI on port 8080\n"))
+	if ports := outputPorts(r); !slices.Equal(ports, []int{5173, 8080}) {
+		t.Fatalf("output ports %v", ports)
+	}
+	if runtime.GOOS != "linux" {
+		return
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	if ports := listeningPorts(syscall.Getpgrp()); !slices.Contains(ports, port) {
+		t.Fatalf("expected port %d in %v", port, ports)
+	}
+}
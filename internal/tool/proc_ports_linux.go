@@ -0,0 +1,69 @@
+package tool
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// listeningPorts returns the TCP ports on which processes in the process
+// group pgid are listening, read from /proc.
+func listeningPorts(pgid int) []int {
+	inodes := map[string]bool{}
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+	for _, stat := range stats {
+		b, err := os.ReadFile(stat)
+		if err != nil {
+			continue
+		}
+		// pid (comm) state ppid pgrp ...; comm may contain spaces.
+		i := strings.LastIndexByte(string(b), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(b[i+1:]))
+		if len(fields) < 3 || fields[2] != strconv.Itoa(pgid) {
+			continue
+		}
+		fds, _ := os.ReadDir(filepath.Join(filepath.Dir(stat), "fd"))
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(filepath.Dir(stat), "fd", fd.Name()))
+			if err == nil && strings.HasPrefix(link, "socket:[") {
+				inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] = true
+			}
+		}
+	}
+	if len(inodes) == 0 {
+		return nil
+	}
+	seen := map[int]bool{}
+	var ports []int
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		f, err := os.Open(table)
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+			fields := strings.Fields(sc.Text())
+			if len(fields) < 10 || fields[3] != "0A" || !inodes[fields[9]] {
+				continue
+			}
+			_, hexPort, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			if n, err := strconv.ParseInt(hexPort, 16, 32); err == nil && !seen[int(n)] {
+				seen[int(n)] = true
+				ports = append(ports, int(n))
+			}
+		}
+		f.Close()
+	}
+	sort.Ints(ports)
+	return ports
+}
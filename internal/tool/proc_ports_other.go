@@ -0,0 +1,7 @@
+//go:build !linux
+
+package tool
+
+// listeningPorts is only implemented on Linux; elsewhere ports are taken
+// from the process output.
+func listeningPorts(int) []int { return nil }
@@ -2,11 +2,11 @@ package tool
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 )
 
@@ -64,19 +64,18 @@ func readLinesExec(ctx context.Context, args map[string]any) (string, error) {
 	maxLinesInt, _ := getIntArg(args, "max_lines", 1000)
 
 	path = absPath(path)
-	file, err := os.Open(path)
+	data, err := readFile(ctx, path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
 
-	if err := recordView(path); err != nil {
+	if err := recordView(ctx, path); err != nil {
 		return "", fmt.Errorf("failed to record view: %w", err)
 	}
 
 	// Read all lines first for easier slicing and to allow optional context fields
 	var allLines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	for scanner.Scan() {
 		allLines = append(allLines, scanner.Text())
 	}
@@ -110,6 +109,7 @@ func readLinesExec(ctx context.Context, args map[string]any) (string, error) {
 		"start_line": int(startLine),
 		"lines_read": len(selected),
 		"content":    strings.Join(selected, "\n"),
+		"hash":       shortHash(contentHash(data)),
 	}
 	if hasEndLine {
 		result["end_line"] = int(endLineInt)
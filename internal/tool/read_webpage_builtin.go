@@ -5,10 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/marcodenic/agentry/internal/httpx"
 )
 
 func init() {
@@ -42,7 +43,8 @@ func init() {
 				"max_length": 5000,
 			},
 		},
-		Exec: readWebpageExec,
+		Policy: webPolicy,
+		Exec:   readWebpageExec,
 	}
 }
 
@@ -67,29 +69,23 @@ func readWebpageExec(ctx context.Context, args map[string]any) (string, error) {
 		return "", errors.New("only HTTP/HTTPS URLs are supported")
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("User-Agent", "Agentry/1.0 (Web Content Reader)")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-
-	resp, err := client.Do(req)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	resp, err := httpx.Default().Get(ctx, urlStr, map[string]string{
+		"User-Agent": "Agentry/1.0 (Web Content Reader)",
+		"Accept":     "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch URL: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+	if !textual(resp.ContentType) {
+		return "", fmt.Errorf("%s is %s, not a web page; use download to save it", urlStr, resp.ContentType)
 	}
+	body := resp.Body
 
 	var result map[string]any
 
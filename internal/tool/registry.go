@@ -18,7 +18,11 @@ type simpleTool struct {
 	desc    string
 	schema  map[string]any
 	fn      func(context.Context, map[string]any) (string, error)
+	result  func(context.Context, map[string]any) (Result, error)
 	allowed bool
+	policy  ExecPolicy
+	// commands describes the command lines a call runs; see CommandLister.
+	commands func(map[string]any) []string
 }
 
 func New(name, desc string, fn func(context.Context, map[string]any) (string, error)) Tool {
@@ -32,16 +36,34 @@ func NewWithSchema(name, desc string, schema map[string]any, fn func(context.Con
 func (t *simpleTool) Name() string               { return t.name }
 func (t *simpleTool) Description() string        { return t.desc }
 func (t *simpleTool) JSONSchema() map[string]any { return t.schema }
+
+// ExecPolicy implements PolicyTool.
+func (t *simpleTool) ExecPolicy() ExecPolicy { return t.policy }
+
+// Commands implements CommandLister.
+func (t *simpleTool) Commands(args map[string]any) []string {
+	if t.commands == nil {
+		return nil
+	}
+	return t.commands(args)
+}
+
 func (t *simpleTool) Execute(ctx context.Context, args map[string]any) (string, error) {
 	if !t.allowed {
 		return "", fmt.Errorf("%w: %s", ErrToolDenied, t.name)
 	}
-	if !permitted(t.name) {
-		return "", fmt.Errorf("%w: %s", ErrToolDenied, t.name)
-	}
 	return t.fn(ctx, args)
 }
 
+// ExecuteResult implements ResultTool.
+func (t *simpleTool) ExecuteResult(ctx context.Context, args map[string]any) (Result, error) {
+	if t.result == nil || !t.allowed {
+		s, err := t.Execute(ctx, args)
+		return Result{Text: s}, err
+	}
+	return t.result(ctx, args)
+}
+
 type Registry map[string]Tool
 
 func (r Registry) Use(name string) (Tool, bool) {
@@ -0,0 +1,53 @@
+package tool
+
+import (
+	"context"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+	"github.com/marcodenic/agentry/internal/repomap"
+)
+
+// maxTaskQuery bounds how much of the agent's task is used to rank the map
+// when no query is given.
+const maxTaskQuery = 2000
+
+func init() {
+	builtinMap["repo_map"] = builtinSpec{
+		Desc: "Map the repository: source files with their top-level symbols and signatures, most referenced and most relevant to the query (or current task) first, trimmed to a token budget. Use it to find where to look before reading files.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query":         map[string]any{"type": "string", "description": "Words to rank by (defaults to the current task)"},
+				"max_tokens":    map[string]any{"type": "integer", "description": "Token budget for the map (default 1500, max 20000)"},
+				"paths":         map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Directories to limit the map to"},
+				"include_tests": map[string]any{"type": "boolean", "description": "Include test files (default false)"},
+			},
+			"required": []string{},
+			"example":  map[string]any{"query": "session token refresh", "max_tokens": 1500},
+		},
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			root, err := sandboxFrom(ctx).Confine("")
+			if err != nil {
+				return "", err
+			}
+			query := strArg(args, "query")
+			if query == "" {
+				if task, ok := ctx.Value(contracts.TaskContextKey).(string); ok {
+					query = task[:min(len(task), maxTaskQuery)]
+				}
+			}
+			budget, _ := getIntArg(args, "max_tokens", repomap.DefaultMaxTokens)
+			includeTests, _ := args["include_tests"].(bool)
+			m, err := repomap.Build(root, repomap.Options{
+				Query:        query,
+				MaxTokens:    budget,
+				Paths:        strSlice(args, "paths"),
+				IncludeTests: includeTests,
+			})
+			if err != nil {
+				return "", err
+			}
+			return m.Text, nil
+		},
+	}
+}
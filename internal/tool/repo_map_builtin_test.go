@@ -0,0 +1,20 @@
+package tool
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+)
+
+func TestRepoMapUsesTask(t *testing.T) {
+	ctx := context.WithValue(context.Background(), contracts.TaskContextKey, "rank the repo map builtin")
+	out, err := builtinMap["repo_map"].Exec(ctx, map[string]any{"max_tokens": 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "relevance to: rank repo map builtin") || !strings.Contains(out, "repo_map_builtin.go:") {
+		t.Fatalf("map not ranked by task:\n%s", out)
+	}
+}
@@ -0,0 +1,120 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+
+	"github.com/marcodenic/agentry/internal/httpx"
+)
+
+// Result is a tool result with more than text. Text is what the model
+// sees; Data, MIME and Attachments let the TUI, the audit log and trace
+// consumers render the result without parsing Text.
+type Result struct {
+	Text        string       `json:"text,omitempty"`
+	Data        any          `json:"data,omitempty"`
+	MIME        string       `json:"mime,omitempty"` // of Text; empty means plain text
+	Attachments []Attachment `json:"attachments,omitempty"`
+	ErrorClass  ErrorClass   `json:"error_class,omitempty"`
+}
+
+// Attachment is a file a tool produced along with its result.
+type Attachment struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+	MIME string `json:"mime,omitempty"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// ResultTool is implemented by tools that return a Result.
+type ResultTool interface {
+	Tool
+	ExecuteResult(ctx context.Context, args map[string]any) (Result, error)
+}
+
+// Run executes t and returns its Result; a tool that only returns text
+// gets a Result holding just that. A failed call's Result is classified
+// (see Classify) unless the tool classified it itself.
+func Run(ctx context.Context, t Tool, args map[string]any) (Result, error) {
+	var r Result
+	var err error
+	if rt, ok := t.(ResultTool); ok {
+		r, err = rt.ExecuteResult(ctx, args)
+	} else {
+		r.Text, err = t.Execute(ctx, args)
+	}
+	if err != nil && r.ErrorClass == "" {
+		r.ErrorClass = Classify(err)
+	}
+	return r, err
+}
+
+// jsonResult returns v as the Result's data, marshalled for the model.
+func jsonResult(v any) (Result, error) {
+	s, err := marshal(v)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Text: s, Data: v, MIME: "application/json"}, nil
+}
+
+// textOf adapts a result function to ExecFn for builtinSpec.Exec.
+func textOf(fn func(context.Context, map[string]any) (Result, error)) ExecFn {
+	return func(ctx context.Context, args map[string]any) (string, error) {
+		r, err := fn(ctx, args)
+		return r.Text, err
+	}
+}
+
+// ErrorClass says what kind of failure a tool call ended in, so callers can
+// react to it without matching on messages.
+type ErrorClass string
+
+const (
+	ErrClassDenied      ErrorClass = "denied"       // refused by permissions, policy or a guard
+	ErrClassInvalidArgs ErrorClass = "invalid_args" // the call's arguments were wrong
+	ErrClassNotFound    ErrorClass = "not_found"    // a file or resource does not exist
+	ErrClassConflict    ErrorClass = "conflict"     // a stale edit or another agent's lease
+	ErrClassTimeout     ErrorClass = "timeout"
+	ErrClassCanceled    ErrorClass = "canceled"
+	ErrClassNetwork     ErrorClass = "network"
+	ErrClassFailed      ErrorClass = "failed" // anything else
+)
+
+// ClassError attaches a class to an error for Classify.
+type ClassError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *ClassError) Error() string { return e.Err.Error() }
+func (e *ClassError) Unwrap() error { return e.Err }
+
+// Classify returns the class of a tool error.
+func Classify(err error) ErrorClass {
+	var ce *ClassError
+	var stale *StaleError
+	var blocked *httpx.BlockedError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &ce):
+		return ce.Class
+	case errors.Is(err, ErrToolDenied), errors.As(err, &blocked):
+		return ErrClassDenied
+	case errors.As(err, &stale):
+		return ErrClassConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrClassCanceled
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrClassNotFound
+	case errors.As(err, &netErr):
+		return ErrClassNetwork
+	}
+	return ErrClassFailed
+}
@@ -0,0 +1,65 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStructuredResultThroughWrappers(t *testing.T) {
+	probe := func(context.Context, map[string]any) (Result, error) {
+		return jsonResult(map[string]any{"count": 2})
+	}
+	var log bytes.Buffer
+	reg := WrapWithAudit(Registry{"probe": MarkTerminal(&simpleTool{name: "probe", fn: textOf(probe), result: probe, allowed: true})}, &log)
+
+	r, err := Run(context.Background(), reg["probe"], map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Text != `{"count":2}` || r.MIME != "application/json" || r.Data.(map[string]any)["count"] != 2 {
+		t.Fatalf("result: %+v", r)
+	}
+	if s, _ := reg["probe"].Execute(context.Background(), map[string]any{}); s != r.Text {
+		t.Fatalf("Execute returned %q", s)
+	}
+
+	var evt AuditEvent
+	if err := json.Unmarshal(bytes.SplitN(log.Bytes(), []byte("\n"), 2)[0], &evt); err != nil {
+		t.Fatal(err)
+	}
+	if evt.Result == nil || evt.Result.Text != "" || evt.Result.Data.(map[string]any)["count"] != 2.0 {
+		t.Fatalf("audit result: %+v", evt.Result)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for err, want := range map[error]ErrorClass{
+		fmt.Errorf("%w: sh", ErrToolDenied):                                   ErrClassDenied,
+		&StaleError{Path: "a.go"}:                                             ErrClassConflict,
+		fmt.Errorf("fetch failed: %w", context.DeadlineExceeded):              ErrClassTimeout,
+		&ClassError{Class: ErrClassInvalidArgs, Err: errors.New("bad range")}: ErrClassInvalidArgs,
+		errors.New("boom"):                                                    ErrClassFailed,
+	} {
+		if got := Classify(err); got != want {
+			t.Errorf("Classify(%v) = %s, want %s", err, got, want)
+		}
+	}
+
+	failing := New("fail", "", func(context.Context, map[string]any) (string, error) {
+		return "", checkLease(agentCtx("tester"), "/nowhere")
+	})
+	leases.Lock()
+	leases.m["/nowhere"] = lease{Path: "/nowhere", Owner: "coder", By: "agent_0", Expires: time.Now().Add(time.Hour)}
+	leases.Unlock()
+	defer func() { leases.Lock(); delete(leases.m, "/nowhere"); leases.Unlock() }()
+	r, err := Run(context.Background(), failing, nil)
+	if err == nil || r.ErrorClass != ErrClassConflict || !strings.Contains(err.Error(), "leased to coder") {
+		t.Fatalf("lease conflict: %v %q", err, r.ErrorClass)
+	}
+}
@@ -0,0 +1,185 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/sandbox"
+	"github.com/marcodenic/agentry/internal/testrun"
+	"github.com/marcodenic/agentry/internal/trace"
+)
+
+const (
+	defaultTestTimeout = 10 * time.Minute
+	defaultMaxFailures = 10
+)
+
+func init() {
+	var names []string
+	for _, fw := range testrun.Frameworks() {
+		names = append(names, string(fw))
+	}
+	builtinMap["run_tests"] = builtinSpec{
+		Desc: "Run the project's tests (Go, pytest, jest, vitest or cargo, detected automatically) and return a compact JSON summary: counts, and for each failure the test, file:line, message and the end of its log. The full log is saved to the file named in \"log\".",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"framework":    map[string]any{"type": "string", "enum": names, "description": "Override detection"},
+				"paths":        map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Packages (Go, e.g. ./internal/auth), files or directories to test; default the whole suite"},
+				"filter":       map[string]any{"type": "string", "description": "Only tests matching this name pattern (go -run, pytest -k, jest/vitest -t, cargo filter)"},
+				"timeout":      map[string]any{"type": "integer", "description": "Seconds before the run is stopped (default 600)"},
+				"max_failures": map[string]any{"type": "integer", "description": "Failures to include in the summary (default 10)"},
+			},
+			"example": map[string]any{"paths": []string{"./internal/auth"}, "filter": "TestLogin"},
+		},
+		Commands: func(args map[string]any) []string {
+			// Commands has no context to carry the caller's sandbox, so
+			// detect in the current directory, the default workspace.
+			plan, _, err := testPlan(sandbox.Policy{}, args, os.TempDir())
+			if err != nil {
+				return nil
+			}
+			return []string{strings.Join(plan.Argv, " ")}
+		},
+		Exec: runTests,
+	}
+}
+
+// testPlan picks the framework (from args or detection in p's workspace)
+// and builds its command. It also returns the workspace root.
+func testPlan(p sandbox.Policy, args map[string]any, tmpDir string) (testrun.Plan, string, error) {
+	root, err := p.Confine("")
+	if err != nil {
+		return testrun.Plan{}, "", err
+	}
+	fw := testrun.Framework(strArg(args, "framework"))
+	if fw == "" {
+		detected := testrun.Detect(root)
+		if len(detected) == 0 {
+			return testrun.Plan{}, root, errors.New("no supported test framework found (looked for go.mod, Cargo.toml, jest or vitest in package.json, and pytest configuration); pass framework to choose one")
+		}
+		fw = detected[0]
+	}
+	plan, err := testrun.NewPlan(fw, testrun.Options{Paths: strSlice(args, "paths"), Filter: strArg(args, "filter")}, tmpDir)
+	return plan, root, err
+}
+
+func runTests(ctx context.Context, args map[string]any) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "agentry-tests-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+	plan, root, err := testPlan(sandboxFrom(ctx), args, tmpDir)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := defaultTestTimeout
+	if secs, ok := getIntArg(args, "timeout", 0); ok && secs > 0 {
+		timeout = time.Duration(secs) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd, err := sandboxFrom(ctx).Command(ctx, "", plan.Argv)
+	if err != nil {
+		return "", err
+	}
+	// go test -json output is for the parser, not for people watching.
+	out := &testOutput{emit: plan.Framework != testrun.Go, ctx: ctx}
+	cmd.Stdout, cmd.Stderr = out, out
+	start := time.Now()
+	runErr := cmd.Run()
+	elapsed := time.Since(start)
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(runErr, &exitErr) && ctx.Err() == nil {
+			return "", fmt.Errorf("run %s: %w", plan.Argv[0], runErr)
+		}
+	}
+
+	log := out.Bytes()
+	if b, err := os.ReadFile(plan.ResultFile); err == nil && plan.ResultFile != "" {
+		log = append(append(log, "\n\n==> report "+plan.ResultFile+" <==\n"...), b...)
+	}
+	logPath, logErr := saveArtifact("run_tests-"+string(plan.Framework), ".log", log)
+
+	report, parseErr := plan.Parse(out.Bytes(), root)
+	pass, fail, skip := report.Counts()
+	failures := report.Failures()
+	res := map[string]any{
+		"framework":   plan.Framework,
+		"command":     strings.Join(plan.Argv, " "),
+		"ok":          runErr == nil && len(failures) == 0,
+		"passed":      pass,
+		"failed":      fail,
+		"skipped":     skip,
+		"duration_ms": elapsed.Milliseconds(),
+	}
+	if runErr != nil {
+		res["exit_code"] = cmd.ProcessState.ExitCode()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		res["timed_out"] = true
+		res["ok"] = false
+	}
+	if logErr == nil {
+		res["log"] = logPath
+	}
+	maxFailures, _ := getIntArg(args, "max_failures", defaultMaxFailures)
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFailures
+	}
+	if len(failures) > maxFailures {
+		res["more_failures"] = len(failures) - maxFailures
+		failures = failures[:maxFailures]
+	}
+	res["failures"] = failures
+	// When nothing could be parsed, the end of the output usually says why
+	// (runner not installed, no tests collected, a crash).
+	if runErr != nil && len(failures) == 0 {
+		msg := strings.TrimSpace(out.String())
+		if len(msg) > 1500 {
+			msg = "... " + msg[len(msg)-1500:]
+		}
+		if parseErr != nil {
+			msg = parseErr.Error() + "\n" + msg
+		}
+		res["error"] = msg
+	}
+	return marshal(res)
+}
+
+// testOutput collects a test run's combined output and, when emit is set,
+// streams it as tool_output trace events.
+type testOutput struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	emit bool
+	ctx  context.Context
+}
+
+func (o *testOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.Write(p)
+	if o.emit {
+		trace.Emit(o.ctx, trace.EventToolOutput, map[string]any{"name": "run_tests", "output": string(p)})
+	}
+	return len(p), nil
+}
+
+func (o *testOutput) Bytes() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]byte(nil), o.buf.Bytes()...)
+}
+
+func (o *testOutput) String() string { return string(o.Bytes()) }
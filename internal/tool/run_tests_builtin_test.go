@@ -0,0 +1,73 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunTestsGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/rt\n\ngo 1.21\n",
+		"calc/calc.go": "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+		"calc/calc_test.go": "package calc\n\nimport \"testing\"\n\n" +
+			"func TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fatal(\"bad\")\n\t}\n}\n\n" +
+			"func TestWrong(t *testing.T) {\n\tif got := Add(2, 2); got != 5 {\n\t\tt.Errorf(\"Add(2, 2) = %d, want 5\", got)\n\t}\n}\n",
+	}
+	for name, body := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	t.Setenv("AGENTRY_ARTIFACTS_DIR", t.TempDir())
+
+	out, err := builtinMap["run_tests"].Exec(context.Background(), map[string]any{"paths": []any{"./calc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Framework string `json:"framework"`
+		OK        bool   `json:"ok"`
+		Passed    int    `json:"passed"`
+		Failed    int    `json:"failed"`
+		Log       string `json:"log"`
+		Failures  []struct {
+			Name    string `json:"name"`
+			File    string `json:"file"`
+			Line    int    `json:"line"`
+			Message string `json:"message"`
+		} `json:"failures"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("bad JSON %q: %v", out, err)
+	}
+	if res.Framework != "go" || res.OK || res.Passed != 1 || res.Failed != 1 || len(res.Failures) != 1 {
+		t.Fatalf("unexpected summary: %s", out)
+	}
+	f := res.Failures[0]
+	if f.Name != "TestWrong" || f.File != "calc/calc_test.go" || f.Line != 13 || f.Message != "Add(2, 2) = 4, want 5" {
+		t.Fatalf("unexpected failure: %+v", f)
+	}
+	log, err := os.ReadFile(res.Log)
+	if err != nil || !strings.Contains(string(log), `"Test":"TestWrong"`) {
+		t.Fatalf("log artifact %s not saved: %v", res.Log, err)
+	}
+
+	out, err = builtinMap["run_tests"].Exec(context.Background(), map[string]any{"filter": "TestAdd"})
+	if err != nil || !strings.Contains(out, `"ok":true`) {
+		t.Fatalf("filtered run: %s, %v", out, err)
+	}
+}
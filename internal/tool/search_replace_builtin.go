@@ -5,9 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
+
+	"github.com/marcodenic/agentry/internal/journal"
 )
 
 func init() {
@@ -38,6 +39,7 @@ func init() {
 					"description": "Maximum number of replacements to make (default: -1 for all)",
 					"default":     -1,
 				},
+				"expected_hash": expectedHashSchema,
 			},
 			"required": []string{"path", "search", "replace"},
 			"example": map[string]any{
@@ -62,11 +64,13 @@ func searchReplaceExec(ctx context.Context, args map[string]any) (string, error)
 	maxReplacements, _ := getIntArg(args, "max_replacements", -1)
 
 	path = absPath(path)
-	if err := checkForOverwrite(path); err != nil {
+	defer lockFiles(path)()
+	if err := checkForOverwrite(ctx, path, args); err != nil {
 		return "", err
 	}
+	before := journal.Snapshot(path)
 
-	content, err := os.ReadFile(path)
+	content, err := readFile(ctx, path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
@@ -118,18 +122,13 @@ func searchReplaceExec(ctx context.Context, args map[string]any) (string, error)
 		return fmt.Sprintf(`{"path": "%s", "replacements": 0, "message": "No matches found"}`, path), nil
 	}
 
-	tempPath := path + ".tmp"
-	if err := os.WriteFile(tempPath, []byte(newContent), 0644); err != nil {
-		return "", fmt.Errorf("failed to write temp file: %w", err)
-	}
-
-	if err := os.Rename(tempPath, path); err != nil {
-		os.Remove(tempPath)
-		return "", fmt.Errorf("failed to move temp file: %w", err)
+	if err := writeFile(ctx, path, []byte(newContent), 0644); err != nil {
+		return "", err
 	}
+	recordWrite(ctx, "search_replace", before)
 
 	// Update viewed timestamp after modification so subsequent edits are allowed
-	_ = recordView(path)
+	_ = recordView(ctx, path)
 
 	resultInfo := map[string]any{
 		"path":          path,
@@ -5,26 +5,60 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"time"
 
+	"github.com/marcodenic/agentry/internal/journal"
 	"github.com/marcodenic/agentry/internal/patch"
 )
 
+// shellPolicy bounds the shell tools: a command that hangs fails after ten
+// minutes instead of stalling its agent, and long output keeps both its
+// start and its end, where errors usually are.
+var shellPolicy = ExecPolicy{Timeout: 10 * time.Minute, MaxOutput: 64 << 10, Truncate: "middle"}
+
 func init() {
 	// Add patch tool
 	builtinMap["patch"] = builtinSpec{
-		Desc: "Apply a unified diff patch",
+		Desc: "Apply a unified diff patch. Hunks are found even if line numbers are off; the patch applies to all files or none, and rejected hunks are reported with the closest matching lines.",
 		Schema: map[string]any{
-			"type":       "object",
-			"properties": map[string]any{"patch": map[string]any{"type": "string"}},
-			"required":   []string{"patch"},
-			"example":    map[string]any{"patch": ""},
+			"type": "object",
+			"properties": map[string]any{
+				"patch":             map[string]any{"type": "string"},
+				"fuzz":              map[string]any{"type": "integer", "description": "Context lines that may be ignored at each end of a hunk (default 2; 0 for exact context)"},
+				"ignore_whitespace": map[string]any{"type": "boolean", "description": "Match lines ignoring differences in whitespace"},
+			},
+			"required": []string{"patch"},
+			"example":  map[string]any{"patch": ""},
 		},
 		Exec: func(ctx context.Context, args map[string]any) (string, error) {
 			patchStr, _ := args["patch"].(string)
 			if patchStr == "" {
 				return "", errors.New("missing patch")
 			}
-			res, err := patch.Apply(patchStr)
+			files, err := patch.Files(patchStr)
+			if err != nil {
+				return "", err
+			}
+			paths := make([]string, len(files))
+			for i, f := range files {
+				paths[i] = absPath(f)
+			}
+			defer lockFiles(paths...)()
+			before := map[string]journal.Change{}
+			for _, f := range files {
+				if err := checkForOverwrite(ctx, absPath(f), nil); err != nil {
+					return "", err
+				}
+				before[f] = journal.Snapshot(absPath(f))
+			}
+			opts := patch.DefaultOptions()
+			opts.Fuzz, _ = getIntArg(args, "fuzz", opts.Fuzz)
+			opts.IgnoreWhitespace, _ = args["ignore_whitespace"].(bool)
+			res, err := applyPatch(ctx, patchStr, opts)
+			for _, c := range res.Files {
+				recordWrite(ctx, "patch", before[c.Path])
+				_ = recordView(ctx, absPath(c.Path))
+			}
 			if err != nil {
 				return "", err
 			}
@@ -47,11 +81,15 @@ func init() {
 				"required": []string{"command"},
 				"example":  map[string]any{"command": "Get-ChildItem -Name '*.go'"},
 			},
+			Policy: shellPolicy,
 			Exec: func(ctx context.Context, args map[string]any) (string, error) {
 				cmd, _ := args["command"].(string)
 				if cmd == "" {
 					return "", errors.New("missing command")
 				}
+				if err := refuseInDryRun(ctx, "shell"); err != nil {
+					return "", err
+				}
 				return ExecDirect(ctx, cmd)
 			},
 		}
@@ -69,11 +107,15 @@ func init() {
 				"required": []string{"command"},
 				"example":  map[string]any{"command": "dir *.go"},
 			},
+			Policy: shellPolicy,
 			Exec: func(ctx context.Context, args map[string]any) (string, error) {
 				cmd, _ := args["command"].(string)
 				if cmd == "" {
 					return "", errors.New("missing command")
 				}
+				if err := refuseInDryRun(ctx, "shell"); err != nil {
+					return "", err
+				}
 				// Execute using cmd.exe
 				cmdLine := fmt.Sprintf("cmd /c %s", cmd)
 				return ExecDirect(ctx, cmdLine)
@@ -93,11 +135,15 @@ func init() {
 				"required": []string{"command"},
 				"example":  map[string]any{"command": "ls -la *.go"},
 			},
+			Policy: shellPolicy,
 			Exec: func(ctx context.Context, args map[string]any) (string, error) {
 				cmd, _ := args["command"].(string)
 				if cmd == "" {
 					return "", errors.New("missing command")
 				}
+				if err := refuseInDryRun(ctx, "shell"); err != nil {
+					return "", err
+				}
 				return ExecDirect(ctx, cmd)
 			},
 		}
@@ -115,11 +161,15 @@ func init() {
 				"required": []string{"command"},
 				"example":  map[string]any{"command": "find . -name '*.go'"},
 			},
+			Policy: shellPolicy,
 			Exec: func(ctx context.Context, args map[string]any) (string, error) {
 				cmd, _ := args["command"].(string)
 				if cmd == "" {
 					return "", errors.New("missing command")
 				}
+				if err := refuseInDryRun(ctx, "shell"); err != nil {
+					return "", err
+				}
 				return ExecDirect(ctx, cmd)
 			},
 		}
@@ -0,0 +1,378 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+	"github.com/marcodenic/agentry/internal/sandbox"
+	"github.com/marcodenic/agentry/internal/trace"
+)
+
+// shell_session keeps one long-lived shell per agent so that cd, exported
+// variables, activated virtualenvs and sourced scripts persist between
+// calls. Each command is eval'ed with stdin from /dev/null and followed by a
+// printf of a random marker, the exit status and $PWD, which delimits its
+// output on the shared stdout/stderr pipe.
+
+const (
+	defaultSessionTimeout = 2 * time.Minute
+	// sessionInterruptGrace is how long a command has to stop after SIGINT
+	// before the whole shell is killed.
+	sessionInterruptGrace = 3 * time.Second
+	maxSessionOutput      = 64 << 10
+)
+
+type shellSession struct {
+	mu    sync.Mutex // one command at a time
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   chan []byte   // closed when the shell's output ends
+	done  chan struct{} // closed when the shell has exited
+	err   error         // exit status, valid after done
+}
+
+var shellSessions = struct {
+	sync.Mutex
+	m map[string]*shellSession
+}{m: map[string]*shellSession{}}
+
+func init() {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	builtinMap["shell_session"] = builtinSpec{
+		Desc: "Run a command in a persistent shell: cd, exported variables and sourced scripts carry over to the next call. Returns the output followed by the exit code and working directory.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string", "description": "Shell command to run; stdin is /dev/null"},
+				"timeout": map[string]any{"type": "integer", "description": "Seconds before the command is interrupted (default 120)"},
+				"reset":   map[string]any{"type": "boolean", "description": "Kill the session and start a fresh shell before running command"},
+			},
+			"example": map[string]any{"command": "cd internal && go test ./..."},
+		},
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			command := strArg(args, "command")
+			reset, _ := args["reset"].(bool)
+			key := sessionKey(ctx)
+			if reset {
+				closeShellSession(key)
+				if command == "" {
+					return "shell session reset", nil
+				}
+			}
+			if command == "" {
+				return "", errors.New("missing command")
+			}
+			if err := refuseInDryRun(ctx, "shell_session"); err != nil {
+				return "", err
+			}
+			timeout := defaultSessionTimeout
+			if secs, ok := getIntArg(args, "timeout", 0); ok && secs > 0 {
+				timeout = time.Duration(secs) * time.Second
+			}
+			s, err := getShellSession(ctx, key)
+			if err != nil {
+				return "", err
+			}
+			emit := func(chunk string) {
+				trace.Emit(ctx, trace.EventToolOutput, map[string]any{"name": "shell_session", "output": chunk})
+			}
+			res := s.run(ctx, command, timeout, emit)
+			if res.dead {
+				dropShellSession(key, s)
+			}
+			return res.String(), nil
+		},
+	}
+}
+
+// sessionKey identifies the calling agent; tools called outside an agent
+// share one session.
+func sessionKey(ctx context.Context) string {
+	if id, ok := ctx.Value(contracts.AgentIDContextKey).(string); ok && id != "" {
+		return id
+	}
+	if name, ok := ctx.Value(contracts.AgentNameContextKey).(string); ok && name != "" {
+		return name
+	}
+	return "default"
+}
+
+func getShellSession(ctx context.Context, key string) (*shellSession, error) {
+	shellSessions.Lock()
+	defer shellSessions.Unlock()
+	if s := shellSessions.m[key]; s != nil {
+		select {
+		case <-s.done:
+		default:
+			return s, nil
+		}
+	}
+	s, err := startShellSession(sandboxFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	shellSessions.m[key] = s
+	return s, nil
+}
+
+func dropShellSession(key string, s *shellSession) {
+	shellSessions.Lock()
+	if shellSessions.m[key] == s {
+		delete(shellSessions.m, key)
+	}
+	shellSessions.Unlock()
+	s.kill()
+}
+
+func closeShellSession(key string) {
+	shellSessions.Lock()
+	s := shellSessions.m[key]
+	delete(shellSessions.m, key)
+	shellSessions.Unlock()
+	if s != nil {
+		s.kill()
+	}
+}
+
+// CloseShellSessions kills every persistent shell started by shell_session.
+func CloseShellSessions() {
+	shellSessions.Lock()
+	sessions := shellSessions.m
+	shellSessions.m = map[string]*shellSession{}
+	shellSessions.Unlock()
+	for _, s := range sessions {
+		s.kill()
+	}
+}
+
+// startShellSession starts bash (or sh) under policy p in its own
+// process group. The shell traps SIGINT so an interrupt stops the running
+// command but not the shell.
+func startShellSession(p sandbox.Policy) (*shellSession, error) {
+	argv := []string{"sh"}
+	if bash, err := exec.LookPath("bash"); err == nil {
+		argv = []string{bash, "--noprofile", "--norc"}
+	}
+	cmd, err := p.Command(context.Background(), "", argv)
+	if err != nil {
+		return nil, err
+	}
+	setSessionGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout, cmd.Stderr = pw, pw
+	if err := cmd.Start(); err != nil {
+		pr.Close()
+		pw.Close()
+		return nil, fmt.Errorf("start shell: %w", err)
+	}
+	pw.Close()
+
+	s := &shellSession{cmd: cmd, stdin: stdin, out: make(chan []byte, 64), done: make(chan struct{})}
+	go func() {
+		defer close(s.out)
+		defer pr.Close()
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := pr.Read(buf)
+			if n > 0 {
+				s.out <- append([]byte(nil), buf[:n]...)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		s.err = cmd.Wait()
+		close(s.done)
+	}()
+	if _, err := io.WriteString(stdin, "trap ':' INT\n"); err != nil {
+		s.kill()
+		return nil, fmt.Errorf("start shell: %w", err)
+	}
+	return s, nil
+}
+
+func (s *shellSession) kill() {
+	_ = s.stdin.Close()
+	killSessionGroup(s.cmd)
+	// Drain so the reader goroutine can finish.
+	go func() {
+		for range s.out {
+		}
+	}()
+}
+
+type sessionResult struct {
+	output      string
+	exit        int
+	cwd         string
+	interrupted string // why the command was interrupted, if it was
+	dead        bool   // the shell is gone and must be restarted
+	note        string
+}
+
+func (r sessionResult) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.output)
+	if r.output != "" && !strings.HasSuffix(r.output, "\n") {
+		sb.WriteByte('\n')
+	}
+	var meta []string
+	if r.interrupted != "" {
+		meta = append(meta, "interrupted: "+r.interrupted)
+	}
+	if r.note != "" {
+		meta = append(meta, r.note)
+	} else {
+		meta = append(meta, "exit code "+strconv.Itoa(r.exit))
+		if r.cwd != "" {
+			meta = append(meta, "cwd "+r.cwd)
+		}
+	}
+	sb.WriteString("[" + strings.Join(meta, " | ") + "]")
+	return sb.String()
+}
+
+// sessionOutput keeps the head and tail of a command's output when it
+// exceeds maxSessionOutput.
+type sessionOutput struct {
+	head, tail []byte
+	omitted    int
+}
+
+func (o *sessionOutput) add(b []byte) {
+	if room := maxSessionOutput/2 - len(o.head); room > 0 {
+		n := min(room, len(b))
+		o.head = append(o.head, b[:n]...)
+		b = b[n:]
+	}
+	o.tail = append(o.tail, b...)
+	if extra := len(o.tail) - maxSessionOutput/2; extra > 0 {
+		o.omitted += extra
+		o.tail = append(o.tail[:0], o.tail[extra:]...)
+	}
+}
+
+func (o *sessionOutput) String() string {
+	if o.omitted == 0 {
+		return string(o.head) + string(o.tail)
+	}
+	return string(o.head) + fmt.Sprintf("\n... [%d bytes omitted] ...\n", o.omitted) + string(o.tail)
+}
+
+// run sends one command to the shell and collects its output up to the
+// marker line, streaming it to emit as it arrives.
+func (s *shellSession) run(ctx context.Context, command string, timeout time.Duration, emit func(string)) sessionResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := randomMarker()
+	if err != nil {
+		return sessionResult{note: err.Error()}
+	}
+	marker := []byte("\n" + token + " ")
+	script := "eval " + shellQuote(command) + " </dev/null\nprintf '\\n%s %d %s\\n' " + token + " \"$?\" \"$PWD\"\n"
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		return sessionResult{dead: true, note: "shell is not running (" + err.Error() + "); a new session starts on the next call"}
+	}
+
+	var (
+		out     sessionOutput
+		pending []byte
+		res     sessionResult
+		grace   <-chan time.Time
+	)
+	commit := func(b []byte) {
+		if len(b) > 0 {
+			out.add(b)
+			emit(string(b))
+		}
+	}
+	interrupt := func(why string) {
+		if res.interrupted == "" {
+			res.interrupted = why
+			interruptSessionGroup(s.cmd)
+			grace = time.After(sessionInterruptGrace)
+		}
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	done := ctx.Done()
+
+	for {
+		if i := bytes.Index(pending, marker); i >= 0 {
+			status := pending[i+len(marker):]
+			if j := bytes.IndexByte(status, '\n'); j >= 0 {
+				commit(pending[:i])
+				code, cwd, _ := strings.Cut(string(status[:j]), " ")
+				res.exit, _ = strconv.Atoi(code)
+				res.cwd = cwd
+				res.output = out.String()
+				return res
+			}
+		} else if safe := len(pending) - len(marker) + 1; safe > 0 {
+			// Hold back a possible partial marker.
+			commit(pending[:safe])
+			pending = append(pending[:0], pending[safe:]...)
+		}
+
+		select {
+		case chunk, ok := <-s.out:
+			if !ok {
+				<-s.done
+				commit(pending)
+				res.output = out.String()
+				res.dead = true
+				res.note = "shell exited"
+				if s.err != nil {
+					res.note += " (" + s.err.Error() + ")"
+				}
+				res.note += "; a new session starts on the next call"
+				return res
+			}
+			pending = append(pending, chunk...)
+		case <-timer.C:
+			interrupt("timeout after " + timeout.String())
+		case <-done:
+			done = nil
+			interrupt("cancelled")
+		case <-grace:
+			commit(pending)
+			res.output = out.String()
+			res.dead = true
+			res.note = "command ignored the interrupt; shell killed, a new session starts on the next call"
+			return res
+		}
+	}
+}
+
+func randomMarker() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "__AGENTRY_DONE_" + hex.EncodeToString(b), nil
+}
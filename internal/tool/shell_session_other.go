@@ -0,0 +1,27 @@
+//go:build !unix
+
+package tool
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setSessionGroup(*exec.Cmd) {}
+
+func interruptSessionGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Signal(os.Interrupt)
+	}
+}
+
+func killSessionGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+// terminateSessionGroup kills the process; Windows has no SIGTERM.
+func terminateSessionGroup(cmd *exec.Cmd) {
+	killSessionGroup(cmd)
+}
@@ -0,0 +1,80 @@
+package tool
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+	"github.com/marcodenic/agentry/internal/trace"
+)
+
+func TestShellSession(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell_session is unix-only")
+	}
+	defer CloseShellSessions()
+	dir := t.TempDir()
+	ctx := context.WithValue(context.Background(), contracts.AgentIDContextKey, "session-test")
+	var streamed strings.Builder
+	ctx = trace.WithEmitter(ctx, func(typ trace.EventType, data any) {
+		if m, ok := data.(map[string]any); ok && typ == trace.EventToolOutput {
+			streamed.WriteString(m["output"].(string))
+		}
+	})
+	run := func(args map[string]any) string {
+		t.Helper()
+		out, err := builtinMap["shell_session"].Exec(ctx, args)
+		if err != nil {
+			t.Fatalf("shell_session %v: %v", args, err)
+		}
+		return out
+	}
+
+	out := run(map[string]any{"command": "cd " + shellQuote(dir) + " && export GREETING=hello"})
+	if !strings.Contains(out, "exit code 0") || !strings.Contains(out, "cwd "+dir) {
+		t.Fatalf("unexpected result: %q", out)
+	}
+	out = run(map[string]any{"command": `echo "$GREETING from $(pwd)"; echo oops >&2; false`})
+	if !strings.Contains(out, "hello from "+dir) || !strings.Contains(out, "oops") || !strings.Contains(out, "exit code 1") {
+		t.Fatalf("state not kept or exit code lost: %q", out)
+	}
+	if !strings.Contains(streamed.String(), "hello from") {
+		t.Fatalf("output not streamed to trace: %q", streamed.String())
+	}
+
+	// A timed-out command is interrupted but the shell survives.
+	out = run(map[string]any{"command": "sleep 30", "timeout": 1})
+	if !strings.Contains(out, "interrupted: timeout") {
+		t.Fatalf("expected interrupt, got %q", out)
+	}
+	out = run(map[string]any{"command": "echo $GREETING"})
+	if !strings.Contains(out, "hello") {
+		t.Fatalf("session lost after interrupt: %q", out)
+	}
+
+	// exit ends the shell; the next call starts fresh.
+	out = run(map[string]any{"command": "exit 3"})
+	if !strings.Contains(out, "shell exited") {
+		t.Fatalf("expected shell exit, got %q", out)
+	}
+	out = run(map[string]any{"command": "echo [$GREETING]"})
+	if !strings.Contains(out, "[]") {
+		t.Fatalf("expected fresh session, got %q", out)
+	}
+
+	run(map[string]any{"command": "export GREETING=again"})
+	if out := run(map[string]any{"reset": true}); out != "shell session reset" {
+		t.Fatalf("unexpected reset result %q", out)
+	}
+	if out := run(map[string]any{"command": "echo [$GREETING]"}); !strings.Contains(out, "[]") {
+		t.Fatalf("reset kept state: %q", out)
+	}
+
+	// Commands with quotes and syntax errors do not wedge the shell.
+	out = run(map[string]any{"command": `echo 'it'"'"'s'; if then`})
+	if !strings.Contains(out, "exit code 2") {
+		t.Fatalf("expected syntax error status, got %q", out)
+	}
+}
@@ -1,5 +1,7 @@
 package tool
 
+import "context"
+
 // TerminalAware is an optional interface a Tool can implement to signal that
 // a successful execution should normally terminate the agent loop without an
 // additional model reflection pass. Example: the "agent" delegation tool
@@ -14,6 +16,14 @@ type terminalTool struct{ Tool }
 
 func (t terminalTool) Terminal() bool { return true }
 
+// ExecPolicy implements PolicyTool for the wrapped tool.
+func (t terminalTool) ExecPolicy() ExecPolicy { return PolicyOf(t.Tool) }
+
+// ExecuteResult implements ResultTool for the wrapped tool.
+func (t terminalTool) ExecuteResult(ctx context.Context, args map[string]any) (Result, error) {
+	return Run(ctx, t.Tool, args)
+}
+
 // MarkTerminal wraps a Tool so the agent runtime can detect it and finalize
 // immediately after successful execution (if all tool calls in a step are
 // terminal and there are no errors).
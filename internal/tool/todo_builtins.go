@@ -59,21 +59,14 @@ func getTodo(ns, id string) (todoItem, bool, error) {
 }
 
 func listTodos(ns string) ([]todoItem, error) {
-	keys, err := memstore.Get().Keys(ns)
+	entries, err := memstore.Get().Scan(ns, "item:")
 	if err != nil {
 		return nil, err
 	}
 	var items []todoItem
-	for _, k := range keys {
-		if !strings.HasPrefix(k, "item:") {
-			continue
-		}
-		b, ok, err := memstore.Get().Get(ns, k)
-		if err != nil || !ok {
-			continue
-		}
+	for _, e := range entries {
 		var it todoItem
-		if json.Unmarshal(b, &it) == nil {
+		if json.Unmarshal(e.Value, &it) == nil {
 			items = append(items, it)
 		}
 	}
@@ -136,33 +129,8 @@ func init() {
 				"tags":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
 			},
 		},
-		Exec: func(ctx context.Context, args map[string]any) (string, error) {
-			ns := todoNamespace()
-			items, err := listTodos(ns)
-			if err != nil {
-				return "", err
-			}
-			status := strings.TrimSpace(strArg(args, "status"))
-			priority := strings.TrimSpace(strArg(args, "priority"))
-			tags := strSlice(args, "tags")
-			var out []todoItem
-		next:
-			for _, it := range items {
-				if status != "" && it.Status != status {
-					continue next
-				}
-				if priority != "" && it.Priority != priority {
-					continue next
-				}
-				if len(tags) > 0 {
-					if !hasAllTags(it.Tags, tags) {
-						continue next
-					}
-				}
-				out = append(out, it)
-			}
-			return marshal(map[string]any{"ok": true, "count": len(out), "items": out})
-		},
+		Exec:   textOf(todoList),
+		Result: todoList,
 	}
 
 	// Get
@@ -260,6 +228,31 @@ func init() {
 	}
 }
 
+// todoList implements todo_list; its data is the filtered items.
+func todoList(ctx context.Context, args map[string]any) (Result, error) {
+	items, err := listTodos(todoNamespace())
+	if err != nil {
+		return Result{}, err
+	}
+	status := strings.TrimSpace(strArg(args, "status"))
+	priority := strings.TrimSpace(strArg(args, "priority"))
+	tags := strSlice(args, "tags")
+	out := []todoItem{}
+	for _, it := range items {
+		if status != "" && it.Status != status {
+			continue
+		}
+		if priority != "" && it.Priority != priority {
+			continue
+		}
+		if len(tags) > 0 && !hasAllTags(it.Tags, tags) {
+			continue
+		}
+		out = append(out, it)
+	}
+	return jsonResult(map[string]any{"ok": true, "count": len(out), "items": out})
+}
+
 func strArg(args map[string]any, key string) string {
 	if v, ok := args[key].(string); ok {
 		return v
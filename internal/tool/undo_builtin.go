@@ -0,0 +1,108 @@
+package tool
+
+import (
+	"context"
+	"errors"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+	"github.com/marcodenic/agentry/internal/debug"
+	"github.com/marcodenic/agentry/internal/journal"
+)
+
+// recordWrite journals a write made by a file tool. before is the file as
+// captured by journal.Snapshot ahead of the write; tool names the builtin
+// when the write did not come from a model tool call. Dry-run writes never
+// reach the disk and are not journaled.
+func recordWrite(ctx context.Context, tool string, before journal.Change) {
+	noteWrite(ctx, tool, before.Path)
+	if dryRun(ctx) {
+		return
+	}
+	before.Tool = tool
+	if call, ok := ctx.Value(contracts.ToolCallContextKey).(contracts.ToolCall); ok {
+		before.CallID = call.ID
+		if call.Name != "" {
+			before.Tool = call.Name
+		}
+	}
+	before.AgentID, _ = ctx.Value(contracts.AgentIDContextKey).(string)
+	before.Agent, _ = ctx.Value(contracts.AgentNameContextKey).(string)
+	if _, err := journal.Default().Record(before); err != nil {
+		debug.Printf("journal: %s: %v", before.Path, err)
+	}
+}
+
+// changeSummary is how journal entries are reported to the model.
+func changeSummary(es []journal.Entry) []map[string]any {
+	out := make([]map[string]any, 0, len(es))
+	for _, e := range es {
+		m := map[string]any{"seq": e.Seq, "path": e.Path, "tool": e.Tool, "agent": e.AgentLabel(), "created": !e.Existed}
+		if e.CallID != "" {
+			m["call_id"] = e.CallID
+		}
+		if e.Undone {
+			m["undone"] = true
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func init() {
+	builtinMap["undo"] = builtinSpec{
+		Desc: "Undo file changes made by the file tools in this session, restoring each file exactly as it was. Defaults to your own most recent change; refuses if a file has changed since, unless force is set.",
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"last":    map[string]any{"type": "integer", "description": "Undo the last N changes (tool calls); default 1"},
+				"agent":   map[string]any{"type": "string", "description": "Agent name or ID whose changes to undo; \"all\" for every agent (default: yourself)"},
+				"call_id": map[string]any{"type": "string", "description": "Undo only the writes of this tool call"},
+				"force":   map[string]any{"type": "boolean", "description": "Restore even over later changes to the same files"},
+				"list":    map[string]any{"type": "boolean", "description": "List the session's changes instead of undoing"},
+			},
+			"required": []string{},
+			"example":  map[string]any{"last": 1},
+		},
+		Exec: func(ctx context.Context, args map[string]any) (string, error) {
+			if dryRun(ctx) {
+				return "", errors.New("undo is not available in a dry run; changes are only proposed")
+			}
+			j := journal.Default()
+			sel := journal.Selector{CallID: strArg(args, "call_id")}
+			sel.Last, _ = getIntArg(args, "last", 0)
+			switch agent := strArg(args, "agent"); agent {
+			case "all":
+			case "":
+				sel.Agent, _ = ctx.Value(contracts.AgentIDContextKey).(string)
+			default:
+				sel.Agent = agent
+			}
+			if list, _ := args["list"].(bool); list {
+				var es []journal.Entry
+				for _, e := range j.Entries() {
+					if sel.Agent == "" || e.Agent == sel.Agent || e.AgentID == sel.Agent {
+						es = append(es, e)
+					}
+				}
+				return marshal(map[string]any{"session": j.Session(), "changes": changeSummary(es)})
+			}
+			if sel.Last == 0 && sel.CallID == "" {
+				sel.Last = 1
+			}
+			force, _ := args["force"].(bool)
+			undone, err := j.Undo(sel, force)
+			if err != nil && len(undone) == 0 {
+				return "", err
+			}
+			for _, e := range undone {
+				noteWrite(ctx, "undo", e.Path)
+				_ = recordView(ctx, e.Path)
+			}
+			res := map[string]any{"session": j.Session(), "undone": changeSummary(undone)}
+			if err != nil {
+				res["error"] = err.Error()
+			}
+			return marshal(res)
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcodenic/agentry/internal/contracts"
+	"github.com/marcodenic/agentry/internal/journal"
+)
+
+// TestMain keeps the journal written by file tool tests out of the user's
+// cache directory, and lets network tools reach the tests' loopback servers.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "agentry-journal-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("AGENTRY_JOURNAL_DIR", dir)
+	os.Setenv("AGENTRY_HTTP_ALLOW_PRIVATE", "1")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestUndoFileTools(t *testing.T) {
+	j, err := journal.Open(t.TempDir(), "undo-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.SetDefault(j)
+	defer journal.SetDefault(nil)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	call := func(agent, id, name string, args map[string]any) string {
+		t.Helper()
+		ctx := context.WithValue(context.Background(), contracts.AgentIDContextKey, agent)
+		ctx = context.WithValue(ctx, contracts.ToolCallContextKey, contracts.ToolCall{ID: id, Name: name})
+		out, err := builtinMap[name].Exec(ctx, args)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return out
+	}
+
+	call("coder", "call-1", "create", map[string]any{"path": path, "content": "one\ntwo"})
+	call("coder", "call-2", "search_replace", map[string]any{"path": path, "search": "two", "replace": "2"})
+	call("coder", "call-3", "insert_at", map[string]any{"path": path, "line": 2, "content": "three"})
+	call("tester", "call-4", "write", map[string]any{"path": filepath.Join(dir, "other.txt"), "content": "x"})
+
+	es := j.Entries()
+	if len(es) != 4 || es[1].Tool != "search_replace" || es[1].CallID != "call-2" || es[1].AgentID != "coder" || !es[1].Existed {
+		t.Fatalf("journal entries: %+v", es)
+	}
+
+	// Without arguments an agent undoes its own last change.
+	out := call("coder", "call-5", "undo", map[string]any{})
+	if b, _ := os.ReadFile(path); string(b) != "one\n2" || !strings.Contains(out, `"tool":"insert_at"`) {
+		t.Fatalf("undo insert_at: %s\n%q", out, b)
+	}
+	call("coder", "call-6", "undo", map[string]any{"last": 2})
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("created file not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.txt")); err != nil {
+		t.Fatal("another agent's change was undone")
+	}
+	out = call("coder", "call-7", "undo", map[string]any{"list": true, "agent": "all"})
+	if strings.Count(out, `"undone":true`) != 3 {
+		t.Fatalf("list: %s", out)
+	}
+}
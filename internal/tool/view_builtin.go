@@ -2,10 +2,10 @@ package tool
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 )
 
@@ -71,17 +71,16 @@ func viewFileExec(ctx context.Context, args map[string]any) (string, error) {
 	maxLines, _ := getIntArg(args, "max_lines", 1000)
 
 	path = absPath(path)
-	if err := recordView(path); err != nil {
+	if err := recordView(ctx, path); err != nil {
 		return "", fmt.Errorf("failed to record view: %w", err)
 	}
 
-	file, err := os.Open(path)
+	data, err := readFile(ctx, path)
 	if err != nil {
 		return "", fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	var lines []string
 	currentLine := 1
 	linesRead := 0
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/marcodenic/agentry/internal/httpx"
 )
 
 func init() {
@@ -41,14 +43,16 @@ func init() {
 				"max_results": 5,
 			},
 		},
-		Exec: webSearchExec,
+		Policy: webPolicy,
+		Exec:   textOf(webSearch),
+		Result: webSearch,
 	}
 }
 
-func webSearchExec(ctx context.Context, args map[string]any) (string, error) {
+func webSearch(ctx context.Context, args map[string]any) (Result, error) {
 	query, _ := args["query"].(string)
 	if query == "" {
-		return "", errors.New("missing query")
+		return Result{}, errors.New("missing query")
 	}
 
 	provider := "duckduckgo"
@@ -74,11 +78,11 @@ func webSearchExec(ctx context.Context, args map[string]any) (string, error) {
 	case "google":
 		results, err = searchGoogle(ctx, query, maxResults)
 	default:
-		return "", fmt.Errorf("unsupported search provider: %s", provider)
+		return Result{}, fmt.Errorf("unsupported search provider: %s", provider)
 	}
 
 	if err != nil {
-		return "", fmt.Errorf("search failed: %w", err)
+		return Result{}, fmt.Errorf("search failed: %w", err)
 	}
 
 	// Ensure results is a JSON array even if provider returned nil
@@ -93,33 +97,25 @@ func webSearchExec(ctx context.Context, args map[string]any) (string, error) {
 		"timestamp": time.Now().Format(time.RFC3339),
 	}
 
-	jsonResult, _ := json.Marshal(response)
-	return string(jsonResult), nil
+	return jsonResult(response)
 }
 
 func searchDuckDuckGo(ctx context.Context, query string, maxResults int) ([]map[string]any, error) {
 	apiURL := fmt.Sprintf("https://api.duckduckgo.com/?q=%s&format=json&no_html=1&skip_disambig=1", url.QueryEscape(query))
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("User-Agent", "Agentry/1.0")
-
-	resp, err := client.Do(req)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := httpx.Default().Get(ctx, apiURL, map[string]string{"User-Agent": "Agentry/1.0"})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
 	}
 
 	var ddgResp map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&ddgResp); err != nil {
+	if err := json.Unmarshal(resp.Body, &ddgResp); err != nil {
 		return nil, err
 	}
 
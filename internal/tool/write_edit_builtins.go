@@ -5,9 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"time"
+
+	"github.com/marcodenic/agentry/internal/journal"
 )
 
 func init() {
@@ -17,10 +16,11 @@ func init() {
 		Schema: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
-				"file":    map[string]any{"type": "string", "description": "File path (alias: path)"},
-				"path":    map[string]any{"type": "string", "description": "File path (alias: file)"},
-				"content": map[string]any{"type": "string", "description": "Content to write (alias: text)"},
-				"text":    map[string]any{"type": "string", "description": "Content to write (alias: content)"},
+				"file":          map[string]any{"type": "string", "description": "File path (alias: path)"},
+				"path":          map[string]any{"type": "string", "description": "File path (alias: file)"},
+				"content":       map[string]any{"type": "string", "description": "Content to write (alias: text)"},
+				"text":          map[string]any{"type": "string", "description": "Content to write (alias: content)"},
+				"expected_hash": expectedHashSchema,
 			},
 			"required": []string{},
 			"example":  map[string]any{"file": "test.txt", "content": "hello"},
@@ -34,10 +34,11 @@ func init() {
 		Schema: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
-				"file":    map[string]any{"type": "string", "description": "File path (alias: path)"},
-				"path":    map[string]any{"type": "string", "description": "File path (alias: file)"},
-				"content": map[string]any{"type": "string", "description": "New content (alias: text)"},
-				"text":    map[string]any{"type": "string", "description": "New content (alias: content)"},
+				"file":          map[string]any{"type": "string", "description": "File path (alias: path)"},
+				"path":          map[string]any{"type": "string", "description": "File path (alias: file)"},
+				"content":       map[string]any{"type": "string", "description": "New content (alias: text)"},
+				"text":          map[string]any{"type": "string", "description": "New content (alias: content)"},
+				"expected_hash": expectedHashSchema,
 			},
 			"required": []string{},
 			"example":  map[string]any{"path": "test.txt", "text": "updated"},
@@ -68,35 +69,22 @@ func writeFileExec(ctx context.Context, args map[string]any) (string, error) {
 		return "", errors.New("missing path")
 	}
 	p = absPath(p)
+	defer lockFiles(p)()
 
-	// If we have a prior view record and file exists, ensure unchanged since view
-	if v, ok := viewedFiles.Load(p); ok {
-		if info, err := os.Stat(p); err == nil {
-			if ts, ok2 := v.(time.Time); ok2 {
-				if !info.ModTime().Equal(ts) {
-					return "", fmt.Errorf("file changed on disk since view: %s", p)
-				}
-			}
-		}
+	// Refuse to overwrite changes made since this agent last read the file
+	if err := checkForOverwrite(ctx, p, args); err != nil {
+		return "", err
 	}
 
-	// Ensure directory exists
-	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
-		return "", fmt.Errorf("failed to create directory: %w", err)
+	before := journal.Snapshot(p)
+	if err := writeFile(ctx, p, []byte(content), 0o644); err != nil {
+		return "", err
 	}
+	recordWrite(ctx, "write", before)
 
-	tmp := p + ".tmp"
-	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
-		return "", fmt.Errorf("failed to write temp file: %w", err)
-	}
-	if err := os.Rename(tmp, p); err != nil {
-		_ = os.Remove(tmp)
-		return "", fmt.Errorf("failed to move temp file: %w", err)
-	}
+	_ = recordView(ctx, p)
 
-	_ = recordView(p)
-
-	out := map[string]any{"path": p, "bytes": len(content)}
+	out := map[string]any{"path": p, "bytes": len(content), "hash": shortHash(contentHash([]byte(content)))}
 	b, _ := json.Marshal(out)
 	return string(b), nil
 }
@@ -107,33 +95,26 @@ func editFileExec(ctx context.Context, args map[string]any) (string, error) {
 		return "", errors.New("missing path")
 	}
 	p = absPath(p)
+	defer lockFiles(p)()
 
-	// Require prior view
-	v, ok := viewedFiles.Load(p)
-	if !ok {
+	// Require prior view of the current content
+	if _, ok := lastRead(ctx, p); !ok && strArg(args, "expected_hash") == "" {
 		return "", errors.New("cannot edit without prior view")
 	}
-	if info, err := os.Stat(p); err == nil {
-		if ts, ok2 := v.(time.Time); ok2 {
-			if !info.ModTime().Equal(ts) {
-				return "", fmt.Errorf("file changed on disk since view: %s", p)
-			}
-		}
-	} else {
+	if _, err := statFile(ctx, p); err != nil {
 		return "", fmt.Errorf("failed to stat file: %w", err)
 	}
-
-	tmp := p + ".tmp"
-	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
-		return "", fmt.Errorf("failed to write temp file: %w", err)
+	if err := checkForOverwrite(ctx, p, args); err != nil {
+		return "", err
 	}
-	if err := os.Rename(tmp, p); err != nil {
-		_ = os.Remove(tmp)
-		return "", fmt.Errorf("failed to move temp file: %w", err)
+	before := journal.Snapshot(p)
+	if err := writeFile(ctx, p, []byte(content), 0o644); err != nil {
+		return "", err
 	}
-	_ = recordView(p)
+	recordWrite(ctx, "edit", before)
+	_ = recordView(ctx, p)
 
-	out := map[string]any{"path": p, "edited": true, "bytes": len(content)}
+	out := map[string]any{"path": p, "edited": true, "bytes": len(content), "hash": shortHash(contentHash([]byte(content)))}
 	b, _ := json.Marshal(out)
 	return string(b), nil
 }
@@ -0,0 +1,44 @@
+package trace
+
+import "context"
+
+type ctxWriterKey struct{}
+
+// WithWriter returns a context whose trace events are also delivered to w.
+// Agents run with the context (including agents spawned by delegation)
+// report to it in addition to their own Tracer, so a caller can observe a
+// whole delegated run without knowing which agents it creates.
+func WithWriter(ctx context.Context, w Writer) context.Context {
+	if prev := WriterFrom(ctx); prev != nil {
+		w = NewMulti(prev, w)
+	}
+	return context.WithValue(ctx, ctxWriterKey{}, w)
+}
+
+// WriterFrom returns the writer attached by WithWriter, or nil.
+func WriterFrom(ctx context.Context) Writer {
+	if ctx == nil {
+		return nil
+	}
+	w, _ := ctx.Value(ctxWriterKey{}).(Writer)
+	return w
+}
+
+type ctxEmitKey struct{}
+
+// Emitter records an event on behalf of the agent that is running a tool.
+type Emitter func(typ EventType, data any)
+
+// WithEmitter returns a context through which tools can report events to
+// the agent that called them.
+func WithEmitter(ctx context.Context, e Emitter) context.Context {
+	return context.WithValue(ctx, ctxEmitKey{}, e)
+}
+
+// Emit reports an event through the context's Emitter. It is a no-op when
+// the tool was not called by an agent.
+func Emit(ctx context.Context, typ EventType, data any) {
+	if e, ok := ctx.Value(ctxEmitKey{}).(Emitter); ok && e != nil {
+		e(typ, data)
+	}
+}
@@ -16,8 +16,14 @@ type EventType string
 const (
 	EventStepStart EventType = "step_start"
 	// EventToolStart captures a tool invocation including parameters.
-	EventToolStart  EventType = "tool_start"
-	EventToolEnd    EventType = "tool_end"
+	EventToolStart EventType = "tool_start"
+	EventToolEnd   EventType = "tool_end"
+	// EventToolOutput carries output a tool produces while it is still
+	// running (see Emit).
+	EventToolOutput EventType = "tool_output"
+	// EventToolRetry reports a failed attempt the tool's execution policy
+	// retries.
+	EventToolRetry  EventType = "tool_retry"
 	EventFinal      EventType = "final"
 	EventModelStart EventType = "model_start"
 	// EventToken represents a streaming token from the AI response
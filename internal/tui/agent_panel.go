@@ -2,9 +2,13 @@ package tui
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/marcodenic/agentry/internal/glyphs"
+	"github.com/marcodenic/agentry/internal/journal"
 )
 
 // agentPanel renders the sidebar showing all agents and their status.
@@ -43,6 +47,7 @@ func (m Model) agentPanel(panelWidth int) string {
 		Render(statsLine))
 	lines = append(lines, "")
 
+	changes := changesByAgent()
 	for i, id := range m.order {
 		ag := m.infos[id]
 
@@ -134,6 +139,10 @@ func (m Model) agentPanel(panelWidth int) string {
 			}
 		}
 
+		if ag.Agent != nil {
+			lines = append(lines, m.changeLines(changes[ag.Agent.ID.String()], panelWidth)...)
+		}
+
 		lines = append(lines, "")
 	}
 
@@ -179,6 +188,31 @@ func (m Model) agentPanel(panelWidth int) string {
 		lines = append(lines, "")
 	}
 
+	// Shared workspace block, kept live by the store watch
+	if len(m.todos) > 0 || len(m.workspaceEvents) > 0 {
+		title := lipgloss.NewStyle().
+			Foreground(lipgloss.Color(m.theme.PanelTitleColor)).
+			Bold(true).
+			Render("📋 WORKSPACE")
+		lines = append(lines, title)
+		if len(m.todos) > 0 {
+			counts := map[string]int{}
+			for _, t := range m.todos {
+				counts[t.Status]++
+			}
+			lines = append(lines, fmt.Sprintf("  todo: %d  doing: %d  done: %d",
+				counts["todo"], counts["in_progress"], counts["done"]))
+		}
+		for _, ev := range m.workspaceEvents {
+			line := fmt.Sprintf("  %s %s: %s", ev.Timestamp.Format("15:04"), ev.AgentID, ev.Description)
+			if maxW := panelWidth - 2; maxW > 3 && lipgloss.Width(line) > maxW {
+				line = string([]rune(line)[:maxW-1]) + "…"
+			}
+			lines = append(lines, lipgloss.NewStyle().Faint(true).Render(line))
+		}
+		lines = append(lines, "")
+	}
+
 	if len(m.infos) > 0 {
 		lines = append(lines, lipgloss.NewStyle().
 			Foreground(lipgloss.Color(m.theme.Palette.Foreground)).
@@ -197,6 +231,52 @@ func (m Model) agentPanel(panelWidth int) string {
 	return lipgloss.JoinVertical(lipgloss.Left, lines...)
 }
 
+// changesByAgent groups this session's journalled writes that have not
+// been undone by agent ID, newest first.
+func changesByAgent() map[string][]journal.Entry {
+	out := map[string][]journal.Entry{}
+	es := journal.Default().Entries()
+	for i := len(es) - 1; i >= 0; i-- {
+		if !es[i].Undone {
+			out[es[i].AgentID] = append(out[es[i].AgentID], es[i])
+		}
+	}
+	return out
+}
+
+// changeLines lists the files an agent has changed, most recent first.
+func (m Model) changeLines(es []journal.Entry, panelWidth int) []string {
+	if len(es) == 0 {
+		return nil
+	}
+	var files []string
+	seen := map[string]bool{}
+	for _, e := range es {
+		if !seen[e.Path] {
+			seen[e.Path] = true
+			files = append(files, e.Path)
+		}
+	}
+	faint := lipgloss.NewStyle().Foreground(lipgloss.Color(m.theme.Palette.Foreground)).Faint(true)
+	lines := []string{faint.Render(fmt.Sprintf("  changes: %d files, %d writes", len(files), len(es)))}
+	wd, _ := os.Getwd()
+	for i, f := range files {
+		if i == 3 {
+			lines = append(lines, faint.Render(fmt.Sprintf("    +%d more (agentry undo --list)", len(files)-i)))
+			break
+		}
+		if rel, err := filepath.Rel(wd, f); err == nil && !strings.HasPrefix(rel, "..") {
+			f = rel
+		}
+		line := "    " + f
+		if maxW := panelWidth - 2; maxW > 8 && lipgloss.Width(line) > maxW {
+			line = "    …" + string([]rune(f)[len([]rune(f))-(maxW-5):])
+		}
+		lines = append(lines, faint.Render(line))
+	}
+	return lines
+}
+
 func severityGlyph(sev string) string {
 	switch sev {
 	case "warning":
@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marcodenic/agentry/internal/policy"
+)
+
+// approvalRequest is a call the permissions policy marks "ask", waiting for
+// the user's answer.
+type approvalRequest struct {
+	ctx    context.Context
+	call   policy.Call
+	d      policy.Decision
+	answer chan bool
+}
+
+// approvalMsg delivers a new approval request to the UI.
+type approvalMsg struct{ req approvalRequest }
+
+// approvals queues requests from running agents until the user answers
+// them, one at a time, with y or n.
+type approvals struct {
+	ch      chan approvalRequest
+	pending []approvalRequest
+}
+
+func newApprovals() approvals {
+	return approvals{ch: make(chan approvalRequest)}
+}
+
+// approver returns the policy.Approver for agents run from the TUI. It
+// blocks the call until the user answers or the run is cancelled, which
+// counts as a refusal.
+func (a approvals) approver() policy.Approver {
+	ch := a.ch
+	return func(ctx context.Context, c policy.Call, d policy.Decision) bool {
+		req := approvalRequest{ctx: ctx, call: c, d: d, answer: make(chan bool, 1)}
+		select {
+		case ch <- req:
+		case <-ctx.Done():
+			return false
+		}
+		select {
+		case ok := <-req.answer:
+			return ok
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// withApprover attaches the TUI's approver to ctx.
+func (m Model) withApprover(ctx context.Context) context.Context {
+	return policy.WithApprover(ctx, m.approvals.approver())
+}
+
+// waitForApproval blocks until an agent asks for approval.
+func waitForApproval(ch <-chan approvalRequest) tea.Cmd {
+	return func() tea.Msg {
+		return approvalMsg{req: <-ch}
+	}
+}
+
+// handleApproval queues the request and listens for the next one.
+func (m Model) handleApproval(msg approvalMsg) (Model, tea.Cmd) {
+	m.approvals.pending = append(m.approvals.pending, msg.req)
+	return m, waitForApproval(m.approvals.ch)
+}
+
+// current drops requests whose run has ended and returns the one being asked.
+func (a *approvals) current() (approvalRequest, bool) {
+	for len(a.pending) > 0 && a.pending[0].ctx.Err() != nil {
+		a.pending = a.pending[1:]
+	}
+	if len(a.pending) == 0 {
+		return approvalRequest{}, false
+	}
+	return a.pending[0], true
+}
+
+// handleApprovalKey answers the current request with y or n (Esc also
+// refuses). While a request is open other keys are ignored, except quit.
+func (m Model) handleApprovalKey(msg tea.KeyMsg) (Model, tea.Cmd, bool) {
+	req, ok := m.approvals.current()
+	if !ok {
+		return m, nil, false
+	}
+	switch msg.String() {
+	case "y", "Y":
+		req.answer <- true
+	case "n", "N", "esc":
+		req.answer <- false
+	case m.keys.Quit:
+		return m, nil, false
+	default:
+		return m, nil, true
+	}
+	m.approvals.pending = m.approvals.pending[1:]
+	return m, nil, true
+}
+
+// approvalPrompt renders the current request in place of the input, cut to
+// width.
+func (m Model) approvalPrompt(width int) (string, bool) {
+	req, ok := m.approvals.current()
+	if !ok {
+		return "", false
+	}
+	args, _ := json.Marshal(req.call.Args)
+	s := fmt.Sprintf("⚠️  Policy %s asks before running %s %s", req.d.Rule, req.call.Tool, args)
+	if req.d.Reason != "" {
+		s += " (" + req.d.Reason + ")"
+	}
+	const suffix = " Allow? [y/N]"
+	if r, max := []rune(s), width-len(suffix); max > 3 && len(r) > max {
+		s = string(r[:max-3]) + "..."
+	}
+	return s + suffix, true
+}
@@ -3,7 +3,6 @@ package tui
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 
@@ -12,6 +11,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/google/uuid"
 	"github.com/marcodenic/agentry/internal/team"
+	"github.com/marcodenic/agentry/internal/tool"
 	"github.com/marcodenic/agentry/internal/trace"
 )
 
@@ -53,7 +53,7 @@ func (m Model) startAgent(id uuid.UUID, input string) (Model, tea.Cmd) {
 	// Increase scanner buffer to handle large JSONL trace events (e.g., big tool results)
 	// Default is 64K which is too small for some tool outputs.
 	info.Scanner.Buffer(make([]byte, 0, 256*1024), 4*1024*1024)
-	ctx := team.WithContext(context.Background(), m.team)
+	ctx := m.withApprover(team.WithContext(context.Background(), m.team))
 	ctx, cancel := context.WithCancel(ctx)
 	info.Cancel = cancel
 	m.infos[id] = info
@@ -70,7 +70,7 @@ func (m Model) startAgent(id uuid.UUID, input string) (Model, tea.Cmd) {
 	return m, tea.Batch(m.readCmd(id), waitErr(errCh), waitComplete(id, completeCh), startThinkingAnimation(id))
 }
 
-// handleDiagnostics triggers lsp_diagnostics tool and parses results
+// handleDiagnostics runs the lsp_diagnostics tool on Agent 0's registry
 func (m Model) handleDiagnostics() (Model, tea.Cmd) {
 	if m.diagRunning {
 		return m, nil
@@ -92,24 +92,9 @@ func (m Model) handleDiagnostics() (Model, tea.Cmd) {
 		if !ok {
 			return errMsg{error: fmt.Errorf("lsp_diagnostics tool not available")}
 		}
-		out, err := tl.Execute(context.Background(), map[string]any{})
-		type res struct {
-			Diagnostics []struct {
-				File     string `json:"file"`
-				Line     int    `json:"line"`
-				Col      int    `json:"col"`
-				Code     string `json:"code"`
-				Severity string `json:"severity"`
-				Message  string `json:"message"`
-			} `json:"diagnostics"`
-			Ok    bool   `json:"ok"`
-			Error string `json:"error"`
-		}
-		var r res
-		if err == nil {
-			_ = json.Unmarshal([]byte(out), &r)
-		}
-		return toolUseMsg{id: m.active, name: "lsp_diagnostics", args: map[string]any{"result": r, "raw": out, "err": err}}
+		// A failed run still ends the diagnostics status, with no results.
+		res, _ := tool.Run(m.withApprover(context.Background()), tl, map[string]any{})
+		return toolUseMsg{id: m.active, name: "lsp_diagnostics", data: res.Data}
 	}
 }
 
@@ -23,6 +23,7 @@ import (
 	"github.com/marcodenic/agentry/internal/cost"
 	"github.com/marcodenic/agentry/internal/debug"
 	"github.com/marcodenic/agentry/internal/glyphs"
+	"github.com/marcodenic/agentry/internal/overlay"
 	"github.com/marcodenic/agentry/internal/statusbar"
 	"github.com/marcodenic/agentry/internal/team"
 )
@@ -115,6 +116,14 @@ type Model struct {
 
 	// TODO Board
 	todoBoard TodoBoard
+	todos     []TodoItem
+
+	// Live shared store subscriptions and the workspace events they refresh
+	watch           storeWatch
+	workspaceEvents []team.WorkspaceEvent
+
+	// Calls the permissions policy asks about, waiting for y or n
+	approvals approvals
 
 	// Dynamic input sizing and history
 	inputHeight  int
@@ -197,6 +206,15 @@ func New(ag *core.Agent) Model {
 	return NewWithConfig(ag, nil, "")
 }
 
+// Overlay returns the overlay holding the session's dry-run file changes,
+// or nil when nothing ran dry.
+func (m Model) Overlay() *overlay.FS {
+	if m.team == nil {
+		return nil
+	}
+	return m.team.Overlay()
+}
+
 // NewWithConfig creates a new TUI model bound to an Agent with optional config.
 func NewWithConfig(ag *core.Agent, includePaths []string, configDir string) Model {
 	th := LoadTheme()
@@ -408,6 +426,9 @@ func NewWithConfig(ag *core.Agent, includePaths []string, configDir string) Mode
 		statusBarModel:  statusBarModel,
 		pricing:         cost.NewPricingTable(),
 		todoBoard:       NewTodoBoard(),
+		watch:           newStoreWatch(tm),
+		workspaceEvents: tm.GetWorkspaceEvents(workspaceEventLimit),
+		approvals:       newApprovals(),
 	}
 	return m
 }
@@ -449,6 +470,7 @@ func (m *Model) Cleanup() {
 			m.infos[id] = info
 		}
 	}
+	m.watch.stop()
 }
 
 // addContentWithSpacing adds content to agent history with proper spacing based on content type transitions
@@ -79,6 +79,7 @@ func (m Model) handleQuit() (Model, tea.Cmd) {
 			m.infos[id] = info
 		}
 	}
+	m.watch.stop()
 	return m, tea.Quit
 }
 
@@ -27,10 +27,12 @@ type tokenStreamTick struct {
 	position int
 }
 
+// toolUseMsg reports a finished tool call; data is the structured part of
+// its result, if any (see tool.Result).
 type toolUseMsg struct {
 	id   uuid.UUID
 	name string
-	args map[string]any
+	data any
 }
 
 type thinkingAnimationMsg struct {
@@ -124,9 +126,13 @@ func (m *Model) readEvent(id uuid.UUID) tea.Msg {
 			}
 		case trace.EventToolEnd:
 			if m2, ok := ev.Data.(map[string]any); ok {
+				// Failed calls leave their status line pending, as before
+				// tool_end carried errors.
+				if _, failed := m2["error"]; failed {
+					continue
+				}
 				if name, ok := m2["name"].(string); ok {
-					args, _ := m2["args"].(map[string]any)
-					return toolUseMsg{id: id, name: name, args: args}
+					return toolUseMsg{id: id, name: name, data: m2["data"]}
 				}
 			}
 		default:
@@ -11,6 +11,7 @@ import (
 	"github.com/marcodenic/agentry/internal/core"
 	"github.com/marcodenic/agentry/internal/memory"
 	"github.com/marcodenic/agentry/internal/model"
+	"github.com/marcodenic/agentry/internal/policy"
 	"github.com/marcodenic/agentry/internal/tool"
 )
 
@@ -83,3 +84,43 @@ func TestWindowSizing(t *testing.T) {
 		t.Fatalf("agent should be running after receiving input, got status: %v", activeInfo.Status)
 	}
 }
+
+func TestDiagnosticsFromToolEnd(t *testing.T) {
+	ag := core.New(model.NewMock(), "mock", tool.Registry{}, memory.NewInMemory(), memory.NewInMemoryVector(), nil)
+	m := New(ag)
+	m.diagRunning = true
+	// Diagnostics an agent ran arrive as the decoded JSON of a tool_end event.
+	data := map[string]any{"ok": false, "diagnostics": []any{
+		map[string]any{"file": "main.go", "line": 3.0, "col": 2.0, "severity": "error", "message": "undefined: x"},
+	}}
+	nm, _ := m.Update(toolUseMsg{id: m.active, name: "lsp_diagnostics", data: data})
+	m = nm.(Model)
+	if m.diagRunning || len(m.diags) != 1 || m.diags[0].Line != 3 || m.diags[0].Message != "undefined: x" {
+		t.Fatalf("diags: %+v running=%v", m.diags, m.diagRunning)
+	}
+}
+
+func TestApprovalPrompt(t *testing.T) {
+	ag := core.New(model.NewMock(), "mock", tool.Registry{}, memory.NewInMemory(), memory.NewInMemoryVector(), nil)
+	m := New(ag)
+	approve := m.approvals.approver()
+	got := make(chan bool, 1)
+	go func() {
+		got <- approve(context.Background(), policy.Call{Tool: "bash", Args: map[string]any{"command": "git push"}}, policy.Decision{Action: policy.Ask, Rule: "push"})
+	}()
+	nm, _ := m.Update(waitForApproval(m.approvals.ch)())
+	m = nm.(Model)
+	if prompt, ok := m.approvalPrompt(200); !ok || !strings.Contains(prompt, "git push") {
+		t.Fatalf("prompt: %q %v", prompt, ok)
+	}
+	nm, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	m = nm.(Model)
+	if m.input.Value() != "" || len(m.approvals.pending) != 1 {
+		t.Fatalf("keys other than y/n must not reach the input: %q", m.input.Value())
+	}
+	nm, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	m = nm.(Model)
+	if !<-got || len(m.approvals.pending) != 0 {
+		t.Fatal("y should approve the call")
+	}
+}
@@ -1,7 +1,10 @@
 package tui
 
 import (
+	"encoding/json"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marcodenic/agentry/internal/tool"
 )
 
 // handleToolUseMessage processes tool usage messages (tool completion)
@@ -12,25 +15,12 @@ func (m Model) handleToolUseMessage(msg toolUseMsg) (Model, tea.Cmd) {
 	// Complete the progressive status update (add green tick and change bar color)
 	info.completeProgressiveStatusUpdate(m)
 
-	// Special handling for diagnostics tool to store structured results
+	// Diagnostics feed the panel from the tool's structured result.
 	if msg.name == "lsp_diagnostics" {
-		if r, ok := msg.args["result"].(map[string]any); ok {
-			// reset list
+		if rep, ok := diagnosticsReport(msg.data); ok {
 			m.diags = nil
-			if arr, ok := r["diagnostics"].([]any); ok {
-				for _, it := range arr {
-					if m2, ok := it.(map[string]any); ok {
-						d := Diag{
-							File:     strVal(m2["file"]),
-							Line:     intVal(m2["line"]),
-							Col:      intVal(m2["col"]),
-							Code:     strVal(m2["code"]),
-							Severity: strVal(m2["severity"]),
-							Message:  strVal(m2["message"]),
-						}
-						m.diags = append(m.diags, d)
-					}
-				}
+			for _, d := range rep.Diagnostics {
+				m.diags = append(m.diags, Diag{File: d.File, Line: d.Line, Col: d.Col, Code: d.Code, Severity: d.Severity, Message: d.Message})
 			}
 		}
 		m.diagRunning = false
@@ -85,22 +75,20 @@ func (m Model) handleModelMessage(msg modelMsg) (Model, tea.Cmd) {
 	return m, m.readCmd(msg.id)
 }
 
-// Helpers to decode numbers/strings from any
-func strVal(v any) string {
-	if s, ok := v.(string); ok {
-		return s
-	}
-	return ""
-}
-func intVal(v any) int {
-	switch t := v.(type) {
-	case float64:
-		return int(t)
-	case int:
-		return t
-	case int64:
-		return int(t)
-	default:
-		return 0
+// diagnosticsReport returns the lsp_diagnostics result in data: the value
+// itself when the tool ran in this process, or its JSON form when it came
+// through a trace event.
+func diagnosticsReport(data any) (tool.DiagnosticsReport, bool) {
+	switch d := data.(type) {
+	case tool.DiagnosticsReport:
+		return d, true
+	case map[string]any:
+		b, err := json.Marshal(d)
+		if err != nil {
+			return tool.DiagnosticsReport{}, false
+		}
+		var rep tool.DiagnosticsReport
+		return rep, json.Unmarshal(b, &rep) == nil
 	}
+	return tool.DiagnosticsReport{}, false
 }
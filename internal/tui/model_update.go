@@ -17,6 +17,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// An open approval prompt takes every key but quit.
+		if m, cmd, handled := m.handleApprovalKey(msg); handled {
+			return m, cmd
+		}
 		var cmd tea.Cmd
 		m, cmd = m.handleKeyMessages(msg)
 		if cmd != nil {
@@ -67,6 +71,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleThinkingAnimation(msg)
 	case tea.WindowSizeMsg:
 		return m.handleWindowResize(msg)
+	case storeEventMsg:
+		return m.handleStoreEvent(msg)
+	case approvalMsg:
+		return m.handleApproval(msg)
+	case todoMsg:
+		m.todos = msg.items
+		var cmd tea.Cmd
+		m.todoBoard, cmd = m.todoBoard.Update(msg)
+		return m, cmd
 	}
 
 	// Handle viewport scrolling based on active tab
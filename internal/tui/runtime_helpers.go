@@ -100,6 +100,27 @@ func (m *Model) formatToolAction(toolName string, args map[string]any) string {
 			return fmt.Sprintf("%s Replacing %q -> %q in %s", glyphs.YellowStar(), sr, rp, path)
 		}
 		return glyphs.YellowStar() + " Search/replace in file"
+	case "go_outline", "go_replace_func", "go_add_method", "go_ensure_import", "go_remove_import", "go_rename_local":
+		path, _ := args["path"].(string)
+		switch toolName {
+		case "go_outline":
+			return fmt.Sprintf("%s Outlining %s", glyphs.BlueCircle(), path)
+		case "go_replace_func":
+			name, _ := args["name"].(string)
+			return fmt.Sprintf("%s Replacing %s in %s", glyphs.YellowStar(), name, path)
+		case "go_rename_local":
+			oldName, _ := args["old"].(string)
+			newName, _ := args["new"].(string)
+			return fmt.Sprintf("%s Renaming %s to %s in %s", glyphs.YellowStar(), oldName, newName, path)
+		case "go_ensure_import", "go_remove_import":
+			imp, _ := args["import"].(string)
+			verb := "Importing"
+			if toolName == "go_remove_import" {
+				verb = "Removing import"
+			}
+			return fmt.Sprintf("%s %s %s in %s", glyphs.YellowStar(), verb, imp, path)
+		}
+		return fmt.Sprintf("%s Adding method to %s", glyphs.YellowStar(), path)
 	case "create":
 		if path, ok := args["path"].(string); ok && path != "" {
 			return fmt.Sprintf("%s Creating %s", glyphs.GreenCheckmark(), path)
@@ -110,11 +131,16 @@ func (m *Model) formatToolAction(toolName string, args map[string]any) string {
 			return fmt.Sprintf("%s Listing %s", glyphs.BlueCircle(), path)
 		}
 		return glyphs.BlueCircle() + " Listing directory"
-	case "bash", "powershell", "cmd":
+	case "bash", "shell_session", "powershell", "cmd":
 		if cmd, ok := args["command"].(string); ok && cmd != "" {
 			return fmt.Sprintf("%s Running: %s", glyphs.OrangeTriangle(), truncateString(cmd, 80))
 		}
 		return glyphs.OrangeTriangle() + " Running command"
+	case "run_tests":
+		if filter, ok := args["filter"].(string); ok && filter != "" {
+			return fmt.Sprintf("%s Running tests matching %s", glyphs.OrangeTriangle(), truncateString(filter, 60))
+		}
+		return glyphs.OrangeTriangle() + " Running tests"
 	case "agent":
 		if agent, ok := args["agent"].(string); ok {
 			if input, ok := args["input"].(string); ok && input != "" {
@@ -163,6 +189,24 @@ func (m *Model) formatToolAction(toolName string, args map[string]any) string {
 			return fmt.Sprintf("%s Web search (%s): %s", glyphs.YellowStar(), provider, truncateString(query, 80))
 		}
 		return glyphs.YellowStar() + " Web search"
+	case "lsp_definition", "lsp_references", "lsp_hover", "lsp_rename":
+		target, _ := args["symbol"].(string)
+		if target == "" {
+			target, _ = args["path"].(string)
+		}
+		verb := map[string]string{"lsp_definition": "Definition of", "lsp_references": "References to", "lsp_hover": "Hover on", "lsp_rename": "Renaming"}[toolName]
+		if newName, ok := args["new_name"].(string); ok && newName != "" {
+			return fmt.Sprintf("%s Renaming %s to %s", glyphs.YellowStar(), truncateString(target, 60), newName)
+		}
+		return fmt.Sprintf("%s %s %s", glyphs.BlueCircle(), verb, truncateString(target, 80))
+	case "lsp_symbols":
+		if q, ok := args["query"].(string); ok && q != "" {
+			return fmt.Sprintf("%s Searching symbols: %s", glyphs.BlueCircle(), truncateString(q, 60))
+		}
+		if path, ok := args["path"].(string); ok && path != "" {
+			return fmt.Sprintf("%s Outlining %s", glyphs.BlueCircle(), path)
+		}
+		return glyphs.BlueCircle() + " Listing symbols"
 	case "lsp_diagnostics":
 		// Show brief info about scope
 		if paths, ok := args["paths"].([]any); ok && len(paths) > 0 {
@@ -193,6 +237,30 @@ func (m *Model) formatToolAction(toolName string, args map[string]any) string {
 			desc += " (dirs only)"
 		}
 		return desc
+	case "undo":
+		if list, _ := args["list"].(bool); list {
+			return glyphs.BlueCircle() + " Listing file changes"
+		}
+		if agent, ok := args["agent"].(string); ok && agent != "" {
+			return fmt.Sprintf("%s Undoing changes by %s", glyphs.YellowStar(), agent)
+		}
+		return glyphs.YellowStar() + " Undoing last change"
+	case "repo_map":
+		if q, ok := args["query"].(string); ok && q != "" {
+			return fmt.Sprintf("%s Mapping repository for %q", glyphs.BlueCircle(), truncateString(q, 50))
+		}
+		return glyphs.BlueCircle() + " Mapping repository"
+	case "lease":
+		switch action, _ := args["action"].(string); action {
+		case "list":
+			return glyphs.BlueCircle() + " Listing file leases"
+		case "release":
+			return glyphs.YellowStar() + " Releasing file leases"
+		}
+		if owner, ok := args["owner"].(string); ok && owner != "" {
+			return fmt.Sprintf("%s Leasing files to %s", glyphs.YellowStar(), owner)
+		}
+		return glyphs.YellowStar() + " Leasing files"
 	case "fetch":
 		if url, ok := args["url"].(string); ok {
 			return fmt.Sprintf("%s Fetching %s", glyphs.BlueCircle(), url)
@@ -236,6 +304,11 @@ func (m Model) Init() tea.Cmd {
 		cmds = append(cmds, info.Spinner.Tick)
 	}
 
+	// Load the TODO board and follow shared store changes from here on
+	cmds = append(cmds, LoadTodos())
+	cmds = append(cmds, m.watch.cmds()...)
+	cmds = append(cmds, waitForApproval(m.approvals.ch))
+
 	return tea.Batch(cmds...)
 }
 
@@ -336,7 +409,9 @@ func (m *Model) addDebugTraceEvent(id uuid.UUID, ev trace.Event) {
 	case trace.EventToolEnd:
 		if m2, ok := ev.Data.(map[string]any); ok {
 			if name, ok := m2["name"].(string); ok {
-				if result, ok := m2["result"].(string); ok {
+				if e, ok := m2["error"].(string); ok {
+					details = fmt.Sprintf("Tool %s failed (%v): %s", name, m2["error_class"], truncateString(e, 200))
+				} else if result, ok := m2["result"].(string); ok {
 					displayResult := result
 					if len(result) > 200 {
 						displayResult = result[:200] + "... [truncated]"
@@ -347,6 +422,11 @@ func (m *Model) addDebugTraceEvent(id uuid.UUID, ev trace.Event) {
 				}
 			}
 		}
+	case trace.EventToolOutput:
+		if m2, ok := ev.Data.(map[string]any); ok {
+			out, _ := m2["output"].(string)
+			details = fmt.Sprintf("Tool %v output: %s", m2["name"], truncateString(out, 200))
+		}
 	case trace.EventFinal:
 		if result, ok := ev.Data.(string); ok && result != "" {
 			details = fmt.Sprintf("Final result: %s", truncateString(result, 150))
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/marcodenic/agentry/internal/memstore"
+	"github.com/marcodenic/agentry/internal/team"
+)
+
+// workspaceEventLimit is how many recent workspace events the agent panel shows.
+const workspaceEventLimit = 3
+
+// storeEventMsg carries a shared store change from one of the TUI's watches.
+type storeEventMsg struct {
+	ev memstore.Event
+	ch <-chan memstore.Event
+}
+
+// storeWatch holds the live subscriptions that keep the TODO board, workspace
+// events and agent panel in sync with the shared store.
+type storeWatch struct {
+	cancel context.CancelFunc
+	todos  <-chan memstore.Event
+	team   <-chan memstore.Event
+}
+
+func newStoreWatch(tm *team.Team) storeWatch {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := storeWatch{cancel: cancel}
+	if ch, err := memstore.Get().Watch(ctx, todoNamespace(), "item:"); err == nil {
+		w.todos = ch
+	}
+	if tm != nil {
+		if ch, err := tm.Watch(ctx); err == nil {
+			w.team = ch
+		}
+	}
+	return w
+}
+
+func (w storeWatch) stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+}
+
+// cmds returns the commands that start listening on each subscription.
+func (w storeWatch) cmds() []tea.Cmd {
+	var cmds []tea.Cmd
+	for _, ch := range []<-chan memstore.Event{w.todos, w.team} {
+		if ch != nil {
+			cmds = append(cmds, waitForStoreEvent(ch))
+		}
+	}
+	return cmds
+}
+
+// waitForStoreEvent blocks until ch delivers an event. It returns nil once the
+// subscription is closed, which ends the listen loop.
+func waitForStoreEvent(ch <-chan memstore.Event) tea.Cmd {
+	return func() tea.Msg {
+		ev, ok := <-ch
+		if !ok {
+			return nil
+		}
+		return storeEventMsg{ev: ev, ch: ch}
+	}
+}
+
+// handleStoreEvent refreshes whatever the event touched and re-arms the watch.
+func (m Model) handleStoreEvent(msg storeEventMsg) (Model, tea.Cmd) {
+	cmds := []tea.Cmd{waitForStoreEvent(msg.ch)}
+	if msg.ch == m.watch.todos {
+		cmds = append(cmds, LoadTodos())
+	} else if m.team != nil {
+		m.workspaceEvents = m.team.GetWorkspaceEvents(workspaceEventLimit)
+	}
+	return m, tea.Batch(cmds...)
+}
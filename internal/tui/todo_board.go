@@ -196,24 +196,15 @@ func LoadTodos() tea.Cmd {
 		ns := todoNamespace()
 
 		// List all TODO items
-		keys, err := memstore.Get().Keys(ns)
+		entries, err := memstore.Get().Scan(ns, "item:")
 		if err != nil {
 			return todoMsg{items: []TodoItem{}}
 		}
 
 		var items []TodoItem
-		for _, key := range keys {
-			if !strings.HasPrefix(key, "item:") {
-				continue
-			}
-
-			data, ok, err := memstore.Get().Get(ns, key)
-			if err != nil || !ok {
-				continue
-			}
-
+		for _, e := range entries {
 			var item TodoItem
-			if err := json.Unmarshal(data, &item); err != nil {
+			if err := json.Unmarshal(e.Value, &item); err != nil {
 				continue
 			}
 
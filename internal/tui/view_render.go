@@ -124,6 +124,9 @@ func (m Model) View() string {
 
 	// Render input as-is to avoid double-wrapping/cropping by lipgloss
 	inputSection := m.input.View()
+	if prompt, ok := m.approvalPrompt(m.width); ok {
+		inputSection = lipgloss.NewStyle().Foreground(lipgloss.Color(m.theme.AIBarColor)).Render(prompt)
+	}
 
 	// Stack everything vertically
 	content := lipgloss.JoinVertical(lipgloss.Left, topSection, horizontalLine, inputSection)
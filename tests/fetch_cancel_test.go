@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/marcodenic/agentry/internal/httpx"
 	"github.com/marcodenic/agentry/internal/tool"
 )
 
@@ -28,6 +29,16 @@ func TestFetchCanceledContext(t *testing.T) {
 	defer server.Shutdown(context.Background())
 	url := "http://" + listener.Addr().String()
 
+	// The test server is on loopback, which the network tools refuse by
+	// default.
+	allowLocal, err := httpx.New(httpx.Config{AllowPrivate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := httpx.Default()
+	httpx.SetDefault(allowLocal)
+	defer httpx.SetDefault(prev)
+
 	tl, ok := tool.DefaultRegistry().Use("fetch")
 	if !ok {
 		t.Fatal("fetch tool not found")
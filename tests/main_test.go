@@ -13,5 +13,7 @@ func TestMain(m *testing.M) {
 		// Integration suite requires network and local listeners; skip unless explicitly enabled.
 		os.Exit(0)
 	}
+	// Tests serve on loopback, which the network client refuses by default.
+	os.Setenv("AGENTRY_HTTP_ALLOW_PRIVATE", "1")
 	os.Exit(m.Run())
 }
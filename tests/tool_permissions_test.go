@@ -3,16 +3,21 @@ package tests
 import (
 	"context"
 	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/marcodenic/agentry/internal/core"
+	"github.com/marcodenic/agentry/internal/memory"
+	"github.com/marcodenic/agentry/internal/sandbox"
+	"github.com/marcodenic/agentry/internal/team"
 	"github.com/marcodenic/agentry/internal/tool"
 )
 
 func TestToolPermissions(t *testing.T) {
-	tool.SetPermissions([]string{"echo"})
-	defer tool.SetPermissions(nil)
-
-	reg := tool.DefaultRegistry()
+	reg := tool.NewPermissions([]string{"echo"}, nil, nil).Apply(tool.DefaultRegistry())
 	tl, ok := reg.Use("echo")
 	if !ok {
 		t.Fatal("echo tool missing")
@@ -29,3 +34,94 @@ func TestToolPermissions(t *testing.T) {
 		t.Fatalf("expected denial, got %v", err)
 	}
 }
+
+func TestToolPermissionsPerRegistry(t *testing.T) {
+	parent := tool.NewPermissions([]string{"echo", "ping"}, nil, nil)
+	child := parent.Narrow([]string{"echo", "ls"}, []string{"ping"})
+
+	// The child cannot gain ls, which the parent never had.
+	for name, want := range map[string]bool{"echo": true, "ping": false, "ls": false} {
+		if got := child.Allows(name); got != want {
+			t.Errorf("child.Allows(%s) = %v, want %v", name, got, want)
+		}
+	}
+	if !parent.Allows("ping") {
+		t.Fatal("narrowing the child changed the parent")
+	}
+
+	// Registries with different permissions are enforced side by side.
+	open := tool.DefaultRegistry()
+	narrow := child.Apply(tool.DefaultRegistry())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			args := map[string]any{"text": "hi"}
+			if _, err := open["ls"].Execute(context.Background(), map[string]any{"path": "."}); err != nil {
+				t.Errorf("unrestricted ls failed: %v", err)
+			}
+			if _, err := narrow["ls"].Execute(context.Background(), nil); !errors.Is(err, tool.ErrToolDenied) {
+				t.Errorf("restricted ls: expected denial, got %v", err)
+			}
+			if _, err := narrow["echo"].Execute(context.Background(), args); err != nil {
+				t.Errorf("restricted echo failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestToolPermissionsSandboxPerRegistry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses pwd")
+	}
+	pwd := tool.Registry{"pwd": tool.New("pwd", "Print the working directory", func(ctx context.Context, _ map[string]any) (string, error) {
+		return tool.ExecDirect(ctx, "pwd")
+	})}
+	parentDir, childDir := t.TempDir(), t.TempDir()
+	parent := tool.NewPermissions(nil, nil, nil).Sandboxed(sandbox.Policy{Root: parentDir})
+	child := parent.Narrow(nil, nil).Sandboxed(sandbox.Policy{Root: childDir})
+	if parent.Narrow(nil, nil).Sandbox().Root != parentDir {
+		t.Fatal("Narrow dropped the sandbox")
+	}
+
+	// Each registry runs commands under its own sandbox, even when the
+	// child is called from within a parent's tool call.
+	ctx := tool.WithSandbox(context.Background(), sandbox.Policy{Root: parentDir})
+	for reg, want := range map[string]string{"parent": parentDir, "child": childDir} {
+		perms := parent
+		if reg == "child" {
+			perms = child
+		}
+		out, err := perms.Apply(pwd)["pwd"].Execute(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := filepath.EvalSymlinks(strings.TrimSpace(out))
+		if wantReal, _ := filepath.EvalSymlinks(want); got != wantReal {
+			t.Errorf("%s ran in %s, want %s", reg, got, wantReal)
+		}
+	}
+}
+
+func TestSpawnedAgentInheritsPermissions(t *testing.T) {
+	ag := core.New(staticClient{out: "ok"}, "mock", tool.DefaultRegistry(), memory.NewInMemory(), memory.NewInMemoryVector(), nil)
+	ag.Permissions = tool.NewPermissions([]string{"echo"}, nil, nil)
+	tm, err := team.NewTeam(ag, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err := tm.SpawnAgent(context.Background(), "w", "coder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if worker.Agent.Permissions.Allows("ls") {
+		t.Fatal("worker gained ls, which the parent does not allow")
+	}
+	if ls, ok := worker.Agent.Tools.Use("ls"); ok {
+		if _, err := ls.Execute(context.Background(), nil); !errors.Is(err, tool.ErrToolDenied) {
+			t.Fatalf("worker ls: expected denial, got %v", err)
+		}
+	}
+}